@@ -2,6 +2,8 @@ package assets
 
 import (
 	"embed"
+	"errors"
+	"fmt"
 	"io/fs"
 	"path/filepath"
 )
@@ -18,6 +20,20 @@ func ReadEnvExample(app string) ([]byte, error) {
 	return fs.ReadFile(dockerFS, filepath.Join("docker", app, ".env.example"))
 }
 
+// ReadManifest lee assets/docker/<app>/app.toml (manifiesto del catálogo).
+// Solo se admite TOML: si la app trae app.yaml se devuelve un error que lo dice.
+func ReadManifest(app string) ([]byte, error) {
+	data, err := fs.ReadFile(dockerFS, filepath.Join("docker", app, "app.toml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		for _, alt := range []string{"app.yaml", "app.yml"} {
+			if _, statErr := fs.Stat(dockerFS, filepath.Join("docker", app, alt)); statErr == nil {
+				return nil, fmt.Errorf("%s no está soportado: el manifiesto debe ser app.toml", alt)
+			}
+		}
+	}
+	return data, err
+}
+
 // ListApps devuelve todas las apps que tienen plantilla
 func ListApps() ([]string, error) {
 	entries, err := fs.ReadDir(dockerFS, "docker")
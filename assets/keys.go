@@ -0,0 +1,12 @@
+package assets
+
+import _ "embed"
+
+//go:generate sh -c "curl -fsSL https://www.hashicorp.com/.well-known/pgp-key.txt -o keys/hashicorp.asc"
+
+// HashiCorpKey es la clave pública (armada) que firma releases.hashicorp.com.
+// Va embebida para no depender de descargarla en cada host; la huella se fija
+// en infra.HashiCorpSigner y gpg la comprueba al verificar.
+//
+//go:embed keys/hashicorp.asc
+var HashiCorpKey []byte
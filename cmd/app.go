@@ -4,6 +4,9 @@ import (
 	"autohost-cli/internal/helpers/app"
 	"autohost-cli/utils"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
@@ -24,13 +27,15 @@ var appInstallCmd = &cobra.Command{
 			return
 		}
 
-		fmt.Printf("✅ %s instalado correctamente. Revisa ~/.autohost/docker/compose/%s.yml\n", appName, appName)
+		fmt.Printf("✅ %s instalado correctamente. Revisa ~/.autohost/apps/%s/\n", appName, appName)
 
 		if utils.Confirm(fmt.Sprintf("¿Deseas levantar %s ahora con Docker? [y/N]: ", appName)) {
 			if err := app.StartApp(appName); err != nil {
 				fmt.Printf("❌ Error al iniciar %s: %v\n", appName, err)
+			} else if m, err := app.LoadManifest(appName); err == nil {
+				fmt.Printf("🚀 %s está corriendo en http://localhost:%d\n", m.DisplayName, m.DefaultPort)
 			} else {
-				fmt.Printf("🚀 %s está corriendo en http://localhost:8080\n", appName)
+				fmt.Printf("🚀 %s está corriendo.\n", appName)
 			}
 		}
 	}),
@@ -55,8 +60,10 @@ var appStopCmd = &cobra.Command{
 	Short: "Detiene una aplicación",
 	Args:  cobra.ExactArgs(1),
 	Run: utils.WithAppName(func(appName string) {
-		err := app.StopApp(appName)
-
+		inst, err := app.OpenAppInstance(appName)
+		if err == nil {
+			err = inst.Stop()
+		}
 		if err != nil {
 			fmt.Printf("❌ No se pudo detener %s: %v\n", appName, err)
 		} else {
@@ -65,14 +72,58 @@ var appStopCmd = &cobra.Command{
 	}),
 }
 
+var appRestartCmd = &cobra.Command{
+	Use:   "restart [nombre]",
+	Short: "Reinicia los contenedores de una aplicación",
+	Args:  cobra.ExactArgs(1),
+	Run: utils.WithAppName(func(appName string) {
+		inst, err := app.OpenAppInstance(appName)
+		if err == nil {
+			err = inst.Restart()
+		}
+		if err != nil {
+			fmt.Printf("❌ No se pudo reiniciar %s: %v\n", appName, err)
+		} else {
+			fmt.Printf("🔁 %s reiniciada.\n", appName)
+		}
+	}),
+}
+
+var appPullCmd = &cobra.Command{
+	Use:   "pull [nombre]",
+	Short: "Descarga las imágenes más recientes de una aplicación",
+	Args:  cobra.ExactArgs(1),
+	Run: utils.WithAppName(func(appName string) {
+		inst, err := app.OpenAppInstance(appName)
+		if err == nil {
+			err = inst.Pull()
+		}
+		if err != nil {
+			fmt.Printf("❌ No se pudieron descargar las imágenes de %s: %v\n", appName, err)
+		} else {
+			fmt.Printf("⬇️  Imágenes de %s actualizadas. Ejecuta `autohost app start %s` para aplicarlas.\n", appName, appName)
+		}
+	}),
+}
+
+var appRemoveVolumes bool
+
 var appRemoveCmd = &cobra.Command{
 	Use:   "remove [nombre]",
 	Short: "Elimina una aplicación",
 	Args:  cobra.ExactArgs(1),
 	Run: utils.WithAppName(func(appName string) {
-		if utils.Confirm(fmt.Sprintf("¿Estás seguro que quieres eliminar %s? [y/N]: ", appName)) {
-			err := app.RemoveApp(appName)
-			if err != nil {
+		inst, err := app.OpenAppInstance(appName)
+		if err != nil {
+			fmt.Printf("❌ No se pudo eliminar %s: %v\n", appName, err)
+			return
+		}
+		prompt := fmt.Sprintf("¿Estás seguro que quieres eliminar %s? [y/N]: ", appName)
+		if appRemoveVolumes {
+			prompt = fmt.Sprintf("¿Eliminar %s y TODOS sus volúmenes de datos? [y/N]: ", appName)
+		}
+		if utils.Confirm(prompt) {
+			if err := inst.Down(appRemoveVolumes); err != nil {
 				fmt.Printf("❌ No se pudo eliminar %s: %v\n", appName, err)
 			} else {
 				fmt.Printf("🧹 %s eliminada correctamente.\n", appName)
@@ -86,20 +137,195 @@ var appStatusCmd = &cobra.Command{
 	Short: "Muestra el estado de una aplicación",
 	Args:  cobra.ExactArgs(1),
 	Run: utils.WithAppName(func(appName string) {
-		status, err := app.GetAppStatus(appName)
+		inst, err := app.OpenAppInstance(appName)
 		if err != nil {
 			fmt.Printf("❌ No se pudo obtener el estado de %s: %v\n", appName, err)
-		} else {
-			fmt.Printf("📊 Estado de %s: %s\n", appName, status)
+			return
+		}
+		containers, err := inst.Ps()
+		if err != nil {
+			fmt.Printf("❌ No se pudo obtener el estado de %s: %v\n", appName, err)
+			return
+		}
+		fmt.Printf("📊 Estado de %s: %s\n", appName, app.SummarizeState(containers))
+		for _, c := range containers {
+			line := fmt.Sprintf("   • %s (%s): %s", c.Name, c.Service, c.State)
+			if c.Health != "" {
+				line += " [" + c.Health + "]"
+			}
+			if len(c.Ports) > 0 {
+				line += " " + strings.Join(c.Ports, ", ")
+			}
+			fmt.Println(line)
 		}
 	}),
 }
 
+var appLogsOpts app.LogsOptions
+
+var appLogsCmd = &cobra.Command{
+	Use:   "logs [nombre]",
+	Short: "Muestra los logs de una aplicación",
+	Args:  cobra.ExactArgs(1),
+	Run: utils.WithAppName(func(appName string) {
+		inst, err := app.OpenAppInstance(appName)
+		if err == nil {
+			err = inst.Logs(os.Stdout, appLogsOpts)
+		}
+		if err != nil {
+			fmt.Printf("❌ No se pudieron leer los logs de %s: %v\n", appName, err)
+		}
+	}),
+}
+
+var appCatalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Lista las aplicaciones disponibles en el catálogo",
+	Run: func(cmd *cobra.Command, args []string) {
+		manifests, err := app.Catalog()
+		if err != nil {
+			fmt.Println("⚠️  Algunas entradas del catálogo son inválidas:", err)
+		}
+		if len(manifests) == 0 {
+			fmt.Println("ℹ️  El catálogo está vacío.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NOMBRE\tAPP\tVERSIÓN\tPUERTO\tDESCRIPCIÓN")
+		for _, m := range manifests {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", m.Name, m.DisplayName, m.Version, m.DefaultPort, m.Description)
+		}
+		w.Flush()
+	},
+}
+
+var appInfoCmd = &cobra.Command{
+	Use:   "info [nombre]",
+	Short: "Muestra el manifiesto de una aplicación del catálogo",
+	Args:  cobra.ExactArgs(1),
+	Run: utils.WithAppName(func(appName string) {
+		m, err := app.LoadManifest(appName)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+
+		fmt.Printf("📦 %s (%s)\n", m.DisplayName, m.Name)
+		fmt.Println("   ", m.Description)
+		fmt.Println("🏷️  Versión:", m.Version)
+		if m.Homepage != "" {
+			fmt.Println("🔗 Web:", m.Homepage)
+		}
+		fmt.Println("🔌 Puerto por defecto:", m.DefaultPort)
+
+		fmt.Println("\n🧰 Requisitos")
+		if m.Require.MinRAMMB > 0 {
+			fmt.Printf("   RAM mínima: %d MB\n", m.Require.MinRAMMB)
+		}
+		if len(m.Require.Architectures) > 0 {
+			fmt.Println("   Arquitecturas:", strings.Join(m.Require.Architectures, ", "))
+		}
+		if len(m.Require.Networks) > 0 {
+			fmt.Println("   Redes Docker:", strings.Join(m.Require.Networks, ", "))
+		}
+
+		if len(m.Variables) == 0 {
+			return
+		}
+		fmt.Println("\n🔧 Variables")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "   NOMBRE\tTIPO\tDEFAULT\tGENERADOR\tDESCRIPCIÓN")
+		for _, v := range m.Variables {
+			def := v.Default
+			if v.Secret && def != "" {
+				def = "••••"
+			}
+			name := v.Name
+			if v.Required {
+				name += " *"
+			}
+			fmt.Fprintf(w, "   %s\t%s\t%s\t%s\t%s\n", name, v.Type, dash(def), dash(v.Generator), v.Description)
+		}
+		w.Flush()
+		fmt.Println("   (* requerida)")
+	}),
+}
+
+var appListOutput string
+
+var appListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lista las apps instaladas y del catálogo con su estado en vivo",
+	Example: `  autohost app list
+  autohost app list --output json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(appListOutput); err != nil {
+			return err
+		}
+		apps, err := app.ListApps()
+		if err != nil {
+			return err
+		}
+		if appListOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, appListOutput, apps)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "APP\tESTADO\tCONTENEDOR\tESTADO CONT.\tPUERTOS\tHOSTS\tIMAGEN")
+		for _, a := range apps {
+			hosts := dash(strings.Join(a.Hostnames, ","))
+			if len(a.Containers) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t%s\t-\n", a.Name, a.State, hosts)
+				continue
+			}
+			for i, c := range a.Containers {
+				name, state := a.Name, a.State
+				if i > 0 {
+					name, state, hosts = "", "", ""
+				}
+				cState := c.State
+				if c.Health != "" {
+					cState += " (" + c.Health + ")"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					name, state, c.Name, cState, dash(strings.Join(c.Ports, ",")), hosts, c.Image)
+			}
+		}
+		w.Flush()
+
+		for _, a := range apps {
+			if a.Error != "" {
+				fmt.Printf("⚠️  %s: %s\n", a.Name, a.Error)
+			}
+		}
+		return nil
+	},
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func init() {
+	appCmd.AddCommand(appCatalogCmd)
+	appCmd.AddCommand(appInfoCmd)
+	appCmd.AddCommand(appListCmd)
+	appListCmd.Flags().StringVarP(&appListOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
 	appCmd.AddCommand(appInstallCmd)
 	appCmd.AddCommand(appStartCmd)
 	appCmd.AddCommand(appStopCmd)
+	appCmd.AddCommand(appRestartCmd)
+	appCmd.AddCommand(appPullCmd)
 	appCmd.AddCommand(appRemoveCmd)
 	appCmd.AddCommand(appStatusCmd)
+	appCmd.AddCommand(appLogsCmd)
+	appRemoveCmd.Flags().BoolVar(&appRemoveVolumes, "volumes", false, "Eliminar también los volúmenes de datos")
+	appLogsCmd.Flags().BoolVarP(&appLogsOpts.Follow, "follow", "f", false, "Seguir los logs en vivo")
+	appLogsCmd.Flags().IntVar(&appLogsOpts.Tail, "tail", 100, "Número de líneas finales a mostrar (0 = todas)")
+	appLogsCmd.Flags().StringVar(&appLogsOpts.Service, "service", "", "Mostrar solo un servicio del compose")
 	rootCmd.AddCommand(appCmd)
 }
@@ -0,0 +1,467 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"autohost-cli/internal/helpers/app"
+	"autohost-cli/internal/helpers/backup"
+	"autohost-cli/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Respalda los datos de las apps (volúmenes, bind mounts, .env y compose)",
+}
+
+var (
+	backupAll     bool
+	backupQuiesce string
+	backupRepo    string
+)
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create [app]",
+	Short: "Crea un snapshot comprimido y verificable de una app (o de todas)",
+	Example: `  autohost backup create bookstack
+  autohost backup create nextcloud --quiesce pause
+  autohost backup create nextcloud --repo nas
+  autohost backup create --all`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if backupAll && len(args) > 0 {
+			return errors.New("usa una app o --all, no ambos")
+		}
+		if !backupAll && len(args) != 1 {
+			return errors.New("indica la app a respaldar o usa --all")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := args
+		if backupAll {
+			apps, err := app.InstalledApps()
+			if err != nil {
+				return err
+			}
+			if len(apps) == 0 {
+				fmt.Println("ℹ️  No hay apps instaladas.")
+				return nil
+			}
+			for _, a := range apps {
+				names = append(names, a.Name)
+			}
+		}
+
+		var failed []string
+		for _, name := range names {
+			fmt.Printf("💾 Respaldando %s...\n", name)
+			m, err := backup.Create(name, backup.CreateOptions{Quiesce: backupQuiesce, Repository: backupRepo})
+			if err != nil {
+				fmt.Printf("❌ %s: %v\n", name, err)
+				failed = append(failed, name)
+				continue
+			}
+			for _, s := range m.Skipped {
+				fmt.Printf("⚠️  Omitido %s: %s\n", s.Source, s.Reason)
+			}
+			switch {
+			case m.Archive != nil:
+				fmt.Printf("✅ Snapshot %s de %s: %s (%s, %d elementos)\n", m.ID, name, m.ArchivePath(), humanSize(m.Size()), len(m.Entries))
+			case m.Repository != nil:
+				fmt.Printf("✅ Snapshot %s de %s en el repositorio %s (%s, %d elementos; subidos %s nuevos)\n",
+					m.ID, name, m.Repository.Name, humanSize(m.Size()), len(m.Entries), humanSize(m.Repository.Added))
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("falló el backup de: %s", strings.Join(failed, ", "))
+		}
+		return nil
+	},
+}
+
+var backupListOutput string
+
+var backupListCmd = &cobra.Command{
+	Use:     "list [app]",
+	Aliases: []string{"ls"},
+	Short:   "Lista los snapshots guardados",
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(backupListOutput); err != nil {
+			return err
+		}
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		snaps, err := backup.List(name)
+		if err != nil {
+			fmt.Println("⚠️  Algunos manifiestos son ilegibles:", err)
+		}
+		if snaps == nil {
+			snaps = []*backup.Manifest{}
+		}
+		if backupListOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, backupListOutput, snaps)
+		}
+		if len(snaps) == 0 {
+			fmt.Println("ℹ️  No hay backups.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "APP\tID\tFECHA\tTAMAÑO\tELEMENTOS\tMODO\tDESTINO")
+		for _, m := range snaps {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+				m.App, m.ID, m.Created.Local().Format("2006-01-02 15:04"), humanSize(m.Size()), len(m.Entries), m.Quiesce, m.Location())
+		}
+		return w.Flush()
+	},
+}
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify [app] [id]",
+	Short: "Comprueba los checksums de los snapshots (todos, de una app o uno)",
+	Example: `  autohost backup verify
+  autohost backup verify bookstack
+  autohost backup verify bookstack 20250101T030000Z`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var snaps []*backup.Manifest
+		if len(args) == 2 {
+			m, err := backup.Find(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			snaps = []*backup.Manifest{m}
+		} else {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			var err error
+			if snaps, err = backup.List(name); err != nil {
+				return err
+			}
+		}
+		if len(snaps) == 0 {
+			fmt.Println("ℹ️  No hay backups.")
+			return nil
+		}
+
+		bad := 0
+		for _, m := range snaps {
+			if err := backup.Verify(m); err != nil {
+				fmt.Printf("❌ %s/%s: %v\n", m.App, m.ID, err)
+				bad++
+				continue
+			}
+			fmt.Printf("✅ %s/%s íntegro (%s)\n", m.App, m.ID, humanSize(m.Size()))
+		}
+		if bad > 0 {
+			return fmt.Errorf("%d de %d snapshots no pasaron la verificación", bad, len(snaps))
+		}
+		return nil
+	},
+}
+
+var backupRetention backup.Retention
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune [app]",
+	Short: "Borra los snapshots que no conserva la política de retención",
+	Example: `  autohost backup prune
+  autohost backup prune nextcloud --keep-daily 3 --keep-weekly 2 --keep-monthly 0
+  autohost backup prune --dry-run`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		res, err := backup.Prune(name, backupRetention)
+		if res != nil {
+			for _, m := range res.Kept {
+				fmt.Printf("📌 Se conserva %s/%s (%s)\n", m.App, m.ID, res.ReasonText(m))
+			}
+			verb, chunkVerb := "Eliminado", "eliminados"
+			if dryRun {
+				verb, chunkVerb = "Se eliminaría", "por eliminar"
+			}
+			for _, m := range res.Removed {
+				fmt.Printf("🗑️  %s %s/%s (%s)\n", verb, m.App, m.ID, humanSize(m.Size()))
+			}
+			for repo, n := range res.Chunks {
+				if n > 0 {
+					fmt.Printf("🧹 Repositorio %s: %d fragmentos sin referencias %s\n", repo, n, chunkVerb)
+				}
+			}
+			if len(res.Removed) == 0 && err == nil {
+				fmt.Println("ℹ️  No hay snapshots para eliminar.")
+			}
+		}
+		return err
+	},
+}
+
+var (
+	backupRestoreOpts backup.RestoreOptions
+	backupRestoreYes  bool
+)
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore [app]",
+	Short: "Restaura una app desde un snapshot (o crea una copia con --to)",
+	Example: `  autohost backup restore bookstack --dry-run
+  autohost backup restore bookstack --snapshot 20250101T030000Z
+  autohost backup restore nextcloud --to nextcloud-prueba`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := backup.PlanRestore(args[0], backupRestoreOpts)
+		if err != nil {
+			return err
+		}
+		m := plan.Snapshot
+		fmt.Printf("♻️  Snapshot %s de %s (%s) → %s\n", m.ID, m.App, m.Created.Local().Format("2006-01-02 15:04"), plan.App)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ELEMENTO\tDESTINO\tACCIÓN\tDETALLE")
+		for _, a := range plan.Actions {
+			action := "crea"
+			if a.Exists {
+				action = "sobrescribe"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.Entry, a.Target, action, dash(a.Detail))
+		}
+		w.Flush()
+		if plan.Running {
+			fmt.Printf("⚠️  %s está en ejecución: se detendrá durante la restauración.\n", plan.App)
+		}
+
+		if dryRun {
+			fmt.Println(utils.DryRunPrefix, "no se modificó nada.")
+			return nil
+		}
+		if !plan.Copy && !backupRestoreYes {
+			if !utils.Confirm(fmt.Sprintf("¿Sobrescribir los datos de %s con el snapshot %s? [y/N]: ", plan.App, m.ID)) {
+				fmt.Println("ℹ️  Restauración cancelada.")
+				return nil
+			}
+		}
+		if err := backup.Restore(plan); err != nil {
+			return err
+		}
+		fmt.Printf("✅ %s restaurada desde %s y en ejecución.\n", plan.App, m.ID)
+		return nil
+	},
+}
+
+var backupRepoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Repositorios cifrados y deduplicados (directorio local, SFTP o S3)",
+	Long: `Un repositorio guarda los snapshots partidos en fragmentos cifrados
+(AES-256-GCM) e identificados por su contenido: los datos que no cambiaron
+entre un backup y el siguiente no se vuelven a subir.
+
+Destinos:
+  /srv/backups/autohost                 directorio local o disco montado
+  sftp:usuario@nas:/srv/backups         SFTP (con las claves de ~/.ssh)
+  s3:https://minio.local:9000/backups   S3 o compatible (AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)`,
+}
+
+var (
+	backupRepoEncryption string
+	backupRepoDefault    bool
+)
+
+var backupRepoInitCmd = &cobra.Command{
+	Use:   "init <nombre> [destino]",
+	Short: "Crea un repositorio (o se conecta a uno existente) y lo registra",
+	Example: `  autohost backup repo init local
+  autohost backup repo init nas sftp:backup@nas.lan:/srv/autohost --default
+  AWS_ACCESS_KEY_ID=... AWS_SECRET_ACCESS_KEY=... autohost backup repo init s3 s3:https://minio.lan:9000/autohost
+  AUTOHOST_BACKUP_PASSPHRASE=... autohost backup repo init nas sftp:backup@nas.lan:/srv/autohost`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := backup.InitOptions{Encryption: backupRepoEncryption, Default: backupRepoDefault}
+		if len(args) == 2 {
+			opts.URL = args[1]
+		}
+		res, err := backup.InitRepository(args[0], opts)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+		cfg := res.Config
+		if res.Created {
+			fmt.Printf("✅ Repositorio %s creado en %s (cifrado: %s).\n", cfg.Name, cfg.URL, cfg.Encryption)
+		} else {
+			fmt.Printf("🔗 Conectado al repositorio existente en %s; %d snapshots registrados.\n", cfg.URL, res.Synced)
+		}
+		if res.Passphrase != "" {
+			fmt.Printf("🔑 Frase generada: %s\n", res.Passphrase)
+		}
+		if res.Secret != "" {
+			fmt.Printf("⚠️  Guarda una copia de %s fuera de este servidor: sin ella no se pueden restaurar los backups.\n", res.Secret)
+		}
+		return nil
+	},
+}
+
+var backupRepoListOutput string
+
+var backupRepoListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lista los repositorios registrados",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(backupRepoListOutput); err != nil {
+			return err
+		}
+		repos, def, err := backup.Repositories()
+		if err != nil {
+			return err
+		}
+		if repos == nil {
+			repos = []*backup.RepoConfig{}
+		}
+		if backupRepoListOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, backupRepoListOutput, map[string]any{"default": def, "repositories": repos})
+		}
+		if len(repos) == 0 {
+			fmt.Println("ℹ️  No hay repositorios; los backups se guardan como .tar.gz locales.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NOMBRE\tDESTINO\tCIFRADO\tPREDETERMINADO")
+		for _, c := range repos {
+			mark := ""
+			if c.Name == def {
+				mark = "✔"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, c.URL, c.Encryption, dash(mark))
+		}
+		return w.Flush()
+	},
+}
+
+var backupRepoDefaultCmd = &cobra.Command{
+	Use:   "default <nombre|local>",
+	Short: "Elige el destino de `backup create` sin --repo (local = .tar.gz)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if name == backup.LocalArchive {
+			name = ""
+		}
+		if err := backup.SetDefaultRepository(name); err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Println("✅ Los backups vuelven a guardarse como .tar.gz locales.")
+		} else {
+			fmt.Printf("✅ %s es el repositorio predeterminado.\n", name)
+		}
+		return nil
+	},
+}
+
+var backupRepoSyncCmd = &cobra.Command{
+	Use:   "sync <nombre>",
+	Short: "Registra en este servidor los snapshots del repositorio que no aparecen en backup list",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := backup.SyncRepository(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ %d snapshots nuevos registrados desde %s.\n", n, args[0])
+		return nil
+	},
+}
+
+var backupRepoRmCmd = &cobra.Command{
+	Use:   "rm <nombre>",
+	Short: "Quita un repositorio del registro (no borra sus datos ni su clave)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := backup.ForgetRepository(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Repositorio %s quitado del registro.\n", args[0])
+		return nil
+	},
+}
+
+var backupRepoUnlockAll bool
+
+var backupRepoUnlockCmd = &cobra.Command{
+	Use:   "unlock <nombre>",
+	Short: "Borra los bloqueos que dejaron backups o prunes interrumpidos",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := backup.RemoveLocks(args[0], backupRepoUnlockAll)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ %d bloqueos borrados de %s.\n", n, args[0])
+		return nil
+	},
+}
+
+// humanSize formatea bytes en unidades binarias (KiB, MiB...).
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	backupCreateCmd.Flags().BoolVar(&backupAll, "all", false, "Respaldar todas las apps instaladas")
+	backupCreateCmd.Flags().StringVar(&backupQuiesce, "quiesce", backup.QuiesceStop, "Cómo congelar la app durante la copia: stop|pause|none")
+	backupCreateCmd.Flags().StringVar(&backupRepo, "repo", "", "Repositorio destino (por defecto el predeterminado; local = .tar.gz)")
+	backupListCmd.Flags().StringVarP(&backupListOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	backupPruneCmd.Flags().IntVar(&backupRetention.Last, "keep-last", 0, "Conservar los N snapshots más recientes")
+	backupPruneCmd.Flags().IntVar(&backupRetention.Daily, "keep-daily", 7, "Conservar el último snapshot de cada uno de los N días más recientes")
+	backupPruneCmd.Flags().IntVar(&backupRetention.Weekly, "keep-weekly", 4, "Conservar el último snapshot de cada una de las N semanas más recientes")
+	backupPruneCmd.Flags().IntVar(&backupRetention.Monthly, "keep-monthly", 6, "Conservar el último snapshot de cada uno de los N meses más recientes")
+
+	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.Snapshot, "snapshot", "", "ID (o prefijo) del snapshot; por defecto el más reciente")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.To, "to", "", "Restaurar como una app nueva con este nombre (copia para pruebas)")
+	backupRestoreCmd.Flags().DurationVar(&backupRestoreOpts.HealthTimeout, "timeout", 2*time.Minute, "Tiempo máximo de espera a que la app responda")
+	backupRestoreCmd.Flags().BoolVarP(&backupRestoreYes, "yes", "y", false, "No pedir confirmación")
+
+	backupRepoInitCmd.Flags().StringVar(&backupRepoEncryption, "encryption", backup.EncryptionPassphrase, "Cifrado: passphrase (frase) o key (clave aleatoria de 256 bits)")
+	backupRepoInitCmd.Flags().BoolVar(&backupRepoDefault, "default", false, "Usarlo como destino predeterminado de backup create")
+	backupRepoListCmd.Flags().StringVarP(&backupRepoListOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	backupRepoUnlockCmd.Flags().BoolVar(&backupRepoUnlockAll, "all", false, "Borrar también los bloqueos vigentes (solo si ningún backup ni prune está en curso)")
+	backupRepoCmd.AddCommand(backupRepoInitCmd)
+	backupRepoCmd.AddCommand(backupRepoListCmd)
+	backupRepoCmd.AddCommand(backupRepoDefaultCmd)
+	backupRepoCmd.AddCommand(backupRepoSyncCmd)
+	backupRepoCmd.AddCommand(backupRepoUnlockCmd)
+	backupRepoCmd.AddCommand(backupRepoRmCmd)
+
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupVerifyCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupRepoCmd)
+	rootCmd.AddCommand(backupCmd)
+}
@@ -3,10 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"text/tabwriter"
 
+	"autohost-cli/internal/helpers/caddy"
 	"autohost-cli/utils"
 
 	"github.com/spf13/cobra"
@@ -26,16 +27,6 @@ var caddyInstallCmd = &cobra.Command{
 			return
 		}
 
-		homeDir, _ := os.UserHomeDir()
-		caddyDir := filepath.Join(homeDir, ".autohost", "caddy")
-		caddyfilePath := filepath.Join(caddyDir, "Caddyfile")
-
-		err := os.MkdirAll(caddyDir, 0755)
-		if err != nil {
-			fmt.Println("❌ No se pudo crear el directorio de configuración de Caddy:", err)
-			return
-		}
-
 		fmt.Println("📦 Instalando Caddy...")
 
 		installScript := `
@@ -46,16 +37,14 @@ var caddyInstallCmd = &cobra.Command{
 		sudo apt install caddy
 	`
 
-		installCmd := exec.Command("bash", "-c", installScript)
-		installCmd.Stdout = os.Stdout
-		installCmd.Stderr = os.Stderr
-
-		if err := installCmd.Run(); err != nil {
+		if err := utils.ExecShell(installScript); err != nil {
 			fmt.Println("❌ Error al instalar Caddy:", err)
 			return
 		}
 
-		// Crear Caddyfile si no existe
+		// El paquete deja /etc/caddy/Caddyfile; si no existe (AUTOHOST_CADDYFILE
+		// o instalación sin paquete) se crea el maestro que Caddy va a leer.
+		caddyfilePath := caddy.MasterCaddyfilePath()
 		if _, err := os.Stat(caddyfilePath); os.IsNotExist(err) {
 			base := `# Archivo de configuración de Caddy para AutoHost
 # Ejemplo:
@@ -63,7 +52,14 @@ var caddyInstallCmd = &cobra.Command{
 #     reverse_proxy 127.0.0.1:32400
 # }
 `
-			os.WriteFile(caddyfilePath, []byte(base), 0644)
+			if err := utils.DefaultRunner().MkdirAll(filepath.Dir(caddyfilePath), 0755); err != nil {
+				fmt.Println("❌ No se pudo crear el directorio de configuración de Caddy:", err)
+				return
+			}
+			utils.DefaultRunner().WriteFile(caddyfilePath, []byte(base), 0644)
+		}
+		if _, err := caddy.EnsureImport(); err != nil {
+			fmt.Println("⚠️ No se pudo agregar el import de sitios al Caddyfile:", err)
 		}
 
 		fmt.Println("✅ Caddy instalado y configurado. Puedes editar tu archivo en:")
@@ -80,44 +76,23 @@ var (
 
 var caddyAddServiceCmd = &cobra.Command{
 	Use:   "add-service",
-	Short: "Agrega un nuevo servicio al archivo Caddyfile",
+	Short: "Agrega un nuevo servicio a Caddy (equivale a `caddy site add`)",
 	Run: func(cmd *cobra.Command, args []string) {
-		homeDir := utils.GetAutohostDir()
-		caddyfilePath := filepath.Join(homeDir, ".autohost", "caddy", "Caddyfile")
-
-		block := fmt.Sprintf(`
-
-%s {
-    reverse_proxy 127.0.0.1:%d
-}
-`, serviceHost, servicePort)
-
-		contentBytes, err := os.ReadFile(caddyfilePath)
-		if err != nil {
-			fmt.Println("❌ No se pudo leer el archivo Caddyfile:", err)
-			return
-		}
-		content := string(contentBytes)
-
-		if strings.Contains(content, serviceHost) {
-			fmt.Printf("⚠️ Ya existe una entrada para %s en el Caddyfile.\n", serviceHost)
-			return
-		}
-
-		file, err := os.OpenFile(caddyfilePath, os.O_APPEND|os.O_WRONLY, 0644)
+		site, changed, res, err := caddy.AddSite(caddy.SiteSpec{
+			Host:     serviceHost,
+			Upstream: strconv.Itoa(servicePort),
+			App:      serviceName,
+		})
 		if err != nil {
-			fmt.Println("❌ No se pudo abrir el archivo Caddyfile:", err)
+			fmt.Println("❌ No se pudo agregar el servicio:", err)
 			return
 		}
-		defer file.Close()
-
-		_, err = file.WriteString(block)
-		if err != nil {
-			fmt.Println("❌ No se pudo escribir en el archivo Caddyfile:", err)
+		if !changed {
+			fmt.Printf("ℹ️  %s ya apunta a %s; sin cambios.\n", site.Host, site.Upstream)
 			return
 		}
-
-		fmt.Printf("✅ Servicio '%s' agregado exitosamente a Caddyfile.\n", serviceName)
+		printApplyResult(res)
+		fmt.Printf("✅ Servicio '%s' agregado exitosamente en %s.\n", serviceName, site.Path)
 	},
 }
 
@@ -125,14 +100,10 @@ var caddyStartCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Inicia Caddy con el archivo de configuración de AutoHost",
 	Run: func(cmd *cobra.Command, args []string) {
-		homeDir, _ := os.UserHomeDir()
-		caddyfilePath := filepath.Join(homeDir, ".autohost", "caddy", "Caddyfile")
+		caddyfilePath := caddy.MasterCaddyfilePath()
 
 		fmt.Println("🚀 Iniciando servidor Caddy...")
-		startCmd := exec.Command("caddy", "run", "--config", caddyfilePath)
-		startCmd.Stdout = os.Stdout
-		startCmd.Stderr = os.Stderr
-		err := startCmd.Run()
+		err := utils.Exec("caddy", "run", "--config", caddyfilePath)
 		if err != nil {
 			fmt.Println("❌ Error al iniciar Caddy:", err)
 		} else {
@@ -141,6 +112,135 @@ var caddyStartCmd = &cobra.Command{
 	},
 }
 
+var caddySiteCmd = &cobra.Command{
+	Use:   "site",
+	Short: "Administra los sitios de Caddy gestionados por AutoHost",
+	Long: `Cada sitio vive en ~/.autohost/caddy/sites/<host>.caddy con un encabezado de
+metadatos, y el Caddyfile maestro los carga con una única línea import.`,
+}
+
+var (
+	siteUpstream string
+	sitePort     int
+	siteApp      string
+	siteOutput   string
+)
+
+var caddySiteAddCmd = &cobra.Command{
+	Use:   "add <host>",
+	Short: "Crea o actualiza un sitio (host → upstream)",
+	Example: `  autohost caddy site add wiki.maza-server --port 6875 --app bookstack
+  autohost caddy site add nas.maza-server --upstream http://192.168.1.10:5000`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		upstream := siteUpstream
+		if upstream == "" {
+			if sitePort <= 0 {
+				return fmt.Errorf("indica --port o --upstream")
+			}
+			upstream = strconv.Itoa(sitePort)
+		}
+		site, changed, res, err := caddy.AddSite(caddy.SiteSpec{Host: args[0], Upstream: upstream, App: siteApp})
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Printf("ℹ️  %s ya apunta a %s; sin cambios.\n", site.Host, site.Upstream)
+			return nil
+		}
+		printApplyResult(res)
+		fmt.Printf("✅ Sitio %s → %s guardado en %s\n", site.Host, site.Upstream, site.Path)
+		return nil
+	},
+}
+
+var caddySiteRemoveCmd = &cobra.Command{
+	Use:     "remove <host>",
+	Aliases: []string{"rm"},
+	Short:   "Elimina un sitio gestionado",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed, res, err := caddy.RemoveSite(args[0])
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("ℹ️  No existe el sitio %s.\n", args[0])
+			return nil
+		}
+		printApplyResult(res)
+		fmt.Printf("🗑️  Sitio %s eliminado.\n", args[0])
+		return nil
+	},
+}
+
+var caddySiteListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lista los sitios gestionados",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(siteOutput); err != nil {
+			return err
+		}
+		sites, err := caddy.ListSites()
+		if err != nil {
+			return err
+		}
+		if siteOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, siteOutput, sites)
+		}
+		if len(sites) == 0 {
+			fmt.Println("ℹ️  No hay sitios gestionados. Crea uno con `autohost caddy site add`.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tUPSTREAM\tAPP\tACTUALIZADO")
+		for _, s := range sites {
+			updated := "-"
+			if !s.Updated.IsZero() {
+				updated = s.Updated.Local().Format("2006-01-02 15:04")
+			}
+			app := dash(s.App)
+			if s.Legacy {
+				app += " (sin metadatos)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Host, dash(s.Upstream), app, updated)
+		}
+		return w.Flush()
+	},
+}
+
+var caddySiteShowCmd = &cobra.Command{
+	Use:   "show <host>",
+	Short: "Muestra el archivo de un sitio",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		site, err := caddy.GetSite(args[0])
+		if err != nil {
+			return err
+		}
+		if site == nil {
+			return fmt.Errorf("no existe el sitio %s", args[0])
+		}
+		b, err := os.ReadFile(site.Path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📄 %s\n\n%s", site.Path, b)
+		return nil
+	},
+}
+
+// printApplyResult informa si la configuración se validó y recargó.
+func printApplyResult(res caddy.ApplyResult) {
+	if res.Skipped != "" {
+		fmt.Println("ℹ️ ", res.Skipped)
+	}
+	if res.Reloaded {
+		fmt.Println("🔁 Configuración validada y Caddy recargado.")
+	}
+}
+
 func init() {
 	// Flags para add-service
 	caddyAddServiceCmd.Flags().StringVar(&serviceName, "name", "", "Nombre del servicio (ej: plex)")
@@ -155,6 +255,13 @@ func init() {
 	caddyCmd.AddCommand(caddyAddServiceCmd)
 	caddyCmd.AddCommand(caddyStartCmd)
 
+	caddySiteAddCmd.Flags().IntVar(&sitePort, "port", 0, "Puerto local del servicio (upstream localhost:<port>)")
+	caddySiteAddCmd.Flags().StringVar(&siteUpstream, "upstream", "", "Upstream explícito (host:puerto o http://host:puerto)")
+	caddySiteAddCmd.Flags().StringVar(&siteApp, "app", "", "(Opcional) app de AutoHost asociada")
+	caddySiteListCmd.Flags().StringVarP(&siteOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	caddySiteCmd.AddCommand(caddySiteAddCmd, caddySiteRemoveCmd, caddySiteListCmd, caddySiteShowCmd)
+	caddyCmd.AddCommand(caddySiteCmd)
+
 	// Agregar grupo caddy al root
 	rootCmd.AddCommand(caddyCmd)
 }
@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
+	"autohost-cli/internal/helpers/cloudflared"
+	"autohost-cli/internal/infra"
 	"autohost-cli/utils"
 
 	"github.com/spf13/cobra"
@@ -26,20 +28,8 @@ var cloudflareInstallCmd = &cobra.Command{
 			return
 		}
 
-		fmt.Println("🌐 Instalando Cloudflare Tunnel (cloudflared)...")
-		installCmd := exec.Command("sh", "-c", `
-			curl -L https://github.com/cloudflare/cloudflared/releases/latest/download/cloudflared-linux-amd64 -o cloudflared &&
-			chmod +x cloudflared &&
-			sudo mv cloudflared /usr/local/bin/
-		`)
-		installCmd.Stdout = os.Stdout
-		installCmd.Stderr = os.Stderr
-
-		err := installCmd.Run()
-		if err != nil {
+		if err := cloudflared.InstallCloudflared(); err != nil {
 			fmt.Println("❌ Error al instalar cloudflared:", err)
-		} else {
-			fmt.Println("✅ Cloudflare Tunnel instalado con éxito.")
 		}
 	},
 }
@@ -55,10 +45,7 @@ var cloudflareLoginCmd = &cobra.Command{
 		}
 
 		fmt.Println("🔐 Ejecutando 'cloudflared tunnel login'...")
-		loginCmd := exec.Command("cloudflared", "tunnel", "login")
-		loginCmd.Stdout = os.Stdout
-		loginCmd.Stderr = os.Stderr
-		err := loginCmd.Run()
+		err := utils.Exec("cloudflared", "tunnel", "login")
 		if err != nil {
 			fmt.Println("❌ Error al iniciar sesión:", err)
 		} else {
@@ -67,66 +54,297 @@ var cloudflareLoginCmd = &cobra.Command{
 	},
 }
 
+var (
+	cfTunnelName    string
+	cfTunnelService string
+)
+
 // Subcomando: crear túnel
 var cloudflareTunnelCmd = &cobra.Command{
 	Use:   "tunnel [dominio]",
-	Short: "Crea un túnel en Cloudflare y lo vincula al dominio",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Crea (o reutiliza) un túnel en Cloudflare y lo vincula al dominio",
+	Example: `  autohost cloudflare tunnel blog.midominio.com
+  autohost cloudflare tunnel blog.midominio.com --name casa --service http://localhost:8080`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if !utils.IsInitialized() {
-			fmt.Println("⚠️ Ejecuta `autohost init` primero.")
-			return
+			return fmt.Errorf("AutoHost no está inicializado; ejecuta `autohost init` primero")
 		}
-
 		domain := args[0]
-		fmt.Printf("⚙️ Creando túnel para %s...\n", domain)
+		res, err := cloudflared.ConfigureCloudflareTunnel(cfTunnelName, domain, cfTunnelService)
+		if err != nil {
+			return err
+		}
+		printTunnelApplyResult(res)
+		fmt.Println("✅ Túnel creado y vinculado al dominio:", domain)
 
-		// Crear el túnel
-		createCmd := exec.Command("cloudflared", "tunnel", "create", "autohost-tunnel")
-		createCmd.Stdout = os.Stdout
-		createCmd.Stderr = os.Stderr
-		err := createCmd.Run()
+		cfg := utils.Config{
+			Tunnel: "cloudflare",
+			Domain: domain,
+		}
+		if err := utils.SaveConfig(cfg); err != nil {
+			fmt.Println("⚠️ Error al guardar config:", err)
+		}
+		return nil
+	},
+}
+
+var cloudflareRouteCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Administra las reglas de ingress del túnel (hostname → servicio)",
+	Long: `Las reglas se guardan en ~/.autohost/cloudflare/tunnel-config.json y se genera
+config.yml en el mismo directorio. Antes de aplicar un cambio se valida con
+` + "`cloudflared tunnel ingress validate`" + `; si el servicio cloudflared está activo se reinicia.`,
+}
+
+var (
+	routePath             string
+	routeNoTLSVerify      bool
+	routeHTTPHostHeader   string
+	routeOriginServerName string
+	routeConnectTimeout   string
+	routeNoDNS            bool
+	routeOutput           string
+)
+
+var cloudflareRouteAddCmd = &cobra.Command{
+	Use:   "add <hostname> <servicio>",
+	Short: "Agrega o actualiza una regla de ingress",
+	Example: `  autohost cloudflare route add wiki.midominio.com 6875
+  autohost cloudflare route add app.midominio.com http://localhost:3000 --path '^/api'
+  autohost cloudflare route add nas.midominio.com https://192.168.1.10:5001 --no-tls-verify`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rule := cloudflared.IngressRule{
+			Hostname: args[0],
+			Path:     routePath,
+			Service:  args[1],
+			OriginRequest: &cloudflared.OriginRequest{
+				NoTLSVerify:      routeNoTLSVerify,
+				HTTPHostHeader:   routeHTTPHostHeader,
+				OriginServerName: routeOriginServerName,
+				ConnectTimeout:   routeConnectTimeout,
+			},
+		}
+		changed, res, err := cloudflared.AddRoute(rule, !routeNoDNS)
 		if err != nil {
-			fmt.Println("❌ Error al crear túnel:", err)
-			return
+			return err
+		}
+		if !changed {
+			fmt.Printf("ℹ️  La regla de %s ya existe; sin cambios.\n", args[0])
+			return nil
+		}
+		printTunnelApplyResult(res)
+		fmt.Printf("✅ Regla %s → %s guardada en %s\n", args[0], args[1], cloudflared.ConfigPath())
+		return nil
+	},
+}
+
+var cloudflareRouteRemoveCmd = &cobra.Command{
+	Use:     "remove <hostname>",
+	Aliases: []string{"rm"},
+	Short:   "Elimina una regla de ingress",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed, res, err := cloudflared.RemoveRoute(args[0], routePath)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("ℹ️  No existe una regla para %s.\n", args[0])
+			return nil
+		}
+		printTunnelApplyResult(res)
+		fmt.Printf("🗑️  Regla %s eliminada (el registro DNS sigue en Cloudflare).\n", args[0])
+		return nil
+	},
+}
+
+var cloudflareRouteListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lista las reglas de ingress en el orden en que se evalúan",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(routeOutput); err != nil {
+			return err
+		}
+		c, err := cloudflared.LoadConfig()
+		if err != nil {
+			return err
+		}
+		if routeOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, routeOutput, c)
+		}
+		if c.TunnelID == "" {
+			fmt.Println("ℹ️  No hay túnel configurado. Créalo con `autohost cloudflare tunnel <dominio>`.")
+			return nil
 		}
+		fmt.Printf("🚇 Túnel %s (%s)\n\n", c.Tunnel, c.TunnelID)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tHOSTNAME\tPATH\tSERVICIO\tOPCIONES")
+		for i, r := range c.Ingress {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, r.Hostname, dash(r.Path), r.Service, dash(originOptions(r.OriginRequest)))
+		}
+		fmt.Fprintf(w, "%d\t*\t-\thttp_status:404\t-\n", len(c.Ingress)+1)
+		return w.Flush()
+	},
+}
 
-		// Mover archivo del túnel
-		tunnelFile := filepath.Join(os.Getenv("HOME"), ".cloudflared", "autohost-tunnel.json")
-		target := filepath.Join(utils.GetAutohostDir(), "cloudflare", "tunnel.json")
+// originOptions resume las opciones de origen de una regla para la tabla.
+func originOptions(o *cloudflared.OriginRequest) string {
+	if o == nil {
+		return ""
+	}
+	var opts []string
+	if o.NoTLSVerify {
+		opts = append(opts, "noTLSVerify")
+	}
+	if o.HTTPHostHeader != "" {
+		opts = append(opts, "httpHostHeader="+o.HTTPHostHeader)
+	}
+	if o.OriginServerName != "" {
+		opts = append(opts, "originServerName="+o.OriginServerName)
+	}
+	if o.ConnectTimeout != "" {
+		opts = append(opts, "connectTimeout="+o.ConnectTimeout)
+	}
+	return strings.Join(opts, ",")
+}
 
-		if err := utils.CopyFile(tunnelFile, target); err != nil {
-			fmt.Println("⚠️ No se pudo mover el archivo del túnel:", err)
+var cloudflareServiceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Ejecuta el conector del túnel como servicio (systemd o Docker)",
+	Long: `El conector usa el config.yml y las credenciales de ~/.autohost/cloudflare.
+Con systemd se instala la unidad cloudflared.service; con Docker se crea el
+contenedor ` + infra.CloudflaredContainer + ` en la red ` + infra.AutohostNetwork + ` (localhost se traduce a
+host.docker.internal). En ambos casos se reinicia solo si falla.`,
+}
+
+var (
+	serviceRuntime    string
+	serviceStatusOut  string
+	serviceLogsTail   int
+	serviceLogsFollow bool
+)
+
+var cloudflareServiceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Instala e inicia el conector del túnel",
+	Example: `  autohost cloudflare service install
+  autohost cloudflare service install --runtime docker`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runtime := serviceRuntime
+		if runtime == "" {
+			runtime = cloudflared.DefaultRuntime()
+		}
+		fmt.Printf("⚙️ Instalando el conector del túnel (%s)...\n", runtime)
+		if err := cloudflared.InstallService(runtime); err != nil {
+			return err
 		}
+		fmt.Printf("✅ Conector instalado (%s). Consulta su estado con `autohost cloudflare service status`.\n", runtime)
+		return nil
+	},
+}
 
-		// Enlazar túnel al dominio
-		routeCmd := exec.Command("cloudflared", "tunnel", "route", "dns", "autohost-tunnel", domain)
-		routeCmd.Stdout = os.Stdout
-		routeCmd.Stderr = os.Stderr
-		err = routeCmd.Run()
+var cloudflareServiceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Detiene y elimina el conector (el túnel y sus reglas se conservan)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed, err := cloudflared.UninstallService()
 		if err != nil {
-			fmt.Println("❌ Error al configurar ruta DNS:", err)
-		} else {
-			fmt.Println("✅ Túnel creado y vinculado al dominio:", domain)
+			return err
+		}
+		if !removed {
+			fmt.Println("ℹ️  El conector no está instalado.")
+			return nil
 		}
+		fmt.Println("🗑️  Conector eliminado.")
+		return nil
+	},
+}
 
-		// Guardar config
-		cfg := utils.Config{
-			Tunnel: "cloudflare",
-			Domain: domain,
+var cloudflareServiceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Muestra el estado del conector y sus conexiones con Cloudflare",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(serviceStatusOut); err != nil {
+			return err
 		}
-		if err := utils.SaveConfig(cfg); err != nil {
-			fmt.Println("⚠️ Error al guardar config:", err)
+		st, err := cloudflared.GetServiceStatus()
+		if err != nil {
+			return err
+		}
+		if serviceStatusOut != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, serviceStatusOut, st)
 		}
+		if !st.Installed {
+			fmt.Println("❌ Conector no instalado. Instálalo con `autohost cloudflare service install`.")
+			return nil
+		}
+		icon := "✅"
+		switch {
+		case !st.Running:
+			icon = "❌"
+		case !st.Ready:
+			icon = "⚠️ "
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s Conector\t%s (%s)\n", icon, st.State, st.Runtime)
+		fmt.Fprintf(w, "🚇 Túnel\t%s (%s)\n", dash(st.Tunnel), dash(st.TunnelID))
+		fmt.Fprintf(w, "🕒 Desde\t%s\n", dash(st.Since))
+		fmt.Fprintf(w, "🔁 Reinicios\t%d\n", st.Restarts)
+		if st.Running {
+			ready := fmt.Sprintf("%d conexiones con Cloudflare", st.Connections)
+			if st.Detail != "" {
+				ready += " (" + st.Detail + ")"
+			}
+			fmt.Fprintf(w, "📡 Listo\t%s\n", ready)
+		}
+		return w.Flush()
+	},
+}
 
-		// utils.SaveStatus("cloudflare_tunnel", true)
-		// utils.SaveStatus("cloudflare_domain", domain)
+var cloudflareServiceLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Muestra los logs del conector",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cloudflared.ServiceLogs(os.Stdout, serviceLogsTail, serviceLogsFollow)
 	},
 }
 
+// printTunnelApplyResult informa si config.yml se validó y el túnel se reinició.
+func printTunnelApplyResult(res cloudflared.ApplyResult) {
+	if res.Skipped != "" {
+		fmt.Println("ℹ️ ", res.Skipped)
+	}
+	if res.Restarted {
+		fmt.Println("🔁 Configuración validada y túnel reiniciado.")
+	}
+}
+
 func init() {
 	cloudflareCmd.AddCommand(cloudflareInstallCmd)
 	cloudflareCmd.AddCommand(cloudflareLoginCmd)
 	cloudflareCmd.AddCommand(cloudflareTunnelCmd)
+	cloudflareTunnelCmd.Flags().StringVar(&cfTunnelName, "name", "", "Nombre del túnel (por defecto el configurado o "+cloudflared.DefaultTunnelName+")")
+	cloudflareTunnelCmd.Flags().StringVar(&cfTunnelService, "service", "http://localhost:80", "Servicio al que apunta el dominio")
+
+	cloudflareRouteAddCmd.Flags().StringVar(&routePath, "path", "", "Expresión regular del path (ej: ^/api)")
+	cloudflareRouteAddCmd.Flags().BoolVar(&routeNoTLSVerify, "no-tls-verify", false, "No verificar el certificado TLS del origen")
+	cloudflareRouteAddCmd.Flags().StringVar(&routeHTTPHostHeader, "http-host-header", "", "Cabecera Host que se envía al origen")
+	cloudflareRouteAddCmd.Flags().StringVar(&routeOriginServerName, "origin-server-name", "", "Nombre esperado en el certificado del origen")
+	cloudflareRouteAddCmd.Flags().StringVar(&routeConnectTimeout, "connect-timeout", "", "Timeout de conexión al origen (ej: 30s)")
+	cloudflareRouteAddCmd.Flags().BoolVar(&routeNoDNS, "no-dns", false, "No crear el registro DNS en Cloudflare")
+	cloudflareRouteRemoveCmd.Flags().StringVar(&routePath, "path", "", "Path de la regla a eliminar")
+	cloudflareRouteListCmd.Flags().StringVarP(&routeOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	cloudflareRouteCmd.AddCommand(cloudflareRouteAddCmd, cloudflareRouteRemoveCmd, cloudflareRouteListCmd)
+	cloudflareCmd.AddCommand(cloudflareRouteCmd)
+
+	cloudflareServiceInstallCmd.Flags().StringVar(&serviceRuntime, "runtime", "", "Dónde correr el conector: systemd|docker (por defecto systemd si está disponible)")
+	cloudflareServiceStatusCmd.Flags().StringVarP(&serviceStatusOut, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	cloudflareServiceLogsCmd.Flags().IntVar(&serviceLogsTail, "tail", 100, "Número de líneas finales a mostrar (0 = todas)")
+	cloudflareServiceLogsCmd.Flags().BoolVarP(&serviceLogsFollow, "follow", "f", false, "Seguir los logs en vivo")
+	cloudflareServiceCmd.AddCommand(cloudflareServiceInstallCmd, cloudflareServiceUninstallCmd, cloudflareServiceStatusCmd, cloudflareServiceLogsCmd)
+	cloudflareCmd.AddCommand(cloudflareServiceCmd)
 	rootCmd.AddCommand(cloudflareCmd)
 }
@@ -0,0 +1,191 @@
+// cmd/dns.go
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"autohost-cli/internal/helpers/tailscale"
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dnsOutput     string
+	dnsRecordType string
+	dnsRecordTTL  int
+)
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Administra las zonas y registros del CoreDNS de AutoHost",
+}
+
+var dnsZonesCmd = &cobra.Command{
+	Use:   "zones",
+	Short: "Lista las zonas servidas por CoreDNS",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(dnsOutput); err != nil {
+			return err
+		}
+		zones, err := infra.ListDNSZones()
+		if err != nil {
+			return err
+		}
+		if dnsOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, dnsOutput, zones)
+		}
+		if len(zones) == 0 {
+			fmt.Println("ℹ️  No hay zonas. Crea una con `autohost dns add <host.zona> <ip>`.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ZONA\tBIND\tREGISTROS")
+		for _, z := range zones {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", z.Name, dash(strings.Join(z.Bind, ",")), z.Records)
+		}
+		return w.Flush()
+	},
+}
+
+var dnsRecordsCmd = &cobra.Command{
+	Use:   "records [zona]",
+	Short: "Lista los registros de una zona (o de todas)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(dnsOutput); err != nil {
+			return err
+		}
+		zone := ""
+		if len(args) == 1 {
+			zone = args[0]
+		}
+		records, err := infra.ListDNSRecords(zone)
+		if err != nil {
+			return err
+		}
+		if dnsOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, dnsOutput, records)
+		}
+		if len(records) == 0 {
+			fmt.Println("ℹ️  No hay registros.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NOMBRE\tTIPO\tVALOR\tTTL\tZONA")
+		for _, r := range records {
+			ttl := "-"
+			if r.TTL > 0 {
+				ttl = fmt.Sprint(r.TTL)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Type, r.Value, ttl, r.Zone)
+		}
+		return w.Flush()
+	},
+}
+
+var dnsAddCmd = &cobra.Command{
+	Use:   "add <fqdn> <valor>",
+	Short: "Agrega o actualiza un registro (crea la zona si no existe)",
+	Long: `Agrega o actualiza un registro en la zona gestionada que contiene el fqdn.
+
+Tipos soportados: A, AAAA, CNAME, TXT y SRV. Si el valor es una IP el tipo se
+deduce (A o AAAA); para el resto usa --type. El fqdn admite comodín en la
+primera etiqueta (*.apps.zona). Los valores SRV son "prioridad peso puerto destino".`,
+	Example: `  autohost dns add wiki.maza-server 100.112.92.90
+  autohost dns add '*.apps.maza-server' 100.112.92.90
+  autohost dns add docs.maza-server wiki.maza-server --type CNAME
+  autohost dns add maza-server "v=spf1 -all" --type TXT --ttl 3600
+  autohost dns add _http._tcp.maza-server "10 5 80 wiki.maza-server" --type SRV`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		typ := strings.ToUpper(dnsRecordType)
+		if typ == "" {
+			ip := net.ParseIP(args[1])
+			switch {
+			case ip == nil:
+				return fmt.Errorf("%q no es una IP; indica el tipo con --type (CNAME|TXT|SRV)", args[1])
+			case ip.To4() != nil:
+				typ = "A"
+			default:
+				typ = "AAAA"
+			}
+		}
+		// Si hay que crear la zona, CoreDNS escucha en la IP de tailnet de este host.
+		bind, _ := tailscale.TailscaleIP()
+		chs, err := infra.AddDNSRecord(args[0], typ, args[1], dnsRecordTTL, bind)
+		if err != nil {
+			return err
+		}
+		rec := infra.DNSRecord{Name: args[0], Type: typ, Value: args[1]}
+		return reportDNSChanges(chs, fmt.Sprintf("%s ya tiene el registro %s %s", args[0], typ, args[1]), &rec)
+	},
+}
+
+var dnsRmCmd = &cobra.Command{
+	Use:     "rm <fqdn>",
+	Aliases: []string{"remove"},
+	Short:   "Elimina los registros de un nombre (o solo los de --type)",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chs, err := infra.RemoveDNSRecord(args[0], dnsRecordType)
+		if err != nil {
+			return err
+		}
+		return reportDNSChanges(chs, "", nil)
+	},
+}
+
+var dnsShowCorefileCmd = &cobra.Command{
+	Use:   "show-corefile",
+	Short: "Muestra el Corefile actual",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, content, err := infra.ReadCorefile()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📄 %s\n\n%s", path, content)
+		return nil
+	},
+}
+
+// reportDNSChanges imprime el diff, recarga CoreDNS solo si hubo cambios y,
+// si se indica verify, comprueba que CoreDNS ya responde ese registro. Si la
+// recarga falla devuelve error: el cambio está escrito pero no se sirve.
+func reportDNSChanges(chs infra.DNSChanges, unchangedMsg string, verify *infra.DNSRecord) error {
+	if !chs.Changed() {
+		if unchangedMsg != "" {
+			fmt.Printf("ℹ️  %s; sin cambios.\n", unchangedMsg)
+		}
+		return nil
+	}
+	fmt.Print(chs.Diff())
+	if err := infra.ReloadCoreDNSDocker(); err != nil {
+		return fmt.Errorf("zona actualizada, pero no se pudo recargar CoreDNS (el cambio no se sirve hasta recargarlo): %w", err)
+	}
+	if verify != nil {
+		if err := infra.VerifyDNSRecord(*verify); err != nil {
+			return fmt.Errorf("zona actualizada y CoreDNS recargado, pero la verificación falló: %w", err)
+		}
+		fmt.Println("✅ Zona actualizada, CoreDNS recargado y registro verificado.")
+		return nil
+	}
+	fmt.Println("✅ Zona actualizada y CoreDNS recargado.")
+	return nil
+}
+
+func init() {
+	dnsZonesCmd.Flags().StringVarP(&dnsOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	dnsRecordsCmd.Flags().StringVarP(&dnsOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	dnsAddCmd.Flags().StringVarP(&dnsRecordType, "type", "t", "", "Tipo de registro: A|AAAA|CNAME|TXT|SRV (por defecto se deduce de la IP)")
+	dnsAddCmd.Flags().IntVar(&dnsRecordTTL, "ttl", 0, "TTL en segundos (0 = el de la zona)")
+	dnsRmCmd.Flags().StringVarP(&dnsRecordType, "type", "t", "", "Eliminar solo los registros de este tipo")
+
+	dnsCmd.AddCommand(dnsZonesCmd, dnsRecordsCmd, dnsAddCmd, dnsRmCmd, dnsShowCorefileCmd)
+	rootCmd.AddCommand(dnsCmd)
+}
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
-	"text/template"
+	"text/tabwriter"
 
+	"autohost-cli/internal/helpers/caddy"
+	"autohost-cli/internal/helpers/cloudflared"
+	"autohost-cli/internal/helpers/exposure"
+	"autohost-cli/internal/helpers/tailscale"
 	"autohost-cli/internal/infra"
 	"autohost-cli/utils"
 
@@ -18,17 +22,26 @@ import (
 
 var (
 	// selector
-	provider string // tailscale|cloudflare
+	provider string // tailscale|tailscale-serve|cloudflare
 
 	// tailscale
 	subdomain string // ej: app.maza-server  (FQDN dentro de tu zona interna)
 	port      int    // ej: 3000
-	withCaddy bool   // genera vhost y reload
+	withCaddy bool   // genera vhost y reload (por defecto según el proveedor, ver caddyDefault)
 
-	// opcional override para Terraform
-	tailnet string
+	// split DNS: tailnet y backend (api|terraform)
+	tailnet    string
+	dnsBackend string
 
-	// cloudflare (tu flujo existente; opcional)
+	// tailscale serve/funnel
+	servePath  string
+	httpsPort  int
+	withFunnel bool
+
+	// listado
+	exposeOutput string
+
+	// cloudflare
 	domain     string
 	serviceURL string
 	tunnelName string
@@ -36,11 +49,21 @@ var (
 
 var exposeCmd = &cobra.Command{
 	Use:   "expose",
-	Short: "Expone una app por Tailscale (split-DNS + CoreDNS en Docker) o Cloudflare Tunnel",
+	Short: "Expone una app por Tailscale (split-DNS + CoreDNS, o Serve/Funnel) o Cloudflare Tunnel",
+	Example: `  autohost expose --subdomain app.maza-server --port 3000
+  autohost expose --provider tailscale-serve --port 3000 --path /app
+  autohost expose --port 3000 --funnel
+  autohost expose --provider cloudflare --domain app.midominio.com --port 3000`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if provider == "" {
 			provider = "tailscale"
 		}
+		if withFunnel && !cmd.Flags().Changed("provider") {
+			provider = exposure.ProviderTailscaleServe
+		}
+		if withFunnel && provider != exposure.ProviderTailscaleServe {
+			return fmt.Errorf("--funnel solo aplica con --provider %s", exposure.ProviderTailscaleServe)
+		}
 		switch strings.ToLower(provider) {
 		case "tailscale":
 			if err := require(utils.IsInitialized(), "⚠️ AutoHost no está inicializado. Ejecuta `autohost init` primero."); err != nil {
@@ -52,33 +75,75 @@ var exposeCmd = &cobra.Command{
 			if err := require(port > 0, "--port es requerido (ej: 3000)"); err != nil {
 				return err
 			}
-			return exposeWithTailscale(subdomain, port, withCaddy, tailnet)
+			return exposeWithTailscale(subdomain, port, withCaddyFor(cmd, "tailscale"), tailnet)
 
-		// case "cloudflare":
-		// 	// valida y llama a exposeWithCloudflare(...)
-		// 	return exposeWithCloudflare(tunnelName, domain, serviceURL)
+		case exposure.ProviderTailscaleServe:
+			if err := require(port > 0 || serviceURL != "", "--port o --service es requerido (ej: --port 3000)"); err != nil {
+				return err
+			}
+			return exposeWithTailscaleServe(port, serviceURL, servePath, httpsPort, withFunnel)
+
+		case "cloudflare":
+			if err := require(utils.IsInitialized(), "⚠️ AutoHost no está inicializado. Ejecuta `autohost init` primero."); err != nil {
+				return err
+			}
+			if domain == "" {
+				domain = subdomain
+			}
+			if err := require(domain != "", "--domain es requerido (ej: app.midominio.com)"); err != nil {
+				return err
+			}
+			if err := require(port > 0 || serviceURL != "", "--port o --service es requerido (ej: --port 3000)"); err != nil {
+				return err
+			}
+			return exposeWithCloudflare(tunnelName, domain, port, serviceURL, withCaddyFor(cmd, "cloudflare"))
 
 		default:
-			return fmt.Errorf("provider inválido: %s (usa tailscale|cloudflare)", provider)
+			return fmt.Errorf("provider inválido: %s (usa tailscale|tailscale-serve|cloudflare)", provider)
 		}
 	},
 }
 
+// caddyDefault indica si cada proveedor pone Caddy delante cuando no se pasa
+// --with-caddy: en la tailnet Caddy termina TLS; con Cloudflare el túnel ya lo
+// hace y puede ir directo a la app.
+var caddyDefault = map[string]bool{
+	"tailscale":  true,
+	"cloudflare": false,
+}
+
+// withCaddyFor devuelve --with-caddy si se indicó o el valor por defecto del proveedor.
+func withCaddyFor(cmd *cobra.Command, provider string) bool {
+	if cmd.Flags().Changed("with-caddy") {
+		return withCaddy
+	}
+	return caddyDefault[provider]
+}
+
 func init() {
 	rootCmd.AddCommand(exposeCmd)
 
-	exposeCmd.Flags().StringVar(&provider, "provider", "tailscale", "Proveedor: tailscale|cloudflare")
+	exposeCmd.Flags().StringVar(&provider, "provider", "tailscale", "Proveedor: tailscale|tailscale-serve|cloudflare")
 
 	// Tailscale
 	exposeCmd.Flags().StringVar(&subdomain, "subdomain", "", "Subdominio interno FQDN (ej: app.maza-server)")
 	exposeCmd.Flags().IntVar(&port, "port", 0, "Puerto local donde corre la app (ej: 3000)")
-	exposeCmd.Flags().BoolVar(&withCaddy, "with-caddy", true, "Generar vhost en Caddy y recargar")
-	exposeCmd.Flags().StringVar(&tailnet, "tailnet", "", "(Opcional) tailnet para Terraform (si se omite, se usa TAILSCALE_TAILNET o '-')")
-
-	// Cloudflare (compat con tu código actual; opcional)
-	exposeCmd.Flags().StringVar(&domain, "domain", "", "Dominio FQDN (ej: app.midominio.com)")
-	exposeCmd.Flags().StringVar(&serviceURL, "service", "", "Servicio local (ej: http://localhost:3000)")
-	exposeCmd.Flags().StringVar(&tunnelName, "tunnel-name", "", "Nombre del túnel")
+	exposeCmd.Flags().BoolVar(&withCaddy, "with-caddy", false, "Generar vhost en Caddy y recargar (por defecto sí con tailscale y no con cloudflare)")
+	exposeCmd.Flags().StringVar(&tailnet, "tailnet", "", "(Opcional) tailnet para Split DNS (si se omite, se usa TAILSCALE_TAILNET o '-')")
+	exposeCmd.Flags().StringVar(&dnsBackend, "dns-backend", "", "Backend de Split DNS: api|terraform (por defecto AUTOHOST_SPLITDNS_BACKEND o api)")
+
+	// Tailscale Serve/Funnel
+	exposeCmd.Flags().StringVar(&servePath, "path", "", "Path donde montar la app en el nodo (ej: /app; por defecto la raíz)")
+	exposeCmd.Flags().IntVar(&httpsPort, "https-port", 443, "Puerto HTTPS del nodo (Funnel: 443, 8443 o 10000)")
+	exposeCmd.Flags().BoolVar(&withFunnel, "funnel", false, "Publicar también en internet con Tailscale Funnel (implica --provider tailscale-serve)")
+
+	// Cloudflare
+	exposeCmd.Flags().StringVar(&domain, "domain", "", "Dominio público FQDN (ej: app.midominio.com)")
+	exposeCmd.Flags().StringVar(&serviceURL, "service", "", "Servicio local si no es localhost:<port> (ej: http://10.0.0.5:8080)")
+
+	exposeListCmd.Flags().StringVarP(&exposeOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	exposeCmd.AddCommand(exposeListCmd, exposeRemoveCmd)
+	exposeCmd.Flags().StringVar(&tunnelName, "tunnel-name", "", "Nombre del túnel (por defecto el configurado o "+cloudflared.DefaultTunnelName+")")
 }
 
 // --------------------- TAILSCALE FLOW ---------------------
@@ -91,7 +156,7 @@ func exposeWithTailscale(fqdn string, port int, setupCaddy bool, tailnet string)
 	}
 
 	// 1) IP tailscale local (este host será el nameserver)
-	tailIP, err := tailscaleIP()
+	tailIP, err := tailscale.TailscaleIP()
 	if err != nil || tailIP == "" {
 		return fmt.Errorf("no pude obtener IP de tailscale (¿logueado?): %v", err)
 	}
@@ -111,20 +176,21 @@ func exposeWithTailscale(fqdn string, port int, setupCaddy bool, tailnet string)
 	}
 	fmt.Println("🧩 CoreDNS (Docker) listo. Corefile:", corefilePath)
 
-	// 4) Añadir/actualizar FQDN en Corefile y reiniciar contenedor si cambió
+	// 4) Añadir/actualizar FQDN, recargar CoreDNS en caliente si cambió y verificar
 	if err := infra.EnsureDomainAndReload(zone, fqdn, tailIP); err != nil {
 		return fmt.Errorf("CoreDNS update/reload: %w", err)
 	}
-	fmt.Println("✅ CoreDNS actualizado (si fue necesario).")
+	fmt.Printf("✅ CoreDNS responde %s → %s.\n", fqdn, tailIP)
 
-	// 5) Terraform Split-DNS: la zona la resuelve ESTE nameserver (tailIP)
-	fmt.Println("⚙️  Aplicando Split DNS (Terraform) en el tailnet…")
-	if err := infra.ConfigureSplitDNSWithTerraform(infra.SplitDNSOpts{
+	// 5) Split-DNS: la zona la resuelve ESTE nameserver (tailIP)
+	fmt.Printf("⚙️  Aplicando Split DNS (%s) en el tailnet…\n", infra.SplitDNSBackend(dnsBackend))
+	if err := infra.ConfigureSplitDNS(infra.SplitDNSOpts{
 		Tailnet:      tailnet,          // si vacío, tu función usa TAILSCALE_TAILNET o '-'
 		Domain:       zone,             // apex
 		Nameservers:  []string{tailIP}, // este nodo responde la zona
 		SearchPaths:  []string{zone},   // para resolver "host" corto
 		APIKeyEnvVar: "TAILSCALE_API_KEY",
+		Backend:      dnsBackend,
 	}); err != nil {
 		return err
 	}
@@ -138,7 +204,20 @@ func exposeWithTailscale(fqdn string, port int, setupCaddy bool, tailnet string)
 			fmt.Println("✅ Caddy site configurado y recargado.")
 		}
 	} else {
-		fmt.Println("ℹ️  Omitido Caddy (usa --with-caddy para generarlo).")
+		fmt.Printf("ℹ️  Omitido Caddy (--with-caddy=false): la app queda en http://%s:%d.\n", fqdn, port)
+	}
+
+	// 7) Registrar la exposición en el estado de autohost
+	if err := exposure.Save(exposure.Exposure{
+		Host:     fqdn,
+		Provider: exposure.ProviderTailscale,
+		Port:     port,
+		Service:  fmt.Sprintf("http://localhost:%d", port),
+		Caddy:    setupCaddy,
+		Zone:     zone,
+		Address:  tailIP,
+	}); err != nil {
+		fmt.Println("⚠️  No se pudo registrar la exposición:", err)
 	}
 
 	fmt.Printf("\n🎯 Listo. %s resolverá a %s en tu tailnet y proxyeará a localhost:%d (si Caddy está habilitado)\n", fqdn, tailIP, port)
@@ -146,20 +225,109 @@ func exposeWithTailscale(fqdn string, port int, setupCaddy bool, tailnet string)
 	return nil
 }
 
-// --------------------- HELPERS ---------------------
+// --------------------- TAILSCALE SERVE/FUNNEL FLOW ---------------------
+func exposeWithTailscaleServe(port int, service, path string, httpsPort int, funnel bool) error {
+	mode := "Serve (solo tailnet)"
+	if funnel {
+		mode = "Funnel (público)"
+	}
+	fmt.Printf("🔗 Proveedor: Tailscale %s\n", mode)
+
+	if err := checkBinary("tailscale"); err != nil {
+		return err
+	}
+	if service == "" {
+		service = fmt.Sprintf("http://localhost:%d", port)
+	}
+	spec := tailscale.ServeSpec{Target: service, Path: path, HTTPSPort: httpsPort, Funnel: funnel}
+	host, err := tailscale.Serve(spec)
+	if err != nil {
+		return err
+	}
+
+	e := exposure.Exposure{
+		Host:      host,
+		HTTPSPort: httpsPort,
+		Path:      path,
+		Provider:  exposure.ProviderTailscaleServe,
+		Port:      port,
+		Service:   service,
+		Funnel:    funnel,
+	}
+	if err := exposure.Save(e); err != nil {
+		fmt.Println("⚠️  No se pudo registrar la exposición:", err)
+	}
+
+	where := "en tu tailnet"
+	if funnel {
+		where = "en internet"
+	}
+	fmt.Printf("\n🎯 Listo. %s → %s %s (certificado *.ts.net del nodo)\n", e.URL(), service, where)
+	return nil
+}
+
+// --------------------- CLOUDFLARE FLOW ---------------------
+func exposeWithCloudflare(name, fqdn string, port int, service string, setupCaddy bool) error {
+	fmt.Println("🔗 Proveedor: Cloudflare Tunnel")
+	fqdn = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(fqdn), "."))
+
+	// 0) binarios imprescindibles
+	if err := checkBinary("cloudflared"); err != nil {
+		return err
+	}
+
+	// 1) destino final de la app
+	if service == "" {
+		service = fmt.Sprintf("http://localhost:%d", port)
+	}
+	origin := service
+
+	// 2) (opcional) Caddy delante: el túnel entra por HTTP a Caddy (:80) y Caddy
+	//    proxyea a la app. TLS lo termina Cloudflare, así que el sitio es solo HTTP.
+	if setupCaddy {
+		if err := ensureCaddyHTTPSite(fqdn, service); err != nil {
+			fmt.Println("⚠️  No se pudo escribir/reload Caddy; el túnel irá directo a la app:", err)
+			setupCaddy = false
+		} else {
+			origin = "http://localhost:80"
+			fmt.Println("✅ Caddy site (HTTP) configurado y recargado.")
+		}
+	} else {
+		fmt.Println("ℹ️  Sin Caddy: el túnel va directo a la app (usa --with-caddy para ponerlo delante).")
+	}
 
-func tailscaleIP() (string, error) {
-	out, err := exec.Command("tailscale", "ip", "-4").Output()
+	// 3) túnel + regla de ingress + config.yml validado + reinicio + DNS
+	tc, changed, res, err := cloudflared.EnsureRoute(name, cloudflared.IngressRule{Hostname: fqdn, Service: origin})
 	if err != nil {
-		return "", err
+		return fmt.Errorf("Cloudflare Tunnel: %w", err)
 	}
-	ip := strings.TrimSpace(string(out))
-	if i := strings.IndexByte(ip, '\n'); i > -1 {
-		ip = ip[:i]
+	if changed {
+		printTunnelApplyResult(res)
+	} else {
+		fmt.Printf("ℹ️  La regla de %s ya estaba en el túnel; sin cambios.\n", fqdn)
+	}
+	fmt.Printf("✅ Túnel %s: %s → %s (DNS creado en Cloudflare).\n", tc.Tunnel, fqdn, origin)
+
+	// 4) Registrar la exposición en el estado de autohost
+	if err := exposure.Save(exposure.Exposure{
+		Host:     fqdn,
+		Provider: exposure.ProviderCloudflare,
+		Port:     port,
+		Service:  service,
+		Caddy:    setupCaddy,
+		Tunnel:   tc.Tunnel,
+		TunnelID: tc.TunnelID,
+	}); err != nil {
+		fmt.Println("⚠️  No se pudo registrar la exposición:", err)
 	}
-	return ip, nil
+
+	fmt.Printf("\n🎯 Listo. https://%s llega por el túnel %s a %s\n", fqdn, tc.Tunnel, service)
+	fmt.Printf("   config.yml: %s\n", cloudflared.ConfigPath())
+	return nil
 }
 
+// --------------------- HELPERS ---------------------
+
 func splitHostZone(fqdn string) (host, zone string) {
 	s := strings.TrimSpace(fqdn)
 	if s == "" {
@@ -178,54 +346,29 @@ func ensureCaddySite(fqdn string, port int) error {
 	if err := checkBinary("caddy"); err != nil {
 		return err
 	}
-	home, _ := os.UserHomeDir()
-	sitesDir := filepath.Join(home, ".autohost", "caddy", "sites")
-	_ = os.MkdirAll(sitesDir, 0o755)
-
-	sitePath := filepath.Join(sitesDir, safeName(fqdn)+".caddy")
-	siteT := `{{.Host}} {
-	encode zstd gzip
-	reverse_proxy localhost:{{.Port}}
-}
-`
-	if err := renderToFile(siteT, sitePath, map[string]any{
-		"Host": fqdn, "Port": port,
-	}); err != nil {
-		return err
-	}
-
-	// Asegura import en Caddyfile maestro
-	caddyfile := "/etc/caddy/Caddyfile"
-	importLine := "\n# autohost import\nimport " + sitesDir + "/*.caddy\n"
-	_ = ensureLineInFile(caddyfile, importLine)
-
-	// reload caddy
-	_ = exec.Command("systemctl", "reload", "caddy").Run()
-	return nil
-}
-
-func ensureLineInFile(path, line string) error {
-	b, err := os.ReadFile(path)
+	_, _, res, err := caddy.AddSite(caddy.SiteSpec{Host: fqdn, Upstream: strconv.Itoa(port)})
 	if err != nil {
-		return os.WriteFile(path, []byte(line+"\n"), 0o644)
+		return err
 	}
-	if !strings.Contains(string(b), line) {
-		return os.WriteFile(path, append(b, []byte("\n"+line+"\n")...), 0o644)
+	if res.Skipped != "" {
+		fmt.Println("ℹ️ ", res.Skipped)
 	}
 	return nil
 }
 
-func renderToFile(tmpl, outPath string, data any) error {
-	t, err := template.New("tmpl").Parse(tmpl)
-	if err != nil {
+// ensureCaddyHTTPSite crea el sitio solo-HTTP que usa Cloudflare Tunnel como origen.
+func ensureCaddyHTTPSite(fqdn, upstream string) error {
+	if err := checkBinary("caddy"); err != nil {
 		return err
 	}
-	f, err := os.Create(outPath)
+	_, _, res, err := caddy.AddSite(caddy.SiteSpec{Host: fqdn, Upstream: upstream, HTTPOnly: true})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return t.Execute(f, data)
+	if res.Skipped != "" {
+		fmt.Println("ℹ️ ", res.Skipped)
+	}
+	return nil
 }
 
 func checkBinary(bin string) error {
@@ -236,16 +379,114 @@ func checkBinary(bin string) error {
 	return nil
 }
 
-func safeName(s string) string {
-	s = strings.TrimSpace(strings.ToLower(s))
-	s = strings.ReplaceAll(s, "/", "_")
-	s = strings.ReplaceAll(s, ":", "_")
-	return s
-}
-
 func require(ok bool, msg string) error {
 	if !ok {
 		return errors.New(msg)
 	}
 	return nil
 }
+
+// --------------------- LIST / REMOVE ---------------------
+
+var exposeListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lista las apps expuestas con `autohost expose`",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(exposeOutput); err != nil {
+			return err
+		}
+		all, err := exposure.List()
+		if err != nil {
+			return err
+		}
+		if exposeOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, exposeOutput, all)
+		}
+		if len(all) == 0 {
+			fmt.Println("ℹ️  No hay apps expuestas. Usa `autohost expose`.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "URL\tPROVEEDOR\tDESTINO\tCADDY\tACTUALIZADO")
+		for _, e := range all {
+			prov := e.Provider
+			if e.Funnel {
+				prov += " (funnel)"
+			}
+			caddyCol := "no"
+			if e.Caddy {
+				caddyCol = "sí"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.URL(), prov, dash(e.Service), caddyCol, e.Updated.Local().Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	},
+}
+
+var exposeRemoveCmd = &cobra.Command{
+	Use:     "remove <host|url>",
+	Aliases: []string{"rm"},
+	Short:   "Deja de exponer una app y deshace lo que configuró `expose`",
+	Example: `  autohost expose remove app.midominio.com
+  autohost expose remove https://server.tailnet.ts.net/app`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		e, err := exposure.Get(args[0])
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			return fmt.Errorf("no hay ninguna exposición registrada para %s (mira `autohost expose list`)", args[0])
+		}
+		if err := removeExposure(*e); err != nil {
+			return err
+		}
+		if _, err := exposure.Delete(e.Key()); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  %s ya no está expuesta.\n", e.URL())
+		return nil
+	},
+}
+
+// removeExposure deshace la configuración del proveedor y el sitio de Caddy.
+// La zona de CoreDNS, el split DNS y el túnel se conservan: pueden servir a otras apps.
+func removeExposure(e exposure.Exposure) error {
+	switch e.Provider {
+	case exposure.ProviderTailscaleServe:
+		spec := tailscale.ServeSpec{Path: e.Path, HTTPSPort: e.HTTPSPort, Funnel: e.Funnel}
+		if err := tailscale.Unserve(spec); err != nil {
+			return err
+		}
+		fmt.Println("✅ Mapeo de tailscale serve eliminado.")
+	case exposure.ProviderCloudflare:
+		removed, res, err := cloudflared.RemoveRoute(e.Host, "")
+		if err != nil {
+			return err
+		}
+		if removed {
+			printTunnelApplyResult(res)
+			fmt.Println("✅ Regla del túnel eliminada (el registro DNS sigue en Cloudflare).")
+		}
+	case exposure.ProviderTailscale:
+		chs, err := infra.RemoveDNSRecord(e.Host, "")
+		if err != nil {
+			fmt.Println("⚠️  No se pudo quitar el registro de CoreDNS:", err)
+		} else if err := reportDNSChanges(chs, "", nil); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("proveedor desconocido en el estado: %s", e.Provider)
+	}
+	if e.Caddy {
+		removed, res, err := caddy.RemoveSite(e.Host)
+		if err != nil {
+			fmt.Println("⚠️  No se pudo quitar el sitio de Caddy:", err)
+		} else if removed {
+			printApplyResult(res)
+			fmt.Printf("✅ Sitio de Caddy %s eliminado.\n", e.Host)
+		}
+	}
+	return nil
+}
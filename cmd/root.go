@@ -6,9 +6,14 @@ package cmd
 import (
 	"os"
 
+	"autohost-cli/utils"
+
 	"github.com/spf13/cobra"
 )
 
+// dryRun activa el Runner de previsualización para todos los subcomandos.
+var dryRun bool
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "autohost-cli",
@@ -22,6 +27,11 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if dryRun {
+			utils.SetDefaultRunner(utils.NewDryRunner(os.Stdout))
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -39,6 +49,7 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.autohost-cli.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Muestra comandos, escrituras y recargas sin ejecutarlos")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
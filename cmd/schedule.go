@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"autohost-cli/internal/helpers/schedule"
+	"autohost-cli/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Programa comandos de autohost recurrentes (backups, pulls...) con systemd o cron",
+}
+
+var (
+	scheduleCron    string
+	scheduleBackend string
+)
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add <nombre> --cron <horario> -- <comando de autohost>",
+	Short: "Crea una tarea programada que ejecuta un comando de autohost",
+	Long: `Crea una tarea programada que ejecuta un comando de autohost.
+
+El horario es una expresión cron de 5 campos (minuto hora día mes día-semana)
+o @hourly, @daily, @weekly y @monthly. Se instala como timer de systemd si el
+host lo usa y, si no, en el crontab del usuario (--backend para elegir).`,
+	Example: `  autohost schedule add backup-nightly --cron "0 3 * * *" -- backup create --all
+  autohost schedule add backup-prune --cron "30 4 * * 0" -- backup prune --keep-daily 7
+  autohost schedule add pull-weekly --cron @weekly -- app pull bookstack`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return errors.New("indica el nombre de la tarea y, tras --, el comando de autohost")
+		}
+		return validateScheduledCommand(args[1:])
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		j := &schedule.Job{Name: args[0], Schedule: scheduleCron, Args: args[1:], Backend: scheduleBackend}
+		if err := schedule.Add(j); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Tarea %s programada (%s, %s): %s\n", j.Name, j.Schedule, j.Backend, j.CommandLine())
+		return nil
+	},
+}
+
+// validateScheduledCommand comprueba que args sea un comando de autohost
+// ejecutable, para no descubrir una errata en la primera ejecución.
+func validateScheduledCommand(args []string) error {
+	c, rest, err := rootCmd.Find(args)
+	if err != nil || c == rootCmd || !c.Runnable() {
+		return fmt.Errorf("comando de autohost desconocido: %q", strings.Join(args, " "))
+	}
+	for p := c; p != nil; p = p.Parent() {
+		if p == scheduleCmd {
+			return errors.New("una tarea no puede ejecutar `autohost schedule`")
+		}
+	}
+	if err := c.ParseFlags(rest); err != nil {
+		return fmt.Errorf("%s: %w", c.CommandPath(), err)
+	}
+	return nil
+}
+
+var scheduleListOutput string
+
+var scheduleListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lista las tareas programadas y su última ejecución",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(scheduleListOutput); err != nil {
+			return err
+		}
+		jobs, err := schedule.Jobs()
+		if err != nil {
+			return err
+		}
+		type jobView struct {
+			*schedule.Job
+			LastRun *schedule.Run `json:"last_run"`
+		}
+		views := []jobView{}
+		for _, j := range jobs {
+			last, err := schedule.LastRun(j.Name)
+			if err != nil {
+				return err
+			}
+			views = append(views, jobView{Job: j, LastRun: last})
+		}
+		if scheduleListOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, scheduleListOutput, views)
+		}
+		if len(views) == 0 {
+			fmt.Println("ℹ️  No hay tareas programadas.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NOMBRE\tHORARIO\tBACKEND\tCOMANDO\tÚLTIMA\tESTADO\tDURACIÓN")
+		for _, v := range views {
+			last, state, took := "-", "-", "-"
+			if v.LastRun != nil {
+				last = v.LastRun.Start.Local().Format("2006-01-02 15:04")
+				state, took = runState(v.LastRun), v.LastRun.Duration().String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", v.Name, v.Schedule, v.Backend, v.CommandLine(), last, state, took)
+		}
+		return w.Flush()
+	},
+}
+
+var scheduleRmCmd = &cobra.Command{
+	Use:     "rm <nombre>",
+	Aliases: []string{"remove"},
+	Short:   "Elimina una tarea programada, su timer o línea de crontab y su historial",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := schedule.Remove(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Tarea %s eliminada.\n", args[0])
+		return nil
+	},
+}
+
+var scheduleScheduled bool
+
+var scheduleRunNowCmd = &cobra.Command{
+	Use:   "run-now <nombre>",
+	Short: "Ejecuta una tarea ahora y registra el resultado en su historial",
+	Args:  cobra.ExactArgs(1),
+	// Un fallo de la tarea no es un error de uso.
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trigger := schedule.TriggerManual
+		if scheduleScheduled {
+			trigger = schedule.TriggerSchedule
+		}
+		// Lanzada por systemd o cron la salida solo va al log.
+		var out io.Writer
+		if !scheduleScheduled {
+			fmt.Printf("▶️  Ejecutando la tarea %s...\n", args[0])
+			out = os.Stdout
+		}
+		run, err := schedule.RunNow(args[0], trigger, out)
+		if run != nil && !scheduleScheduled {
+			if run.OK() {
+				fmt.Printf("✅ Tarea %s completada en %s (log: %s)\n", run.Job, run.Duration(), run.Log)
+			} else {
+				fmt.Printf("❌ Tarea %s: %s tras %s (log: %s)\n", run.Job, runState(run), run.Duration(), run.Log)
+			}
+		}
+		return err
+	},
+}
+
+var (
+	scheduleHistoryOutput string
+	scheduleHistoryLimit  int
+)
+
+var scheduleHistoryCmd = &cobra.Command{
+	Use:   "history <nombre>",
+	Short: "Muestra las últimas ejecuciones de una tarea",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(scheduleHistoryOutput); err != nil {
+			return err
+		}
+		if _, err := schedule.Get(args[0]); err != nil {
+			return err
+		}
+		runs, err := schedule.History(args[0])
+		if err != nil {
+			return err
+		}
+		if scheduleHistoryLimit > 0 && len(runs) > scheduleHistoryLimit {
+			runs = runs[len(runs)-scheduleHistoryLimit:]
+		}
+		if runs == nil {
+			runs = []*schedule.Run{}
+		}
+		if scheduleHistoryOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, scheduleHistoryOutput, runs)
+		}
+		if len(runs) == 0 {
+			fmt.Printf("ℹ️  La tarea %s todavía no se ha ejecutado.\n", args[0])
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "INICIO\tORIGEN\tESTADO\tDURACIÓN\tLOG")
+		for i := len(runs) - 1; i >= 0; i-- {
+			r := runs[i]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				r.Start.Local().Format("2006-01-02 15:04:05"), r.Trigger, runState(r), r.Duration(), dash(r.Log))
+		}
+		return w.Flush()
+	},
+}
+
+// runState resume el resultado de una ejecución.
+func runState(r *schedule.Run) string {
+	switch {
+	case r.OK():
+		return "ok"
+	case r.Error != "":
+		return "error"
+	}
+	return fmt.Sprintf("código %d", r.ExitCode)
+}
+
+// printScheduleStatus es la sección de tareas programadas de `autohost status`.
+func printScheduleStatus() {
+	jobs, err := schedule.Jobs()
+	if err != nil {
+		fmt.Println("⚠️  Tareas programadas:", err)
+		return
+	}
+	if len(jobs) == 0 {
+		fmt.Println("🗓️ Ninguna tarea programada")
+		return
+	}
+	fmt.Printf("🗓️ Tareas programadas (%d):\n", len(jobs))
+	for _, j := range jobs {
+		last, err := schedule.LastRun(j.Name)
+		switch {
+		case err != nil:
+			fmt.Printf("   ⚠️  %s: %v\n", j.Name, err)
+		case last == nil:
+			fmt.Printf("   ⏳ %s (%s): aún sin ejecutar\n", j.Name, j.Schedule)
+		case last.OK():
+			fmt.Printf("   ✅ %s (%s): %s, %s\n", j.Name, j.Schedule, last.Start.Local().Format("2006-01-02 15:04"), last.Duration())
+		default:
+			fmt.Printf("   ❌ %s (%s): %s el %s tras %s\n", j.Name, j.Schedule, runState(last), last.Start.Local().Format("2006-01-02 15:04"), last.Duration())
+		}
+	}
+}
+
+func init() {
+	scheduleAddCmd.Flags().StringVar(&scheduleCron, "cron", "", "Horario: expresión cron de 5 campos o @hourly|@daily|@weekly|@monthly")
+	scheduleAddCmd.Flags().StringVar(&scheduleBackend, "backend", "", "Dónde instalarla: systemd|cron (por defecto systemd si está disponible)")
+	scheduleAddCmd.MarkFlagRequired("cron")
+	scheduleListCmd.Flags().StringVarP(&scheduleListOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	scheduleRunNowCmd.Flags().BoolVar(&scheduleScheduled, "scheduled", false, "Marca la ejecución como lanzada por systemd o cron")
+	scheduleRunNowCmd.Flags().MarkHidden("scheduled")
+	scheduleHistoryCmd.Flags().StringVarP(&scheduleHistoryOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	scheduleHistoryCmd.Flags().IntVarP(&scheduleHistoryLimit, "limit", "n", 20, "Número de ejecuciones a mostrar (0 = todas)")
+
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleRmCmd)
+	scheduleCmd.AddCommand(scheduleRunNowCmd)
+	scheduleCmd.AddCommand(scheduleHistoryCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
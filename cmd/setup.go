@@ -51,12 +51,21 @@ var setupCmd = &cobra.Command{
 		case "Tailscale (privado)":
 			tailscale.InstallTailscale()
 		case "Cloudflare Tunnel (público con dominio)":
-			cloudflared.InstallCloudflared()
+			if err := cloudflared.InstallCloudflared(); err != nil {
+				fmt.Println("❌ Error al instalar cloudflared:", err)
+				return
+			}
 			fmt.Print("Introduce el subdominio para el túnel (ej: blog.misitio.com): ")
 			reader := bufio.NewReader(os.Stdin)
 			domain, _ := reader.ReadString('\n')
 			domain = strings.TrimSpace(domain)
-			cloudflared.ConfigureCloudflareTunnel(domain)
+			res, err := cloudflared.ConfigureCloudflareTunnel("", domain, "http://localhost:80")
+			if err != nil {
+				fmt.Println("❌ Error al configurar el túnel:", err)
+				return
+			}
+			printTunnelApplyResult(res)
+			fmt.Println("✅ Túnel configurado correctamente.")
 		}
 
 		fmt.Println("\n✅ Configuración inicial completa.")
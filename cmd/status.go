@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"autohost-cli/internal/helpers/docker"
+	"autohost-cli/internal/helpers/tailscale"
 	"autohost-cli/utils"
 
 	"github.com/spf13/cobra"
@@ -21,15 +22,35 @@ var statusCmd = &cobra.Command{
 			return
 		}
 
-		fmt.Println("📦 Estado del sistema AutoHost\n")
+		fmt.Print("📦 Estado del sistema AutoHost\n\n")
 
 		// Estado de Docker
 		if docker.DockerInstalled() {
 			fmt.Println("✅ Docker instalado")
+			if err := docker.DaemonStatus(); err != nil {
+				fmt.Println("⚠️  Docker daemon:", err)
+			}
 		} else {
 			fmt.Println("❌ Docker no está disponible")
 		}
 
+		// Tailscale (LocalAPI de tailscaled)
+		if st, err := tailscale.Status(); err != nil {
+			fmt.Println("❌ Tailscale no disponible:", err)
+		} else if !st.Running() {
+			fmt.Printf("⚠️  Tailscale instalado pero no conectado (estado: %s)\n", st.BackendState)
+		} else {
+			online := 0
+			peers := st.Peers()
+			for _, p := range peers {
+				if p.Online {
+					online++
+				}
+			}
+			fmt.Printf("✅ Tailscale conectado: %s (%s) en %s, %d/%d peers en línea\n",
+				st.DNSName(), st.IPv4(), st.TailnetName(), online, len(peers))
+		}
+
 		// Leer status.json
 		status := loadStatus()
 
@@ -47,6 +68,9 @@ var statusCmd = &cobra.Command{
 			fmt.Println("🌐 Ningún dominio vinculado aún")
 		}
 
+		// Tareas programadas y su última ejecución
+		printScheduleStatus()
+
 		// Mostrar ubicación del sistema
 		base := utils.GetAutohostDir()
 		fmt.Println("\n🛠️ Directorio base:", base)
@@ -3,9 +3,10 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
+	"text/tabwriter"
 
+	"autohost-cli/internal/helpers/tailscale"
 	"autohost-cli/internal/infra"
 	"autohost-cli/utils"
 
@@ -29,12 +30,10 @@ var tailscaleInstallCmd = &cobra.Command{
 
 		fmt.Println("📦 Instalando Tailscale...")
 
-		installCmd := exec.Command("sh", "-c", `
-		curl -fsSL https://tailscale.com/install.sh | sh
-		`)
-		installCmd.Stdout = os.Stdout
-		installCmd.Stderr = os.Stderr
-		installCmd.Run()
+		if err := utils.ExecShell("curl -fsSL https://tailscale.com/install.sh | sh"); err != nil {
+			fmt.Println("❌ Error al instalar Tailscale:", err)
+			return
+		}
 
 		fmt.Println("✅ Tailscale instalado. Ahora ejecuta `autohost tailscale login` para autenticarte.")
 	},
@@ -47,11 +46,7 @@ var tailscaleLoginCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("🔐 Autenticando con Tailscale...")
 
-		loginCmd := exec.Command("sudo", "tailscale", "up")
-		loginCmd.Stdout = os.Stdout
-		loginCmd.Stderr = os.Stderr
-
-		if err := loginCmd.Run(); err != nil {
+		if err := utils.Exec("sudo", "tailscale", "up"); err != nil {
 			fmt.Println("❌ Error al conectar con Tailscale:", err)
 			return
 		}
@@ -67,10 +62,10 @@ var tailscaleLogoutCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("🔌 Cerrando sesión de Tailscale...")
 
-		logoutCmd := exec.Command("sudo", "tailscale", "logout")
-		logoutCmd.Stdout = os.Stdout
-		logoutCmd.Stderr = os.Stderr
-		logoutCmd.Run()
+		if err := utils.Exec("sudo", "tailscale", "logout"); err != nil {
+			fmt.Println("❌ Error al cerrar sesión:", err)
+			return
+		}
 
 		fmt.Println("✅ Sesión cerrada.")
 	},
@@ -78,9 +73,12 @@ var tailscaleLogoutCmd = &cobra.Command{
 
 var tailscaleSplitDnsCmd = &cobra.Command{
 	Use:   "split-dns",
-	Short: "Configura Split DNS para Tailscale (vía Terraform)",
-	Long: `Aplica Split DNS en tu tailnet usando Terraform y el provider oficial de Tailscale.
-Requiere TAILSCALE_API_KEY (y opcional TAILSCALE_TAILNET) en el entorno.
+	Short: "Configura Split DNS para Tailscale (API de Tailscale o Terraform)",
+	Long: `Aplica Split DNS en tu tailnet. Por defecto usa la API v2 de Tailscale y
+fusiona el dominio y los search paths con la configuración existente; con
+--backend terraform usa el provider oficial.
+Requiere TAILSCALE_API_KEY o TAILSCALE_OAUTH_CLIENT_ID/TAILSCALE_OAUTH_CLIENT_SECRET
+(y opcional TAILSCALE_TAILNET) en el entorno.
 
 Ejemplo:
   autohost tailscale split-dns \
@@ -93,23 +91,33 @@ Ejemplo:
 		nsStr, _ := cmd.Flags().GetString("nameservers")
 		searchStr, _ := cmd.Flags().GetString("search-paths")
 		tailnet, _ := cmd.Flags().GetString("tailnet")
+		backend, _ := cmd.Flags().GetString("backend")
 
 		if domain == "" || nsStr == "" {
 			return fmt.Errorf("flags requeridas: --domain y --nameservers (separados por coma si son varios)")
 		}
 
-		nameservers := splitAndTrim(nsStr)
-		searchPaths := splitAndTrim(searchStr)
-
-		fmt.Println("⚙️  Configurando Split DNS con Terraform...")
-		err := infra.ConfigureSplitDNSWithTerraform(infra.SplitDNSOpts{
+		opts := infra.SplitDNSOpts{
 			Tailnet:      tailnet,
 			Domain:       domain,
-			Nameservers:  nameservers,
-			SearchPaths:  searchPaths,
+			Nameservers:  splitAndTrim(nsStr),
+			SearchPaths:  splitAndTrim(searchStr),
 			APIKeyEnvVar: "TAILSCALE_API_KEY",
-		})
-		if err != nil {
+			Backend:      backend,
+		}
+
+		if plan, _ := cmd.Flags().GetBool("plan"); plan {
+			fmt.Printf("🔎 Plan de Split DNS (%s), no se aplica nada:\n", infra.SplitDNSBackend(backend))
+			out, err := infra.PlanSplitDNS(opts)
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		}
+
+		fmt.Printf("⚙️  Configurando Split DNS (%s)...\n", infra.SplitDNSBackend(backend))
+		if err := infra.ConfigureSplitDNS(opts); err != nil {
 			return err
 		}
 		fmt.Println("✅ Split DNS aplicado.")
@@ -117,15 +125,151 @@ Ejemplo:
 	},
 }
 
+var tailscaleSplitDnsRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Aliases: []string{"rm"},
+	Short:   "Quita un dominio de Split DNS y elimina su workspace",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain, _ := cmd.Flags().GetString("domain")
+		tailnet, _ := cmd.Flags().GetString("tailnet")
+		if domain == "" {
+			return fmt.Errorf("flag requerida: --domain")
+		}
+		fmt.Printf("🗑️  Quitando Split DNS para %s...\n", domain)
+		if err := infra.RemoveSplitDNS(tailnet, domain, "TAILSCALE_API_KEY"); err != nil {
+			return err
+		}
+		fmt.Println("✅ Split DNS eliminado.")
+		return nil
+	},
+}
+
+var tailscaleSplitDnsListOutput string
+
+var tailscaleSplitDnsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lista los dominios de Split DNS que gestiona autohost",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(tailscaleSplitDnsListOutput); err != nil {
+			return err
+		}
+		states, err := infra.ListSplitDNS()
+		if err != nil {
+			return err
+		}
+		if tailscaleSplitDnsListOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, tailscaleSplitDnsListOutput, states)
+		}
+		if len(states) == 0 {
+			fmt.Println("ℹ️  autohost no gestiona ningún dominio de Split DNS.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TAILNET\tDOMINIO\tBACKEND\tNAMESERVERS\tSEARCH PATHS\tAPLICADO")
+		for _, st := range states {
+			applied := "-"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", st.Tailnet, dash(st.Domain), dash(st.Backend),
+				dash(strings.Join(st.Nameservers, ",")), dash(strings.Join(st.SearchPaths, ",")), applied)
+		}
+		return w.Flush()
+	},
+}
+
+var tailscaleDevicesOutput string
+
+// Subcomando: devices
+var tailscaleDevicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "Lista los dispositivos de la tailnet (API de Tailscale)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateOutputFormat(tailscaleDevicesOutput); err != nil {
+			return err
+		}
+		tailnet, _ := cmd.Flags().GetString("tailnet")
+		c, err := infra.NewTailscaleClient(tailnet)
+		if err != nil {
+			return err
+		}
+		devices, err := c.Devices()
+		if err != nil {
+			return err
+		}
+		if tailscaleDevicesOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, tailscaleDevicesOutput, devices)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NOMBRE\tDIRECCIONES\tSO\tVISTO")
+		for _, d := range devices {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Name, dash(strings.Join(d.Addresses, ",")), dash(d.OS), dash(d.LastSeen))
+		}
+		return w.Flush()
+	},
+}
+
+var (
+	tailscaleStatusOutput string
+	tailscaleStatusJSON   bool
+)
+
 // Subcomando: status
 var tailscaleStatusCmd = &cobra.Command{
 	Use:   "status",
-	Short: "Muestra el estado actual de Tailscale",
-	Run: func(cmd *cobra.Command, args []string) {
-		statusCmd := exec.Command("sudo", "tailscale", "status")
-		statusCmd.Stdout = os.Stdout
-		statusCmd.Stderr = os.Stderr
-		statusCmd.Run()
+	Short: "Muestra el estado de Tailscale en este nodo (LocalAPI de tailscaled)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tailscaleStatusJSON {
+			tailscaleStatusOutput = utils.OutputJSON
+		}
+		if err := utils.ValidateOutputFormat(tailscaleStatusOutput); err != nil {
+			return err
+		}
+		st, err := tailscale.Status()
+		if err != nil {
+			return err
+		}
+		if tailscaleStatusOutput != utils.OutputTable {
+			return utils.WriteStructured(os.Stdout, tailscaleStatusOutput, st)
+		}
+
+		icon := "✅"
+		if !st.Running() {
+			icon = "❌"
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s Estado\t%s (tailscale %s)\n", icon, st.BackendState, dash(st.Version))
+		fmt.Fprintf(w, "🖥️  Nodo\t%s\n", dash(st.DNSName()))
+		fmt.Fprintf(w, "🛰️  IPs\t%s\n", dash(strings.Join(st.TailscaleIPs, ", ")))
+		fmt.Fprintf(w, "🌐 Tailnet\t%s\n", dash(st.TailnetName()))
+		fmt.Fprintf(w, "🔒 Certificados\t%s\n", dash(strings.Join(st.CertDomains, ", ")))
+		for _, h := range st.Health {
+			fmt.Fprintf(w, "⚠️  Salud\t%s\n", h)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		peers := st.Peers()
+		if len(peers) == 0 {
+			return nil
+		}
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PEER\tIP\tSO\tEN LÍNEA")
+		for _, p := range peers {
+			ip := ""
+			if len(p.TailscaleIPs) > 0 {
+				ip = p.TailscaleIPs[0]
+			}
+			online := "no"
+			if p.Online {
+				online = "sí"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.ShortName(), dash(ip), dash(p.OS), online)
+		}
+		return w.Flush()
 	},
 }
 
@@ -135,10 +279,21 @@ func init() {
 	tailscaleCmd.AddCommand(tailscaleLogoutCmd)
 	tailscaleCmd.AddCommand(tailscaleStatusCmd)
 	tailscaleCmd.AddCommand(tailscaleSplitDnsCmd)
+	tailscaleCmd.AddCommand(tailscaleDevicesCmd)
 	tailscaleSplitDnsCmd.Flags().String("domain", "", "Dominio a resolver vía Split DNS (ej. maza-server)")
 	tailscaleSplitDnsCmd.Flags().String("nameservers", "", "Lista de resolvers (coma-separados), ej. 100.112.92.90,1.1.1.1")
 	tailscaleSplitDnsCmd.Flags().String("search-paths", "", "(Opcional) dominios de búsqueda, coma-separados")
 	tailscaleSplitDnsCmd.Flags().String("tailnet", "", "(Opcional) tailnet; si no se indica usa TAILSCALE_TAILNET o '-'")
+	tailscaleSplitDnsCmd.Flags().String("backend", "", "Backend: api|terraform (por defecto AUTOHOST_SPLITDNS_BACKEND o api)")
+	tailscaleSplitDnsCmd.Flags().Bool("plan", false, "Muestra los cambios (diff de la API o terraform plan) sin aplicarlos")
+	tailscaleSplitDnsCmd.AddCommand(tailscaleSplitDnsRemoveCmd, tailscaleSplitDnsListCmd)
+	tailscaleSplitDnsRemoveCmd.Flags().String("domain", "", "Dominio a quitar (ej. maza-server)")
+	tailscaleSplitDnsRemoveCmd.Flags().String("tailnet", "", "(Opcional) tailnet; si no se indica usa TAILSCALE_TAILNET o '-'")
+	tailscaleSplitDnsListCmd.Flags().StringVarP(&tailscaleSplitDnsListOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	tailscaleStatusCmd.Flags().StringVarP(&tailscaleStatusOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
+	tailscaleStatusCmd.Flags().BoolVar(&tailscaleStatusJSON, "json", false, "Atajo de --output json")
+	tailscaleDevicesCmd.Flags().String("tailnet", "", "(Opcional) tailnet; si no se indica usa TAILSCALE_TAILNET o '-'")
+	tailscaleDevicesCmd.Flags().StringVarP(&tailscaleDevicesOutput, "output", "o", utils.OutputTable, "Formato de salida: table|json|yaml")
 
 	rootCmd.AddCommand(tailscaleCmd)
 }
@@ -6,18 +6,33 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
 func InstallApp(app string) error {
-	appDir := filepath.Join(utils.GetSubdir("apps"), app)
-	composePath := filepath.Join(appDir, "docker-compose.yml")
-	envPath := filepath.Join(appDir, ".env")
+	inst, err := NewAppInstance(app)
+	if err != nil {
+		return err
+	}
+	appDir, composePath, envPath := inst.Dir, inst.ComposeFile, inst.EnvFile
+
+	// === 0) Requisitos del manifiesto (si la app está en el catálogo) ===
+	if m, err := LoadManifest(app); err == nil {
+		warnings, err := m.CheckHost()
+		if err != nil {
+			return err
+		}
+		for _, w := range warnings {
+			fmt.Println("⚠️ ", w)
+		}
+	} else if _, e := assets.ReadManifest(app); e == nil {
+		// hay manifiesto pero no es válido: mejor fallar que instalar a ciegas
+		return err
+	}
 
 	// Crear el directorio destino
-	if err := os.MkdirAll(appDir, 0o755); err != nil {
+	if err := utils.DefaultRunner().MkdirAll(appDir, 0o755); err != nil {
 		return fmt.Errorf("error creando directorio de destino: %w", err)
 	}
 
@@ -39,7 +54,7 @@ func InstallApp(app string) error {
 		fmt.Println("📦 Usando plantilla embebida para:", app)
 	}
 
-	if err := os.WriteFile(composePath, data, 0o644); err != nil {
+	if err := utils.DefaultRunner().WriteFile(composePath, data, 0o644); err != nil {
 		return fmt.Errorf("error escribiendo docker-compose.yml: %w", err)
 	}
 
@@ -47,31 +62,20 @@ func InstallApp(app string) error {
 	if _, err := os.Stat(envPath); errors.Is(err, os.ErrNotExist) {
 		// Intentar leer .env.example embebido
 		if example, e := assets.ReadEnvExample(app); e == nil {
-			values := map[string]string{}
-
-			// Genera APP_KEY solo si el ejemplo lo pide
-			if strings.Contains(string(example), "{{APP_KEY}}") {
-				if key, genErr := utils.GenerateLaravelAppKey(); genErr == nil {
-					values["APP_KEY"] = key
-				} else {
-					return fmt.Errorf("no se pudo generar APP_KEY: %w", genErr)
-				}
+			final, err := utils.ReplacePlaceholders(string(example), nil)
+			if err != nil {
+				return fmt.Errorf("error generando .env para %s: %w", app, err)
 			}
-
-			// Si quieres agregar más placeholders globales, hazlo aquí:
-			// p.ej. PUERTOS ALEATORIOS, PASSWORDS, ETC.
-			// if strings.Contains(string(example), "{{MYSQL_PASSWORD}}") {
-			//     values["MYSQL_PASSWORD"] = utils.GeneratePassword(20)
-			// }
-
-			final := utils.ReplacePlaceholders(string(example), values)
-			if writeErr := os.WriteFile(envPath, []byte(final), 0o600); writeErr != nil {
+			if final, err = completeEnvFromManifest(app, final); err != nil {
+				return err
+			}
+			if writeErr := utils.DefaultRunner().WriteFile(envPath, []byte(final), 0o600); writeErr != nil {
 				return fmt.Errorf("error escribiendo .env: %w", writeErr)
 			}
 			fmt.Println("✅ .env generado desde .env.example")
 		} else if errors.Is(e, os.ErrNotExist) {
 			// Si la app no trae .env.example, crea uno vacío
-			if writeErr := os.WriteFile(envPath, []byte("# .env generado por autohost\n"), 0o600); writeErr != nil {
+			if writeErr := utils.DefaultRunner().WriteFile(envPath, []byte("# .env generado por autohost\n"), 0o600); writeErr != nil {
 				return fmt.Errorf("error creando .env vacío: %w", writeErr)
 			}
 			fmt.Println("ℹ️  Sin .env.example embebido; se creó .env vacío.")
@@ -86,52 +90,48 @@ func InstallApp(app string) error {
 	return nil
 }
 
-// StartApp ejecuta docker compose up -d para una app
-func StartApp(app string) error {
-	ymlPath := filepath.Join(utils.GetSubdir("apps"), app, "docker-compose.yml")
-
-	// Validar si existe el archivo docker-compose.yml
-	if _, err := os.Stat(ymlPath); os.IsNotExist(err) {
-		return fmt.Errorf("el archivo de configuración no existe: %s", ymlPath)
+// completeEnvFromManifest agrega al .env las variables del manifiesto que la
+// plantilla no declara: usa su generador o su default; si es requerida y no
+// tiene ninguno de los dos, la instalación falla.
+func completeEnvFromManifest(app, env string) (string, error) {
+	m, err := LoadManifest(app)
+	if err != nil {
+		return env, nil
 	}
+	present := utils.ParseEnv(env)
 
-	fmt.Printf("🔄 Levantando aplicación '%s'...\n", app)
-
-	// Usar Exec con working dir del compose
-	return utils.ExecWithDir(filepath.Dir(ymlPath), "docker", "compose", "-f", ymlPath, "up", "-d")
-}
-
-//modificar todas las ficniones de abajo
-
-// StopApp ejecuta docker compose stop para una app
-func StopApp(app string) error {
-	cmd := exec.Command("docker", "compose", "-f", appComposePath(app), "stop")
-	return cmd.Run()
-}
-
-// RemoveApp ejecuta docker compose down para una app
-func RemoveApp(app string) error {
-	cmd := exec.Command("docker", "compose", "-f", appComposePath(app), "down")
-	return cmd.Run()
-}
-
-// GetAppStatus devuelve si los contenedores están "running", "exited", etc.
-func GetAppStatus(app string) (string, error) {
-	cmd := exec.Command("docker", "compose", "-f", appComposePath(app), "ps", "--status=running")
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
+	var extra []string
+	for _, v := range m.Variables {
+		if _, ok := present[v.Name]; ok {
+			continue
+		}
+		switch {
+		case v.Generator != "":
+			val, err := utils.GeneratePlaceholderSpec(v.Generator)
+			if err != nil {
+				return "", fmt.Errorf("variable %s: %w", v.Name, err)
+			}
+			extra = append(extra, v.Name+"="+val)
+		case v.Default != "":
+			extra = append(extra, v.Name+"="+v.Default)
+		case v.Required:
+			return "", fmt.Errorf("la variable requerida %s no tiene valor ni generador", v.Name)
+		}
 	}
-	if strings.Contains(string(out), "Up") {
-		return "en ejecución", nil
+	if len(extra) == 0 {
+		return env, nil
 	}
-	return "detenida", nil
+	return strings.TrimRight(env, "\n") + "\n\n# agregado por autohost desde app.toml\n" + strings.Join(extra, "\n") + "\n", nil
 }
 
-// appComposePath devuelve la ruta al archivo docker-compose.yml de la app
-func appComposePath(app string) string {
-	fmt.Println(app)
-	return fmt.Sprintf("%s/docker/compose/%s.yml", utils.GetAutohostDir(), app)
+// StartApp ejecuta docker compose up -d para una app
+func StartApp(app string) error {
+	inst, err := OpenAppInstance(app)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔄 Levantando aplicación '%s'...\n", app)
+	return inst.Start()
 }
 
 // func TemplateExists(appName string) bool {
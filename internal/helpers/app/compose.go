@@ -0,0 +1,75 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ComposeConfig es el compose ya resuelto por `docker compose config`
+// (variables del .env sustituidas y rutas relativas convertidas en absolutas).
+type ComposeConfig struct {
+	Name     string                    `json:"name"`
+	Services map[string]ComposeService `json:"services"`
+	Volumes  map[string]ComposeVolume  `json:"volumes"`
+}
+
+// ComposeService es un servicio del compose resuelto.
+type ComposeService struct {
+	Image         string             `json:"image"`
+	ContainerName string             `json:"container_name"`
+	Environment   map[string]*string `json:"environment"`
+	Labels        map[string]string  `json:"labels"`
+	Volumes       []ComposeMount     `json:"volumes"`
+}
+
+// ComposeMount es un montaje de un servicio (volumen con nombre o bind mount).
+type ComposeMount struct {
+	Type     string `json:"type"`   // volume | bind | tmpfs
+	Source   string `json:"source"` // clave del volumen o ruta absoluta del host
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// ComposeVolume es un volumen declarado en el compose.
+type ComposeVolume struct {
+	Name     string `json:"name"` // nombre real en Docker (<proyecto>_<clave>)
+	External bool   `json:"external"`
+}
+
+// Config devuelve el compose de la app resuelto con su .env.
+func (a *AppInstance) Config() (*ComposeConfig, error) {
+	out, err := a.output("config", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	var cfg ComposeConfig
+	if err := json.Unmarshal(out, &cfg); err != nil {
+		return nil, fmt.Errorf("salida inesperada de docker compose config: %w", err)
+	}
+	for key, v := range cfg.Volumes {
+		if v.Name == "" && !v.External {
+			v.Name = a.Project + "_" + key
+			cfg.Volumes[key] = v
+		}
+	}
+	return &cfg, nil
+}
+
+// ServiceNames devuelve los servicios del compose ordenados.
+func (c *ComposeConfig) ServiceNames() []string {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Env devuelve el valor resuelto de una variable del servicio ("" si no está).
+func (s ComposeService) Env(key string) string {
+	if v := s.Environment[key]; v != nil {
+		return *v
+	}
+	return ""
+}
@@ -0,0 +1,265 @@
+package app
+
+import (
+	"autohost-cli/utils"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// AppInstance representa una app instalada en ~/.autohost/apps/<nombre>.
+// Todas las operaciones de ciclo de vida pasan por aquí para usar siempre
+// el mismo compose, .env y nombre de proyecto.
+type AppInstance struct {
+	Name        string
+	Dir         string
+	ComposeFile string
+	EnvFile     string
+	Project     string
+}
+
+// LogsOptions controla `docker compose logs`.
+type LogsOptions struct {
+	Follow  bool
+	Tail    int // 0 = todo
+	Service string
+}
+
+// NewAppInstance resuelve las rutas de una app (no verifica que esté instalada).
+func NewAppInstance(name string) (*AppInstance, error) {
+	if !appNameRe.MatchString(name) {
+		return nil, fmt.Errorf("nombre de app inválido: %q", name)
+	}
+	dir := filepath.Join(utils.GetSubdir("apps"), name)
+	return &AppInstance{
+		Name:        name,
+		Dir:         dir,
+		ComposeFile: filepath.Join(dir, "docker-compose.yml"),
+		EnvFile:     filepath.Join(dir, ".env"),
+		Project:     name, // igual al nombre del directorio: compatible con instalaciones previas
+	}, nil
+}
+
+// OpenAppInstance es como NewAppInstance pero exige que la app esté instalada.
+func OpenAppInstance(name string) (*AppInstance, error) {
+	a, err := NewAppInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Installed() {
+		return nil, fmt.Errorf("%s no está instalada (no existe %s); ejecuta `autohost app install %s`", name, a.ComposeFile, name)
+	}
+	return a, nil
+}
+
+// InstalledApps devuelve las apps con compose en ~/.autohost/apps, ordenadas por nombre.
+func InstalledApps() ([]*AppInstance, error) {
+	appsDir := utils.GetSubdir("apps")
+	entries, err := os.ReadDir(appsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("no se pudo leer %s: %w", appsDir, err)
+	}
+	var out []*AppInstance
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if inst, err := NewAppInstance(e.Name()); err == nil && inst.Installed() {
+			out = append(out, inst)
+		}
+	}
+	return out, nil
+}
+
+// RunningServices devuelve los servicios con algún contenedor en ejecución.
+func (a *AppInstance) RunningServices() ([]string, error) {
+	cs, err := a.Ps()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, c := range cs {
+		if c.State == "running" && !seen[c.Service] {
+			seen[c.Service] = true
+			out = append(out, c.Service)
+		}
+	}
+	return out, nil
+}
+
+// Installed indica si existe el docker-compose.yml de la app.
+func (a *AppInstance) Installed() bool {
+	_, err := os.Stat(a.ComposeFile)
+	return err == nil
+}
+
+// Start ejecuta `docker compose up -d`.
+func (a *AppInstance) Start() error { return a.run("up", "-d") }
+
+// Stop ejecuta `docker compose stop`.
+func (a *AppInstance) Stop() error { return a.run("stop") }
+
+// Restart ejecuta `docker compose restart`.
+func (a *AppInstance) Restart() error { return a.run("restart") }
+
+// Pause congela los contenedores en ejecución (`docker compose pause`).
+func (a *AppInstance) Pause() error { return a.run("pause") }
+
+// Unpause reanuda los contenedores congelados con Pause.
+func (a *AppInstance) Unpause() error { return a.run("unpause") }
+
+// StartServices arranca servicios existentes sin recrearlos (`docker compose start`).
+func (a *AppInstance) StartServices(services ...string) error {
+	return a.run(append([]string{"start"}, services...)...)
+}
+
+// Pull descarga las imágenes más recientes de la app.
+func (a *AppInstance) Pull() error { return a.run("pull") }
+
+// Down elimina contenedores y redes de la app (y volúmenes si se pide).
+func (a *AppInstance) Down(removeVolumes bool) error {
+	if removeVolumes {
+		return a.run("down", "--volumes")
+	}
+	return a.run("down")
+}
+
+// Ps devuelve el estado de los contenedores de la app.
+func (a *AppInstance) Ps() ([]ContainerStatus, error) {
+	out, err := a.output("ps", "--all", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseComposePS(out)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ContainerStatus, 0, len(entries))
+	for _, e := range entries {
+		cs := ContainerStatus{Name: e.Name, Service: e.Service, State: e.State, Health: e.Health, Image: e.Image, Ports: []string{}}
+		seen := map[string]bool{}
+		for _, p := range e.Publishers {
+			if p.PublishedPort == 0 {
+				continue
+			}
+			port := fmt.Sprintf("%d->%d/%s", p.PublishedPort, p.TargetPort, p.Protocol)
+			if !seen[port] { // compose repite el puerto para IPv4 e IPv6
+				seen[port] = true
+				cs.Ports = append(cs.Ports, port)
+			}
+		}
+		res = append(res, cs)
+	}
+	return res, nil
+}
+
+// Status resume el estado de la app (ver constantes State*).
+func (a *AppInstance) Status() (string, error) {
+	cs, err := a.Ps()
+	if err != nil {
+		return StateUnknown, err
+	}
+	return SummarizeState(cs), nil
+}
+
+// Logs escribe los logs de la app en w.
+func (a *AppInstance) Logs(w io.Writer, opts LogsOptions) error {
+	args := []string{"logs", "--no-color"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Service != "" {
+		args = append(args, opts.Service)
+	}
+	c := a.command(args...)
+	c.Stdout = w
+	c.ReadOnly = true
+	return a.wrapErr(args[0], utils.DefaultRunner().Run(c))
+}
+
+// Exec ejecuta un comando dentro del contenedor en marcha de un servicio
+// (`docker compose exec -T`), conectando stdin/stdout a los indicados.
+func (a *AppInstance) Exec(service string, stdin io.Reader, stdout io.Writer, args ...string) error {
+	if !a.Installed() {
+		return fmt.Errorf("el archivo de configuración no existe: %s", a.ComposeFile)
+	}
+	c := a.command(append([]string{"exec", "-T", service}, args...)...)
+	c.Stdin, c.Stdout = stdin, stdout
+	return a.wrapErr("exec", utils.DefaultRunner().Run(c))
+}
+
+// ExecOutput ejecuta un comando dentro del contenedor de un servicio y
+// devuelve su salida sin mostrarla (stderr va en el error). No se marca como
+// consulta: el comando puede modificar el contenedor, así que en --dry-run
+// solo se muestra y devuelve una salida vacía.
+func (a *AppInstance) ExecOutput(service string, args ...string) ([]byte, error) {
+	if !a.Installed() {
+		return nil, fmt.Errorf("el archivo de configuración no existe: %s", a.ComposeFile)
+	}
+	out, err := utils.DefaultRunner().Output(a.command(append([]string{"exec", "-T", service}, args...)...))
+	return out, a.wrapErr("exec", err)
+}
+
+// RunOneOff ejecuta un contenedor efímero del servicio sin sus dependencias
+// (`docker compose run --rm --no-deps -T`), útil con la app detenida.
+func (a *AppInstance) RunOneOff(service string, stdin io.Reader, stdout io.Writer, entrypoint string, args ...string) error {
+	if !a.Installed() {
+		return fmt.Errorf("el archivo de configuración no existe: %s", a.ComposeFile)
+	}
+	full := []string{"run", "--rm", "--no-deps", "-T"}
+	if entrypoint != "" {
+		full = append(full, "--entrypoint", entrypoint)
+	}
+	c := a.command(append(append(full, service), args...)...)
+	c.Stdin, c.Stdout = stdin, stdout
+	return a.wrapErr("run", utils.DefaultRunner().Run(c))
+}
+
+// composeArgs arma `compose -p <proyecto> -f <compose> [--env-file .env] <args>`.
+func (a *AppInstance) composeArgs(args ...string) []string {
+	base := []string{"compose", "-p", a.Project, "-f", a.ComposeFile}
+	if _, err := os.Stat(a.EnvFile); err == nil {
+		base = append(base, "--env-file", a.EnvFile)
+	}
+	return append(base, args...)
+}
+
+func (a *AppInstance) command(args ...string) utils.Cmd {
+	c := utils.Command("docker", a.composeArgs(args...)...)
+	c.Dir = a.Dir
+	return c
+}
+
+// run muestra el progreso de compose al usuario; el Runner incluye stderr
+// en el error si el comando falla.
+func (a *AppInstance) run(args ...string) error {
+	if !a.Installed() {
+		return fmt.Errorf("el archivo de configuración no existe: %s", a.ComposeFile)
+	}
+	return a.wrapErr(args[0], utils.DefaultRunner().Run(a.command(args...)))
+}
+
+// output captura stdout sin mostrarlo (solo para consultas).
+func (a *AppInstance) output(args ...string) ([]byte, error) {
+	if !a.Installed() {
+		return nil, fmt.Errorf("el archivo de configuración no existe: %s", a.ComposeFile)
+	}
+	c := a.command(args...)
+	c.ReadOnly = true
+	out, err := utils.DefaultRunner().Output(c)
+	return out, a.wrapErr(args[0], err)
+}
+
+func (a *AppInstance) wrapErr(sub string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("docker compose %s (%s): %w", sub, a.Name, err)
+}
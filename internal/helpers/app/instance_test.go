@@ -0,0 +1,60 @@
+package app
+
+import (
+	"autohost-cli/utils"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testInstance(t *testing.T) *AppInstance {
+	t.Helper()
+	dir := t.TempDir()
+	compose := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(compose, []byte("services: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &AppInstance{Name: "db", Dir: dir, ComposeFile: compose, EnvFile: filepath.Join(dir, ".env"), Project: "autohost-db"}
+}
+
+// En --dry-run los exec solo se muestran: ni Exec ni ExecOutput llegan a docker.
+func TestExecDryRunDoesNotRun(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // si se ejecutara, docker no existe y fallaría
+	var buf bytes.Buffer
+	utils.SetDefaultRunner(utils.NewDryRunner(&buf))
+	t.Cleanup(func() { utils.SetDefaultRunner(nil) })
+	inst := testInstance(t)
+
+	out, err := inst.ExecOutput("db", "sh", "-c", "pg_isready -q")
+	if err != nil || len(out) != 0 {
+		t.Fatalf("ExecOutput = %q, %v", out, err)
+	}
+	if err := inst.Exec("db", nil, nil, "sh", "-c", "pg_dump app"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("salida del dry-run:\n%s", buf.String())
+	}
+	for i, want := range []string{"exec -T db sh -c 'pg_isready -q'", "exec -T db sh -c 'pg_dump app'"} {
+		if !strings.HasPrefix(lines[i], utils.DryRunPrefix+" $ ") || !strings.Contains(lines[i], "docker compose -p autohost-db -f ") || !strings.HasSuffix(lines[i], want+")") {
+			t.Errorf("línea %d = %q, quiero ...%s", i, lines[i], want)
+		}
+	}
+}
+
+// ps es una consulta (se ejecuta en --dry-run); exec no.
+func TestExecIsNotReadOnly(t *testing.T) {
+	rec := utils.NewRecordingRunner()
+	utils.SetDefaultRunner(rec)
+	t.Cleanup(func() { utils.SetDefaultRunner(nil) })
+	inst := testInstance(t)
+
+	_, _ = inst.ExecOutput("db", "true")
+	_, _ = inst.Ps()
+	if len(rec.Commands) != 2 || rec.Commands[0].ReadOnly || !rec.Commands[1].ReadOnly {
+		t.Fatalf("comandos = %+v", rec.Commands)
+	}
+}
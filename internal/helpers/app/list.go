@@ -0,0 +1,224 @@
+package app
+
+import (
+	"autohost-cli/internal/helpers/caddy"
+	"autohost-cli/internal/helpers/exposure"
+	"autohost-cli/utils"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// AppSummary resume una app del catálogo y/o instalada en ~/.autohost/apps.
+type AppSummary struct {
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name,omitempty"`
+	Source      string            `json:"source"` // catalog | custom
+	Installed   bool              `json:"installed"`
+	Dir         string            `json:"dir,omitempty"`
+	State       string            `json:"state"`
+	Containers  []ContainerStatus `json:"containers"`
+	Hostnames   []string          `json:"hostnames"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// ContainerStatus es el estado de un contenedor según `docker compose ps`.
+type ContainerStatus struct {
+	Name    string   `json:"name"`
+	Service string   `json:"service"`
+	State   string   `json:"state"`
+	Health  string   `json:"health,omitempty"`
+	Image   string   `json:"image"`
+	Ports   []string `json:"ports"`
+}
+
+// Estados agregados de una app.
+const (
+	StateNotInstalled = "no instalada"
+	StateStopped      = "detenida"
+	StateRunning      = "en ejecución"
+	StatePartial      = "parcial"
+	StateUnhealthy    = "con problemas"
+	StateUnknown      = "desconocido"
+)
+
+// ListApps combina las apps instaladas en ~/.autohost/apps con el catálogo embebido
+// y consulta el estado de sus contenedores.
+func ListApps() ([]AppSummary, error) {
+	byName := map[string]*AppSummary{}
+
+	catalog, _ := Catalog()
+	for _, m := range catalog {
+		byName[m.Name] = &AppSummary{Name: m.Name, DisplayName: m.DisplayName, Source: "catalog", State: StateNotInstalled}
+	}
+
+	appsDir := utils.GetSubdir("apps")
+	entries, err := os.ReadDir(appsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("no se pudo leer %s: %w", appsDir, err)
+	}
+	hosts := exposedHostsByPort()
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		inst, err := NewAppInstance(e.Name())
+		if err != nil || !inst.Installed() {
+			continue
+		}
+		s, ok := byName[inst.Name]
+		if !ok {
+			s = &AppSummary{Name: inst.Name, Source: "custom"}
+			byName[inst.Name] = s
+		}
+		s.Installed = true
+		s.Dir = inst.Dir
+
+		containers, err := inst.Ps()
+		if err != nil {
+			s.State = StateUnknown
+			s.Error = err.Error()
+			continue
+		}
+		s.Containers = containers
+		s.State = SummarizeState(containers)
+		s.Hostnames = hostnamesFor(containers, hosts)
+	}
+
+	out := make([]AppSummary, 0, len(byName))
+	for _, s := range byName {
+		if s.Containers == nil {
+			s.Containers = []ContainerStatus{}
+		}
+		if s.Hostnames == nil {
+			s.Hostnames = []string{}
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Installed != out[j].Installed {
+			return out[i].Installed
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// composePSEntry refleja los campos que nos interesan de `docker compose ps --format json`.
+type composePSEntry struct {
+	Name       string `json:"Name"`
+	Service    string `json:"Service"`
+	State      string `json:"State"`
+	Health     string `json:"Health"`
+	Image      string `json:"Image"`
+	Publishers []struct {
+		URL           string `json:"URL"`
+		TargetPort    int    `json:"TargetPort"`
+		PublishedPort int    `json:"PublishedPort"`
+		Protocol      string `json:"Protocol"`
+	} `json:"Publishers"`
+}
+
+// parseComposePS acepta tanto un arreglo JSON (compose < 2.21) como JSON por línea.
+func parseComposePS(out []byte) ([]composePSEntry, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+	if out[0] == '[' {
+		var list []composePSEntry
+		if err := json.Unmarshal(out, &list); err != nil {
+			return nil, fmt.Errorf("salida inesperada de docker compose ps: %w", err)
+		}
+		return list, nil
+	}
+	var list []composePSEntry
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e composePSEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("salida inesperada de docker compose ps: %w", err)
+		}
+		list = append(list, e)
+	}
+	return list, sc.Err()
+}
+
+// SummarizeState reduce el estado de varios contenedores a un estado de app.
+func SummarizeState(cs []ContainerStatus) string {
+	if len(cs) == 0 {
+		return StateStopped
+	}
+	running := 0
+	for _, c := range cs {
+		if c.State == "running" {
+			running++
+		}
+		if c.Health == "unhealthy" || c.State == "restarting" {
+			return StateUnhealthy
+		}
+	}
+	switch running {
+	case 0:
+		return StateStopped
+	case len(cs):
+		return StateRunning
+	default:
+		return StatePartial
+	}
+}
+
+var publishedPortsRe = regexp.MustCompile(`^(\d+)->`)
+
+// exposedHostsByPort junta los sitios de Caddy gestionados por autohost y las
+// exposiciones registradas (cloudflare, tailscale-serve…) y devuelve
+// puerto local → hostnames, sin repetir.
+func exposedHostsByPort() map[int][]string {
+	type portHost struct {
+		port int
+		host string
+	}
+	res := map[int][]string{}
+	seen := map[portHost]bool{}
+	add := func(port int, host string) {
+		if port <= 0 || seen[portHost{port, host}] {
+			return
+		}
+		seen[portHost{port, host}] = true
+		res[port] = append(res[port], host)
+	}
+	sites, _ := caddy.ListSites()
+	for _, s := range sites {
+		add(s.Port(), s.Host)
+	}
+	exposures, _ := exposure.List()
+	for _, e := range exposures {
+		add(e.Port, e.Key())
+	}
+	return res
+}
+
+func hostnamesFor(cs []ContainerStatus, hosts map[int][]string) []string {
+	var out []string
+	for _, c := range cs {
+		for _, p := range c.Ports {
+			if m := publishedPortsRe.FindStringSubmatch(p); m != nil {
+				port, _ := strconv.Atoi(m[1])
+				out = append(out, hosts[port]...)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
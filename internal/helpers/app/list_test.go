@@ -0,0 +1,69 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Los hostnames salen de los sitios de Caddy y de las exposiciones
+// registradas por cualquier proveedor, sin repetirse.
+func TestExposedHostsByPort(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	sites := filepath.Join(home, ".autohost", "caddy", "sites")
+	writeTestFile(t, filepath.Join(sites, "wiki.maza-server.caddy"),
+		"# autohost:site v1\n# host: wiki.maza-server\n# upstream: localhost:6875\nwiki.maza-server {\n\treverse_proxy localhost:6875\n}\n")
+	writeTestFile(t, filepath.Join(sites, "remoto.caddy"),
+		"# autohost:site v1\n# host: remoto\n# upstream: 10.0.0.5:80\nremoto {\n\treverse_proxy 10.0.0.5:80\n}\n")
+	writeTestFile(t, filepath.Join(home, ".autohost", "state", "exposures.json"), `{"exposures": [
+		{"host": "wiki.maza-server", "provider": "tailscale", "port": 6875, "service": "http://localhost:6875", "caddy": true},
+		{"host": "wiki.example.com", "provider": "cloudflare", "port": 6875, "service": "http://localhost:6875"},
+		{"host": "nas.tail1234.ts.net", "https_port": 8443, "path": "/cloud", "provider": "tailscale-serve", "port": 8080, "service": "http://127.0.0.1:8080"}
+	]}`)
+
+	got := exposedHostsByPort()
+	want := map[int][]string{
+		6875: {"wiki.maza-server", "wiki.example.com"},
+		8080: {"nas.tail1234.ts.net:8443/cloud"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("exposedHostsByPort() = %q\nquiero %q", got, want)
+	}
+
+	cs := []ContainerStatus{{Ports: []string{"6875->80/tcp"}}, {Ports: []string{"8080->80/tcp", "9000->9000/tcp"}}}
+	if hosts := hostnamesFor(cs, got); !reflect.DeepEqual(hosts, []string{"nas.tail1234.ts.net:8443/cloud", "wiki.example.com", "wiki.maza-server"}) {
+		t.Fatalf("hostnamesFor = %q", hosts)
+	}
+}
+
+func TestSummarizeState(t *testing.T) {
+	tests := []struct {
+		name string
+		cs   []ContainerStatus
+		want string
+	}{
+		{"sin contenedores", nil, StateStopped},
+		{"todos corriendo", []ContainerStatus{{State: "running"}, {State: "running", Health: "healthy"}}, StateRunning},
+		{"uno detenido", []ContainerStatus{{State: "running"}, {State: "exited"}}, StatePartial},
+		{"todos detenidos", []ContainerStatus{{State: "exited"}}, StateStopped},
+		{"unhealthy", []ContainerStatus{{State: "running", Health: "unhealthy"}}, StateUnhealthy},
+		{"reiniciando", []ContainerStatus{{State: "running"}, {State: "restarting"}}, StateUnhealthy},
+	}
+	for _, tt := range tests {
+		if got := SummarizeState(tt.cs); got != tt.want {
+			t.Errorf("%s: SummarizeState = %q, quiero %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package app
+
+import (
+	"autohost-cli/assets"
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Manifest describe una entrada del catálogo (assets/docker/<app>/app.toml).
+// Los manifiestos se escriben solo en TOML; un app.yaml se rechaza al cargarlo.
+type Manifest struct {
+	Name        string       `toml:"name" json:"name"`
+	DisplayName string       `toml:"display_name" json:"display_name"`
+	Description string       `toml:"description" json:"description"`
+	Version     string       `toml:"version" json:"version"`
+	Homepage    string       `toml:"homepage" json:"homepage,omitempty"`
+	DefaultPort int          `toml:"default_port" json:"default_port"`
+	Require     Requirements `toml:"requirements" json:"requirements"`
+	Variables   []Variable   `toml:"variables" json:"variables"`
+}
+
+// Requirements agrupa lo que la app necesita del host.
+type Requirements struct {
+	MinRAMMB      int      `toml:"min_ram_mb" json:"min_ram_mb"`
+	Architectures []string `toml:"architectures" json:"architectures"`
+	Networks      []string `toml:"networks" json:"networks"`
+}
+
+// Variable es una clave del .env que la app espera.
+type Variable struct {
+	Name        string `toml:"name" json:"name"`
+	Type        string `toml:"type" json:"type"`
+	Description string `toml:"description" json:"description"`
+	Default     string `toml:"default" json:"default,omitempty"`
+	Required    bool   `toml:"required" json:"required"`
+	Secret      bool   `toml:"secret" json:"secret"`
+	Generator   string `toml:"generator" json:"generator,omitempty"`
+}
+
+var (
+	varNameRe = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+	appNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+	knownVarTypes = []string{"string", "int", "bool", "port", "url", "email"}
+	knownArches   = []string{"amd64", "arm64", "arm", "386"}
+)
+
+// ParseManifest decodifica y valida un manifiesto TOML.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := toml.NewDecoder(bytes.NewReader(data)).Strict(true).Decode(&m); err != nil {
+		return nil, fmt.Errorf("manifiesto inválido: %w", err)
+	}
+	for i := range m.Variables {
+		if m.Variables[i].Type == "" {
+			m.Variables[i].Type = "string"
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// LoadManifest lee el manifiesto embebido de una app del catálogo.
+func LoadManifest(app string) (*Manifest, error) {
+	data, err := assets.ReadManifest(app)
+	if err != nil {
+		return nil, fmt.Errorf("%s no tiene manifiesto en el catálogo: %w", app, err)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", app, err)
+	}
+	if m.Name != app {
+		return nil, fmt.Errorf("%s: el manifiesto declara name=%q (debe coincidir con el directorio)", app, m.Name)
+	}
+	return m, nil
+}
+
+// Catalog devuelve los manifiestos de todas las apps embebidas, ordenados por nombre.
+// Si alguna entrada es inválida se devuelve el error junto con las entradas válidas.
+func Catalog() ([]*Manifest, error) {
+	apps, err := assets.ListApps()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(apps)
+
+	var out []*Manifest
+	var errs []error
+	for _, name := range apps {
+		m, err := LoadManifest(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, errors.Join(errs...)
+}
+
+// Validate revisa que el manifiesto tenga los campos y tipos esperados.
+func (m *Manifest) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if !appNameRe.MatchString(m.Name) {
+		add("name %q inválido (minúsculas, dígitos, '-' o '_')", m.Name)
+	}
+	if strings.TrimSpace(m.DisplayName) == "" {
+		add("display_name es requerido")
+	}
+	if strings.TrimSpace(m.Version) == "" {
+		add("version es requerido")
+	}
+	if m.DefaultPort < 1 || m.DefaultPort > 65535 {
+		add("default_port fuera de rango: %d", m.DefaultPort)
+	}
+	if m.Require.MinRAMMB < 0 {
+		add("requirements.min_ram_mb no puede ser negativo")
+	}
+	for _, a := range m.Require.Architectures {
+		if !contains(knownArches, a) {
+			add("requirements.architectures: arquitectura desconocida %q", a)
+		}
+	}
+	for _, n := range m.Require.Networks {
+		if strings.TrimSpace(n) == "" {
+			add("requirements.networks contiene un nombre vacío")
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, v := range m.Variables {
+		if !varNameRe.MatchString(v.Name) {
+			add("variables[%d]: nombre %q inválido", i, v.Name)
+			continue
+		}
+		if seen[v.Name] {
+			add("variables: %s está duplicada", v.Name)
+		}
+		seen[v.Name] = true
+
+		if !contains(knownVarTypes, v.Type) {
+			add("variables.%s: tipo desconocido %q", v.Name, v.Type)
+			continue
+		}
+		if v.Default != "" {
+			if err := checkVarValue(v.Type, v.Default); err != nil {
+				add("variables.%s: default inválido: %v", v.Name, err)
+			}
+		}
+		if v.Generator != "" {
+			if err := utils.ValidatePlaceholderSpec(v.Generator); err != nil {
+				add("variables.%s: %v", v.Name, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Variable busca una variable por nombre.
+func (m *Manifest) Variable(name string) (Variable, bool) {
+	for _, v := range m.Variables {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variable{}, false
+}
+
+// SupportsArch indica si la app declara soporte para la arquitectura dada
+// (sin arquitecturas declaradas se asume que corre en cualquiera).
+func (m *Manifest) SupportsArch(arch string) bool {
+	return len(m.Require.Architectures) == 0 || contains(m.Require.Architectures, arch)
+}
+
+// CheckHost valida los requisitos del manifiesto contra este host.
+// Una arquitectura no soportada es error; poca RAM solo genera advertencias.
+func (m *Manifest) CheckHost() (warnings []string, err error) {
+	if !m.SupportsArch(runtime.GOARCH) {
+		return nil, fmt.Errorf("%s no soporta la arquitectura %s (soportadas: %s)",
+			m.DisplayName, runtime.GOARCH, strings.Join(m.Require.Architectures, ", "))
+	}
+	if m.Require.MinRAMMB > 0 {
+		if total, e := hostRAMMB(); e == nil && total < m.Require.MinRAMMB {
+			warnings = append(warnings, fmt.Sprintf("%s recomienda al menos %d MB de RAM; este host tiene %d MB",
+				m.DisplayName, m.Require.MinRAMMB, total))
+		}
+	}
+	if len(m.Require.Networks) == 0 {
+		return warnings, nil
+	}
+	dc, err := infra.NewDockerClient()
+	if err != nil {
+		return append(warnings, fmt.Sprintf("no se pudieron comprobar las redes Docker: %v", err)), nil
+	}
+	for _, n := range m.Require.Networks {
+		_, err := dc.InspectNetwork(n)
+		if errors.Is(err, infra.ErrDockerNotFound) {
+			warnings = append(warnings, fmt.Sprintf("%s requiere la red Docker %q; créala con: docker network create %s", m.DisplayName, n, n))
+		} else if err != nil {
+			return append(warnings, fmt.Sprintf("no se pudieron comprobar las redes Docker: %v", err)), nil
+		}
+	}
+	return warnings, nil
+}
+
+func checkVarValue(typ, val string) error {
+	switch typ {
+	case "int":
+		_, err := strconv.Atoi(val)
+		return err
+	case "port":
+		p, err := strconv.Atoi(val)
+		if err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("%q no es un puerto válido", val)
+		}
+	case "bool":
+		_, err := strconv.ParseBool(val)
+		return err
+	case "url":
+		if !strings.Contains(val, "://") {
+			return fmt.Errorf("%q no es una URL", val)
+		}
+	case "email":
+		if !strings.Contains(val, "@") {
+			return fmt.Errorf("%q no es un email", val)
+		}
+	}
+	return nil
+}
+
+// hostRAMMB lee la memoria total desde /proc/meminfo (solo Linux).
+func hostRAMMB() (int, error) {
+	f, err := os.Open(filepath.Join("/proc", "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0, err
+			}
+			return kb / 1024, nil
+		}
+	}
+	return 0, errors.New("MemTotal no encontrado en /proc/meminfo")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"autohost-cli/internal/infra"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDockerNetworks atiende GET /networks/{name} en un socket unix y apunta
+// DOCKER_HOST a él; solo existen las redes de exists.
+func fakeDockerNetworks(t *testing.T, exists ...string) *[]string {
+	t.Helper()
+	var asked []string
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		asked = append(asked, r.Method+" "+name)
+		for _, n := range exists {
+			if n == name {
+				w.Write([]byte(`{"Name":"` + n + `","Driver":"bridge"}`))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"network ` + name + ` not found"}`))
+	}))
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	t.Setenv("DOCKER_HOST", "unix://"+sock)
+	return &asked
+}
+
+func TestCheckHostNetworks(t *testing.T) {
+	tests := []struct {
+		name     string
+		exists   []string
+		networks []string
+		want     []string // fragmentos esperados, uno por advertencia
+	}{
+		{name: "sin redes", networks: nil},
+		{name: "red existente", exists: []string{"autohost_net"}, networks: []string{"autohost_net"}},
+		{name: "red faltante", networks: []string{"autohost_net"}, want: []string{"docker network create autohost_net"}},
+		{name: "una de dos", exists: []string{"a"}, networks: []string{"a", "b"}, want: []string{`red Docker "b"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked := fakeDockerNetworks(t, tt.exists...)
+			m := &Manifest{DisplayName: "Demo", Require: Requirements{Networks: tt.networks}}
+			warnings, err := m.CheckHost()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("advertencias = %q, quiero %d", warnings, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(warnings[i], w) {
+					t.Errorf("advertencia %d = %q, quiero %q", i, warnings[i], w)
+				}
+			}
+			if len(*asked) != len(tt.networks) {
+				t.Errorf("peticiones a Docker = %q", *asked)
+			}
+		})
+	}
+}
+
+// Sin daemon no se inventa que falte la red: se avisa que no se pudo comprobar.
+func TestCheckHostDockerUnavailable(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "no.sock"))
+	m := &Manifest{DisplayName: "Demo", Require: Requirements{Networks: []string{"autohost_net"}}}
+	warnings, err := m.CheckHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "no se pudieron comprobar") {
+		t.Fatalf("advertencias = %q", warnings)
+	}
+}
+
+// Todas las apps embebidas deben tener un app.toml válido.
+func TestCatalogManifestsValid(t *testing.T) {
+	apps, err := Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) == 0 {
+		t.Fatal("el catálogo está vacío")
+	}
+	// Las apps solo pueden pedir la red compartida que crea autohost.
+	for _, m := range apps {
+		for _, n := range m.Require.Networks {
+			if n != infra.AutohostNetwork {
+				t.Errorf("%s requiere la red %q; la red compartida es %q", m.DisplayName, n, infra.AutohostNetwork)
+			}
+		}
+	}
+}
@@ -0,0 +1,669 @@
+package backup
+
+import (
+	"archive/tar"
+	"autohost-cli/internal/helpers/app"
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formas de congelar la app mientras se copian sus datos.
+const (
+	QuiesceStop  = "stop"  // docker compose stop / start
+	QuiescePause = "pause" // docker compose pause / unpause
+	QuiesceNone  = "none"  // copia en caliente
+)
+
+// Tipos de entrada dentro del archivo.
+const (
+	KindFile   = "file"   // docker-compose.yml y .env de la app
+	KindVolume = "volume" // tar de un volumen con nombre
+	KindBind   = "bind"   // tar de un bind mount
+)
+
+const (
+	manifestVersion = 1
+	manifestName    = "manifest.json"
+	archiveExt      = ".tar.gz"
+	idLayout        = "20060102T150405Z"
+
+	defaultHelperImage = "alpine:3.20"
+)
+
+// Entry es un miembro del archivo con su checksum.
+type Entry struct {
+	Name     string   `json:"name"` // ruta dentro del archivo
+	Kind     string   `json:"kind"`
+	Source   string   `json:"source"`           // archivo de la app, volumen Docker o ruta del host
+	Volume   string   `json:"volume,omitempty"` // clave del volumen en el compose
+	Services []string `json:"services,omitempty"`
+	// SingleFile indica un bind mount de un archivo (no de un directorio).
+	SingleFile bool `json:"single_file,omitempty"`
+	// Engine y Database describen un volcado (KindDump); en SQLite Database
+	// es la ruta del archivo dentro del contenedor.
+	Engine   string `json:"engine,omitempty"`
+	Database string `json:"database,omitempty"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// Skipped es un montaje que no se incluyó en el backup.
+type Skipped struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// ArchiveInfo describe el archivo comprimido (solo en el manifiesto externo).
+type ArchiveInfo struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describe un snapshot. Se guarda dentro del archivo (manifest.json)
+// y junto a él (<id>.json) con el checksum del archivo completo.
+type Manifest struct {
+	Version  int          `json:"version"`
+	ID       string       `json:"id"`
+	App      string       `json:"app"`
+	Project  string       `json:"project"`
+	Created  time.Time    `json:"created"`
+	Hostname string       `json:"hostname,omitempty"`
+	Quiesce  string       `json:"quiesce"`
+	Entries  []Entry      `json:"entries"`
+	Skipped  []Skipped    `json:"skipped,omitempty"`
+	Archive  *ArchiveInfo `json:"archive,omitempty"`
+	// Repository indica que el snapshot está en un repositorio y no en un .tar.gz local.
+	Repository *RepoRef `json:"repository,omitempty"`
+}
+
+// Size devuelve el tamaño del archivo comprimido o, en un repositorio, el
+// del tar (0 si no se conoce).
+func (m *Manifest) Size() int64 {
+	switch {
+	case m.Archive != nil:
+		return m.Archive.Size
+	case m.Repository != nil:
+		return m.Repository.Size
+	}
+	return 0
+}
+
+// Location es el archivo local o el repositorio donde está el snapshot.
+func (m *Manifest) Location() string {
+	if m.Repository != nil {
+		return "repo:" + m.Repository.Name
+	}
+	return LocalArchive
+}
+
+// CreateOptions controla `autohost backup create`.
+type CreateOptions struct {
+	Quiesce string // stop | pause | none
+	// Repository es el repositorio destino: "" usa el predeterminado (o un
+	// .tar.gz local si no hay) y LocalArchive fuerza el .tar.gz.
+	Repository string
+}
+
+// Dir es la raíz de los backups (~/.autohost/backups).
+func Dir() string { return utils.GetSubdir("backups") }
+
+// AppDir es el directorio de los snapshots de una app.
+func AppDir(appName string) string { return filepath.Join(Dir(), appName) }
+
+// ArchivePath es el archivo comprimido del snapshot.
+func (m *Manifest) ArchivePath() string {
+	return filepath.Join(AppDir(m.App), m.ID+archiveExt)
+}
+
+// ManifestPath es el manifiesto externo del snapshot.
+func (m *Manifest) ManifestPath() string {
+	return filepath.Join(AppDir(m.App), m.ID+".json")
+}
+
+// helperImage es la imagen usada para leer volúmenes (AUTOHOST_BACKUP_IMAGE).
+func helperImage() string {
+	if img := strings.TrimSpace(os.Getenv("AUTOHOST_BACKUP_IMAGE")); img != "" {
+		return img
+	}
+	return defaultHelperImage
+}
+
+// source es un volumen o bind mount a copiar.
+type source struct {
+	entry Entry
+	mount string // origen para `docker run -v`
+}
+
+// Create respalda una app: compose, .env, volúmenes con nombre y bind mounts.
+// Con QuiesceStop/QuiescePause la app queda detenida o congelada solo
+// mientras se copian los datos.
+func Create(appName string, opts CreateOptions) (*Manifest, error) {
+	switch opts.Quiesce {
+	case "":
+		opts.Quiesce = QuiesceStop
+	case QuiesceStop, QuiescePause, QuiesceNone:
+	default:
+		return nil, fmt.Errorf("modo inválido: %q (usa %s|%s|%s)", opts.Quiesce, QuiesceStop, QuiescePause, QuiesceNone)
+	}
+	inst, err := app.OpenAppInstance(appName)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := inst.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	m := &Manifest{
+		Version: manifestVersion,
+		ID:      now.Format(idLayout),
+		App:     inst.Name,
+		Project: inst.Project,
+		Created: now,
+		Quiesce: opts.Quiesce,
+		Entries: []Entry{},
+	}
+	m.Hostname, _ = os.Hostname()
+	used := map[string]bool{}
+	sources, skipped, err := planSources(inst, cfg, used)
+	if err != nil {
+		return nil, err
+	}
+	m.Skipped = skipped
+	databases := detectDatabases(cfg)
+	repo, err := resolveRepository(opts.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	r := utils.DefaultRunner()
+	if r.DryRun() {
+		up, err := runningSet(inst)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range databases {
+			if !up[t.Service] {
+				continue
+			}
+			if err := inst.Exec(t.Service, nil, io.Discard, "sh", "-c", t.dumpScript()); err != nil {
+				return m, err
+			}
+		}
+		err = withQuiesce(inst, opts.Quiesce, func() error {
+			for _, s := range sources {
+				if err := r.Run(tarCmd(s, io.Discard)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		dest := m.ArchivePath()
+		if repo != nil {
+			dest = "el repositorio " + repo.Config.Name + " (" + repo.Location() + ")"
+		}
+		fmt.Printf("%s escribiría en %s\n", utils.DryRunPrefix, dest)
+		return m, err
+	}
+
+	if err := r.MkdirAll(AppDir(m.App), 0o700); err != nil {
+		return nil, err
+	}
+	if pathExists(m.ArchivePath()) || pathExists(m.ManifestPath()) {
+		return nil, fmt.Errorf("ya existe el snapshot %s de %s", m.ID, m.App)
+	}
+	if repo != nil {
+		fmt.Printf("🔐 Destino: repositorio %s (%s)\n", repo.Config.Name, repo.Location())
+		err = writeRepository(repo, inst, m, sources, databases, used)
+	} else {
+		err = writeArchive(inst, m, sources, databases, used)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := writeManifest(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// planSources arma la lista de volúmenes y bind mounts de los servicios.
+func planSources(inst *app.AppInstance, cfg *app.ComposeConfig, usedNames map[string]bool) ([]*source, []Skipped, error) {
+	var sources []*source
+	var skipped []Skipped
+	byMount := map[string]*source{}
+
+	for _, svc := range cfg.ServiceNames() {
+		for _, mnt := range cfg.Services[svc].Volumes {
+			var s *source
+			switch mnt.Type {
+			case "volume":
+				if mnt.Source == "" {
+					continue // volumen anónimo: datos efímeros
+				}
+				name := mnt.Source
+				if v, ok := cfg.Volumes[mnt.Source]; ok && v.Name != "" {
+					name = v.Name
+				}
+				if s = byMount[name]; s == nil {
+					exists, err := volumeExists(name)
+					if err != nil {
+						return nil, nil, err
+					}
+					if !exists {
+						skipped = append(skipped, Skipped{Source: name, Reason: "el volumen no existe (¿la app nunca se inició?)"})
+						byMount[name] = &source{}
+						continue
+					}
+					s = &source{mount: name, entry: Entry{
+						Name:   uniqueName(usedNames, "volumes/"+sanitize(mnt.Source), ".tar"),
+						Kind:   KindVolume,
+						Source: name,
+						Volume: mnt.Source,
+					}}
+					byMount[name] = s
+					sources = append(sources, s)
+				}
+			case "bind":
+				path := filepath.Clean(mnt.Source)
+				if s = byMount[path]; s == nil {
+					entry, reason := planBind(inst, path)
+					if reason != "" {
+						skipped = append(skipped, Skipped{Source: path, Reason: reason})
+						byMount[path] = &source{}
+						continue
+					}
+					entry.Name = uniqueName(usedNames, entry.Name, ".tar")
+					s = &source{mount: path, entry: entry}
+					byMount[path] = s
+					sources = append(sources, s)
+				}
+			default:
+				continue
+			}
+			if s.mount != "" {
+				s.entry.Services = append(s.entry.Services, svc)
+			}
+		}
+	}
+	return sources, skipped, nil
+}
+
+// systemPaths no se respaldan aunque estén montados (sockets, config del host...).
+var systemPaths = []string{"/proc", "/sys", "/dev", "/run", "/var/run", "/etc", "/var/lib/docker"}
+
+func planBind(inst *app.AppInstance, path string) (Entry, string) {
+	for _, p := range systemPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return Entry{}, "ruta del sistema"
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, "no existe en el host"
+	}
+	if !fi.IsDir() && !fi.Mode().IsRegular() {
+		return Entry{}, "no es un directorio ni un archivo"
+	}
+	name := path
+	if rel, err := filepath.Rel(inst.Dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		name = rel
+	}
+	return Entry{
+		Name:       "binds/" + sanitize(name),
+		Kind:       KindBind,
+		Source:     path,
+		SingleFile: !fi.IsDir(),
+	}, ""
+}
+
+var unsafeNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func sanitize(s string) string {
+	s = strings.Trim(unsafeNameRe.ReplaceAllString(s, "_"), "_.")
+	if s == "" {
+		s = "root"
+	}
+	return s
+}
+
+// uniqueName agrega un sufijo si el nombre ya se usó.
+func uniqueName(used map[string]bool, base, ext string) string {
+	name := base + ext
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	used[name] = true
+	return name
+}
+
+// volumeExists consulta el volumen en la Engine API. Solo un 404 cuenta como
+// inexistente: si Docker no responde se devuelve el error.
+func volumeExists(name string) (bool, error) {
+	dc, err := infra.NewDockerClient()
+	if err != nil {
+		return false, err
+	}
+	if _, err := dc.InspectVolume(name); err != nil {
+		if errors.Is(err, infra.ErrDockerNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// tarCmd lee el volumen o bind mount desde un contenedor auxiliar (los datos
+// suelen pertenecer a los usuarios de los contenedores) y escribe un tar en w.
+func tarCmd(s *source, w io.Writer) utils.Cmd {
+	dst, member := "/data", "."
+	if s.entry.SingleFile {
+		member = filepath.Base(s.mount)
+		dst = "/data/" + member
+	}
+	c := utils.Command("docker", "run", "--rm", "--network", "none",
+		"-v", s.mount+":"+dst+":ro", helperImage(),
+		"tar", "-C", "/data", "-cf", "-", member)
+	c.Stdout = w
+	return c
+}
+
+// withQuiesce detiene o congela los servicios en ejecución mientras corre fn
+// y los reanuda siempre al terminar.
+func withQuiesce(inst *app.AppInstance, mode string, fn func() error) (err error) {
+	if mode == QuiesceNone {
+		return fn()
+	}
+	running, err := inst.RunningServices()
+	if err != nil {
+		return err
+	}
+	if len(running) == 0 {
+		return fn()
+	}
+	if mode == QuiescePause {
+		fmt.Printf("⏸️  Congelando %s...\n", inst.Name)
+		if err := inst.Pause(); err != nil {
+			return err
+		}
+		defer func() {
+			if e := inst.Unpause(); e != nil {
+				err = errors.Join(err, e)
+			}
+		}()
+	} else {
+		fmt.Printf("🛑 Deteniendo %s...\n", inst.Name)
+		if err := inst.Stop(); err != nil {
+			return errors.Join(err, inst.StartServices(running...))
+		}
+		defer func() {
+			fmt.Printf("🔄 Reanudando %s...\n", inst.Name)
+			if e := inst.StartServices(running...); e != nil {
+				err = errors.Join(err, e)
+			}
+		}()
+	}
+	return fn()
+}
+
+// writeArchive genera <id>.tar.gz con el contenido de writeMembers.
+func writeArchive(inst *app.AppInstance, m *Manifest, sources []*source, databases []dbTarget, used map[string]bool) (err error) {
+	dir := AppDir(m.App)
+	f, err := os.CreateTemp(dir, "."+m.ID+"-*.partial")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if err := f.Chmod(0o600); err != nil {
+		return err
+	}
+
+	sum := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(f, sum)}
+	gz := gzip.NewWriter(cw)
+	if err := writeMembers(inst, tar.NewWriter(gz), dir, m, sources, databases, used); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := utils.DefaultRunner().Rename(f.Name(), m.ArchivePath()); err != nil {
+		return err
+	}
+	m.Archive = &ArchiveInfo{
+		File:   filepath.Base(m.ArchivePath()),
+		Size:   cw.n,
+		SHA256: hex.EncodeToString(sum.Sum(nil)),
+	}
+	return nil
+}
+
+// writeMembers escribe el tar del snapshot: compose, .env, volcados, datos
+// y manifest.json al final. Los volcados se hacen con la app en marcha,
+// antes de detenerla o congelarla para copiar los volúmenes. dir aloja los
+// temporales.
+func writeMembers(inst *app.AppInstance, tw *tar.Writer, dir string, m *Manifest, sources []*source, databases []dbTarget, used map[string]bool) error {
+	for _, p := range []struct{ name, path string }{
+		{"docker-compose.yml", inst.ComposeFile},
+		{".env", inst.EnvFile},
+	} {
+		data, err := os.ReadFile(p.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		e := Entry{Name: p.name, Kind: KindFile, Source: p.path}
+		if err := addBytes(tw, &e, data, m.Created); err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, e)
+	}
+
+	if err := dumpDatabases(inst, tw, dir, databases, m, used); err != nil {
+		return err
+	}
+
+	err := withQuiesce(inst, m.Quiesce, func() error {
+		for _, s := range sources {
+			fmt.Printf("📦 Copiando %s...\n", s.entry.Source)
+			err := addOutput(tw, dir, &s.entry, m.Created, func(w io.Writer) error {
+				return utils.DefaultRunner().Run(tarCmd(s, w))
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", s.entry.Source, err)
+			}
+			m.Entries = append(m.Entries, s.entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := addBytes(tw, &Entry{Name: manifestName}, append(data, '\n'), m.Created); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// addOutput vuelca la salida de produce a un temporal (hace falta el tamaño
+// para la cabecera) y la agrega al archivo como e.
+func addOutput(tw *tar.Writer, dir string, e *Entry, mtime time.Time, produce func(w io.Writer) error) error {
+	spool, err := os.CreateTemp(dir, ".spool-*.tar")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	sum := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(spool, sum)}
+	if err := produce(cw); err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	e.Size = cw.n
+	e.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	hdr := &tar.Header{Name: e.Name, Mode: 0o600, Size: cw.n, ModTime: mtime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, spool)
+	return err
+}
+
+func addBytes(tw *tar.Writer, e *Entry, data []byte, mtime time.Time) error {
+	sum := sha256.Sum256(data)
+	e.Size = int64(len(data))
+	e.SHA256 = hex.EncodeToString(sum[:])
+	hdr := &tar.Header{Name: e.Name, Mode: 0o600, Size: e.Size, ModTime: mtime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeManifest(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.DefaultRunner().WriteFile(m.ManifestPath(), append(data, '\n'), 0o600)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// -----------------------------------------------------------------------------
+// Consulta
+// -----------------------------------------------------------------------------
+
+// List devuelve los snapshots (de una app o de todas si appName es ""),
+// más recientes primero. Los manifiestos ilegibles se devuelven como error
+// junto con los válidos.
+func List(appName string) ([]*Manifest, error) {
+	apps := []string{appName}
+	if appName == "" {
+		entries, err := os.ReadDir(Dir())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		apps = apps[:0]
+		for _, e := range entries {
+			if e.IsDir() && !strings.HasPrefix(e.Name(), "_") {
+				apps = append(apps, e.Name())
+			}
+		}
+	}
+
+	var out []*Manifest
+	var errs []error
+	for _, a := range apps {
+		files, _ := filepath.Glob(filepath.Join(AppDir(a), "*.json"))
+		for _, path := range files {
+			m, err := readManifest(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			out = append(out, m)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].App != out[j].App {
+			return out[i].App < out[j].App
+		}
+		return out[i].Created.After(out[j].Created)
+	})
+	return out, errors.Join(errs...)
+}
+
+func readManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.ID == "" || m.App == "" {
+		return nil, fmt.Errorf("%s: manifiesto incompleto", path)
+	}
+	return &m, nil
+}
+
+// Find busca un snapshot de la app por ID (o prefijo único); "" o "latest"
+// devuelve el más reciente.
+func Find(appName, id string) (*Manifest, error) {
+	all, err := List(appName)
+	if len(all) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no hay backups de %s", appName)
+	}
+	if id == "" || id == "latest" {
+		return all[0], nil
+	}
+	var found *Manifest
+	for _, m := range all {
+		if m.ID == id {
+			return m, nil
+		}
+		if strings.HasPrefix(m.ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("el ID %q es ambiguo", id)
+			}
+			found = m
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no existe el snapshot %q de %s", id, appName)
+	}
+	return found, nil
+}
@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"autohost-cli/internal/helpers/app"
+	"autohost-cli/internal/infra"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeDockerVolumes atiende GET /volumes/{name} en un socket unix y apunta
+// DOCKER_HOST a él; solo existen los volúmenes de exists.
+func fakeDockerVolumes(t *testing.T, exists ...string) {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutPrefix(r.URL.Path, "/volumes/")
+		if r.Method != http.MethodGet || !ok {
+			t.Errorf("petición inesperada %s %s", r.Method, r.URL)
+			http.Error(w, `{"message":"no soportado"}`, http.StatusBadRequest)
+			return
+		}
+		for _, v := range exists {
+			if v == name {
+				w.Write([]byte(`{"Name":"` + name + `","Driver":"local"}`))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"get ` + name + `: no such volume"}`))
+	}))
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	t.Setenv("DOCKER_HOST", "unix://"+sock)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanSources(t *testing.T) {
+	fakeDockerVolumes(t, "wiki_data", "compartido")
+	dir := t.TempDir()
+	inst := &app.AppInstance{Name: "wiki", Dir: dir, Project: "wiki"}
+	writeFile(t, filepath.Join(dir, "config", "app.ini"), "x")
+	writeFile(t, filepath.Join(dir, "a b", "x"), "x")
+	writeFile(t, filepath.Join(dir, "a_b", "x"), "x")
+	cfg := &app.ComposeConfig{
+		Services: map[string]app.ComposeService{
+			"app": {Volumes: []app.ComposeMount{
+				{Type: "volume", Source: "data", Target: "/data"},
+				{Type: "volume", Source: "", Target: "/tmp/cache"},
+				{Type: "bind", Source: filepath.Join(dir, "config"), Target: "/config"},
+				{Type: "bind", Source: filepath.Join(dir, "a b"), Target: "/ab1"},
+				{Type: "bind", Source: filepath.Join(dir, "a_b"), Target: "/ab2"},
+				{Type: "bind", Source: "/etc/localtime", Target: "/etc/localtime"},
+				{Type: "tmpfs", Target: "/run"},
+			}},
+			"db": {Volumes: []app.ComposeMount{
+				{Type: "volume", Source: "data", Target: "/var/lib/db"},
+				{Type: "volume", Source: "shared", Target: "/shared"},
+				{Type: "volume", Source: "nunca", Target: "/nunca"},
+				{Type: "bind", Source: filepath.Join(dir, "falta") + "/", Target: "/falta"},
+			}},
+		},
+		Volumes: map[string]app.ComposeVolume{
+			"data":   {Name: "wiki_data"},
+			"shared": {Name: "compartido", External: true},
+			"nunca":  {Name: "wiki_nunca"},
+		},
+	}
+
+	sources, skipped, err := planSources(inst, cfg, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range sources {
+		got = append(got, s.entry.Name+" "+s.entry.Kind+" "+s.mount+" "+strings.Join(s.entry.Services, ","))
+	}
+	want := []string{
+		"volumes/data.tar volume wiki_data app,db",
+		"binds/config.tar bind " + filepath.Join(dir, "config") + " app",
+		"binds/a_b.tar bind " + filepath.Join(dir, "a b") + " app",
+		"binds/a_b-2.tar bind " + filepath.Join(dir, "a_b") + " app",
+		"volumes/shared.tar volume compartido db",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sources =\n%s\nquiero\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	wantSkipped := []Skipped{
+		{Source: "/etc/localtime", Reason: "ruta del sistema"},
+		{Source: "wiki_nunca", Reason: "el volumen no existe (¿la app nunca se inició?)"},
+		{Source: filepath.Join(dir, "falta"), Reason: "no existe en el host"},
+	}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Fatalf("skipped = %+v\nquiero %+v", skipped, wantSkipped)
+	}
+}
+
+// Si Docker no responde no se puede saber si el volumen existe: es un error,
+// no un volumen omitido.
+func TestPlanSourcesDockerUnavailable(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "no-existe.sock"))
+	cfg := &app.ComposeConfig{Services: map[string]app.ComposeService{
+		"app": {Volumes: []app.ComposeMount{{Type: "volume", Source: "data", Target: "/data"}}},
+	}}
+	_, _, err := planSources(&app.AppInstance{Dir: t.TempDir()}, cfg, map[string]bool{})
+	if !errors.Is(err, infra.ErrDockerUnavailable) {
+		t.Fatalf("err = %v, quiero ErrDockerUnavailable", err)
+	}
+}
+
+func TestPlanBind(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(dir, "conf", "settings.json"), "{}")
+	sock := filepath.Join(dir, "app.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	inst := &app.AppInstance{Dir: dir}
+
+	tests := []struct {
+		name   string
+		path   string
+		want   Entry
+		reason string
+	}{
+		{name: "directorio de la app", path: filepath.Join(dir, "conf"), want: Entry{Name: "binds/conf", Kind: KindBind, Source: filepath.Join(dir, "conf")}},
+		{name: "archivo de la app", path: filepath.Join(dir, "conf", "settings.json"), want: Entry{Name: "binds/conf_settings.json", Kind: KindBind, Source: filepath.Join(dir, "conf", "settings.json"), SingleFile: true}},
+		{name: "fuera de la app", path: outside, want: Entry{Name: "binds/" + sanitize(outside), Kind: KindBind, Source: outside}},
+		{name: "el propio directorio", path: dir, want: Entry{Name: "binds/root", Kind: KindBind, Source: dir}},
+		{name: "socket de docker", path: "/var/run/docker.sock", reason: "ruta del sistema"},
+		{name: "/etc", path: "/etc", reason: "ruta del sistema"},
+		{name: "prefijo que no es del sistema", path: "/etcetera-autohost", reason: "no existe en el host"},
+		{name: "socket", path: sock, reason: "no es un directorio ni un archivo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := planBind(inst, tt.path)
+			if reason != tt.reason || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("planBind(%s) = %+v, %q; quiero %+v, %q", tt.path, got, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"errors"
+	"io"
+)
+
+// Tamaños de los fragmentos. Los cortes dependen del contenido (FastCDC con
+// "gear hash"), así que insertar o borrar datos en un volumen solo cambia
+// los fragmentos de alrededor y el resto se deduplica.
+const (
+	chunkMin = 512 << 10
+	chunkAvg = 1 << 20
+	chunkMax = 8 << 20
+
+	// Normalización de FastCDC: antes del tamaño medio se exigen más bits a
+	// cero (cortes menos probables) y después menos.
+	chunkBits = 20 // log2(chunkAvg)
+	maskSmall = uint64(1<<(chunkBits+2)-1) << (64 - (chunkBits + 2))
+	maskLarge = uint64(1<<(chunkBits-2)-1) << (64 - (chunkBits - 2))
+)
+
+// chunker parte un flujo en fragmentos de tamaño variable.
+type chunker struct {
+	r    io.Reader
+	gear [256]uint64
+	buf  []byte
+	n    int // bytes válidos en buf
+	eof  bool
+}
+
+// newChunker usa una tabla derivada de la semilla del repositorio: sin la
+// clave no se pueden predecir los cortes (ni deducir contenido por tamaños).
+func newChunker(r io.Reader, seed uint64) *chunker {
+	c := &chunker{r: r, buf: make([]byte, chunkMax)}
+	for i := range c.gear {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		c.gear[i] = z ^ (z >> 31)
+	}
+	return c
+}
+
+// Next devuelve el siguiente fragmento (una copia) o io.EOF al terminar.
+func (c *chunker) Next() ([]byte, error) {
+	if !c.eof && c.n < len(c.buf) {
+		m, err := io.ReadFull(c.r, c.buf[c.n:])
+		c.n += m
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			c.eof = true
+		case err != nil:
+			return nil, err
+		}
+	}
+	if c.n == 0 {
+		return nil, io.EOF
+	}
+	cut := c.cut(c.buf[:c.n])
+	out := make([]byte, cut)
+	copy(out, c.buf[:cut])
+	c.n = copy(c.buf, c.buf[cut:c.n])
+	return out, nil
+}
+
+// cut busca el punto de corte en b (como mucho chunkMax bytes).
+func (c *chunker) cut(b []byte) int {
+	if len(b) <= chunkMin {
+		return len(b)
+	}
+	n := min(len(b), chunkMax)
+	var h uint64
+	i := chunkMin
+	for ; i < n && i < chunkAvg; i++ {
+		h = h<<1 + c.gear[b[i]]
+		if h&maskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		h = h<<1 + c.gear[b[i]]
+		if h&maskLarge == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
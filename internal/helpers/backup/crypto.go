@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+)
+
+// Formato de los objetos cifrados del repositorio:
+//
+//	nonce (12 bytes) || AES-256-GCM(flag || datos)
+//
+// donde flag indica si los datos van comprimidos con DEFLATE. La
+// autenticación de GCM detecta cualquier alteración del objeto.
+const (
+	objectRaw      = 0
+	objectDeflated = 1
+
+	// pbkdf2Iterations es el coste por defecto de derivar la clave desde la
+	// frase (recomendación de OWASP para PBKDF2-HMAC-SHA256).
+	pbkdf2Iterations = 600_000
+)
+
+var errDecrypt = errors.New("no se pudo descifrar (clave incorrecta o datos alterados)")
+
+// masterKey son las claves de un repositorio. Se guardan cifradas en
+// keys/<id> con la frase o la clave aleatoria del almacén de secretos.
+type masterKey struct {
+	Encrypt     []byte `json:"encrypt"`      // AES-256
+	MAC         []byte `json:"mac"`          // HMAC-SHA256 de los IDs de fragmentos
+	ChunkerSeed uint64 `json:"chunker_seed"` // semilla de la tabla del chunker
+}
+
+func newMasterKey() (*masterKey, error) {
+	k := &masterKey{Encrypt: make([]byte, 32), MAC: make([]byte, 32)}
+	var seed [8]byte
+	for _, b := range [][]byte{k.Encrypt, k.MAC, seed[:]} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+	k.ChunkerSeed = binary.LittleEndian.Uint64(seed[:])
+	return k, nil
+}
+
+// chunkID identifica un fragmento por su contenido. Es un HMAC (y no un
+// sha256 simple) para que el repositorio no revele qué archivos contiene.
+func (k *masterKey) chunkID(data []byte) string {
+	h := hmac.New(sha256.New, k.MAC)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sealObject comprime (si reduce el tamaño) y cifra un objeto.
+func sealObject(key, data []byte) ([]byte, error) {
+	payload := append([]byte{objectRaw}, data...)
+	var buf bytes.Buffer
+	buf.WriteByte(objectDeflated)
+	zw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() < len(payload) {
+		payload = buf.Bytes()
+	}
+	return seal(key, payload)
+}
+
+// openObject descifra y descomprime un objeto de sealObject.
+func openObject(key, obj []byte) ([]byte, error) {
+	payload, err := open(key, obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, errDecrypt
+	}
+	switch payload[0] {
+	case objectRaw:
+		return payload[1:], nil
+	case objectDeflated:
+		return io.ReadAll(flate.NewReader(bytes.NewReader(payload[1:])))
+	}
+	return nil, errors.New("formato de objeto desconocido")
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, obj []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(obj) < aead.NonceSize()+aead.Overhead() {
+		return nil, errDecrypt
+	}
+	n := aead.NonceSize()
+	out, err := aead.Open(nil, obj[:n], obj[n:], nil)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return out, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 implementa PBKDF2 (RFC 8018) con HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var out []byte
+	for block := uint32(1); len(out) < keyLen; block++ {
+		out = append(out, pbkdf2Block(prf, salt, iterations, block)...)
+	}
+	return out[:keyLen]
+}
+
+func pbkdf2Block(prf hash.Hash, salt []byte, iterations int, block uint32) []byte {
+	prf.Reset()
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, block))
+	u := prf.Sum(nil)
+	t := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range t {
+			t[j] ^= u[j]
+		}
+	}
+	return t
+}
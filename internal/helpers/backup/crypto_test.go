@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Vectores de PBKDF2-HMAC-SHA256 de RFC 7914, sección 11.
+func TestPBKDF2SHA256RFC7914(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iterations, 64))
+		if got != tt.want {
+			t.Errorf("PBKDF2(%q, %q, %d) =\n%s\nquiero\n%s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+	// Una clave más corta es el prefijo de la larga.
+	if got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 32)); got != tests[0].want[:64] {
+		t.Errorf("PBKDF2 de 32 bytes = %s", got)
+	}
+}
+
+func TestSealOpenObject(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"vacío", nil},
+		{"comprimible", bytes.Repeat([]byte("autohost "), 10000)},
+		{"aleatorio", []byte{0x8b, 0x1f, 0x00, 0xff, 0x42}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := sealObject(key, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := openObject(key, obj)
+			if err != nil || !bytes.Equal(got, tt.data) {
+				t.Fatalf("openObject = %q, %v", got, err)
+			}
+			obj[len(obj)-1] ^= 1
+			if _, err := openObject(key, obj); !errors.Is(err, errDecrypt) {
+				t.Fatalf("objeto alterado: err = %v", err)
+			}
+			if _, err := openObject(bytes.Repeat([]byte{8}, 32), obj); !errors.Is(err, errDecrypt) {
+				t.Fatalf("otra clave: err = %v", err)
+			}
+		})
+	}
+}
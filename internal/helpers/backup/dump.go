@@ -0,0 +1,375 @@
+package backup
+
+import (
+	"archive/tar"
+	"autohost-cli/internal/helpers/app"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Motores de base de datos con volcado lógico.
+const (
+	EngineMySQL    = "mysql" // MariaDB y MySQL
+	EnginePostgres = "postgres"
+	EngineRedis    = "redis"
+	EngineSQLite   = "sqlite"
+)
+
+// KindDump es el volcado lógico de una base de datos.
+const KindDump = "dump"
+
+// sqliteLabel declara bases SQLite de un servicio (rutas del contenedor
+// separadas por comas); las que están en bind mounts se detectan solas.
+const sqliteLabel = "dev.autohost.backup.sqlite"
+
+// dbTarget es una base de datos a volcar dentro del contenedor de su servicio.
+type dbTarget struct {
+	Service  string
+	Engine   string
+	Database string // mysql/postgres: base ("" = todas); sqlite: ruta en el contenedor
+	svc      app.ComposeService
+}
+
+// detectDatabases reconoce las bases de datos del compose por imagen y,
+// para SQLite, por la etiqueta sqliteLabel o la cabecera de los archivos.
+func detectDatabases(cfg *app.ComposeConfig) []dbTarget {
+	var out []dbTarget
+	for _, name := range cfg.ServiceNames() {
+		svc := cfg.Services[name]
+		switch engine := engineForImage(svc.Image); engine {
+		case EngineMySQL:
+			out = append(out, dbTarget{Service: name, Engine: engine, Database: firstEnv(svc, "MARIADB_DATABASE", "MYSQL_DATABASE"), svc: svc})
+		case EnginePostgres:
+			user := firstEnv(svc, "POSTGRES_USER")
+			if user == "" {
+				user = "postgres"
+			}
+			db := firstEnv(svc, "POSTGRES_DB")
+			if db == "" {
+				db = user
+			}
+			out = append(out, dbTarget{Service: name, Engine: engine, Database: db, svc: svc})
+		case EngineRedis:
+			out = append(out, dbTarget{Service: name, Engine: engine, svc: svc})
+		}
+
+		seen := map[string]bool{}
+		for _, p := range strings.Split(svc.Labels[sqliteLabel], ",") {
+			if p = strings.TrimSpace(p); p != "" && !seen[p] {
+				seen[p] = true
+				out = append(out, dbTarget{Service: name, Engine: EngineSQLite, Database: p, svc: svc})
+			}
+		}
+		for _, m := range svc.Volumes {
+			if m.Type != "bind" {
+				continue
+			}
+			for _, p := range findSQLite(m.Source, m.Target) {
+				if !seen[p] {
+					seen[p] = true
+					out = append(out, dbTarget{Service: name, Engine: EngineSQLite, Database: p, svc: svc})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// engineForImage deduce el motor por el nombre de la imagen (sin registro ni tag).
+func engineForImage(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	name := path.Base(image)
+	name, _, _ = strings.Cut(name, ":")
+	if strings.Contains(name, "exporter") {
+		return ""
+	}
+	for _, p := range []struct{ prefix, engine string }{
+		{"mariadb", EngineMySQL}, {"mysql", EngineMySQL}, {"percona", EngineMySQL},
+		{"postgres", EnginePostgres}, {"postgis", EnginePostgres}, {"timescaledb", EnginePostgres},
+		{"redis", EngineRedis}, {"valkey", EngineRedis}, {"keydb", EngineRedis},
+	} {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.engine
+		}
+	}
+	return ""
+}
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// findSQLite busca bases SQLite en un bind mount (hasta 3 niveles) y
+// devuelve sus rutas dentro del contenedor.
+func findSQLite(hostPath, target string) []string {
+	var out []string
+	fi, err := os.Stat(hostPath)
+	if err != nil {
+		return nil
+	}
+	if !fi.IsDir() {
+		if isSQLite(hostPath) {
+			out = append(out, target)
+		}
+		return out
+	}
+	_ = filepath.WalkDir(hostPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(hostPath, p)
+		if d.IsDir() {
+			if strings.Count(rel, string(filepath.Separator)) >= 3 {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && len(out) < 20 && isSQLite(p) {
+			out = append(out, path.Join(target, filepath.ToSlash(rel)))
+		}
+		return nil
+	})
+	return out
+}
+
+func isSQLite(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(sqliteMagic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, sqliteMagic)
+}
+
+// entry arma la entrada del volcado dentro del archivo.
+func (t dbTarget) entry(used map[string]bool) Entry {
+	base, ext := "dumps/"+sanitize(t.Service), ".sql"
+	switch t.Engine {
+	case EngineRedis:
+		ext = ".rdb"
+	case EngineSQLite:
+		base += "-" + sanitize(path.Base(t.Database))
+	}
+	return Entry{
+		Name:     uniqueName(used, base, ext),
+		Kind:     KindDump,
+		Source:   t.Service + " (" + t.Engine + ")",
+		Services: []string{t.Service},
+		Engine:   t.Engine,
+		Database: t.Database,
+	}
+}
+
+// dumpScript es el script de volcado que corre dentro del contenedor. Las
+// credenciales se leen del entorno del contenedor (que compose arma desde
+// el .env de la app), así nunca aparecen en la línea de comandos del host.
+func (t dbTarget) dumpScript() string {
+	switch t.Engine {
+	case EngineMySQL:
+		user, pwd := mysqlCredentials(t.svc)
+		args := "--single-transaction --quick --routines --triggers"
+		if user == "root" {
+			args += " --events"
+		}
+		if t.Database != "" {
+			args += " --databases " + shq(t.Database)
+		} else {
+			args += " --all-databases"
+		}
+		return pwd + `dump=$(command -v mariadb-dump || command -v mysqldump); exec "$dump" -u` + user + " " + args
+	case EnginePostgres:
+		return `export PGPASSWORD="$POSTGRES_PASSWORD"; exec pg_dump -U ` + shq(postgresUser(t.svc)) +
+			" -d " + shq(t.Database) + " --clean --if-exists --no-owner"
+	case EngineRedis:
+		return redisAuth + `redis-cli SAVE >/dev/null || exit 1; ` + redisFile + `cat "$dir/$file"`
+	case EngineSQLite:
+		return `command -v sqlite3 >/dev/null 2>&1 || exit 127; exec sqlite3 -readonly ` + shq(t.Database) + " .dump"
+	}
+	return "exit 1"
+}
+
+// restoreScript importa el volcado (recibido por stdin) en el contenedor.
+func (t dbTarget) restoreScript() string {
+	switch t.Engine {
+	case EngineMySQL:
+		user, pwd := mysqlCredentials(t.svc)
+		return pwd + `cli=$(command -v mariadb || command -v mysql); exec "$cli" -u` + user
+	case EnginePostgres:
+		return `export PGPASSWORD="$POSTGRES_PASSWORD"; exec psql -q -v ON_ERROR_STOP=1 -U ` + shq(postgresUser(t.svc)) + " -d " + shq(t.Database)
+	case EngineRedis:
+		// Redis solo carga el RDB al arrancar: se reemplaza y se apaga sin
+		// guardar (en segundo plano, para que exec termine antes que el contenedor).
+		return redisAuth + redisFile + `cat > "$dir/$file.autohost" && mv "$dir/$file.autohost" "$dir/$file" && ` +
+			`{ (sleep 1; redis-cli SHUTDOWN NOSAVE) >/dev/null 2>&1 & }`
+	case EngineSQLite:
+		p := shq(t.Database)
+		return `command -v sqlite3 >/dev/null 2>&1 || exit 127; rm -f ` + p + " " + shq(t.Database+"-wal") + " " + shq(t.Database+"-shm") + "; exec sqlite3 " + p
+	}
+	return "exit 1"
+}
+
+const (
+	redisAuth = `[ -n "$REDIS_PASSWORD" ] && export REDISCLI_AUTH="$REDIS_PASSWORD"; `
+	redisFile = `dir=$(redis-cli --raw CONFIG GET dir 2>/dev/null | sed -n 2p); file=$(redis-cli --raw CONFIG GET dbfilename 2>/dev/null | sed -n 2p); ` +
+		`dir=${dir:-/data}; file=${file:-dump.rdb}; `
+)
+
+// mysqlCredentials elige root si el .env define su contraseña y, si no, el
+// usuario de la app. Devuelve el usuario (literal o variable) y el export de MYSQL_PWD.
+func mysqlCredentials(svc app.ComposeService) (user, pwdExport string) {
+	for _, k := range []string{"MARIADB_ROOT_PASSWORD", "MYSQL_ROOT_PASSWORD"} {
+		if svc.Env(k) != "" {
+			return "root", `export MYSQL_PWD="$` + k + `"; `
+		}
+	}
+	for _, p := range []struct{ user, pwd string }{
+		{"MARIADB_USER", "MARIADB_PASSWORD"},
+		{"MYSQL_USER", "MYSQL_PASSWORD"},
+	} {
+		if svc.Env(p.user) != "" {
+			return `"$` + p.user + `"`, `export MYSQL_PWD="$` + p.pwd + `"; `
+		}
+	}
+	return "root", ""
+}
+
+func postgresUser(svc app.ComposeService) string {
+	if u := svc.Env("POSTGRES_USER"); u != "" {
+		return u
+	}
+	return "postgres"
+}
+
+func firstEnv(svc app.ComposeService, keys ...string) string {
+	for _, k := range keys {
+		if v := svc.Env(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// shq pone comillas simples de shell.
+func shq(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dumpDatabases vuelca las bases de los servicios en ejecución al archivo.
+// Un motor que falla aborta el backup; SQLite sin sqlite3 en la imagen solo
+// se omite (el archivo ya va en la copia del volumen o bind mount).
+func dumpDatabases(inst *app.AppInstance, tw *tar.Writer, dir string, targets []dbTarget, m *Manifest, used map[string]bool) error {
+	up, err := runningSet(inst)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		if !up[t.Service] {
+			m.Skipped = append(m.Skipped, Skipped{Source: t.Service + " (" + t.Engine + ")", Reason: "servicio detenido; se usan los archivos copiados"})
+			continue
+		}
+		e := t.entry(used)
+		fmt.Printf("🗄️  Volcando %s...\n", e.Source)
+		err := addOutput(tw, dir, &e, m.Created, func(w io.Writer) error {
+			return inst.Exec(t.Service, strings.NewReader(""), w, "sh", "-c", t.dumpScript())
+		})
+		var exitErr *exec.ExitError
+		if t.Engine == EngineSQLite && errors.As(err, &exitErr) && exitErr.ExitCode() == 127 {
+			m.Skipped = append(m.Skipped, Skipped{Source: t.Database, Reason: "la imagen no incluye sqlite3; se usa la copia del archivo"})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("volcado de %s: %w", e.Source, err)
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	return nil
+}
+
+func runningSet(inst *app.AppInstance) (map[string]bool, error) {
+	running, err := inst.RunningServices()
+	if err != nil {
+		return nil, err
+	}
+	up := map[string]bool{}
+	for _, s := range running {
+		up[s] = true
+	}
+	return up, nil
+}
+
+// readyScript termina con éxito cuando el motor acepta conexiones.
+func (t dbTarget) readyScript() string {
+	switch t.Engine {
+	case EngineMySQL:
+		user, pwd := mysqlCredentials(t.svc)
+		return pwd + `cli=$(command -v mariadb-admin || command -v mysqladmin); exec "$cli" -u` + user + " ping"
+	case EnginePostgres:
+		return "exec pg_isready -q -U " + shq(postgresUser(t.svc)) + " -d " + shq(t.Database)
+	case EngineRedis:
+		return redisAuth + "redis-cli ping | grep -q PONG"
+	}
+	return "true"
+}
+
+// offline indica si el volcado se importa con la app detenida (SQLite, en un
+// contenedor efímero) o con el servidor de base de datos en marcha.
+func (e Entry) offline() bool { return e.Engine == EngineSQLite }
+
+// dumpTarget reconstruye el destino de un volcado con el compose actual.
+func dumpTarget(cfg *app.ComposeConfig, e Entry) (dbTarget, error) {
+	if len(e.Services) == 0 {
+		return dbTarget{}, fmt.Errorf("%s: el volcado no indica su servicio", e.Name)
+	}
+	svc, ok := cfg.Services[e.Services[0]]
+	if !ok {
+		return dbTarget{}, fmt.Errorf("%s: el servicio %s no existe en el compose", e.Name, e.Services[0])
+	}
+	return dbTarget{Service: e.Services[0], Engine: e.Engine, Database: e.Database, svc: svc}, nil
+}
+
+// waitReady espera a que el servidor de base de datos acepte conexiones.
+func waitReady(inst *app.AppInstance, t dbTarget, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := inst.ExecOutput(t.Service, "sh", "-c", t.readyScript())
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s no está listo tras %s: %w", t.Service, timeout, err)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// importDump carga un volcado del archivo con el cliente del motor (mysql,
+// psql, sqlite3) o reemplazando el RDB de Redis.
+func importDump(inst *app.AppInstance, cfg *app.ComposeConfig, e Entry, r io.Reader) error {
+	t, err := dumpTarget(cfg, e)
+	if err != nil {
+		return err
+	}
+	if e.offline() {
+		err = inst.RunOneOff(t.Service, r, io.Discard, "sh", "-c", t.restoreScript())
+	} else {
+		err = inst.Exec(t.Service, r, io.Discard, "sh", "-c", t.restoreScript())
+	}
+	if err != nil {
+		return fmt.Errorf("importando %s: %w", e.Name, err)
+	}
+	return nil
+}
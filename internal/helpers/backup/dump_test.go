@@ -0,0 +1,180 @@
+package backup
+
+import (
+	"autohost-cli/internal/helpers/app"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func env(kv ...string) map[string]*string {
+	m := map[string]*string{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := kv[i+1]
+		m[kv[i]] = &v
+	}
+	return m
+}
+
+func TestEngineForImage(t *testing.T) {
+	tests := map[string]string{
+		"mariadb:11":                          EngineMySQL,
+		"lscr.io/linuxserver/mariadb:latest":  EngineMySQL,
+		"mysql":                               EngineMySQL,
+		"percona/percona-server:8.0":          EngineMySQL,
+		"postgres:16-alpine":                  EnginePostgres,
+		"postgis/postgis:16-3.4":              EnginePostgres,
+		"timescale/timescaledb:latest-pg16":   EnginePostgres,
+		"redis:7@sha256:0123abcd":             EngineRedis,
+		"valkey/valkey:8":                     EngineRedis,
+		"eqalpha/keydb":                       EngineRedis,
+		"prom/mysqld-exporter":                "",
+		"oliver006/redis_exporter:v1.62":      "",
+		"nextcloud:29":                        "",
+		"registry.example.com:5000/app:mysql": "",
+		"":                                    "",
+	}
+	for image, want := range tests {
+		if got := engineForImage(image); got != want {
+			t.Errorf("engineForImage(%q) = %q, quiero %q", image, got, want)
+		}
+	}
+}
+
+func TestDetectDatabases(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "data", "app.db"), "SQLite format 3\x00resto")
+	writeFile(t, filepath.Join(dir, "data", "notas.txt"), "SQLite format 3 pero sin el nulo")
+	writeFile(t, filepath.Join(dir, "data", "a", "b", "c", "d", "hondo.db"), "SQLite format 3\x00")
+	writeFile(t, filepath.Join(dir, "solo.sqlite"), "SQLite format 3\x00")
+	if err := os.Mkdir(filepath.Join(dir, "vacio"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &app.ComposeConfig{Services: map[string]app.ComposeService{
+		"db":    {Image: "mariadb:11", Environment: env("MARIADB_DATABASE", "wiki")},
+		"mysql": {Image: "mysql:8", Environment: env("MYSQL_DATABASE", "app")},
+		"pg":    {Image: "postgres:16", Environment: env("POSTGRES_USER", "nc")},
+		"pg2":   {Image: "postgres:16", Environment: env("POSTGRES_DB", "datos")},
+		"cache": {Image: "redis:7"},
+		"web": {
+			Image:  "ghcr.io/example/web:1",
+			Labels: map[string]string{sqliteLabel: "/config/app.db, /var/extra.db,/config/app.db"},
+			Volumes: []app.ComposeMount{
+				{Type: "bind", Source: filepath.Join(dir, "data"), Target: "/config"},
+				{Type: "bind", Source: filepath.Join(dir, "solo.sqlite"), Target: "/solo.sqlite"},
+				{Type: "bind", Source: filepath.Join(dir, "vacio"), Target: "/vacio"},
+				{Type: "volume", Source: "data", Target: "/var"},
+			},
+		},
+		"exporter": {Image: "prom/postgres-exporter"},
+	}}
+	var got []string
+	for _, d := range detectDatabases(cfg) {
+		got = append(got, d.Service+" "+d.Engine+" "+d.Database)
+	}
+	want := []string{
+		"cache redis ",
+		"db mysql wiki",
+		"mysql mysql app",
+		"pg postgres nc",
+		"pg2 postgres datos",
+		"web sqlite /config/app.db",
+		"web sqlite /var/extra.db",
+		"web sqlite /solo.sqlite",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("detectDatabases =\n%s\nquiero\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMySQLScripts(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]*string
+		database   string
+		wantUser   string
+		wantPwd    string
+		wantDump   string // argumentos de mysqldump
+		wantImport string
+	}{
+		{
+			name:     "root de MariaDB",
+			env:      env("MARIADB_ROOT_PASSWORD", "r", "MARIADB_USER", "wiki", "MARIADB_PASSWORD", "w"),
+			database: "wiki",
+			wantUser: "root",
+			wantPwd:  `export MYSQL_PWD="$MARIADB_ROOT_PASSWORD"; `,
+			wantDump: `-uroot --single-transaction --quick --routines --triggers --events --databases 'wiki'`,
+		},
+		{
+			name:     "root de MySQL, todas las bases",
+			env:      env("MYSQL_ROOT_PASSWORD", "r"),
+			wantUser: "root",
+			wantPwd:  `export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"; `,
+			wantDump: `-uroot --single-transaction --quick --routines --triggers --events --all-databases`,
+		},
+		{
+			name:     "usuario de la app (sin --events)",
+			env:      env("MARIADB_USER", "wiki", "MARIADB_PASSWORD", "w"),
+			database: "it's",
+			wantUser: `"$MARIADB_USER"`,
+			wantPwd:  `export MYSQL_PWD="$MARIADB_PASSWORD"; `,
+			wantDump: `-u"$MARIADB_USER" --single-transaction --quick --routines --triggers --databases 'it'\''s'`,
+		},
+		{
+			name:     "usuario de MySQL",
+			env:      env("MYSQL_USER", "app", "MYSQL_PASSWORD", "p", "MYSQL_ROOT_PASSWORD", ""),
+			database: "app",
+			wantUser: `"$MYSQL_USER"`,
+			wantPwd:  `export MYSQL_PWD="$MYSQL_PASSWORD"; `,
+			wantDump: `-u"$MYSQL_USER" --single-transaction --quick --routines --triggers --databases 'app'`,
+		},
+		{
+			name:     "sin credenciales",
+			wantUser: "root",
+			wantDump: `-uroot --single-transaction --quick --routines --triggers --events --all-databases`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := app.ComposeService{Image: "mariadb:11", Environment: tt.env}
+			user, pwd := mysqlCredentials(svc)
+			if user != tt.wantUser || pwd != tt.wantPwd {
+				t.Fatalf("mysqlCredentials = %q, %q; quiero %q, %q", user, pwd, tt.wantUser, tt.wantPwd)
+			}
+			target := dbTarget{Service: "db", Engine: EngineMySQL, Database: tt.database, svc: svc}
+			wantDump := tt.wantPwd + `dump=$(command -v mariadb-dump || command -v mysqldump); exec "$dump" ` + tt.wantDump
+			if got := target.dumpScript(); got != wantDump {
+				t.Fatalf("dumpScript =\n%s\nquiero\n%s", got, wantDump)
+			}
+			wantImport := tt.wantPwd + `cli=$(command -v mariadb || command -v mysql); exec "$cli" -u` + tt.wantUser
+			if got := target.restoreScript(); got != wantImport {
+				t.Fatalf("restoreScript =\n%s\nquiero\n%s", got, wantImport)
+			}
+		})
+	}
+}
+
+// Ningún script lleva contraseñas literales: se leen del entorno del contenedor.
+func TestDumpScriptsDoNotLeakSecrets(t *testing.T) {
+	svcs := map[string]app.ComposeService{
+		EngineMySQL:    {Environment: env("MARIADB_ROOT_PASSWORD", "s3cr3t-root")},
+		EnginePostgres: {Environment: env("POSTGRES_USER", "nc", "POSTGRES_PASSWORD", "s3cr3t-pg")},
+		EngineRedis:    {Environment: env("REDIS_PASSWORD", "s3cr3t-redis")},
+		EngineSQLite:   {},
+	}
+	for engine, svc := range svcs {
+		target := dbTarget{Service: "db", Engine: engine, Database: "/data/app.db", svc: svc}
+		for _, script := range []string{target.dumpScript(), target.restoreScript()} {
+			if strings.Contains(script, "s3cr3t") || script == "exit 1" {
+				t.Errorf("%s: script = %q", engine, script)
+			}
+		}
+	}
+	pg := dbTarget{Engine: EnginePostgres, Database: "nc", svc: svcs[EnginePostgres]}
+	if want := `export PGPASSWORD="$POSTGRES_PASSWORD"; exec pg_dump -U 'nc' -d 'nc' --clean --if-exists --no-owner`; pg.dumpScript() != want {
+		t.Errorf("pg dumpScript = %q", pg.dumpScript())
+	}
+}
@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"autohost-cli/utils"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockStale es la edad a partir de la cual un bloqueo de otro servidor se
+// da por abandonado (el proceso murió sin borrarlo).
+const lockStale = 24 * time.Hour
+
+// repoLock es un objeto locks/<id> del repositorio. `backup create` toma uno
+// compartido y `prune` uno exclusivo: prune borra los fragmentos que no usa
+// ningún snapshot, y un backup en curso usa fragmentos que aún no aparecen
+// en ningún snapshot.
+type repoLock struct {
+	Exclusive bool      `json:"exclusive"`
+	Hostname  string    `json:"hostname"`
+	PID       int       `json:"pid"`
+	Created   time.Time `json:"created"`
+}
+
+// stale indica si el bloqueo ya no tiene dueño: es muy antiguo o lo tomó un
+// proceso de este servidor que ya no existe.
+func (l *repoLock) stale() bool {
+	if time.Since(l.Created) > lockStale {
+		return true
+	}
+	host, _ := os.Hostname()
+	return l.Hostname == host && !processAlive(l.PID)
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// lock bloquea el repositorio y devuelve la función que lo libera. Tras
+// guardar el bloqueo se vuelve a comprobar: si dos procesos lo toman a la
+// vez, al menos uno lo ve y se retira.
+func (r *Repository) lock(exclusive bool) (func() error, error) {
+	if err := r.checkLocks(exclusive, ""); err != nil {
+		return nil, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	name := "locks/" + id
+	if err := saveJSON(r.backend, name, repoLock{Exclusive: exclusive, Hostname: host, PID: os.Getpid(), Created: time.Now().UTC()}); err != nil {
+		return nil, err
+	}
+	if err := r.backend.Flush(); err != nil {
+		return nil, err
+	}
+	release := func() error { return r.backend.Delete(name) }
+	if err := r.checkLocks(exclusive, id); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// checkLocks falla si otro bloqueo vigente es incompatible con el pedido.
+func (r *Repository) checkLocks(exclusive bool, own string) error {
+	ids, err := r.backend.List("locks")
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id == own {
+			continue
+		}
+		var l repoLock
+		if err := loadJSON(r.backend, "locks/"+id, &l); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // se liberó mientras tanto
+			}
+			return fmt.Errorf("%w (bórralo con: autohost backup repo unlock %s)", err, r.Config.Name)
+		}
+		if l.stale() || !(exclusive || l.Exclusive) {
+			continue
+		}
+		what := "un backup"
+		if l.Exclusive {
+			what = "un prune"
+		}
+		return fmt.Errorf("el repositorio %s está bloqueado por %s en %s (pid %d, desde %s); si ese proceso ya no existe, ejecuta: autohost backup repo unlock %s",
+			r.Config.Name, what, l.Hostname, l.PID, l.Created.Local().Format("2006-01-02 15:04"), r.Config.Name)
+	}
+	return nil
+}
+
+// RemoveLocks borra los bloqueos abandonados del repositorio, o todos con
+// all (solo si ningún otro proceso lo está usando).
+func RemoveLocks(name string, all bool) (int, error) {
+	repo, err := OpenRepository(name)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := repo.backend.List("locks")
+	if err != nil {
+		return 0, err
+	}
+	var remove []string
+	for _, id := range ids {
+		var l repoLock
+		err := loadJSON(repo.backend, "locks/"+id, &l)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		// Un bloqueo ilegible no protege nada: se borra igual.
+		if all || err != nil || l.stale() {
+			remove = append(remove, "locks/"+id)
+		}
+	}
+	if utils.DefaultRunner().DryRun() {
+		return len(remove), nil
+	}
+	if err := repo.backend.Delete(remove...); err != nil {
+		return 0, err
+	}
+	return len(remove), nil
+}
@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// holdLock guarda un bloqueo ajeno en el repositorio.
+func holdLock(t *testing.T, repo *Repository, id string, l repoLock) {
+	t.Helper()
+	if err := saveJSON(repo.backend, "locks/"+id, l); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// deadPID devuelve el pid de un proceso que ya terminó.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("sin el comando true:", err)
+	}
+	return cmd.Process.Pid
+}
+
+// openedRepo deja repo abierto con su nombre para las funciones que llaman
+// a OpenRepository.
+func openedRepo(t *testing.T, repo *Repository) {
+	opened[repo.Config.Name] = repo
+	t.Cleanup(func() { delete(opened, repo.Config.Name) })
+}
+
+func TestRepositoryLock(t *testing.T) {
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	live := func(exclusive bool) repoLock {
+		return repoLock{Exclusive: exclusive, Hostname: host, PID: os.Getpid(), Created: now}
+	}
+	tests := []struct {
+		name      string
+		held      []repoLock
+		exclusive bool
+		wantErr   string
+	}{
+		{name: "libre, compartido", exclusive: false},
+		{name: "libre, exclusivo", exclusive: true},
+		{name: "dos backups a la vez", held: []repoLock{live(false)}},
+		{name: "prune durante un backup", held: []repoLock{live(false)}, exclusive: true, wantErr: "bloqueado por un backup"},
+		{name: "backup durante un prune", held: []repoLock{live(true)}, wantErr: "bloqueado por un prune"},
+		{name: "dos prunes", held: []repoLock{live(true)}, exclusive: true, wantErr: "bloqueado por un prune"},
+		{name: "de otro servidor, reciente", held: []repoLock{{Exclusive: true, Hostname: "otro", PID: 1, Created: now}}, wantErr: "en otro (pid 1"},
+		{name: "de otro servidor, antiguo", held: []repoLock{{Exclusive: true, Hostname: "otro", PID: 1, Created: now.Add(-lockStale - time.Hour)}}, exclusive: true},
+		{name: "proceso muerto de este servidor", held: []repoLock{{Exclusive: false, Hostname: host, PID: deadPID(t), Created: now}}, exclusive: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, dir := newTestRepo(t)
+			for i, l := range tt.held {
+				holdLock(t, repo, "ajeno"+strconv.Itoa(i), l)
+			}
+			release, err := repo.lock(tt.exclusive)
+			locks, _ := repo.backend.List("locks")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, quiero %q", err, tt.wantErr)
+				}
+				if len(locks) != len(tt.held) {
+					t.Fatalf("bloqueos = %v, el rechazado no se borró", locks)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(locks) != len(tt.held)+1 {
+				t.Fatalf("bloqueos = %v", locks)
+			}
+			if err := release(); err != nil {
+				t.Fatal(err)
+			}
+			if entries, _ := os.ReadDir(filepath.Join(dir, "locks")); len(entries) != len(tt.held) {
+				t.Fatalf("tras liberar quedan %d bloqueos", len(entries))
+			}
+		})
+	}
+}
+
+// Un prune no borra nada mientras un backup tiene el repositorio bloqueado.
+func TestForgetSnapshotsWaitsForBackup(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	openedRepo(t, repo)
+	id, res := backupBytes(t, repo, testData(5, 2<<20))
+	release, err := repo.lock(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := forgetSnapshots(repo.Config.Name, []string{id}); err == nil || !strings.Contains(err.Error(), "bloqueado") {
+		t.Fatalf("err = %v, quiero el repositorio bloqueado", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "snapshots", id)); err != nil {
+		t.Fatalf("el snapshot se borró con el repositorio bloqueado: %v", err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := forgetSnapshots(repo.Config.Name, []string{id})
+	if err != nil || n != len(res.Chunks) {
+		t.Fatalf("forgetSnapshots = %d, %v; quiero %d", n, err, len(res.Chunks))
+	}
+	if locks, _ := repo.backend.List("locks"); len(locks) != 0 {
+		t.Fatalf("prune dejó bloqueos: %v", locks)
+	}
+}
+
+func TestRemoveLocks(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	openedRepo(t, repo)
+	host, _ := os.Hostname()
+	holdLock(t, repo, "vigente", repoLock{Hostname: host, PID: os.Getpid(), Created: time.Now().UTC()})
+	holdLock(t, repo, "muerto", repoLock{Exclusive: true, Hostname: host, PID: deadPID(t), Created: time.Now().UTC()})
+	if err := os.WriteFile(filepath.Join(dir, "locks", "roto"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := RemoveLocks(repo.Config.Name, false); err != nil || n != 2 {
+		t.Fatalf("RemoveLocks = %d, %v; quiero 2 (el muerto y el ilegible)", n, err)
+	}
+	if locks, _ := repo.backend.List("locks"); len(locks) != 1 || locks[0] != "vigente" {
+		t.Fatalf("bloqueos = %v, quiero solo el vigente", locks)
+	}
+	if n, err := RemoveLocks(repo.Config.Name, true); err != nil || n != 1 {
+		t.Fatalf("RemoveLocks(all) = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,887 @@
+package backup
+
+import (
+	"archive/tar"
+	"autohost-cli/internal/helpers/app"
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cifrado de un repositorio.
+const (
+	EncryptionPassphrase = "passphrase" // frase guardada en el almacén de secretos
+	EncryptionKey        = "key"        // clave aleatoria de 256 bits en el almacén de secretos
+)
+
+// LocalArchive fuerza un .tar.gz local aunque haya un repositorio predeterminado.
+const LocalArchive = "local"
+
+const (
+	repoVersion  = 1
+	repoChunker  = "fastcdc-gear-512k-1m-8m"
+	registryName = "repositories.json"
+)
+
+var repoNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// RepoConfig es un repositorio registrado en ~/.autohost/backups/repositories.json.
+type RepoConfig struct {
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Encryption string    `json:"encryption"`
+	Region     string    `json:"region,omitempty"` // S3
+	Created    time.Time `json:"created"`
+}
+
+// RepoRef ubica en un repositorio un snapshot sin archivo local.
+type RepoRef struct {
+	Name     string `json:"name"`
+	Snapshot string `json:"snapshot"`
+	Size     int64  `json:"size"`   // bytes del tar sin comprimir
+	SHA256   string `json:"sha256"` // del tar
+	Chunks   int    `json:"chunks"`
+	// Added es lo que se subió de verdad (fragmentos nuevos, comprimidos y cifrados).
+	Added int64 `json:"added"`
+}
+
+type repoRegistry struct {
+	Default      string        `json:"default,omitempty"`
+	Repositories []*RepoConfig `json:"repositories"`
+}
+
+func registryPath() string { return filepath.Join(Dir(), registryName) }
+
+func loadRegistry() (*repoRegistry, error) {
+	reg := &repoRegistry{}
+	b, err := os.ReadFile(registryPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, reg); err != nil {
+		return nil, fmt.Errorf("%s: %w", registryPath(), err)
+	}
+	return reg, nil
+}
+
+func (reg *repoRegistry) save() error {
+	data, err := json.MarshalIndent(reg, "", "  ")
+	if err != nil {
+		return err
+	}
+	r := utils.DefaultRunner()
+	if err := r.MkdirAll(Dir(), 0o700); err != nil {
+		return err
+	}
+	return r.WriteFile(registryPath(), append(data, '\n'), 0o600)
+}
+
+func (reg *repoRegistry) find(name string) *RepoConfig {
+	for _, c := range reg.Repositories {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Repositories devuelve los repositorios registrados y el predeterminado.
+func Repositories() ([]*RepoConfig, string, error) {
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, "", err
+	}
+	return reg.Repositories, reg.Default, nil
+}
+
+// DefaultRepoDir es donde va un repositorio local sin destino explícito
+// (el "_" evita chocar con el directorio de una app).
+func DefaultRepoDir(name string) string { return filepath.Join(Dir(), "_repos", name) }
+
+func repoSecretName(repo, kind string) string { return "backup-repo-" + repo + "." + kind }
+
+// -----------------------------------------------------------------------------
+// Alta
+// -----------------------------------------------------------------------------
+
+// InitOptions controla `autohost backup repo init`.
+type InitOptions struct {
+	URL        string // "" = DefaultRepoDir(name)
+	Encryption string // passphrase | key
+	Default    bool
+}
+
+// InitResult cuenta qué hizo InitRepository.
+type InitResult struct {
+	Config  *RepoConfig
+	Created bool // false = se conectó a un repositorio que ya existía
+	// Passphrase es la frase generada (solo si no venía en AUTOHOST_BACKUP_PASSPHRASE).
+	Passphrase string
+	Secret     string // archivo del almacén de secretos con la frase o la clave
+	Synced     int    // snapshots existentes registrados en ~/.autohost/backups
+}
+
+// InitRepository crea el repositorio en el destino y lo registra. Si el
+// destino ya tiene un repositorio (p. ej. al reinstalar el servidor) se
+// conecta a él con la frase o clave del entorno y registra sus snapshots.
+func InitRepository(name string, opts InitOptions) (*InitResult, error) {
+	if !repoNameRe.MatchString(name) || name == LocalArchive {
+		return nil, fmt.Errorf("nombre de repositorio inválido: %q", name)
+	}
+	if opts.Encryption == "" {
+		opts.Encryption = EncryptionPassphrase
+	}
+	if opts.Encryption != EncryptionPassphrase && opts.Encryption != EncryptionKey {
+		return nil, fmt.Errorf("cifrado inválido: %q (usa %s|%s)", opts.Encryption, EncryptionPassphrase, EncryptionKey)
+	}
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if reg.find(name) != nil {
+		return nil, fmt.Errorf("ya existe el repositorio %s", name)
+	}
+	if opts.URL == "" {
+		opts.URL = DefaultRepoDir(name)
+	}
+	t, err := ParseTarget(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &RepoConfig{Name: name, URL: opts.URL, Encryption: opts.Encryption, Created: time.Now().UTC().Truncate(time.Second)}
+	if t.Type == TargetLocal {
+		cfg.URL = t.Path
+	}
+	if t.Type == TargetS3 {
+		cfg.Region = infra.S3RegionFromEnv()
+	}
+	res := &InitResult{Config: cfg}
+
+	r := utils.DefaultRunner()
+	if r.DryRun() {
+		fmt.Printf("%s inicializaría el repositorio %s en %s (cifrado: %s)\n", utils.DryRunPrefix, name, cfg.URL, cfg.Encryption)
+		return res, nil
+	}
+
+	backend, err := openBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = backend.Load("config")
+	switch {
+	case err == nil:
+		// Repositorio existente: hace falta su frase o clave.
+		repo := &Repository{Config: cfg, backend: backend}
+		if err := repo.unlock(); err != nil {
+			return nil, fmt.Errorf("%s ya contiene un repositorio y no se pudo abrir: %w", backend.Location(), err)
+		}
+		if err := storeCredential(cfg, res); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, os.ErrNotExist):
+		res.Created = true
+		if err := createRepository(cfg, backend, res); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("no se pudo acceder a %s: %w", backend.Location(), err)
+	}
+
+	// Las credenciales S3 se guardan para los backups desatendidos (cron, systemd).
+	if t.Type == TargetS3 {
+		if creds := infra.S3CredentialsFromEnv(); creds.AccessKeyID != "" {
+			data, _ := json.Marshal(creds)
+			if err := utils.WriteSecret(repoSecretName(name, "s3"), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	reg.Repositories = append(reg.Repositories, cfg)
+	if opts.Default || reg.Default == "" {
+		reg.Default = name
+	}
+	if err := reg.save(); err != nil {
+		return nil, err
+	}
+	if !res.Created {
+		if res.Synced, err = SyncRepository(name); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// storeCredential guarda en el almacén de secretos la frase o clave del entorno.
+func storeCredential(cfg *RepoConfig, res *InitResult) error {
+	var kind, value string
+	switch cfg.Encryption {
+	case EncryptionPassphrase:
+		kind, value = "passphrase", os.Getenv("AUTOHOST_BACKUP_PASSPHRASE")
+	case EncryptionKey:
+		kind, value = "key", os.Getenv("AUTOHOST_BACKUP_KEY")
+	}
+	res.Secret = utils.SecretPath(repoSecretName(cfg.Name, kind))
+	if value == "" {
+		return nil // ya estaba en el almacén
+	}
+	return utils.WriteSecret(repoSecretName(cfg.Name, kind), []byte(value+"\n"))
+}
+
+// createRepository escribe config y la primera clave en un destino vacío.
+func createRepository(cfg *RepoConfig, backend Backend, res *InitResult) error {
+	kind := "passphrase"
+	if cfg.Encryption == EncryptionKey {
+		kind = "key"
+	}
+	// Un secreto con el mismo nombre puede ser la única copia de la clave de
+	// un repositorio quitado del registro: no se pisa.
+	if pathExists(utils.SecretPath(repoSecretName(cfg.Name, kind))) {
+		return fmt.Errorf("ya existe %s; bórralo o usa otro nombre de repositorio", utils.SecretPath(repoSecretName(cfg.Name, kind)))
+	}
+	switch cfg.Encryption {
+	case EncryptionPassphrase:
+		if os.Getenv("AUTOHOST_BACKUP_PASSPHRASE") == "" {
+			pass, err := utils.RandomToken(24)
+			if err != nil {
+				return err
+			}
+			res.Passphrase = pass
+			if err := utils.WriteSecret(repoSecretName(cfg.Name, "passphrase"), []byte(pass+"\n")); err != nil {
+				return err
+			}
+			res.Secret = utils.SecretPath(repoSecretName(cfg.Name, "passphrase"))
+		} else if err := storeCredential(cfg, res); err != nil {
+			return err
+		}
+	case EncryptionKey:
+		if os.Getenv("AUTOHOST_BACKUP_KEY") == "" {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return err
+			}
+			enc := base64.StdEncoding.EncodeToString(key)
+			if err := utils.WriteSecret(repoSecretName(cfg.Name, "key"), []byte(enc+"\n")); err != nil {
+				return err
+			}
+			res.Secret = utils.SecretPath(repoSecretName(cfg.Name, "key"))
+		} else if err := storeCredential(cfg, res); err != nil {
+			return err
+		}
+	}
+
+	master, err := newMasterKey()
+	if err != nil {
+		return err
+	}
+	repo := &Repository{Config: cfg, backend: backend, key: master}
+	kf, err := repo.newKeyFile()
+	if err != nil {
+		return err
+	}
+	id, err := randomID()
+	if err != nil {
+		return err
+	}
+	if err := saveJSON(backend, "keys/"+id, kf); err != nil {
+		return err
+	}
+	repoID, err := randomID()
+	if err != nil {
+		return err
+	}
+	// config va al final: un destino sin config no es un repositorio.
+	if err := saveJSON(backend, "config", repoFile{Version: repoVersion, ID: repoID, Created: cfg.Created, Chunker: repoChunker}); err != nil {
+		return err
+	}
+	return backend.Flush()
+}
+
+// ForgetRepository quita un repositorio del registro. No borra el destino
+// ni su frase o clave del almacén de secretos.
+func ForgetRepository(name string) error {
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if reg.find(name) == nil {
+		return fmt.Errorf("no existe el repositorio %s", name)
+	}
+	kept := reg.Repositories[:0]
+	for _, c := range reg.Repositories {
+		if c.Name != name {
+			kept = append(kept, c)
+		}
+	}
+	reg.Repositories = kept
+	if reg.Default == name {
+		reg.Default = ""
+	}
+	delete(opened, name)
+	return reg.save()
+}
+
+// SetDefaultRepository marca el repositorio que usa `backup create` sin --repo.
+func SetDefaultRepository(name string) error {
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if name != "" && reg.find(name) == nil {
+		return fmt.Errorf("no existe el repositorio %s", name)
+	}
+	reg.Default = name
+	return reg.save()
+}
+
+// -----------------------------------------------------------------------------
+// Apertura
+// -----------------------------------------------------------------------------
+
+// Repository es un repositorio abierto (con la clave maestra descifrada).
+type Repository struct {
+	Config  *RepoConfig
+	backend Backend
+	key     *masterKey
+}
+
+// repoFile es el objeto "config" (sin cifrar: no contiene secretos).
+type repoFile struct {
+	Version int       `json:"version"`
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Chunker string    `json:"chunker"`
+}
+
+// keyFile guarda la clave maestra cifrada con la frase (PBKDF2) o con la
+// clave aleatoria del almacén (kdf "none").
+type keyFile struct {
+	Created    time.Time `json:"created"`
+	Hostname   string    `json:"hostname,omitempty"`
+	KDF        string    `json:"kdf"`
+	Iterations int       `json:"iterations,omitempty"`
+	Salt       []byte    `json:"salt,omitempty"`
+	Data       []byte    `json:"data"`
+}
+
+// repoSnapshot es el objeto cifrado snapshots/<id>: el manifiesto y la
+// lista ordenada de fragmentos del tar.
+type repoSnapshot struct {
+	Manifest *Manifest `json:"manifest"`
+	Chunks   []string  `json:"chunks"`
+}
+
+// opened evita derivar la clave (PBKDF2) una vez por snapshot al
+// verificar o restaurar varios del mismo repositorio.
+var opened = map[string]*Repository{}
+
+// OpenRepository abre un repositorio registrado.
+func OpenRepository(name string) (*Repository, error) {
+	if repo, ok := opened[name]; ok {
+		return repo, nil
+	}
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	cfg := reg.find(name)
+	if cfg == nil {
+		return nil, fmt.Errorf("no existe el repositorio %s (créalo con `autohost backup repo init`)", name)
+	}
+	backend, err := openBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	repo := &Repository{Config: cfg, backend: backend}
+	if err := repo.unlock(); err != nil {
+		return nil, fmt.Errorf("repositorio %s: %w", name, err)
+	}
+	opened[name] = repo
+	return repo, nil
+}
+
+// Location describe el destino del repositorio.
+func (r *Repository) Location() string { return r.backend.Location() }
+
+func openBackend(cfg *RepoConfig) (Backend, error) {
+	t, err := ParseTarget(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Type {
+	case TargetSFTP:
+		c, err := infra.NewSFTPClient(t.Host, t.Port, t.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &sftpBackend{client: c}, nil
+	case TargetS3:
+		creds := infra.S3CredentialsFromEnv()
+		if creds.AccessKeyID == "" {
+			if b, err := utils.ReadSecret(repoSecretName(cfg.Name, "s3")); err == nil {
+				_ = json.Unmarshal(b, &creds)
+			}
+		}
+		c, err := infra.NewS3Client(t.Endpoint, t.Bucket, cfg.Region, creds)
+		if err != nil {
+			return nil, err
+		}
+		return &s3Backend{client: c, prefix: t.Path}, nil
+	}
+	return &localBackend{dir: t.Path}, nil
+}
+
+// credential devuelve la frase o la clave: primero del entorno
+// (AUTOHOST_BACKUP_PASSPHRASE / AUTOHOST_BACKUP_KEY) y luego del almacén.
+func (r *Repository) credential() ([]byte, error) {
+	env, kind, label := "AUTOHOST_BACKUP_PASSPHRASE", "passphrase", "frase"
+	if r.Config.Encryption == EncryptionKey {
+		env, kind, label = "AUTOHOST_BACKUP_KEY", "key", "clave"
+	}
+	value := []byte(os.Getenv(env))
+	if len(value) == 0 {
+		b, err := utils.ReadSecret(repoSecretName(r.Config.Name, kind))
+		if err != nil {
+			return nil, fmt.Errorf("falta la %s del repositorio (define %s): %w", label, env, err)
+		}
+		value = b
+	}
+	value = []byte(strings.TrimSpace(string(value)))
+	if r.Config.Encryption == EncryptionKey {
+		key, err := base64.StdEncoding.DecodeString(string(value))
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("la clave del repositorio debe ser de 32 bytes en base64")
+		}
+		return key, nil
+	}
+	return value, nil
+}
+
+func (r *Repository) keyEncryptionKey(kf *keyFile, cred []byte) []byte {
+	if kf.KDF == "none" {
+		return cred
+	}
+	return pbkdf2SHA256(cred, kf.Salt, kf.Iterations, 32)
+}
+
+// unlock prueba la credencial contra cada keys/<id> hasta descifrar la clave maestra.
+func (r *Repository) unlock() error {
+	var rf repoFile
+	if err := loadJSON(r.backend, "config", &rf); err != nil {
+		return err
+	}
+	if rf.Version != repoVersion || rf.Chunker != repoChunker {
+		return fmt.Errorf("formato de repositorio no soportado (versión %d, %s)", rf.Version, rf.Chunker)
+	}
+	cred, err := r.credential()
+	if err != nil {
+		return err
+	}
+	ids, err := r.backend.List("keys")
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		var kf keyFile
+		if err := loadJSON(r.backend, "keys/"+id, &kf); err != nil {
+			return err
+		}
+		if kf.KDF != "none" && kf.KDF != "pbkdf2-sha256" {
+			continue
+		}
+		plain, err := open(r.keyEncryptionKey(&kf, cred), kf.Data)
+		if err != nil {
+			continue
+		}
+		var mk masterKey
+		if err := json.Unmarshal(plain, &mk); err != nil {
+			return err
+		}
+		r.key = &mk
+		return nil
+	}
+	return errors.New("ninguna clave del repositorio coincide con la frase o clave indicada")
+}
+
+func (r *Repository) newKeyFile() (*keyFile, error) {
+	cred, err := r.credential()
+	if err != nil {
+		return nil, err
+	}
+	kf := &keyFile{Created: time.Now().UTC().Truncate(time.Second), KDF: "none"}
+	kf.Hostname, _ = os.Hostname()
+	if r.Config.Encryption == EncryptionPassphrase {
+		kf.KDF, kf.Iterations, kf.Salt = "pbkdf2-sha256", pbkdf2Iterations, make([]byte, 16)
+		if _, err := rand.Read(kf.Salt); err != nil {
+			return nil, err
+		}
+	}
+	plain, err := json.Marshal(r.key)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Data, err = seal(r.keyEncryptionKey(kf, cred), plain); err != nil {
+		return nil, err
+	}
+	return kf, nil
+}
+
+// -----------------------------------------------------------------------------
+// Snapshots y fragmentos
+// -----------------------------------------------------------------------------
+
+func chunkPath(id string) string { return "data/" + id[:2] + "/" + id }
+
+// snapshotIDs lista los snapshots del repositorio.
+func (r *Repository) snapshotIDs() ([]string, error) { return r.backend.List("snapshots") }
+
+func (r *Repository) loadSnapshot(id string) (*repoSnapshot, error) {
+	obj, err := r.backend.Load("snapshots/" + id)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openObject(r.key.Encrypt, obj)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	var s repoSnapshot
+	if err := json.Unmarshal(plain, &s); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	if s.Manifest == nil || s.Manifest.Repository == nil {
+		return nil, fmt.Errorf("snapshot %s: incompleto", id)
+	}
+	return &s, nil
+}
+
+func (r *Repository) saveSnapshot(id string, s *repoSnapshot) error {
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	obj, err := sealObject(r.key.Encrypt, plain)
+	if err != nil {
+		return err
+	}
+	if err := r.backend.Save("snapshots/"+id, obj); err != nil {
+		return err
+	}
+	return r.backend.Flush()
+}
+
+// knownChunks lista los fragmentos ya subidos (data/<xx>/<id>) sin descargar
+// ni descifrar los snapshots, así el tiempo de un backup no crece con el
+// historial. Los fragmentos se escriben de forma atómica y no cambian nunca,
+// así que uno listado está completo aunque lo haya subido un backup interrumpido.
+func (r *Repository) knownChunks() (map[string]bool, error) {
+	names, err := r.backend.ListNested("data")
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(names))
+	for _, id := range names {
+		known[id] = true
+	}
+	return known, nil
+}
+
+// storeResult resume lo que store leyó y subió.
+type storeResult struct {
+	Chunks []string
+	Size   int64
+	SHA256 string
+	Added  int64
+}
+
+// store parte src en fragmentos y sube los que no estén en known.
+func (r *Repository) store(src io.Reader, known map[string]bool) (*storeResult, error) {
+	sum := sha256.New()
+	cw := &countingWriter{w: sum}
+	ch := newChunker(io.TeeReader(src, cw), r.key.ChunkerSeed)
+	res := &storeResult{}
+	for {
+		data, err := ch.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		id := r.key.chunkID(data)
+		res.Chunks = append(res.Chunks, id)
+		if known[id] {
+			continue
+		}
+		obj, err := sealObject(r.key.Encrypt, data)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.backend.Save(chunkPath(id), obj); err != nil {
+			return nil, err
+		}
+		known[id] = true
+		res.Added += int64(len(obj))
+	}
+	if err := r.backend.Flush(); err != nil {
+		return nil, err
+	}
+	res.Size = cw.n
+	res.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	return res, nil
+}
+
+// chunkReader reconstruye el tar de un snapshot descargando sus fragmentos
+// en orden; cada uno se comprueba contra su ID.
+type chunkReader struct {
+	repo *Repository
+	ids  []string
+	cur  []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.cur) == 0 {
+		if len(c.ids) == 0 {
+			return 0, io.EOF
+		}
+		id := c.ids[0]
+		c.ids = c.ids[1:]
+		obj, err := c.repo.backend.Load(chunkPath(id))
+		if err != nil {
+			return 0, fmt.Errorf("fragmento %s: %w", short(id), err)
+		}
+		data, err := openObject(c.repo.key.Encrypt, obj)
+		if err != nil {
+			return 0, fmt.Errorf("fragmento %s: %w", short(id), err)
+		}
+		if c.repo.key.chunkID(data) != id {
+			return 0, fmt.Errorf("fragmento %s: el contenido no coincide con su ID", short(id))
+		}
+		c.cur = data
+	}
+	n := copy(p, c.cur)
+	c.cur = c.cur[n:]
+	return n, nil
+}
+
+func (c *chunkReader) Close() error { return nil }
+
+// openRepoTar devuelve el tar de un snapshot guardado en un repositorio.
+func openRepoTar(m *Manifest) (io.ReadCloser, error) {
+	repo, err := OpenRepository(m.Repository.Name)
+	if err != nil {
+		return nil, err
+	}
+	s, err := repo.loadSnapshot(m.Repository.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Chunks) != m.Repository.Chunks {
+		return nil, fmt.Errorf("el snapshot %s del repositorio no coincide con %s", m.Repository.Snapshot, m.ManifestPath())
+	}
+	return &chunkReader{repo: repo, ids: s.Chunks}, nil
+}
+
+// writeRepository guarda el tar del snapshot en el repositorio: se genera
+// en streaming (sin archivo local) y solo se suben los fragmentos nuevos.
+func writeRepository(repo *Repository, inst *app.AppInstance, m *Manifest, sources []*source, databases []dbTarget, used map[string]bool) error {
+	// El bloqueo cubre desde el listado de fragmentos hasta guardar el
+	// snapshot: mientras tanto un prune los vería sin usar y los borraría.
+	release, err := repo.lock(false)
+	if err != nil {
+		return err
+	}
+	defer release()
+	known, err := repo.knownChunks()
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	type result struct {
+		res *storeResult
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := repo.store(pr, known)
+		pr.CloseWithError(err)
+		done <- result{res, err}
+	}()
+	err = writeMembers(inst, tar.NewWriter(pw), AppDir(m.App), m, sources, databases, used)
+	pw.CloseWithError(err)
+	out := <-done
+	if err != nil {
+		return err
+	}
+	if out.err != nil {
+		return out.err
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return err
+	}
+	m.Repository = &RepoRef{
+		Name:     repo.Config.Name,
+		Snapshot: id,
+		Size:     out.res.Size,
+		SHA256:   out.res.SHA256,
+		Chunks:   len(out.res.Chunks),
+		Added:    out.res.Added,
+	}
+	return repo.saveSnapshot(id, &repoSnapshot{Manifest: m, Chunks: out.res.Chunks})
+}
+
+// forgetSnapshots borra snapshots del repositorio y los fragmentos que ya
+// no usa ningún otro. En dry-run solo cuenta lo que borraría; si no, bloquea
+// el repositorio para que ningún backup suba fragmentos mientras tanto.
+func forgetSnapshots(repoName string, ids []string) (int, error) {
+	repo, err := OpenRepository(repoName)
+	if err != nil {
+		return 0, err
+	}
+	if !utils.DefaultRunner().DryRun() {
+		release, err := repo.lock(true)
+		if err != nil {
+			return 0, err
+		}
+		defer release()
+	}
+	all, err := repo.snapshotIDs()
+	if err != nil {
+		return 0, err
+	}
+	remove := map[string]bool{}
+	for _, id := range ids {
+		remove[id] = true
+	}
+	keep, candidates := map[string]bool{}, map[string]bool{}
+	var objects []string
+	for _, id := range all {
+		s, err := repo.loadSnapshot(id)
+		if err != nil {
+			// Sin saber qué usa un snapshot no se puede borrar ningún fragmento.
+			return 0, err
+		}
+		set := keep
+		if remove[id] {
+			set = candidates
+			objects = append(objects, "snapshots/"+id)
+		}
+		for _, c := range s.Chunks {
+			set[c] = true
+		}
+	}
+	var garbage []string
+	for c := range candidates {
+		if !keep[c] {
+			garbage = append(garbage, chunkPath(c))
+		}
+	}
+	sort.Strings(garbage)
+	if utils.DefaultRunner().DryRun() {
+		return len(garbage), nil
+	}
+	// Primero los snapshots: si algo falla después solo quedan fragmentos huérfanos.
+	if err := repo.backend.Delete(objects...); err != nil {
+		return 0, err
+	}
+	if err := repo.backend.Delete(garbage...); err != nil {
+		return 0, err
+	}
+	return len(garbage), nil
+}
+
+// SyncRepository registra en ~/.autohost/backups los snapshots del
+// repositorio que no tienen manifiesto local (p. ej. en un servidor nuevo).
+func SyncRepository(name string) (int, error) {
+	repo, err := OpenRepository(name)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := repo.snapshotIDs()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		s, err := repo.loadSnapshot(id)
+		if err != nil {
+			return n, err
+		}
+		m := s.Manifest
+		m.Repository.Name = name
+		if pathExists(m.ManifestPath()) {
+			continue
+		}
+		if err := utils.DefaultRunner().MkdirAll(AppDir(m.App), 0o700); err != nil {
+			return n, err
+		}
+		if err := writeManifest(m); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// resolveRepository devuelve el repositorio de `backup create`: el indicado,
+// el predeterminado o ninguno ("" = archivo local).
+func resolveRepository(name string) (*Repository, error) {
+	if name == LocalArchive {
+		return nil, nil
+	}
+	if name == "" {
+		reg, err := loadRegistry()
+		if err != nil {
+			return nil, err
+		}
+		if reg.Default == "" {
+			return nil, nil
+		}
+		name = reg.Default
+	}
+	return OpenRepository(name)
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func saveJSON(b Backend, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return b.Save(name, append(data, '\n'))
+}
+
+func loadJSON(b Backend, name string, v any) error {
+	data, err := b.Load(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "caballo-correcto-batería-grapa"
+
+// newTestRepo crea un repositorio con frase en un directorio local y lo abre.
+func newTestRepo(t *testing.T) (*Repository, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AUTOHOST_BACKUP_PASSPHRASE", testPassphrase)
+	dir := t.TempDir()
+	cfg := &RepoConfig{Name: "prueba", URL: dir, Encryption: EncryptionPassphrase}
+	if err := createRepository(cfg, &localBackend{dir: dir}, &InitResult{Config: cfg}); err != nil {
+		t.Fatal(err)
+	}
+	repo := &Repository{Config: cfg, backend: &localBackend{dir: dir}}
+	if err := repo.unlock(); err != nil {
+		t.Fatal(err)
+	}
+	return repo, dir
+}
+
+// testData genera n bytes pseudoaleatorios (no comprimibles) reproducibles.
+func testData(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+// backupBytes guarda data como un snapshot del repositorio.
+func backupBytes(t *testing.T, repo *Repository, data []byte) (string, *storeResult) {
+	t.Helper()
+	known, err := repo.knownChunks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := repo.store(bytes.NewReader(data), known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := randomID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manifest{App: "demo", Repository: &RepoRef{Name: repo.Config.Name, Snapshot: id, Chunks: len(res.Chunks)}}
+	if err := repo.saveSnapshot(id, &repoSnapshot{Manifest: m, Chunks: res.Chunks}); err != nil {
+		t.Fatal(err)
+	}
+	return id, res
+}
+
+func restoreBytes(t *testing.T, repo *Repository, id string) ([]byte, error) {
+	t.Helper()
+	s, err := repo.loadSnapshot(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return io.ReadAll(&chunkReader{repo: repo, ids: s.Chunks})
+}
+
+func chunkFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "data", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestRepositoryRoundTrip(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	data := testData(1, 6<<20)
+	id, res := backupBytes(t, repo, data)
+
+	sum := sha256.Sum256(data)
+	if res.Size != int64(len(data)) || res.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("store: tamaño %d, sha256 %s", res.Size, res.SHA256)
+	}
+	if len(res.Chunks) < 2 || len(chunkFiles(t, dir)) != len(res.Chunks) {
+		t.Fatalf("fragmentos = %d, archivos = %d", len(res.Chunks), len(chunkFiles(t, dir)))
+	}
+	got, err := restoreBytes(t, repo, id)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("restaurado %d bytes (de %d), err = %v", len(got), len(data), err)
+	}
+
+	// Nada de lo guardado es legible sin la clave.
+	for _, f := range append(chunkFiles(t, dir), filepath.Join(dir, "snapshots", id)) {
+		b, _ := os.ReadFile(f)
+		if bytes.Contains(b, data[:64]) || strings.Contains(string(b), "demo") {
+			t.Fatalf("%s guarda datos en claro", f)
+		}
+	}
+
+	// Un fragmento alterado se detecta al restaurar.
+	f := chunkFiles(t, dir)[0]
+	b, _ := os.ReadFile(f)
+	b[len(b)/2] ^= 0xff
+	if err := os.WriteFile(f, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restoreBytes(t, repo, id); err == nil {
+		t.Fatal("se esperaba error con un fragmento alterado")
+	}
+}
+
+func TestRepositoryDedupAcrossSnapshots(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	first := testData(2, 8<<20)
+	_, res1 := backupBytes(t, repo, first)
+	before := len(chunkFiles(t, dir))
+
+	// Mismo contenido: no se sube nada.
+	_, same := backupBytes(t, repo, first)
+	if same.Added != 0 || len(chunkFiles(t, dir)) != before {
+		t.Fatalf("repetido: subidos %d bytes, archivos %d → %d", same.Added, before, len(chunkFiles(t, dir)))
+	}
+
+	// Unos bytes insertados en el medio solo cambian los fragmentos de alrededor.
+	second := append(append(append([]byte(nil), first[:3<<20]...), []byte("cambio en el medio")...), first[3<<20:]...)
+	id2, res2 := backupBytes(t, repo, second)
+	added := len(chunkFiles(t, dir)) - before
+	if added == 0 || added > 3 || res2.Added >= int64(len(second))/2 {
+		t.Fatalf("modificado: %d fragmentos nuevos (%d bytes) de %d", added, res2.Added, len(res2.Chunks))
+	}
+	shared := 0
+	for _, c := range res2.Chunks {
+		for _, c1 := range res1.Chunks {
+			if c == c1 {
+				shared++
+				break
+			}
+		}
+	}
+	if shared < len(res2.Chunks)-3 {
+		t.Fatalf("solo %d de %d fragmentos compartidos", shared, len(res2.Chunks))
+	}
+	got, err := restoreBytes(t, repo, id2)
+	if err != nil || !bytes.Equal(got, second) {
+		t.Fatalf("restaurar el segundo snapshot: %v", err)
+	}
+}
+
+// knownChunks se arma con los nombres de data/<xx>/<id>: no abre snapshots,
+// así que funciona aunque haya miles o alguno no se pueda leer.
+func TestKnownChunksListsData(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	_, res := backupBytes(t, repo, testData(3, 3<<20))
+	if err := os.WriteFile(filepath.Join(dir, "snapshots", "roto"), []byte("basura"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	known, err := repo.knownChunks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(known) != len(res.Chunks) {
+		t.Fatalf("knownChunks = %d, quiero %d", len(known), len(res.Chunks))
+	}
+	for _, c := range res.Chunks {
+		if !known[c] {
+			t.Fatalf("falta el fragmento %s", c)
+		}
+	}
+}
+
+func TestRepositoryWrongPassphrase(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	tests := []struct {
+		name, env, value string
+		enc              string
+	}{
+		{"frase incorrecta", "AUTOHOST_BACKUP_PASSPHRASE", "otra-frase", EncryptionPassphrase},
+		{"frase en mayúsculas", "AUTOHOST_BACKUP_PASSPHRASE", strings.ToUpper(testPassphrase), EncryptionPassphrase},
+		// Una clave de 32 bytes válida pero que no es la del repositorio.
+		{"clave en vez de frase", "AUTOHOST_BACKUP_KEY", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", EncryptionKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			cfg := *repo.Config
+			cfg.Encryption = tt.enc
+			other := &Repository{Config: &cfg, backend: &localBackend{dir: dir}}
+			if err := other.unlock(); err == nil || !strings.Contains(err.Error(), "ninguna clave") {
+				t.Fatalf("err = %v, quiero que no coincida ninguna clave", err)
+			}
+		})
+	}
+
+	// Con la frase correcta se vuelve a abrir y la clave maestra es la misma.
+	again := &Repository{Config: repo.Config, backend: &localBackend{dir: dir}}
+	if err := again.unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.key.Encrypt, repo.key.Encrypt) {
+		t.Fatal("la clave maestra no coincide")
+	}
+}
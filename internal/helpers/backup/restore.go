@@ -0,0 +1,410 @@
+package backup
+
+import (
+	"archive/tar"
+	"autohost-cli/internal/helpers/app"
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RestoreOptions controla `autohost backup restore`.
+type RestoreOptions struct {
+	Snapshot string // ID o prefijo ("" = el más reciente)
+	To       string // restaurar como otra app (copia para pruebas)
+	// HealthTimeout es cuánto esperar a que la app quede en ejecución (0 = 2 min).
+	HealthTimeout time.Duration
+}
+
+// RestoreAction es un elemento que la restauración crea o sobrescribe.
+type RestoreAction struct {
+	Entry  string `json:"entry"` // miembro del archivo
+	Kind   string `json:"kind"`
+	Target string `json:"target"` // ruta, volumen Docker o servicio
+	Exists bool   `json:"exists"` // true = se sobrescribe
+	Detail string `json:"detail,omitempty"`
+}
+
+// RestorePlan es lo que hará Restore; con --dry-run solo se muestra.
+type RestorePlan struct {
+	Snapshot *Manifest         `json:"snapshot"`
+	App      string            `json:"app"`  // app destino
+	Copy     bool              `json:"copy"` // restaurando con otro nombre
+	Running  bool              `json:"running"`
+	Actions  []RestoreAction   `json:"actions"`
+	inst     *app.AppInstance  // destino
+	targets  map[string]string // miembro → destino
+	timeout  time.Duration
+}
+
+// PlanRestore resuelve el snapshot y el destino de cada elemento sin tocar nada.
+func PlanRestore(appName string, opts RestoreOptions) (*RestorePlan, error) {
+	m, err := Find(appName, opts.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	targetName := m.App
+	if opts.To != "" {
+		targetName = opts.To
+	}
+	inst, err := app.NewAppInstance(targetName)
+	if err != nil {
+		return nil, err
+	}
+	p := &RestorePlan{
+		Snapshot: m,
+		App:      inst.Name,
+		Copy:     inst.Name != m.App,
+		Actions:  []RestoreAction{},
+		inst:     inst,
+		targets:  map[string]string{},
+		timeout:  opts.HealthTimeout,
+	}
+	if p.timeout <= 0 {
+		p.timeout = 2 * time.Minute
+	}
+	if p.Copy && inst.Installed() {
+		return nil, fmt.Errorf("ya existe una app %s; elige otro nombre para --to", inst.Name)
+	}
+	if inst.Installed() {
+		running, err := inst.RunningServices()
+		if err != nil {
+			return nil, err
+		}
+		p.Running = len(running) > 0
+	}
+
+	origDir := filepath.Join(utils.GetSubdir("apps"), m.App)
+	for _, e := range m.Entries {
+		a := RestoreAction{Entry: e.Name, Kind: e.Kind}
+		switch e.Kind {
+		case KindFile:
+			a.Target = filepath.Join(inst.Dir, e.Name)
+			a.Exists = pathExists(a.Target)
+			if p.Copy {
+				a.Detail = "adaptado a " + inst.Name
+			}
+		case KindVolume:
+			a.Target = e.Source
+			if p.Copy {
+				if e.Source != m.Project+"_"+e.Volume {
+					return nil, fmt.Errorf("el volumen %s tiene nombre fijo en el compose; no se puede restaurar como copia", e.Source)
+				}
+				a.Target = inst.Project + "_" + e.Volume
+			}
+			if a.Exists, err = volumeExists(a.Target); err != nil {
+				return nil, err
+			}
+			a.Detail = "se vacía y se recrea"
+		case KindBind:
+			a.Target = e.Source
+			if p.Copy {
+				rel, err := filepath.Rel(origDir, e.Source)
+				if err != nil || strings.HasPrefix(rel, "..") {
+					return nil, fmt.Errorf("el bind mount %s está fuera del directorio de la app; no se puede restaurar como copia", e.Source)
+				}
+				a.Target = filepath.Join(inst.Dir, rel)
+			}
+			a.Exists = pathExists(a.Target)
+			if !e.SingleFile {
+				a.Detail = "se vacía el directorio"
+			}
+		case KindDump:
+			a.Target = strings.Join(e.Services, ",")
+			a.Exists = true
+			a.Detail = "importa " + e.Engine
+			if e.Database != "" {
+				a.Detail += " " + e.Database
+			}
+		default:
+			continue
+		}
+		p.targets[e.Name] = a.Target
+		p.Actions = append(p.Actions, a)
+	}
+	return p, nil
+}
+
+// Restore verifica el snapshot, detiene la app destino, restaura compose,
+// .env, bind mounts y volúmenes, la levanta, importa los volcados y espera
+// a que quede en ejecución.
+func Restore(p *RestorePlan) error {
+	m, inst := p.Snapshot, p.inst
+	fmt.Printf("🔍 Verificando %s/%s...\n", m.App, m.ID)
+	if err := Verify(m); err != nil {
+		return fmt.Errorf("el snapshot no pasó la verificación: %w", err)
+	}
+	r := utils.DefaultRunner()
+
+	if inst.Installed() {
+		fmt.Printf("🛑 Deteniendo %s...\n", inst.Name)
+		if err := inst.Down(false); err != nil {
+			return err
+		}
+	}
+	if err := r.MkdirAll(inst.Dir, 0o755); err != nil {
+		return err
+	}
+
+	spoolDir, err := os.MkdirTemp("", "autohost-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(spoolDir)
+
+	entries := map[string]Entry{}
+	for _, e := range m.Entries {
+		entries[e.Name] = e
+	}
+	dumps := map[string]string{} // miembro → volcado extraído
+
+	src, err := openTar(m)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		e, ok := entries[hdr.Name]
+		if !ok {
+			continue // manifest.json
+		}
+		target := p.targets[e.Name]
+		switch e.Kind {
+		case KindFile:
+			err = p.restoreFile(e, target, tr)
+		case KindVolume:
+			fmt.Printf("📦 Restaurando volumen %s...\n", target)
+			err = restoreVolume(inst, target, e.Volume, tr)
+		case KindBind:
+			fmt.Printf("📁 Restaurando %s...\n", target)
+			err = restoreBind(e, target, tr)
+		case KindDump:
+			dumps[e.Name] = filepath.Join(spoolDir, sanitize(e.Name))
+			err = spoolTo(dumps[e.Name], tr)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+	}
+
+	cfg, err := inst.Config()
+	if err != nil {
+		return err
+	}
+	// SQLite se importa con la app detenida; el resto con su servidor en marcha.
+	for _, e := range m.Entries {
+		if e.Kind == KindDump && e.offline() {
+			if err := importSpooled(inst, cfg, e, dumps[e.Name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("🔄 Levantando %s...\n", inst.Name)
+	if err := inst.Start(); err != nil {
+		return err
+	}
+	for _, e := range m.Entries {
+		if e.Kind != KindDump || e.offline() {
+			continue
+		}
+		t, err := dumpTarget(cfg, e)
+		if err != nil {
+			return err
+		}
+		if err := waitReady(inst, t, p.timeout); err != nil {
+			return err
+		}
+		if err := importSpooled(inst, cfg, e, dumps[e.Name]); err != nil {
+			return err
+		}
+		if e.Engine == EngineRedis {
+			// El script apaga Redis para que cargue el RDB al arrancar.
+			time.Sleep(2 * time.Second)
+			if err := inst.StartServices(t.Service); err != nil {
+				return err
+			}
+		}
+	}
+	return waitHealthy(inst, p.timeout)
+}
+
+// restoreFile escribe docker-compose.yml o .env; en una copia adapta nombres y puertos.
+func (p *RestorePlan) restoreFile(e Entry, target string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0o644)
+	if e.Name == ".env" {
+		perm = 0o600
+	}
+	if p.Copy {
+		switch e.Name {
+		case "docker-compose.yml":
+			data = []byte(renameContainers(string(data), p.Snapshot.App, p.App))
+		case ".env":
+			if data, err = cloneEnv(data); err != nil {
+				return err
+			}
+		}
+	}
+	return utils.DefaultRunner().WriteFile(target, data, perm)
+}
+
+// restoreVolume recrea el volumen (con las etiquetas que espera compose) y
+// extrae en él el tar del snapshot.
+func restoreVolume(inst *app.AppInstance, name, key string, r io.Reader) error {
+	dc, err := infra.NewDockerClient()
+	if err != nil {
+		return err
+	}
+	if err := dc.RemoveVolume(name, true); err != nil && !errors.Is(err, infra.ErrDockerNotFound) {
+		return err
+	}
+	labels := map[string]string{
+		"com.docker.compose.project": inst.Project,
+		"com.docker.compose.volume":  key,
+	}
+	if _, err := dc.CreateVolume(name, labels); err != nil {
+		return err
+	}
+	c := utils.Command("docker", "run", "--rm", "-i", "--network", "none",
+		"-v", name+":/data", helperImage(), "tar", "-C", "/data", "-xpf", "-")
+	c.Stdin = r
+	return utils.DefaultRunner().Run(c)
+}
+
+// restoreBind vacía el directorio (o reemplaza el archivo) y extrae el tar.
+func restoreBind(e Entry, path string, r io.Reader) error {
+	run := utils.DefaultRunner()
+	var c utils.Cmd
+	if e.SingleFile {
+		if err := run.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		c = utils.Command("docker", "run", "--rm", "-i", "--network", "none",
+			"-v", filepath.Dir(path)+":/data", helperImage(), "tar", "-C", "/data", "-xpf", "-")
+	} else {
+		if err := run.MkdirAll(path, 0o755); err != nil {
+			return err
+		}
+		c = utils.Command("docker", "run", "--rm", "-i", "--network", "none",
+			"-v", path+":/data", helperImage(),
+			"sh", "-c", "find /data -mindepth 1 -delete && tar -C /data -xpf -")
+	}
+	c.Stdin = r
+	return run.Run(c)
+}
+
+func spoolTo(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func importSpooled(inst *app.AppInstance, cfg *app.ComposeConfig, e Entry, path string) error {
+	fmt.Printf("🗄️  Importando %s...\n", e.Source)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return importDump(inst, cfg, e, f)
+}
+
+// waitHealthy espera a que todos los contenedores estén en ejecución (y
+// healthy si tienen healthcheck).
+func waitHealthy(inst *app.AppInstance, timeout time.Duration) error {
+	fmt.Printf("🩺 Esperando a que %s responda...\n", inst.Name)
+	deadline := time.Now().Add(timeout)
+	for {
+		cs, err := inst.Ps()
+		if err == nil && healthy(cs) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = fmt.Errorf("estado: %s", app.SummarizeState(cs))
+			}
+			return fmt.Errorf("%s no quedó en ejecución tras %s: %w", inst.Name, timeout, err)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func healthy(cs []app.ContainerStatus) bool {
+	if len(cs) == 0 {
+		return false
+	}
+	for _, c := range cs {
+		if c.State != "running" || (c.Health != "" && c.Health != "healthy") {
+			return false
+		}
+	}
+	return true
+}
+
+var containerNameRe = regexp.MustCompile(`(?m)^(\s*container_name:\s*)["']?([^"'\s#]+)["']?`)
+
+// renameContainers evita choques de container_name entre la app y su copia.
+func renameContainers(compose, from, to string) string {
+	return containerNameRe.ReplaceAllStringFunc(compose, func(ln string) string {
+		sm := containerNameRe.FindStringSubmatch(ln)
+		name := sm[2]
+		if strings.HasPrefix(name, from) {
+			name = to + strings.TrimPrefix(name, from)
+		} else {
+			name = to + "_" + name
+		}
+		return sm[1] + name
+	})
+}
+
+var envPortRe = regexp.MustCompile(`(?m)^(\s*(?:export\s+)?APP_PORT\s*=\s*)(\d+)([ \t\r]*)$`)
+
+// cloneEnv cambia APP_PORT por otro puerto libre (y lo actualiza en las
+// URLs) para que la copia no choque con la app original.
+func cloneEnv(data []byte) ([]byte, error) {
+	sm := envPortRe.FindSubmatch(data)
+	if sm == nil {
+		return data, nil
+	}
+	old := string(sm[2])
+	port, err := utils.GeneratePlaceholderSpec("free_port")
+	if err != nil {
+		return nil, err
+	}
+	if port == old {
+		return data, nil
+	}
+	out := envPortRe.ReplaceAll(data, []byte("${1}"+port+"${3}"))
+	out = regexp.MustCompile(`:`+regexp.QuoteMeta(old)+`\b`).ReplaceAll(out, []byte(":"+port))
+	return out, nil
+}
+
+func pathExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil || !errors.Is(err, os.ErrNotExist)
+}
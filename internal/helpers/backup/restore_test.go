@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"autohost-cli/internal/helpers/app"
+	"autohost-cli/utils"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Se borra el volumen (si existe) y se recrea con las etiquetas de compose
+// antes de extraer el tar en él.
+func TestRestoreVolume(t *testing.T) {
+	for _, existed := range []bool{true, false} {
+		var mu sync.Mutex
+		var calls []string
+		var labels map[string]string
+		sock := filepath.Join(t.TempDir(), "docker.sock")
+		ln, err := net.Listen("unix", sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, r.Method+" "+r.URL.RequestURI())
+			switch {
+			case r.Method == http.MethodDelete && !existed:
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"get copia_data: no such volume"}`))
+			case r.Method == http.MethodPost:
+				var body struct{ Labels map[string]string }
+				json.NewDecoder(r.Body).Decode(&body)
+				labels = body.Labels
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"Name":"copia_data"}`))
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		srv.Listener = ln
+		srv.Start()
+		t.Setenv("DOCKER_HOST", "unix://"+sock)
+		rec := utils.NewRecordingRunner()
+		utils.SetDefaultRunner(rec)
+
+		err = restoreVolume(&app.AppInstance{Project: "copia"}, "copia_data", "data", strings.NewReader("tar"))
+		srv.Close()
+		utils.SetDefaultRunner(nil)
+		if err != nil {
+			t.Fatalf("existía=%v: %v", existed, err)
+		}
+		if want := []string{"DELETE /volumes/copia_data?force=true", "POST /volumes/create"}; !reflect.DeepEqual(calls, want) {
+			t.Fatalf("existía=%v: llamadas = %q", existed, calls)
+		}
+		if want := map[string]string{"com.docker.compose.project": "copia", "com.docker.compose.volume": "data"}; !reflect.DeepEqual(labels, want) {
+			t.Fatalf("etiquetas = %v", labels)
+		}
+		if len(rec.Commands) != 1 || !strings.Contains(rec.Commands[0].String(), "-v copia_data:/data") {
+			t.Fatalf("comandos = %+v", rec.Commands)
+		}
+		if b, _ := io.ReadAll(rec.Commands[0].Stdin); string(b) != "tar" {
+			t.Fatalf("stdin = %q", b)
+		}
+	}
+}
+
+func TestRenameContainers(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"con el prefijo de la app", "    container_name: wiki_db\n", "    container_name: copia_db\n"},
+		{"igual al nombre", "container_name: wiki", "container_name: copia"},
+		{"sin el prefijo", "  container_name: mariadb\n", "  container_name: copia_mariadb\n"},
+		{"entre comillas", "  container_name: \"wiki-app\" # fijo\n", "  container_name: copia-app # fijo\n"},
+		{"comillas simples", "  container_name: 'redis'\n", "  container_name: copia_redis\n"},
+		{
+			"varios servicios",
+			"services:\n  app:\n    container_name: wiki\n  db:\n    image: mariadb\n    container_name: wiki_db\n",
+			"services:\n  app:\n    container_name: copia\n  db:\n    image: mariadb\n    container_name: copia_db\n",
+		},
+		{"otras claves no se tocan", "  hostname: wiki\n  # container_name: wiki\n", "  hostname: wiki\n  # container_name: wiki\n"},
+	}
+	for _, tt := range tests {
+		if got := renameContainers(tt.in, "wiki", "copia"); got != tt.want {
+			t.Errorf("%s: renameContainers =\n%q\nquiero\n%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCloneEnv(t *testing.T) {
+	in := "APP_PORT=8080\nAPP_URL=http://localhost:8080/\nOTRO=http://localhost:80801\nDB_PORT=8080\n"
+	out, err := cloneEnv([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := utils.ParseEnv(string(out))
+	port := env["APP_PORT"]
+	if port == "" || port == "8080" || !regexp.MustCompile(`^\d+$`).MatchString(port) {
+		t.Fatalf("APP_PORT = %q", port)
+	}
+	want := map[string]string{
+		"APP_PORT": port,
+		"APP_URL":  "http://localhost:" + port + "/",
+		"OTRO":     "http://localhost:80801",
+		"DB_PORT":  "8080",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("env = %v\nquiero %v", env, want)
+	}
+
+	for _, in := range []string{"", "URL=http://localhost:8080\n", "APP_PORT=${PORT}\n"} {
+		out, err := cloneEnv([]byte(in))
+		if err != nil || string(out) != in {
+			t.Errorf("cloneEnv(%q) = %q, %v; sin APP_PORT numérico no cambia", in, out, err)
+		}
+	}
+	// El fin de línea se conserva aunque APP_PORT sea la última línea.
+	for _, in := range []string{"export APP_PORT = 3000\n", "APP_PORT=3000\r\n"} {
+		out, err := cloneEnv([]byte(in))
+		re := regexp.MustCompile(`^` + strings.Replace(regexp.QuoteMeta(in), "3000", `\d+`, 1) + `$`)
+		if err != nil || !re.Match(out) || string(out) == in {
+			t.Errorf("cloneEnv(%q) = %q, %v", in, out, err)
+		}
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	tests := []struct {
+		name string
+		cs   []app.ContainerStatus
+		want bool
+	}{
+		{"sin contenedores", nil, false},
+		{"en ejecución sin healthcheck", []app.ContainerStatus{{State: "running"}}, true},
+		{"healthy", []app.ContainerStatus{{State: "running", Health: "healthy"}, {State: "running"}}, true},
+		{"arrancando", []app.ContainerStatus{{State: "running", Health: "starting"}}, false},
+		{"unhealthy", []app.ContainerStatus{{State: "running"}, {State: "running", Health: "unhealthy"}}, false},
+		{"uno detenido", []app.ContainerStatus{{State: "running"}, {State: "exited"}}, false},
+		{"reiniciando", []app.ContainerStatus{{State: "restarting"}}, false},
+	}
+	for _, tt := range tests {
+		if got := healthy(tt.cs); got != tt.want {
+			t.Errorf("%s: healthy = %v, quiero %v", tt.name, got, tt.want)
+		}
+	}
+}
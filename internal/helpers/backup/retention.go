@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"autohost-cli/utils"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Retention indica cuántos snapshots conservar por app. Como en restic, de
+// cada día/semana/mes se conserva el más reciente.
+type Retention struct {
+	Last    int `json:"keep_last"`
+	Daily   int `json:"keep_daily"`
+	Weekly  int `json:"keep_weekly"`
+	Monthly int `json:"keep_monthly"`
+}
+
+// PruneResult separa lo que se conserva (con el motivo) de lo eliminado.
+type PruneResult struct {
+	Kept    []*Manifest         `json:"kept"`
+	Reasons map[string][]string `json:"reasons"` // app/id → reglas que lo conservan
+	Removed []*Manifest         `json:"removed"`
+	// Chunks cuenta los fragmentos sin referencias borrados de cada repositorio.
+	Chunks map[string]int `json:"chunks_removed,omitempty"`
+}
+
+// Empty indica que ninguna regla conserva snapshots.
+func (r Retention) Empty() bool {
+	return r.Last <= 0 && r.Daily <= 0 && r.Weekly <= 0 && r.Monthly <= 0
+}
+
+// Prune aplica la retención a los snapshots de una app (o de todas) y borra
+// el resto. Respeta --dry-run.
+func Prune(appName string, policy Retention) (*PruneResult, error) {
+	if policy.Empty() {
+		return nil, errors.New("la retención no conserva nada; indica al menos una regla --keep-*")
+	}
+	all, err := List(appName)
+	if err != nil {
+		return nil, err
+	}
+	res := &PruneResult{Kept: []*Manifest{}, Removed: []*Manifest{}, Reasons: map[string][]string{}, Chunks: map[string]int{}}
+
+	byApp := map[string][]*Manifest{}
+	var apps []string
+	for _, m := range all {
+		if _, ok := byApp[m.App]; !ok {
+			apps = append(apps, m.App)
+		}
+		byApp[m.App] = append(byApp[m.App], m) // List ya los ordena del más reciente al más antiguo
+	}
+
+	r := utils.DefaultRunner()
+	forget := map[string][]*Manifest{} // repositorio → snapshots a borrar
+	for _, a := range apps {
+		snaps := byApp[a]
+		reasons := keepReasons(snaps, policy)
+		for _, m := range snaps {
+			if why := reasons[m.ID]; len(why) > 0 {
+				res.Kept = append(res.Kept, m)
+				res.Reasons[m.App+"/"+m.ID] = why
+				continue
+			}
+			if m.Repository != nil {
+				forget[m.Repository.Name] = append(forget[m.Repository.Name], m)
+				continue
+			}
+			for _, path := range []string{m.ArchivePath(), m.ManifestPath()} {
+				if err := r.Remove(path); err != nil && !os.IsNotExist(err) {
+					return res, fmt.Errorf("no se pudo borrar %s: %w", path, err)
+				}
+			}
+			res.Removed = append(res.Removed, m)
+		}
+	}
+
+	repos := make([]string, 0, len(forget))
+	for name := range forget {
+		repos = append(repos, name)
+	}
+	sort.Strings(repos)
+	for _, name := range repos {
+		// El manifiesto local se borra después: si el repositorio falla, el
+		// snapshot sigue listado y el próximo prune lo vuelve a intentar.
+		var ids []string
+		for _, m := range forget[name] {
+			ids = append(ids, m.Repository.Snapshot)
+		}
+		n, err := forgetSnapshots(name, ids)
+		if err != nil {
+			return res, fmt.Errorf("repositorio %s: %w", name, err)
+		}
+		res.Chunks[name] = n
+		for _, m := range forget[name] {
+			if err := r.Remove(m.ManifestPath()); err != nil && !os.IsNotExist(err) {
+				return res, fmt.Errorf("no se pudo borrar %s: %w", m.ManifestPath(), err)
+			}
+			res.Removed = append(res.Removed, m)
+		}
+	}
+	return res, nil
+}
+
+// keepReasons recorre los snapshots (del más reciente al más antiguo) y
+// asigna a cada uno las reglas que lo conservan.
+func keepReasons(snaps []*Manifest, policy Retention) map[string][]string {
+	reasons := map[string][]string{}
+	rules := []struct {
+		name  string
+		count int
+		key   func(time.Time) string
+	}{
+		{"last", policy.Last, func(t time.Time) string { return t.Format(time.RFC3339) }},
+		{"daily", policy.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{"weekly", policy.Weekly, func(t time.Time) string {
+			y, w := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", y, w)
+		}},
+		{"monthly", policy.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, rule := range rules {
+		if rule.count <= 0 {
+			continue
+		}
+		used, last := 0, ""
+		for _, m := range snaps {
+			if used >= rule.count {
+				break
+			}
+			key := rule.key(m.Created.Local())
+			if key == last {
+				continue
+			}
+			last = key
+			used++
+			reasons[m.ID] = append(reasons[m.ID], rule.name)
+		}
+	}
+	return reasons
+}
+
+// ReasonText devuelve las reglas que conservan un snapshot ("daily, weekly").
+func (p *PruneResult) ReasonText(m *Manifest) string {
+	return strings.Join(p.Reasons[m.App+"/"+m.ID], ", ")
+}
@@ -0,0 +1,46 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeepReasons(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.Local)
+	}
+	// Del más reciente al más antiguo, como los devuelve List. El 15/05/2024
+	// es de la semana ISO 20; el 08/05, de la 19; el 30/04, de la 18.
+	snaps := []*Manifest{
+		{ID: "s1", Created: at(time.May, 15, 18)},
+		{ID: "s2", Created: at(time.May, 15, 9)},
+		{ID: "s3", Created: at(time.May, 14, 10)},
+		{ID: "s4", Created: at(time.May, 8, 10)},
+		{ID: "s5", Created: at(time.April, 30, 10)},
+		{ID: "s6", Created: at(time.April, 2, 10)},
+		{ID: "s7", Created: at(time.March, 1, 10)},
+	}
+	tests := []struct {
+		name   string
+		policy Retention
+		want   map[string][]string
+	}{
+		{"last", Retention{Last: 2}, map[string][]string{"s1": {"last"}, "s2": {"last"}}},
+		{"daily: el más reciente de cada día", Retention{Daily: 2}, map[string][]string{"s1": {"daily"}, "s3": {"daily"}}},
+		{"weekly", Retention{Weekly: 3}, map[string][]string{"s1": {"weekly"}, "s4": {"weekly"}, "s5": {"weekly"}}},
+		{"monthly", Retention{Monthly: 2}, map[string][]string{"s1": {"monthly"}, "s5": {"monthly"}}},
+		{"más cubos que snapshots", Retention{Monthly: 10}, map[string][]string{"s1": {"monthly"}, "s5": {"monthly"}, "s7": {"monthly"}}},
+		{
+			"reglas combinadas",
+			Retention{Last: 1, Daily: 3, Monthly: 3},
+			map[string][]string{"s1": {"last", "daily", "monthly"}, "s3": {"daily"}, "s4": {"daily"}, "s5": {"monthly"}, "s7": {"monthly"}},
+		},
+		{"sin reglas", Retention{}, map[string][]string{}},
+	}
+	for _, tt := range tests {
+		if got := keepReasons(snaps, tt.policy); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keepReasons = %v, quiero %v", tt.name, got, tt.want)
+		}
+	}
+}
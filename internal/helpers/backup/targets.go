@@ -0,0 +1,342 @@
+package backup
+
+import (
+	"autohost-cli/internal/infra"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Backend guarda los objetos de un repositorio por nombre: config,
+// keys/<id>, snapshots/<id> y data/<xx>/<id>. Los objetos no se modifican
+// nunca; solo se crean y se borran.
+type Backend interface {
+	// Location describe el destino para los mensajes.
+	Location() string
+	Save(name string, data []byte) error
+	// Load devuelve un error que cumple errors.Is(err, os.ErrNotExist) si el objeto no existe.
+	Load(name string) ([]byte, error)
+	// List devuelve los nombres (sin el directorio) de los objetos de dir.
+	List(dir string) ([]string, error)
+	// ListNested es List sobre todos los subdirectorios de dir a la vez
+	// (data/<xx>/<id> → <id>), con las menos operaciones remotas posibles.
+	ListNested(dir string) ([]string, error)
+	Delete(names ...string) error
+	// Flush termina de subir lo que el destino tenga encolado.
+	Flush() error
+}
+
+// Tipos de destino de un repositorio.
+const (
+	TargetLocal = "local"
+	TargetSFTP  = "sftp"
+	TargetS3    = "s3"
+)
+
+// Target es un destino ya interpretado desde la URL del repositorio:
+//
+//	/srv/backups/autohost              directorio local (o un disco montado)
+//	sftp:usuario@host:/ruta            SFTP (también sftp://usuario@host:2222/ruta)
+//	s3:https://host:9000/bucket/prefijo S3 o compatible (MinIO, Garage, R2...)
+type Target struct {
+	Type     string
+	Path     string // directorio local, ruta remota o prefijo en el bucket
+	Host     string // SFTP: [usuario@]host
+	Port     string // SFTP
+	Endpoint string // S3: esquema y host
+	Bucket   string // S3
+}
+
+// ParseTarget interpreta la URL de un repositorio.
+func ParseTarget(raw string) (Target, error) {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case raw == "":
+		return Target{}, errors.New("falta el destino del repositorio")
+	case strings.HasPrefix(raw, "s3:"):
+		return parseS3Target(strings.TrimPrefix(raw, "s3:"))
+	case strings.HasPrefix(raw, "sftp:"):
+		return parseSFTPTarget(raw)
+	case strings.Contains(raw, "://"):
+		return Target{}, fmt.Errorf("destino no soportado %q (usa una ruta, sftp:... o s3:...)", raw)
+	}
+	abs, err := filepath.Abs(raw)
+	if err != nil {
+		return Target{}, err
+	}
+	return Target{Type: TargetLocal, Path: abs}, nil
+}
+
+func parseS3Target(rest string) (Target, error) {
+	rest = strings.TrimPrefix(rest, "//")
+	if !strings.Contains(rest, "://") {
+		rest = "https://" + rest
+	}
+	u, err := url.Parse(rest)
+	if err != nil || u.Host == "" {
+		return Target{}, fmt.Errorf("destino S3 inválido %q (ej.: s3:https://minio.local:9000/backups)", rest)
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" {
+		return Target{}, fmt.Errorf("destino S3 sin bucket: %q", rest)
+	}
+	return Target{Type: TargetS3, Endpoint: u.Scheme + "://" + u.Host, Bucket: bucket, Path: strings.Trim(prefix, "/")}, nil
+}
+
+func parseSFTPTarget(raw string) (Target, error) {
+	t := Target{Type: TargetSFTP}
+	if strings.HasPrefix(raw, "sftp://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return Target{}, fmt.Errorf("destino SFTP inválido %q", raw)
+		}
+		t.Host, t.Port, t.Path = u.Hostname(), u.Port(), u.Path
+		if u.User != nil {
+			t.Host = u.User.Username() + "@" + t.Host
+		}
+		// sftp://host/ruta es relativa al home; sftp://host//ruta, absoluta.
+		t.Path = strings.TrimPrefix(t.Path, "/")
+	} else {
+		host, p, ok := strings.Cut(strings.TrimPrefix(raw, "sftp:"), ":")
+		if !ok {
+			return Target{}, fmt.Errorf("destino SFTP inválido %q (ej.: sftp:usuario@nas:/srv/backups)", raw)
+		}
+		t.Host, t.Path = host, p
+	}
+	if t.Host == "" || strings.Trim(t.Path, "/") == "" {
+		return Target{}, fmt.Errorf("destino SFTP incompleto %q: hace falta host y ruta", raw)
+	}
+	return t, nil
+}
+
+// -----------------------------------------------------------------------------
+// Directorio local
+// -----------------------------------------------------------------------------
+
+type localBackend struct{ dir string }
+
+func (b *localBackend) Location() string { return b.dir }
+
+func (b *localBackend) Save(name string, data []byte) error {
+	dst := filepath.Join(b.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+func (b *localBackend) Load(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(b.dir, filepath.FromSlash(name)))
+}
+
+func (b *localBackend) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(b.dir, filepath.FromSlash(dir)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".tmp-") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (b *localBackend) ListNested(dir string) ([]string, error) {
+	subdirs, err := b.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, sub := range subdirs {
+		n, err := b.List(path.Join(dir, sub))
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, n...)
+	}
+	return names, nil
+}
+
+func (b *localBackend) Delete(names ...string) error {
+	for _, name := range names {
+		err := os.Remove(filepath.Join(b.dir, filepath.FromSlash(name)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *localBackend) Flush() error { return nil }
+
+// -----------------------------------------------------------------------------
+// SFTP
+// -----------------------------------------------------------------------------
+
+// sftpUploadBatch es cuánto se acumula antes de abrir una sesión para subirlo.
+const sftpUploadBatch = 64 << 20
+
+// sftpBackend agrupa las subidas: abrir una sesión por fragmento sería muy lento.
+type sftpBackend struct {
+	client  *infra.SFTPClient
+	pending map[string][]byte
+	size    int
+}
+
+func (b *sftpBackend) Location() string {
+	host := b.client.Host
+	if b.client.Port != "" {
+		host += ":" + b.client.Port
+	}
+	return "sftp:" + host + ":" + b.client.Base
+}
+
+func (b *sftpBackend) Save(name string, data []byte) error {
+	if b.pending == nil {
+		b.pending = map[string][]byte{}
+	}
+	b.pending[name] = data
+	b.size += len(data)
+	if b.size >= sftpUploadBatch {
+		return b.Flush()
+	}
+	return nil
+}
+
+func (b *sftpBackend) Flush() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	if err := b.client.Upload(b.pending); err != nil {
+		return err
+	}
+	b.pending, b.size = nil, 0
+	return nil
+}
+
+func (b *sftpBackend) Load(name string) ([]byte, error) {
+	if data, ok := b.pending[name]; ok {
+		return data, nil
+	}
+	data, err := b.client.Download(name)
+	if errors.Is(err, infra.ErrSFTPNotFound) {
+		return nil, fmt.Errorf("%w: %w", os.ErrNotExist, err)
+	}
+	return data, err
+}
+
+func (b *sftpBackend) List(dir string) ([]string, error) { return b.client.List(dir) }
+
+// ListNested usa dos sesiones: una para los subdirectorios y otra para todos
+// sus contenidos.
+func (b *sftpBackend) ListNested(dir string) ([]string, error) {
+	subdirs, err := b.client.List(dir)
+	if err != nil || len(subdirs) == 0 {
+		return nil, err
+	}
+	for i, sub := range subdirs {
+		subdirs[i] = path.Join(dir, sub)
+	}
+	return b.client.List(subdirs...)
+}
+
+func (b *sftpBackend) Delete(names ...string) error { return b.client.Remove(names...) }
+
+// -----------------------------------------------------------------------------
+// S3
+// -----------------------------------------------------------------------------
+
+type s3Backend struct {
+	client *infra.S3Client
+	prefix string
+}
+
+func (b *s3Backend) Location() string {
+	return "s3:" + b.client.Endpoint + "/" + path.Join(b.client.Bucket, b.prefix)
+}
+
+func (b *s3Backend) key(name string) string {
+	if b.prefix == "" {
+		return name
+	}
+	return b.prefix + "/" + name
+}
+
+func (b *s3Backend) Save(name string, data []byte) error {
+	return b.client.PutObject(b.key(name), data)
+}
+
+func (b *s3Backend) Load(name string) ([]byte, error) {
+	data, err := b.client.GetObject(b.key(name))
+	if errors.Is(err, infra.ErrS3NotFound) {
+		return nil, fmt.Errorf("%w: %w", os.ErrNotExist, err)
+	}
+	return data, err
+}
+
+func (b *s3Backend) List(dir string) ([]string, error) {
+	prefix := b.key(dir) + "/"
+	keys, err := b.client.ListObjects(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, k := range keys {
+		if rest := strings.TrimPrefix(k, prefix); rest != "" && !strings.Contains(rest, "/") {
+			names = append(names, rest)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ListNested pide todo el prefijo de una vez (ListObjectsV2 pagina solo).
+func (b *s3Backend) ListNested(dir string) ([]string, error) {
+	prefix := b.key(dir) + "/"
+	keys, err := b.client.ListObjects(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, k := range keys {
+		if sub, name, ok := strings.Cut(strings.TrimPrefix(k, prefix), "/"); ok && sub != "" && name != "" && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *s3Backend) Delete(names ...string) error {
+	for _, name := range names {
+		if err := b.client.DeleteObject(b.key(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) Flush() error { return nil }
@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"autohost-cli/internal/infra"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeSFTP es un `sftp -b -` mínimo: resuelve cada `-ls -1 "ruta"` del lote
+// contra el disco local y anota una línea por sesión en $SFTP_LOG.
+const fakeSFTP = `#!/bin/sh
+echo session >> "$SFTP_LOG"
+while IFS= read -r line; do
+	case "$line" in
+	"-ls -1 "*)
+		d=${line#-ls -1 }; d=${d#\"}; d=${d%\"}
+		echo "sftp> $line"
+		[ -d "$d" ] && ls -1 "$d" | sed "s|^|$d/|"
+		;;
+	esac
+done
+exit 0
+`
+
+func TestSFTPListNestedOneSessionPerLevel(t *testing.T) {
+	bin, base := t.TempDir(), t.TempDir()
+	log := filepath.Join(t.TempDir(), "sessions")
+	if err := os.WriteFile(filepath.Join(bin, "sftp"), []byte(fakeSFTP), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AUTOHOST_SFTP_COMMAND", filepath.Join(bin, "sftp"))
+	t.Setenv("SFTP_LOG", log)
+	for _, f := range []string{"data/ab/ab01", "data/ab/ab02", "data/cd/cd03", "data/cd/cd04.partial", "data/ef/ef05", "snapshots/s1"} {
+		p := filepath.Join(base, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := infra.NewSFTPClient("nas", "", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &sftpBackend{client: c}
+	got, err := b.ListNested("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ab01", "ab02", "cd03", "ef05"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListNested = %q, quiero %q", got, want)
+	}
+	sessions, _ := os.ReadFile(log)
+	if n := strings.Count(string(sessions), "session"); n != 2 {
+		t.Fatalf("sesiones SFTP = %d, quiero 2 (directorios + contenidos)", n)
+	}
+
+	// Sin subdirectorios no hace falta la segunda sesión.
+	os.Remove(log)
+	if got, err := b.ListNested("nada"); err != nil || len(got) != 0 {
+		t.Fatalf("ListNested(nada) = %q, %v", got, err)
+	}
+	sessions, _ = os.ReadFile(log)
+	if n := strings.Count(string(sessions), "session"); n != 1 {
+		t.Fatalf("sesiones SFTP = %d, quiero 1", n)
+	}
+}
@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Verify comprueba el checksum del archivo completo y el de cada miembro
+// contra el manifiesto; los tar de volúmenes se recorren hasta el final. En
+// un repositorio además se comprueba cada fragmento contra su ID.
+func Verify(m *Manifest) error {
+	if m.Repository != nil {
+		return verifyRepository(m)
+	}
+	if m.Archive == nil {
+		return errors.New("el manifiesto no tiene checksum del archivo")
+	}
+	f, err := os.Open(m.ArchivePath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	cw := &countingWriter{w: sum}
+	gz, err := gzip.NewReader(io.TeeReader(f, cw))
+	if err != nil {
+		return fmt.Errorf("archivo corrupto: %w", err)
+	}
+	if err := verifyMembers(m, gz); err != nil {
+		return err
+	}
+	// Consumir el resto del gzip para que el checksum cubra el archivo completo.
+	if _, err := io.Copy(io.Discard, f); err != nil {
+		return err
+	}
+	if cw.n != m.Archive.Size {
+		return fmt.Errorf("tamaño distinto (esperado %d, obtenido %d)", m.Archive.Size, cw.n)
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); got != m.Archive.SHA256 {
+		return fmt.Errorf("checksum del archivo distinto (esperado %s, obtenido %s)", short(m.Archive.SHA256), short(got))
+	}
+	return nil
+}
+
+// verifyRepository descarga y descifra todos los fragmentos del snapshot.
+func verifyRepository(m *Manifest) error {
+	r, err := openRepoTar(m)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	sum := sha256.New()
+	cw := &countingWriter{w: sum}
+	tee := io.TeeReader(r, cw)
+	if err := verifyMembers(m, tee); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return err
+	}
+	if cw.n != m.Repository.Size {
+		return fmt.Errorf("tamaño distinto (esperado %d, obtenido %d)", m.Repository.Size, cw.n)
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); got != m.Repository.SHA256 {
+		return fmt.Errorf("checksum del tar distinto (esperado %s, obtenido %s)", short(m.Repository.SHA256), short(got))
+	}
+	return nil
+}
+
+// verifyMembers recorre el tar del snapshot y compara cada miembro con el manifiesto.
+func verifyMembers(m *Manifest, r io.Reader) error {
+	expected := map[string]Entry{}
+	for _, e := range m.Entries {
+		expected[e.Name] = e
+	}
+	seen := map[string]bool{}
+	manifestOK := false
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("archivo corrupto: %w", err)
+		}
+		if hdr.Name == manifestName {
+			var inner Manifest
+			if err := json.NewDecoder(tr).Decode(&inner); err != nil {
+				return fmt.Errorf("%s ilegible: %w", manifestName, err)
+			}
+			if inner.ID != m.ID || inner.App != m.App || len(inner.Entries) != len(m.Entries) {
+				return fmt.Errorf("%s no coincide con %s", manifestName, m.ManifestPath())
+			}
+			manifestOK = true
+			continue
+		}
+		e, ok := expected[hdr.Name]
+		if !ok {
+			return fmt.Errorf("miembro inesperado: %s", hdr.Name)
+		}
+		h := sha256.New()
+		r := io.TeeReader(tr, h)
+		if strings.HasSuffix(hdr.Name, ".tar") {
+			if err := walkTar(r); err != nil {
+				return fmt.Errorf("%s: tar corrupto: %w", hdr.Name, err)
+			}
+		}
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != e.SHA256 {
+			return fmt.Errorf("%s: checksum distinto (esperado %s, obtenido %s)", hdr.Name, short(e.SHA256), short(got))
+		}
+		seen[hdr.Name] = true
+	}
+	for name := range expected {
+		if !seen[name] {
+			return fmt.Errorf("falta %s en el archivo", name)
+		}
+	}
+	if !manifestOK {
+		return fmt.Errorf("falta %s en el archivo", manifestName)
+	}
+	return nil
+}
+
+// openTar devuelve el tar del snapshot, del .tar.gz local o del repositorio.
+func openTar(m *Manifest) (io.ReadCloser, error) {
+	if m.Repository != nil {
+		return openRepoTar(m)
+	}
+	f, err := os.Open(m.ArchivePath())
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.f.Close())
+}
+
+func walkTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func short(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMember struct {
+	name string
+	data []byte
+}
+
+// writeTestArchive escribe <id>.tar.gz con los miembros y manifest.json, y
+// completa las entradas y el checksum del archivo en m.
+func writeTestArchive(t *testing.T, m *Manifest, members []testMember) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, mb := range members {
+		e := Entry{Name: mb.name, Kind: KindFile}
+		if strings.HasSuffix(mb.name, ".tar") {
+			e.Kind = KindVolume
+		}
+		if err := addBytes(tw, &e, mb.data, m.Created); err != nil {
+			t.Fatal(err)
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := addBytes(tw, &Entry{Name: manifestName}, data, m.Created); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(AppDir(m.App), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.ArchivePath(), buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	m.Archive = &ArchiveInfo{File: filepath.Base(m.ArchivePath()), Size: int64(buf.Len()), SHA256: hex.EncodeToString(sum[:])}
+}
+
+// volumeTar es el tar de un volumen con un archivo.
+func volumeTar(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "./datos.txt", Mode: 0o644, Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte("hola"))
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyLocalArchive(t *testing.T) {
+	vol := volumeTar(t)
+	tests := []struct {
+		name    string
+		members []testMember
+		mutate  func(t *testing.T, m *Manifest)
+		wantErr string
+	}{
+		{name: "íntegro"},
+		{
+			name:    "checksum de un miembro",
+			mutate:  func(t *testing.T, m *Manifest) { m.Entries[1].SHA256 = strings.Repeat("0", 64) },
+			wantErr: ".env: checksum distinto",
+		},
+		{
+			name:    "tamaño del archivo",
+			mutate:  func(t *testing.T, m *Manifest) { m.Archive.Size++ },
+			wantErr: "tamaño distinto",
+		},
+		{
+			name:    "checksum del archivo",
+			mutate:  func(t *testing.T, m *Manifest) { m.Archive.SHA256 = strings.Repeat("a", 64) },
+			wantErr: "checksum del archivo distinto",
+		},
+		{
+			name: "archivo truncado",
+			mutate: func(t *testing.T, m *Manifest) {
+				if err := os.Truncate(m.ArchivePath(), m.Archive.Size/2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "corrupto",
+		},
+		{
+			name:    "el manifiesto externo no coincide",
+			mutate:  func(t *testing.T, m *Manifest) { m.Entries = append(m.Entries, Entry{Name: "binds/config.tar"}) },
+			wantErr: "no coincide",
+		},
+		{
+			name:    "tar de volumen corrupto",
+			members: []testMember{{"volumes/data.tar", []byte("esto no es un tar, aunque lo parezca por el nombre")}},
+			wantErr: "volumes/data.tar: tar corrupto",
+		},
+		{
+			name:    "sin checksum del archivo",
+			mutate:  func(t *testing.T, m *Manifest) { m.Archive = nil },
+			wantErr: "no tiene checksum",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			m := &Manifest{Version: manifestVersion, ID: "20240501T100000Z", App: "wiki", Created: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+			members := tt.members
+			if members == nil {
+				members = []testMember{
+					{"docker-compose.yml", []byte("services: {}\n")},
+					{".env", []byte("APP_PORT=8080\n")},
+					{"volumes/data.tar", vol},
+				}
+			}
+			writeTestArchive(t, m, members)
+			if tt.mutate != nil {
+				tt.mutate(t, m)
+			}
+			err := Verify(m)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, quiero %q", err, tt.wantErr)
+			}
+		})
+	}
+}
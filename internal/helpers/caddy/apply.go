@@ -0,0 +1,318 @@
+package caddy
+
+import (
+	"autohost-cli/utils"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileChange es una escritura (o borrado si Delete) sobre la configuración de Caddy.
+type FileChange struct {
+	Path    string
+	Content []byte
+	Delete  bool
+}
+
+// ApplyResult resume lo que hizo Apply.
+type ApplyResult struct {
+	Validated bool // `caddy adapt`/`caddy validate` aceptaron la config en staging
+	Reloaded  bool // Caddy cargó la nueva configuración
+	// Skipped explica por qué no se validó o no se recargó (Caddy ausente o detenido).
+	Skipped string
+}
+
+// ConfigError es la configuración rechazada por Caddy (al validar o al recargar).
+type ConfigError struct {
+	Stage      string // "validate" | "reload"
+	Output     string
+	RolledBack bool
+}
+
+func (e *ConfigError) Error() string {
+	msg := fmt.Sprintf("Caddy rechazó la configuración (%s)", e.Stage)
+	if e.RolledBack {
+		msg += "; se restauró la configuración anterior"
+	}
+	if e.Output != "" {
+		msg += ":\n" + e.Output
+	}
+	return msg
+}
+
+// errCaddyNotRunning indica que no hay admin API ni servicio activo que recargar.
+var errCaddyNotRunning = errors.New("caddy no está en ejecución")
+
+// Apply valida los cambios sobre una copia en staging del Caddyfile maestro y
+// los sitios, y solo si Caddy la acepta los escribe en su lugar y recarga.
+// Si la recarga falla restaura los archivos anteriores y vuelve a recargar.
+func Apply(changes ...FileChange) (ApplyResult, error) {
+	return ApplyTo(MasterCaddyfilePath(), changes...)
+}
+
+// ApplyTo es Apply con un Caddyfile maestro explícito.
+func ApplyTo(master string, changes ...FileChange) (ApplyResult, error) {
+	var res ApplyResult
+	if len(changes) == 0 {
+		return res, nil
+	}
+	_, caddyErr := exec.LookPath("caddy")
+
+	if caddyErr == nil {
+		if err := validateStaged(master, changes); err != nil {
+			return res, err
+		}
+		res.Validated = true
+	} else {
+		res.Skipped = "caddy no está instalado; se escribió la configuración sin validar"
+	}
+
+	backups, err := snapshot(changes)
+	if err != nil {
+		return res, err
+	}
+	if err := writeChanges(changes); err != nil {
+		_ = restore(backups)
+		return res, err
+	}
+	if caddyErr != nil {
+		return res, nil
+	}
+
+	err = reload(master)
+	switch {
+	case err == nil:
+		res.Reloaded = true
+		return res, nil
+	case errors.Is(err, errCaddyNotRunning):
+		res.Skipped = "Caddy no está en ejecución; la configuración se aplicará al iniciarlo"
+		return res, nil
+	}
+
+	rollbackErr := restore(backups)
+	if rollbackErr == nil {
+		_ = reload(master)
+	}
+	var cfgErr *ConfigError
+	if errors.As(err, &cfgErr) {
+		cfgErr.RolledBack = rollbackErr == nil
+		return res, cfgErr
+	}
+	if rollbackErr != nil {
+		return res, fmt.Errorf("recarga de Caddy falló (%v) y no se pudo restaurar la configuración: %w", err, rollbackErr)
+	}
+	return res, fmt.Errorf("recarga de Caddy falló; se restauró la configuración anterior: %w", err)
+}
+
+// -----------------------------------------------------------------------------
+// Staging y validación
+// -----------------------------------------------------------------------------
+
+// validateStaged copia el Caddyfile maestro y los sitios a un directorio
+// temporal, aplica ahí los cambios y ejecuta `caddy adapt` y `caddy validate`.
+func validateStaged(master string, changes []FileChange) error {
+	tmp, err := os.MkdirTemp("", "autohost-caddy-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	sitesDir := SitesDir()
+	stagedSites := filepath.Join(tmp, "sites")
+	if err := os.MkdirAll(stagedSites, 0o755); err != nil {
+		return err
+	}
+	files, _ := filepath.Glob(filepath.Join(sitesDir, "*.caddy"))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(stagedSites, filepath.Base(f)), b, 0o644); err != nil {
+			return err
+		}
+	}
+
+	masterContent, err := os.ReadFile(master)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, c := range changes {
+		switch {
+		case c.Path == master:
+			masterContent = c.Content
+		case filepath.Dir(c.Path) == sitesDir:
+			staged := filepath.Join(stagedSites, filepath.Base(c.Path))
+			if c.Delete {
+				_ = os.Remove(staged)
+			} else if err := os.WriteFile(staged, c.Content, 0o644); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("cambio fuera de la configuración de Caddy: %s", c.Path)
+		}
+	}
+
+	// El import apunta a los sitios en staging en vez de los reales.
+	importLive := "import " + filepath.Join(sitesDir, "*.caddy")
+	importStaged := "import " + filepath.Join(stagedSites, "*.caddy")
+	stagedMaster := filepath.Join(tmp, "Caddyfile")
+	content := strings.ReplaceAll(string(masterContent), importLive, importStaged)
+	if err := os.WriteFile(stagedMaster, []byte(content), 0o644); err != nil {
+		return err
+	}
+
+	for _, sub := range []string{"adapt", "validate"} {
+		c := utils.Command("caddy", sub, "--config", stagedMaster, "--adapter", "caddyfile")
+		c.ReadOnly = true
+		var out bytes.Buffer
+		c.Stderr = &out
+		if _, err := utils.DefaultRunner().Output(c); err != nil {
+			return &ConfigError{Stage: "validate", Output: cleanCaddyOutput(out.String(), tmp, master)}
+		}
+	}
+	return nil
+}
+
+// cleanCaddyOutput deja solo las líneas de error y cambia las rutas de staging por las reales.
+func cleanCaddyOutput(out, staged, master string) string {
+	out = strings.ReplaceAll(out, filepath.Join(staged, "sites"), SitesDir())
+	out = strings.ReplaceAll(out, filepath.Join(staged, "Caddyfile"), master)
+	var lines []string
+	for _, ln := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.Contains(ln, `"level":"info"`) || strings.Contains(ln, "\tINFO\t") {
+			continue
+		}
+		lines = append(lines, ln)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// -----------------------------------------------------------------------------
+// Escritura y rollback
+// -----------------------------------------------------------------------------
+
+type backup struct {
+	path    string
+	content []byte
+	existed bool
+}
+
+func snapshot(changes []FileChange) ([]backup, error) {
+	out := make([]backup, 0, len(changes))
+	for _, c := range changes {
+		b, err := os.ReadFile(c.Path)
+		switch {
+		case err == nil:
+			out = append(out, backup{path: c.Path, content: b, existed: true})
+		case os.IsNotExist(err):
+			out = append(out, backup{path: c.Path})
+		default:
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func writeChanges(changes []FileChange) error {
+	r := utils.DefaultRunner()
+	for _, c := range changes {
+		if c.Delete {
+			if err := r.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := r.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+			return err
+		}
+		if err := r.WriteFile(c.Path, c.Content, 0o644); err != nil {
+			return fmt.Errorf("no se pudo escribir %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+func restore(backups []backup) error {
+	r := utils.DefaultRunner()
+	var errs []error
+	for _, b := range backups {
+		var err error
+		if b.existed {
+			err = r.WriteFile(b.path, b.content, 0o644)
+		} else if err = r.Remove(b.path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// -----------------------------------------------------------------------------
+// Recarga
+// -----------------------------------------------------------------------------
+
+// adminAddr devuelve la dirección de la admin API (CADDY_ADMIN o localhost:2019).
+func adminAddr() string {
+	if a := strings.TrimSpace(os.Getenv("CADDY_ADMIN")); a != "" {
+		return a
+	}
+	return "localhost:2019"
+}
+
+// reload carga el Caddyfile maestro por la admin API; si no responde, usa
+// `systemctl reload caddy` cuando el servicio está activo.
+func reload(master string) error {
+	if utils.DefaultRunner().DryRun() {
+		fmt.Printf("%s recargar Caddy (POST http://%s/load con %s)\n", utils.DryRunPrefix, adminAddr(), master)
+		return nil
+	}
+	body, err := os.ReadFile(master)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest(http.MethodPost, "http://"+adminAddr()+"/load", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/caddyfile")
+	resp, err := client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return &ConfigError{Stage: "reload", Output: strings.TrimSpace(string(msg))}
+	}
+
+	// Sin admin API: systemd si el servicio está activo.
+	active := utils.Command("systemctl", "is-active", "--quiet", "caddy")
+	active.ReadOnly = true
+	if _, lookErr := exec.LookPath("systemctl"); lookErr != nil || utils.DefaultRunner().Run(active) != nil {
+		return errCaddyNotRunning
+	}
+	var out bytes.Buffer
+	c := utils.Command("sudo", "systemctl", "reload", "caddy")
+	c.Stdout, c.Stderr = io.Discard, &out
+	if err := utils.DefaultRunner().Run(c); err != nil {
+		return &ConfigError{Stage: "reload", Output: strings.TrimSpace(out.String())}
+	}
+	return nil
+}
+
+// Reload recarga Caddy con el Caddyfile maestro actual.
+func Reload() error {
+	return reload(MasterCaddyfilePath())
+}
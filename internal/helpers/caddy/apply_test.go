@@ -0,0 +1,185 @@
+package caddy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCaddyBin pone en el PATH un `caddy` que acepta la config salvo que
+// contenga "ROTO", en cuyo caso falla como `caddy validate`.
+func fakeCaddyBin(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--config" ]; then cfg="$2"; fi
+  shift
+done
+if cat "$cfg" $(sed -n 's/^import //p' "$cfg") 2>/dev/null | grep -q ROTO; then
+  echo '{"level":"info","msg":"using config"}' >&2
+  echo "Error: adapting config using caddyfile: $cfg:3: unrecognized directive: ROTO" >&2
+  exit 1
+fi
+`
+	if err := os.WriteFile(filepath.Join(bin, "caddy"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// fakeAdmin simula la admin API de Caddy: responde status a cada POST /load y
+// guarda los cuerpos recibidos.
+type fakeAdmin struct {
+	mu     sync.Mutex
+	status []int
+	loads  []string
+}
+
+func newFakeAdmin(t *testing.T, status ...int) *fakeAdmin {
+	t.Helper()
+	a := &fakeAdmin{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/load" || r.Header.Get("Content-Type") != "text/caddyfile" {
+			t.Errorf("petición inesperada %s %s", r.Method, r.URL)
+		}
+		a.loads = append(a.loads, string(body))
+		code := http.StatusOK
+		if len(a.status) > 0 {
+			code, a.status = a.status[0], a.status[1:]
+		}
+		if code >= 300 {
+			http.Error(w, `{"error":"loading config: tls: certificado inválido"}`, code)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("CADDY_ADMIN", strings.TrimPrefix(srv.URL, "http://"))
+	return a
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "<no existe>"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestApplyToReloads(t *testing.T) {
+	master := useCaddyHome(t)
+	fakeCaddyBin(t)
+	admin := newFakeAdmin(t)
+	site := filepath.Join(SitesDir(), "a.lan.caddy")
+
+	res, err := ApplyTo(master, FileChange{Path: master, Content: []byte("import x\n")}, FileChange{Path: site, Content: []byte("a.lan {\n}\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Validated || !res.Reloaded || res.Skipped != "" {
+		t.Fatalf("res = %+v", res)
+	}
+	if readFile(t, site) != "a.lan {\n}\n" || len(admin.loads) != 1 || admin.loads[0] != "import x\n" {
+		t.Fatalf("sitio %q, cargas %q", readFile(t, site), admin.loads)
+	}
+}
+
+// Una config rechazada en staging no toca los archivos reales ni recarga.
+func TestApplyToValidateFails(t *testing.T) {
+	master := useCaddyHome(t)
+	fakeCaddyBin(t)
+	admin := newFakeAdmin(t)
+	if err := os.WriteFile(master, []byte("# original\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	site := filepath.Join(SitesDir(), "b.lan.caddy")
+
+	_, err := ApplyTo(master, FileChange{Path: site, Content: []byte("b.lan {\n\tROTO\n}\n")}, FileChange{Path: master, Content: []byte("import " + filepath.Join(SitesDir(), "*.caddy") + "\n")})
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || cfgErr.Stage != "validate" || cfgErr.RolledBack {
+		t.Fatalf("err = %v", err)
+	}
+	// La salida apunta al Caddyfile real, no al de staging, y sin líneas INFO.
+	if !strings.Contains(cfgErr.Output, master+":3: unrecognized directive") || strings.Contains(cfgErr.Output, `"level":"info"`) {
+		t.Fatalf("salida = %q", cfgErr.Output)
+	}
+	if readFile(t, master) != "# original\n" || readFile(t, site) != "<no existe>" || len(admin.loads) != 0 {
+		t.Fatalf("se tocó la configuración: maestro %q, sitio %q, cargas %d", readFile(t, master), readFile(t, site), len(admin.loads))
+	}
+}
+
+// Si Caddy rechaza la recarga se restauran los archivos (incluido borrar los
+// nuevos) y se recarga la configuración anterior.
+func TestApplyToRollbackOnReloadFailure(t *testing.T) {
+	master := useCaddyHome(t)
+	fakeCaddyBin(t)
+	admin := newFakeAdmin(t, http.StatusBadRequest, http.StatusOK)
+	if err := os.MkdirAll(SitesDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	oldSite := filepath.Join(SitesDir(), "viejo.lan.caddy")
+	newSite := filepath.Join(SitesDir(), "nuevo.lan.caddy")
+	gone := filepath.Join(SitesDir(), "borrado.lan.caddy")
+	for path, content := range map[string]string{master: "# v1\n", oldSite: "viejo.lan {\n}\n", gone: "borrado.lan {\n}\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := ApplyTo(master,
+		FileChange{Path: master, Content: []byte("# v2\n")},
+		FileChange{Path: oldSite, Content: []byte("viejo.lan {\n\ttls x\n}\n")},
+		FileChange{Path: newSite, Content: []byte("nuevo.lan {\n}\n")},
+		FileChange{Path: gone, Delete: true},
+	)
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || cfgErr.Stage != "reload" || !cfgErr.RolledBack || !strings.Contains(cfgErr.Output, "certificado inválido") {
+		t.Fatalf("err = %v", err)
+	}
+	want := map[string]string{master: "# v1\n", oldSite: "viejo.lan {\n}\n", newSite: "<no existe>", gone: "borrado.lan {\n}\n"}
+	for path, content := range want {
+		if got := readFile(t, path); got != content {
+			t.Errorf("%s = %q, quiero %q", filepath.Base(path), got, content)
+		}
+	}
+	if len(admin.loads) != 2 || admin.loads[0] != "# v2\n" || admin.loads[1] != "# v1\n" {
+		t.Fatalf("cargas = %q, quiero la nueva y luego la restaurada", admin.loads)
+	}
+}
+
+// Sin caddy instalado se escribe sin validar y se informa por qué.
+func TestApplyToWithoutCaddy(t *testing.T) {
+	master := useCaddyHome(t)
+	t.Setenv("PATH", t.TempDir())
+	res, err := ApplyTo(master, FileChange{Path: master, Content: []byte("# x\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Validated || res.Reloaded || !strings.Contains(res.Skipped, "no está instalado") || readFile(t, master) != "# x\n" {
+		t.Fatalf("res = %+v, maestro %q", res, readFile(t, master))
+	}
+}
+
+func TestApplyToRejectsOutsidePaths(t *testing.T) {
+	master := useCaddyHome(t)
+	fakeCaddyBin(t)
+	outside := filepath.Join(t.TempDir(), "otro.caddy")
+	if _, err := ApplyTo(master, FileChange{Path: outside, Content: []byte("x")}); err == nil || !strings.Contains(err.Error(), "fuera de la configuración") {
+		t.Fatalf("err = %v", err)
+	}
+	if readFile(t, outside) != "<no existe>" {
+		t.Fatal("se escribió un archivo fuera de la configuración de Caddy")
+	}
+}
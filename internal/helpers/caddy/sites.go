@@ -0,0 +1,366 @@
+package caddy
+
+import (
+	"autohost-cli/utils"
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// siteHeader identifica los archivos de sitio escritos por el store.
+const siteHeader = "# autohost:site v1"
+
+// importMarker precede a la línea import que mantenemos en el Caddyfile maestro.
+const importMarker = "# autohost: sitios gestionados (no editar esta línea)"
+
+// Site es un sitio de Caddy gestionado por autohost: un archivo
+// ~/.autohost/caddy/sites/<host>.caddy con un encabezado de metadatos.
+type Site struct {
+	Host     string `json:"host"`
+	Upstream string `json:"upstream"`
+	App      string `json:"app,omitempty"`
+	// HTTPOnly sirve el sitio solo por HTTP (sin certificado propio), p. ej.
+	// detrás de Cloudflare Tunnel, que ya termina TLS.
+	HTTPOnly bool      `json:"http_only,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Path     string    `json:"path"`
+	// Legacy marca archivos sin encabezado (escritos por versiones anteriores).
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+// SiteSpec describe el sitio a crear o actualizar.
+type SiteSpec struct {
+	Host     string
+	Upstream string // "3000", "localhost:3000" o "http://10.0.0.5:8080"
+	App      string
+	HTTPOnly bool
+}
+
+var (
+	hostRe         = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+	legacyHostRe   = regexp.MustCompile(`^\s*([^\s#{][^\s{]*)\s*\{\s*$`)
+	reverseProxyRe = regexp.MustCompile(`^\s*reverse_proxy\s+(\S+)`)
+)
+
+// SitesDir es el directorio de los sitios gestionados.
+func SitesDir() string {
+	return filepath.Join(utils.GetSubdir("caddy"), "sites")
+}
+
+// MasterCaddyfilePath devuelve el Caddyfile que carga Caddy: AUTOHOST_CADDYFILE,
+// /etc/caddy/Caddyfile si existe (instalación por paquete) o ~/.autohost/caddy/Caddyfile.
+func MasterCaddyfilePath() string {
+	if p := strings.TrimSpace(os.Getenv("AUTOHOST_CADDYFILE")); p != "" {
+		return p
+	}
+	if _, err := os.Stat("/etc/caddy/Caddyfile"); err == nil {
+		return "/etc/caddy/Caddyfile"
+	}
+	return filepath.Join(utils.GetSubdir("caddy"), "Caddyfile")
+}
+
+// NormalizeHost valida y pasa a minúsculas el host de un sitio.
+func NormalizeHost(host string) (string, error) {
+	h := strings.ToLower(strings.TrimSpace(host))
+	h = strings.TrimSuffix(h, ".")
+	if !hostRe.MatchString(h) {
+		return "", fmt.Errorf("host inválido: %q (ej: app.maza-server)", host)
+	}
+	return h, nil
+}
+
+// NormalizeUpstream acepta un puerto, host:puerto o una URL http(s).
+func NormalizeUpstream(up string) (string, error) {
+	u := strings.TrimSpace(up)
+	if u == "" {
+		return "", fmt.Errorf("upstream vacío")
+	}
+	if p, err := strconv.Atoi(u); err == nil {
+		if p < 1 || p > 65535 {
+			return "", fmt.Errorf("puerto fuera de rango: %d", p)
+		}
+		return "localhost:" + u, nil
+	}
+	rest := u
+	for _, scheme := range []string{"http://", "https://"} {
+		rest = strings.TrimPrefix(rest, scheme)
+	}
+	h, p, err := net.SplitHostPort(strings.TrimSuffix(rest, "/"))
+	if err != nil || h == "" {
+		return "", fmt.Errorf("upstream inválido: %q (usa puerto, host:puerto o http://host:puerto)", up)
+	}
+	if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("puerto inválido en upstream: %q", up)
+	}
+	return strings.TrimSuffix(u, "/"), nil
+}
+
+// Port devuelve el puerto local del upstream si apunta a localhost (0 si no).
+func (s Site) Port() int {
+	rest := s.Upstream
+	for _, scheme := range []string{"http://", "https://"} {
+		rest = strings.TrimPrefix(rest, scheme)
+	}
+	h, p, err := net.SplitHostPort(rest)
+	if err != nil {
+		return 0
+	}
+	switch h {
+	case "localhost", "127.0.0.1", "::1":
+		n, _ := strconv.Atoi(p)
+		return n
+	}
+	return 0
+}
+
+// sitePath arma la ruta del archivo del sitio (compatible con los de `expose`).
+func sitePath(host string) string {
+	name := strings.NewReplacer("*", "_wildcard", "/", "_", ":", "_").Replace(host)
+	return filepath.Join(SitesDir(), name+".caddy")
+}
+
+// renderSite genera el contenido del archivo con encabezado y bloque de sitio.
+func renderSite(s Site) []byte {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, siteHeader)
+	fmt.Fprintf(&b, "# host: %s\n", s.Host)
+	fmt.Fprintf(&b, "# upstream: %s\n", s.Upstream)
+	if s.App != "" {
+		fmt.Fprintf(&b, "# app: %s\n", s.App)
+	}
+	addr := s.Host
+	if s.HTTPOnly {
+		fmt.Fprintln(&b, "# scheme: http")
+		addr = "http://" + s.Host
+	}
+	fmt.Fprintf(&b, "# created: %s\n", s.Created.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# updated: %s\n", s.Updated.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "%s {\n\tencode zstd gzip\n\treverse_proxy %s\n}\n", addr, s.Upstream)
+	return b.Bytes()
+}
+
+// parseSite lee un archivo de sitio; los que no tienen encabezado se
+// interpretan con el formato anterior (primer bloque + reverse_proxy).
+func parseSite(path string, data []byte) (Site, error) {
+	s := Site{Path: path}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	first := true
+	for sc.Scan() {
+		ln := sc.Text()
+		if first {
+			first = false
+			if strings.TrimSpace(ln) != siteHeader {
+				s.Legacy = true
+			}
+		}
+		if !s.Legacy && strings.HasPrefix(ln, "# ") {
+			k, v, ok := strings.Cut(strings.TrimPrefix(ln, "# "), ":")
+			if !ok {
+				continue
+			}
+			v = strings.TrimSpace(v)
+			switch strings.TrimSpace(k) {
+			case "host":
+				s.Host = v
+			case "upstream":
+				s.Upstream = v
+			case "app":
+				s.App = v
+			case "scheme":
+				s.HTTPOnly = v == "http"
+			case "created":
+				s.Created, _ = time.Parse(time.RFC3339, v)
+			case "updated":
+				s.Updated, _ = time.Parse(time.RFC3339, v)
+			}
+			continue
+		}
+		if m := legacyHostRe.FindStringSubmatch(ln); m != nil && s.Host == "" {
+			s.Host = m[1]
+		}
+		if m := reverseProxyRe.FindStringSubmatch(ln); m != nil && s.Upstream == "" {
+			s.Upstream = m[1]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return s, err
+	}
+	if s.Host == "" {
+		return s, fmt.Errorf("%s: no se encontró el host del sitio", path)
+	}
+	if s.Legacy {
+		if fi, err := os.Stat(path); err == nil {
+			s.Created, s.Updated = fi.ModTime(), fi.ModTime()
+		}
+	}
+	return s, nil
+}
+
+// ListSites devuelve los sitios gestionados ordenados por host.
+func ListSites() ([]Site, error) {
+	files, err := filepath.Glob(filepath.Join(SitesDir(), "*.caddy"))
+	if err != nil {
+		return nil, err
+	}
+	sites := make([]Site, 0, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s, err := parseSite(f, b)
+		if err != nil {
+			continue
+		}
+		sites = append(sites, s)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Host < sites[j].Host })
+	return sites, nil
+}
+
+// GetSite busca un sitio por host; devuelve nil si no existe.
+func GetSite(host string) (*Site, error) {
+	h, err := NormalizeHost(host)
+	if err != nil {
+		return nil, err
+	}
+	path := sitePath(h)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseSite(path, b)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// AddSite crea o actualiza un sitio y recarga Caddy (ver Apply). Es
+// idempotente: si el host ya apunta al mismo upstream no se toca nada y
+// changed es false.
+func AddSite(spec SiteSpec) (site *Site, changed bool, res ApplyResult, err error) {
+	host, err := NormalizeHost(spec.Host)
+	if err != nil {
+		return nil, false, res, err
+	}
+	upstream, err := NormalizeUpstream(spec.Upstream)
+	if err != nil {
+		return nil, false, res, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	s := Site{Host: host, Upstream: upstream, App: spec.App, HTTPOnly: spec.HTTPOnly, Created: now, Updated: now, Path: sitePath(host)}
+
+	prev, err := GetSite(host)
+	if err != nil {
+		return nil, false, res, err
+	}
+	var changes []FileChange
+	if prev != nil && s.App == "" {
+		s.App = prev.App
+	}
+	switch {
+	case prev != nil && !prev.Legacy && prev.Upstream == s.Upstream && prev.App == s.App && prev.HTTPOnly == s.HTTPOnly:
+		s = *prev
+	case prev != nil && !prev.Created.IsZero():
+		s.Created = prev.Created
+		fallthrough
+	default:
+		changes = append(changes, FileChange{Path: s.Path, Content: renderSite(s)})
+	}
+	imp, err := importChange()
+	if err != nil {
+		return nil, false, res, err
+	}
+	if imp != nil {
+		changes = append(changes, *imp)
+	}
+	if len(changes) == 0 {
+		return &s, false, res, nil
+	}
+	res, err = Apply(changes...)
+	if err != nil {
+		return nil, false, res, err
+	}
+	return &s, true, res, nil
+}
+
+// RemoveSite elimina el archivo del sitio y recarga Caddy; removed es false si no existía.
+func RemoveSite(host string) (removed bool, res ApplyResult, err error) {
+	s, err := GetSite(host)
+	if err != nil || s == nil {
+		return false, res, err
+	}
+	res, err = Apply(FileChange{Path: s.Path, Delete: true})
+	if err != nil {
+		return false, res, err
+	}
+	return true, res, nil
+}
+
+// EnsureImport deja exactamente una línea `import <sites>/*.caddy` en el
+// Caddyfile maestro (creándolo si no existe) y elimina duplicados previos.
+func EnsureImport() (ApplyResult, error) {
+	imp, err := importChange()
+	if err != nil || imp == nil {
+		return ApplyResult{}, err
+	}
+	return Apply(*imp)
+}
+
+// importChange calcula el Caddyfile maestro con la línea import; nil si ya está.
+func importChange() (*FileChange, error) {
+	master := MasterCaddyfilePath()
+	importLine := "import " + filepath.Join(SitesDir(), "*.caddy")
+
+	b, err := os.ReadFile(master)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	content := string(b)
+	if os.IsNotExist(err) {
+		content = "# Caddyfile de AutoHost\n"
+	}
+
+	var out []string
+	found := false
+	for _, ln := range strings.Split(content, "\n") {
+		t := strings.TrimSpace(ln)
+		if t == importMarker || t == "# autohost import" {
+			continue
+		}
+		if t == importLine {
+			if found {
+				continue
+			}
+			found = true
+			out = append(out, importMarker, importLine)
+			continue
+		}
+		out = append(out, ln)
+	}
+	if !found {
+		for len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+			out = out[:len(out)-1]
+		}
+		out = append(out, "", importMarker, importLine)
+	}
+	next := strings.TrimRight(strings.Join(out, "\n"), "\n") + "\n"
+	if next == string(b) {
+		return nil, nil
+	}
+	return &FileChange{Path: master, Content: []byte(next)}, nil
+}
@@ -0,0 +1,196 @@
+package caddy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useCaddyHome aísla HOME y fija el Caddyfile maestro en un directorio temporal.
+func useCaddyHome(t *testing.T) (master string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	master = filepath.Join(t.TempDir(), "Caddyfile")
+	t.Setenv("AUTOHOST_CADDYFILE", master)
+	return master
+}
+
+func TestParseSite(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		data    string
+		want    Site
+		wantErr bool
+	}{
+		{
+			name: "render y parse",
+			data: string(renderSite(Site{Host: "wiki.maza-server", Upstream: "localhost:6875", App: "bookstack", Created: created, Updated: created})),
+			want: Site{Host: "wiki.maza-server", Upstream: "localhost:6875", App: "bookstack", Created: created, Updated: created},
+		},
+		{
+			name: "solo http",
+			data: string(renderSite(Site{Host: "app.example.com", Upstream: "localhost:3000", HTTPOnly: true, Created: created, Updated: created})),
+			want: Site{Host: "app.example.com", Upstream: "localhost:3000", HTTPOnly: true, Created: created, Updated: created},
+		},
+		{
+			name: "comentarios extra en el encabezado",
+			data: "# autohost:site v1\n# host: a.lan\n# sin dos puntos\n# upstream: localhost:80\n# otra: cosa\na.lan {\n\treverse_proxy localhost:80\n}\n",
+			want: Site{Host: "a.lan", Upstream: "localhost:80"},
+		},
+		{
+			name: "legacy de expose",
+			data: "plex.maza-server {\n    reverse_proxy 127.0.0.1:32400\n}\n",
+			want: Site{Host: "plex.maza-server", Upstream: "127.0.0.1:32400", Legacy: true},
+		},
+		{
+			// Sin encabezado, los "# host:" son comentarios y no metadatos.
+			name: "legacy con comentarios",
+			data: "# host: engaño\nviejo.lan {\n  reverse_proxy localhost:1\n}\n",
+			want: Site{Host: "viejo.lan", Upstream: "localhost:1", Legacy: true},
+		},
+		{name: "sin host", data: "# autohost:site v1\n# upstream: localhost:80\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSite("/no/existe.caddy", []byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("se esperaba error, site = %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Path = "/no/existe.caddy"
+			if got != tt.want {
+				t.Fatalf("parseSite =\n%+v\nquiero\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeUpstreamAndPort(t *testing.T) {
+	tests := []struct {
+		in, want string
+		port     int
+		wantErr  bool
+	}{
+		{in: "3000", want: "localhost:3000", port: 3000},
+		{in: " localhost:8080 ", want: "localhost:8080", port: 8080},
+		{in: "http://127.0.0.1:9000/", want: "http://127.0.0.1:9000", port: 9000},
+		{in: "https://[::1]:8443", want: "https://[::1]:8443", port: 8443},
+		{in: "10.0.0.5:80", want: "10.0.0.5:80", port: 0},
+		{in: "70000", wantErr: true},
+		{in: "localhost", wantErr: true},
+		{in: "http://:80", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeUpstream(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: se esperaba error, got %q", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: NormalizeUpstream = %q, %v; quiero %q", tt.in, got, err, tt.want)
+			continue
+		}
+		if p := (Site{Upstream: got}).Port(); p != tt.port {
+			t.Errorf("%q: Port() = %d, quiero %d", tt.in, p, tt.port)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	for in, want := range map[string]string{"Wiki.Maza-Server.": "wiki.maza-server", "*.example.com": "*.example.com", "a": "a"} {
+		if got, err := NormalizeHost(in); err != nil || got != want {
+			t.Errorf("NormalizeHost(%q) = %q, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "-a.com", "a..b", "http://a.com", "a_b.com", "a.*.com"} {
+		if _, err := NormalizeHost(in); err == nil {
+			t.Errorf("NormalizeHost(%q): se esperaba error", in)
+		}
+	}
+}
+
+func TestImportChange(t *testing.T) {
+	master := useCaddyHome(t)
+	importLine := "import " + filepath.Join(SitesDir(), "*.caddy")
+	tests := []struct {
+		name     string
+		existing *string // nil = el maestro no existe
+		want     string  // "" = sin cambios
+	}{
+		{
+			name: "maestro inexistente",
+			want: "# Caddyfile de AutoHost\n\n" + importMarker + "\n" + importLine + "\n",
+		},
+		{
+			name:     "agrega al final",
+			existing: ptr(":80 {\n\trespond ok\n}\n\n\n"),
+			want:     ":80 {\n\trespond ok\n}\n\n" + importMarker + "\n" + importLine + "\n",
+		},
+		{
+			name:     "ya está",
+			existing: ptr("# global\n\n" + importMarker + "\n" + importLine + "\n"),
+		},
+		{
+			name:     "duplicados y marcador viejo",
+			existing: ptr("# autohost import\n" + importLine + "\n:80 {\n}\n" + importLine + "\n"),
+			want:     importMarker + "\n" + importLine + "\n:80 {\n}\n",
+		},
+		{
+			name:     "import sin marcador",
+			existing: ptr("a.lan {\n}\n" + importLine + "\n"),
+			want:     "a.lan {\n}\n" + importMarker + "\n" + importLine + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(master)
+			if tt.existing != nil {
+				if err := os.WriteFile(master, []byte(*tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ch, err := importChange()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if ch != nil {
+					t.Fatalf("cambio inesperado:\n%s", ch.Content)
+				}
+				return
+			}
+			if ch == nil || ch.Path != master || string(ch.Content) != tt.want {
+				t.Fatalf("importChange =\n%+v\nquiero\n%s", ch, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+// MasterCaddyfilePath es el archivo donde se escribe el import: el seed de
+// `caddy install` tiene que ser ese mismo archivo.
+func TestMasterCaddyfilePathOverride(t *testing.T) {
+	master := useCaddyHome(t)
+	if got := MasterCaddyfilePath(); got != master {
+		t.Fatalf("MasterCaddyfilePath() = %q, quiero %q", got, master)
+	}
+	t.Setenv("AUTOHOST_CADDYFILE", "")
+	if _, err := os.Stat("/etc/caddy/Caddyfile"); err == nil {
+		t.Skip("el host tiene /etc/caddy/Caddyfile")
+	}
+	if got := MasterCaddyfilePath(); !strings.HasSuffix(got, filepath.Join(".autohost", "caddy", "Caddyfile")) {
+		t.Fatalf("MasterCaddyfilePath() = %q", got)
+	}
+}
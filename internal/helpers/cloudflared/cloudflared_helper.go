@@ -1,24 +1,207 @@
 package cloudflared
 
 import (
+	"autohost-cli/internal/infra"
 	"autohost-cli/utils"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-func InstallCloudflared() {
+func shell(script string) error { return utils.DefaultRunner().Run(utils.ShellCmd(script)) }
+
+// InstallCloudflared descarga cloudflared verificado y lo instala en /usr/local/bin.
+func InstallCloudflared() error {
 	fmt.Println("🌐 Instalando Cloudflare Tunnel (cloudflared)...")
-	utils.ExecShell(`
-		curl -L https://github.com/cloudflare/cloudflared/releases/latest/download/cloudflared-linux-amd64 -o cloudflared &&
-		chmod +x cloudflared &&
-		sudo mv cloudflared /usr/local/bin/
-	`)
-	fmt.Println("✅ Cloudflare Tunnel instalado.")
+	if utils.DefaultRunner().DryRun() {
+		fmt.Printf("%s descargar cloudflared (verificado) e instalarlo en /usr/local/bin\n", utils.DryRunPrefix)
+		return nil
+	}
+	path, version, err := infra.FetchCloudflared()
+	if err != nil {
+		return err
+	}
+	if err := utils.DefaultRunner().Run(utils.Command("sudo", "install", "-m", "0755", path, "/usr/local/bin/cloudflared")); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Cloudflare Tunnel %s instalado.\n", version)
 	fmt.Println("ℹ️ Ejecuta 'cloudflared tunnel login' para autenticarte.")
+	return nil
 }
 
-func ConfigureCloudflareTunnel(domain string) {
+// ConfigureCloudflareTunnel crea (o reutiliza) el túnel name y publica domain
+// hacia service: regla de ingress, config.yml validado y registro DNS.
+func ConfigureCloudflareTunnel(name, domain, service string) (ApplyResult, error) {
 	fmt.Println("⚙️ Configurando Cloudflare Tunnel para:", domain)
-	utils.ExecShell("cloudflared tunnel create autohost-tunnel")
-	utils.ExecShell(fmt.Sprintf("cloudflared tunnel route dns autohost-tunnel %s", domain))
-	fmt.Println("✅ Túnel configurado correctamente.")
+	_, _, res, err := EnsureRoute(name, IngressRule{Hostname: domain, Service: service})
+	return res, err
+}
+
+// EnsureRoute publica la regla r en el túnel name: crea el túnel si aún no hay
+// uno configurado (o si name es otro), aplica config.yml si algo cambió y
+// crea el registro DNS del hostname.
+func EnsureRoute(name string, r IngressRule) (c *TunnelConfig, changed bool, res ApplyResult, err error) {
+	c, err = LoadConfig()
+	if err != nil {
+		return nil, false, res, err
+	}
+	if c.TunnelID == "" || (name != "" && name != c.Tunnel) {
+		if c, err = SetupTunnel(name); err != nil {
+			return nil, false, res, err
+		}
+		changed = true
+	}
+	ruleChanged, err := c.upsertRule(r)
+	if err != nil {
+		return nil, false, res, err
+	}
+	if changed = changed || ruleChanged; changed {
+		if res, err = Apply(c); err != nil {
+			return nil, false, res, err
+		}
+	}
+	return c, changed, res, RouteDNS(c, strings.ToLower(strings.TrimSuffix(r.Hostname, ".")))
+}
+
+// AddRoute agrega (o actualiza) una regla de ingress en el túnel configurado
+// y, si dns es true, crea el registro DNS del hostname una vez validada.
+func AddRoute(r IngressRule, dns bool) (changed bool, res ApplyResult, err error) {
+	c, err := LoadConfig()
+	if err != nil {
+		return false, res, err
+	}
+	if c.TunnelID == "" {
+		return false, res, errors.New("no hay túnel configurado; créalo con `autohost cloudflare tunnel <dominio>`")
+	}
+	if changed, err = c.upsertRule(r); err != nil || !changed {
+		return false, res, err
+	}
+	if res, err = Apply(c); err != nil {
+		return false, res, err
+	}
+	if dns {
+		if err := RouteDNS(c, strings.ToLower(strings.TrimSuffix(r.Hostname, "."))); err != nil {
+			return true, res, err
+		}
+	}
+	return true, res, nil
+}
+
+// RemoveRoute quita la regla (hostname, path). El registro DNS se deja en
+// Cloudflare: cloudflared no permite borrarlo.
+func RemoveRoute(hostname, path string) (removed bool, res ApplyResult, err error) {
+	c, err := LoadConfig()
+	if err != nil {
+		return false, res, err
+	}
+	if !c.removeRule(hostname, path) {
+		return false, res, nil
+	}
+	res, err = Apply(c)
+	return err == nil, res, err
+}
+
+// SetupTunnel crea el túnel name (o reutiliza uno existente con ese nombre),
+// copia sus credenciales <UUID>.json a ~/.autohost/cloudflare y devuelve el
+// modelo actualizado, sin guardarlo: eso lo hace Apply tras validar.
+func SetupTunnel(name string) (*TunnelConfig, error) {
+	c, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		c.Tunnel = name
+	}
+
+	id, err := tunnelID(c.Tunnel)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		fmt.Printf("⚙️ Creando túnel %s...\n", c.Tunnel)
+		if err := utils.DefaultRunner().Run(utils.Command("cloudflared", "tunnel", "create", c.Tunnel)); err != nil {
+			return nil, fmt.Errorf("no se pudo crear el túnel %s: %w", c.Tunnel, err)
+		}
+		if id, err = tunnelID(c.Tunnel); err != nil {
+			return nil, err
+		}
+		if id == "" && utils.DefaultRunner().DryRun() {
+			id = "00000000-0000-0000-0000-000000000000"
+		}
+		if id == "" {
+			return nil, fmt.Errorf("el túnel %s no aparece en `cloudflared tunnel list` tras crearlo", c.Tunnel)
+		}
+	} else {
+		fmt.Printf("ℹ️ Usando el túnel existente %s (%s)\n", c.Tunnel, id)
+	}
+
+	creds, err := copyCredentials(id)
+	if err != nil {
+		return nil, err
+	}
+	c.TunnelID = id
+	c.CredentialsFile = creds
+	return c, nil
+}
+
+// tunnelID busca el UUID del túnel por nombre; "" si no existe.
+func tunnelID(name string) (string, error) {
+	list := utils.Command("cloudflared", "tunnel", "list", "--output", "json", "--name", name)
+	list.ReadOnly = true
+	out, err := utils.DefaultRunner().Output(list)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo listar los túneles (¿ejecutaste `autohost cloudflare login`?): %w", err)
+	}
+	var tunnels []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if len(strings.TrimSpace(string(out))) > 0 {
+		if err := json.Unmarshal(out, &tunnels); err != nil {
+			return "", fmt.Errorf("salida inesperada de `cloudflared tunnel list`: %w", err)
+		}
+	}
+	for _, t := range tunnels {
+		if t.Name == name {
+			return t.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// copyCredentials copia ~/.cloudflared/<id>.json (lo crea `tunnel create`) a
+// ~/.autohost/cloudflare/<id>.json con permisos 0600.
+func copyCredentials(id string) (string, error) {
+	dst := filepath.Join(Dir(), id+".json")
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+	home, _ := os.UserHomeDir()
+	src := filepath.Join(home, ".cloudflared", id+".json")
+	data, err := os.ReadFile(src)
+	if err != nil {
+		if utils.DefaultRunner().DryRun() {
+			fmt.Printf("%s copiar %s a %s\n", utils.DryRunPrefix, src, dst)
+			return dst, nil
+		}
+		return "", fmt.Errorf("no encuentro las credenciales del túnel (%s); si se creó en otra máquina, cópialas ahí: %w", src, err)
+	}
+	if err := utils.DefaultRunner().MkdirAll(Dir(), 0o700); err != nil {
+		return "", err
+	}
+	if err := utils.DefaultRunner().WriteFile(dst, data, 0o600); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// RouteDNS crea el CNAME de hostname hacia el túnel en Cloudflare.
+func RouteDNS(c *TunnelConfig, hostname string) error {
+	if err := utils.DefaultRunner().Run(utils.Command("cloudflared", "tunnel", "route", "dns", c.TunnelID, hostname)); err != nil {
+		return fmt.Errorf("no se pudo crear el registro DNS de %s: %w", hostname, err)
+	}
+	return nil
 }
@@ -0,0 +1,343 @@
+package cloudflared
+
+import (
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultTunnelName es el nombre del túnel si no se indica otro.
+const DefaultTunnelName = "autohost-tunnel"
+
+// catchAllService responde a lo que no coincida con ninguna regla.
+const catchAllService = "http_status:404"
+
+// TunnelConfig es el modelo del túnel gestionado; de él se genera config.yml.
+type TunnelConfig struct {
+	Tunnel          string `json:"tunnel"`
+	TunnelID        string `json:"tunnel_id,omitempty"`
+	CredentialsFile string `json:"credentials_file,omitempty"`
+	// Runtime es dónde corre el conector: systemd, docker o "" (a mano).
+	Runtime string        `json:"runtime,omitempty"`
+	Ingress []IngressRule `json:"ingress"`
+}
+
+// IngressRule es una regla de ingress. El orden importa: gana la primera
+// regla cuyo hostname (y path, si tiene) coincida.
+type IngressRule struct {
+	Hostname      string         `json:"hostname"`
+	Path          string         `json:"path,omitempty"`
+	Service       string         `json:"service"`
+	OriginRequest *OriginRequest `json:"origin_request,omitempty"`
+}
+
+// OriginRequest son las opciones de conexión al origen de una regla.
+type OriginRequest struct {
+	NoTLSVerify      bool   `json:"no_tls_verify,omitempty"`
+	HTTPHostHeader   string `json:"http_host_header,omitempty"`
+	OriginServerName string `json:"origin_server_name,omitempty"`
+	ConnectTimeout   string `json:"connect_timeout,omitempty"`
+}
+
+// ApplyResult resume qué pasó al aplicar la configuración.
+type ApplyResult struct {
+	Validated bool
+	Restarted bool
+	Skipped   string // motivo si no se reinició el túnel
+}
+
+var (
+	hostnameRe = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+	serviceRe  = regexp.MustCompile(`^(https?|tcp|ssh|rdp|smb|unix|unix\+tls)://\S+$|^http_status:[1-5][0-9]{2}$|^hello_world$|^bastion$`)
+	durationRe = regexp.MustCompile(`^[0-9]+(ms|s|m|h)$`)
+)
+
+// Dir es el directorio de Cloudflare de autohost (~/.autohost/cloudflare).
+func Dir() string { return utils.GetSubdir("cloudflare") }
+
+// ModelPath es el JSON con el modelo del túnel.
+func ModelPath() string { return filepath.Join(Dir(), "tunnel-config.json") }
+
+// ConfigPath es el config.yml que usa `cloudflared tunnel run`.
+func ConfigPath() string { return filepath.Join(Dir(), "config.yml") }
+
+// DockerConfigPath es la variante de config.yml para el contenedor: rutas bajo
+// /etc/cloudflared y localhost reemplazado por host.docker.internal.
+func DockerConfigPath() string { return filepath.Join(Dir(), dockerConfigName) }
+
+const dockerConfigName = "config.docker.yml"
+
+// LoadConfig lee el modelo; si no existe devuelve uno vacío.
+func LoadConfig() (*TunnelConfig, error) {
+	c := &TunnelConfig{Tunnel: DefaultTunnelName, Ingress: []IngressRule{}}
+	b, err := os.ReadFile(ModelPath())
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", ModelPath(), err)
+	}
+	if c.Tunnel == "" {
+		c.Tunnel = DefaultTunnelName
+	}
+	return c, nil
+}
+
+// NormalizeService acepta un puerto ("3000" → http://localhost:3000),
+// host:puerto o un servicio de cloudflared (http://, tcp://, http_status:404...).
+func NormalizeService(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if p, err := strconv.Atoi(s); err == nil && p > 0 && p < 65536 {
+		return "http://localhost:" + s, nil
+	}
+	if !strings.Contains(s, "://") && strings.Contains(s, ":") && !strings.HasPrefix(s, "http_status:") {
+		s = "http://" + s
+	}
+	if !serviceRe.MatchString(s) {
+		return "", fmt.Errorf("servicio inválido: %q (ej: 3000, localhost:3000, http://10.0.0.5:8080)", s)
+	}
+	return s, nil
+}
+
+// normalizeRule valida la regla y normaliza hostname y servicio.
+func normalizeRule(r IngressRule) (IngressRule, error) {
+	r.Hostname = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(r.Hostname), "."))
+	if !hostnameRe.MatchString(r.Hostname) {
+		return r, fmt.Errorf("hostname inválido: %q (ej: app.midominio.com)", r.Hostname)
+	}
+	if r.Path != "" {
+		if _, err := regexp.Compile(r.Path); err != nil {
+			return r, fmt.Errorf("path inválido (es una expresión regular): %w", err)
+		}
+	}
+	svc, err := NormalizeService(r.Service)
+	if err != nil {
+		return r, err
+	}
+	r.Service = svc
+	if o := r.OriginRequest; o != nil {
+		if o.ConnectTimeout != "" && !durationRe.MatchString(o.ConnectTimeout) {
+			return r, fmt.Errorf("connect-timeout inválido: %q (ej: 30s)", o.ConnectTimeout)
+		}
+		if *o == (OriginRequest{}) {
+			r.OriginRequest = nil
+		}
+	}
+	return r, nil
+}
+
+// upsertRule agrega o reemplaza la regla de (hostname, path). Una regla con path
+// se coloca antes de la regla general del mismo hostname para que no quede tapada.
+func (c *TunnelConfig) upsertRule(r IngressRule) (changed bool, err error) {
+	r, err = normalizeRule(r)
+	if err != nil {
+		return false, err
+	}
+	for i, cur := range c.Ingress {
+		if cur.Hostname == r.Hostname && cur.Path == r.Path {
+			if sameRule(cur, r) {
+				return false, nil
+			}
+			c.Ingress[i] = r
+			return true, nil
+		}
+	}
+	at := len(c.Ingress)
+	if r.Path != "" {
+		for i, cur := range c.Ingress {
+			if cur.Hostname == r.Hostname && cur.Path == "" {
+				at = i
+				break
+			}
+		}
+	}
+	c.Ingress = append(c.Ingress[:at], append([]IngressRule{r}, c.Ingress[at:]...)...)
+	return true, nil
+}
+
+// removeRule quita la regla de (hostname, path); devuelve false si no estaba.
+func (c *TunnelConfig) removeRule(hostname, path string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(hostname), "."))
+	for i, cur := range c.Ingress {
+		if cur.Hostname == hostname && cur.Path == path {
+			c.Ingress = append(c.Ingress[:i], c.Ingress[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func sameRule(a, b IngressRule) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+// Render genera el config.yml de cloudflared (con la regla catch-all al final).
+func (c *TunnelConfig) Render() string { return c.render(c.CredentialsFile, nil) }
+
+// RenderDocker genera la variante para el contenedor del conector.
+func (c *TunnelConfig) RenderDocker() string {
+	return c.render(containerMountDir+"/"+filepath.Base(c.CredentialsFile), dockerService)
+}
+
+func (c *TunnelConfig) render(credentials string, service func(string) string) string {
+	var b strings.Builder
+	b.WriteString("# Generado por autohost a partir de tunnel-config.json; no editar a mano.\n")
+	fmt.Fprintf(&b, "tunnel: %s\n", c.TunnelID)
+	fmt.Fprintf(&b, "credentials-file: %s\n", strconv.Quote(credentials))
+	b.WriteString("\ningress:\n")
+	for _, r := range c.Ingress {
+		if service != nil {
+			r.Service = service(r.Service)
+		}
+		fmt.Fprintf(&b, "  - hostname: %s\n", strconv.Quote(r.Hostname))
+		if r.Path != "" {
+			fmt.Fprintf(&b, "    path: %s\n", strconv.Quote(r.Path))
+		}
+		fmt.Fprintf(&b, "    service: %s\n", r.Service)
+		if o := r.OriginRequest; o != nil {
+			b.WriteString("    originRequest:\n")
+			if o.NoTLSVerify {
+				b.WriteString("      noTLSVerify: true\n")
+			}
+			if o.HTTPHostHeader != "" {
+				fmt.Fprintf(&b, "      httpHostHeader: %s\n", strconv.Quote(o.HTTPHostHeader))
+			}
+			if o.OriginServerName != "" {
+				fmt.Fprintf(&b, "      originServerName: %s\n", strconv.Quote(o.OriginServerName))
+			}
+			if o.ConnectTimeout != "" {
+				fmt.Fprintf(&b, "      connectTimeout: %s\n", o.ConnectTimeout)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "  - service: %s\n", catchAllService)
+	return b.String()
+}
+
+// Apply valida la configuración con `cloudflared tunnel ingress validate` sobre
+// una copia temporal y, solo si es válida, guarda el modelo y config.yml y
+// reinicia el conector.
+func Apply(c *TunnelConfig) (ApplyResult, error) {
+	var res ApplyResult
+	if err := validateAndSave(c); err != nil {
+		return res, err
+	}
+	res.Validated = true
+	var err error
+	res.Restarted, res.Skipped, err = RestartTunnel(c)
+	return res, err
+}
+
+// validateAndSave valida config.yml en staging y, si es válido, escribe el
+// modelo, config.yml y (con runtime docker) config.docker.yml.
+func validateAndSave(c *TunnelConfig) error {
+	if c.TunnelID == "" || c.CredentialsFile == "" {
+		return errors.New("no hay túnel configurado; créalo con `autohost cloudflare tunnel <dominio>`")
+	}
+	content := c.Render()
+
+	staged, err := os.CreateTemp("", "autohost-cloudflared-*.yml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged.Name())
+	if _, err := staged.WriteString(content); err != nil {
+		staged.Close()
+		return err
+	}
+	staged.Close()
+	validate := utils.Command("cloudflared", "tunnel", "--config", staged.Name(), "ingress", "validate")
+	validate.ReadOnly = true
+	if out, err := utils.DefaultRunner().Output(validate); err != nil {
+		return fmt.Errorf("la configuración del túnel no es válida: %w%s", err, indentOutput(out))
+	}
+
+	if err := saveModel(c); err != nil {
+		return err
+	}
+	r := utils.DefaultRunner()
+	if err := r.WriteFile(ConfigPath()+".tmp", []byte(content), 0o644); err != nil {
+		return err
+	}
+	if err := r.Rename(ConfigPath()+".tmp", ConfigPath()); err != nil {
+		return err
+	}
+	if c.Runtime == RuntimeDocker {
+		return r.WriteFile(DockerConfigPath(), []byte(c.RenderDocker()), 0o644)
+	}
+	return nil
+}
+
+// saveModel escribe tunnel-config.json.
+func saveModel(c *TunnelConfig) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	r := utils.DefaultRunner()
+	if err := r.MkdirAll(Dir(), 0o700); err != nil {
+		return err
+	}
+	return r.WriteFile(ModelPath(), append(data, '\n'), 0o644)
+}
+
+// RestartTunnel reinicia el conector donde corra (contenedor o servicio
+// systemd). Si no hay conector, devuelve el motivo para mostrarlo (no es un error).
+func RestartTunnel(c *TunnelConfig) (restarted bool, skipped string, err error) {
+	if c.Runtime == RuntimeDocker {
+		restarted, err := infra.RestartCloudflaredContainer()
+		if err == nil && !restarted {
+			return false, "el contenedor del conector no existe; créalo con `autohost cloudflare service install --runtime docker`", nil
+		}
+		return restarted, "", err
+	}
+	check := utils.Command("systemctl", "is-active", "--quiet", "cloudflared")
+	check.ReadOnly = true
+	if _, err := utils.DefaultRunner().Output(check); err != nil {
+		return false, fmt.Sprintf("el servicio cloudflared no está activo; instálalo con `autohost cloudflare service install` o ejecuta `cloudflared tunnel --config %s run`", ConfigPath()), nil
+	}
+	if err := utils.DefaultRunner().Run(utils.Command("sudo", "systemctl", "restart", "cloudflared")); err != nil {
+		return false, "", fmt.Errorf("no se pudo reiniciar cloudflared: %w", err)
+	}
+	return true, "", nil
+}
+
+// dockerService cambia localhost por host.docker.internal: dentro del
+// contenedor, localhost es el propio contenedor.
+func dockerService(svc string) string {
+	u, err := url.Parse(svc)
+	if err != nil || u.Host == "" {
+		return svc
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		if p := u.Port(); p != "" {
+			u.Host = "host.docker.internal:" + p
+		} else {
+			u.Host = "host.docker.internal"
+		}
+		return u.String()
+	}
+	return svc
+}
+
+func indentOutput(out []byte) string {
+	s := strings.TrimSpace(string(out))
+	if s == "" {
+		return ""
+	}
+	return "\n   " + strings.ReplaceAll(s, "\n", "\n   ")
+}
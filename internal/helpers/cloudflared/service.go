@@ -0,0 +1,295 @@
+package cloudflared
+
+import (
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dónde corre el conector del túnel.
+const (
+	RuntimeSystemd = "systemd"
+	RuntimeDocker  = "docker"
+)
+
+const (
+	serviceName       = "cloudflared"
+	containerMountDir = "/etc/cloudflared"
+	// systemdMetricsAddr expone /ready del conector instalado con systemd.
+	systemdMetricsAddr = "127.0.0.1:20241"
+)
+
+// systemdUnitPath es la unidad que instala `cloudflare service install`.
+var systemdUnitPath = "/etc/systemd/system/" + serviceName + ".service"
+
+// ServiceStatus es el estado del conector gestionado.
+type ServiceStatus struct {
+	Runtime     string `json:"runtime"`
+	Tunnel      string `json:"tunnel,omitempty"`
+	TunnelID    string `json:"tunnel_id,omitempty"`
+	Installed   bool   `json:"installed"`
+	Running     bool   `json:"running"`
+	State       string `json:"state"`
+	Since       string `json:"since,omitempty"`
+	Restarts    int    `json:"restarts"`
+	Ready       bool   `json:"ready"`
+	Connections int    `json:"connections"`
+	// Detail explica por qué no está listo (error al consultar /ready).
+	Detail string `json:"detail,omitempty"`
+}
+
+// DefaultRuntime elige systemd si el host lo usa y, si no, docker.
+func DefaultRuntime() string {
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		if _, err := os.Stat("/run/systemd/system"); err == nil {
+			return RuntimeSystemd
+		}
+	}
+	return RuntimeDocker
+}
+
+// InstallService deja el conector corriendo como servicio systemd o como
+// contenedor en la red autohost_net, apuntando al config.yml gestionado. Si ya
+// estaba instalado con el otro runtime, lo desinstala antes.
+func InstallService(runtime string) error {
+	if runtime != RuntimeSystemd && runtime != RuntimeDocker {
+		return fmt.Errorf("runtime inválido: %q (usa %s|%s)", runtime, RuntimeSystemd, RuntimeDocker)
+	}
+	c, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if c.Runtime != "" && c.Runtime != runtime {
+		fmt.Printf("ℹ️  Quitando el conector anterior (%s)...\n", c.Runtime)
+		if err := stopRuntime(c.Runtime); err != nil {
+			return err
+		}
+	}
+	c.Runtime = runtime
+	if err := validateAndSave(c); err != nil {
+		return err
+	}
+
+	if runtime == RuntimeDocker {
+		return infra.RunCloudflaredContainer(infra.CloudflaredContainerOpts{
+			Dir:        Dir(),
+			ConfigName: dockerConfigName,
+			User:       fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		})
+	}
+	return installSystemdUnit()
+}
+
+// UninstallService detiene y elimina el conector; el túnel y config.yml se conservan.
+func UninstallService() (removed bool, err error) {
+	c, err := LoadConfig()
+	if err != nil {
+		return false, err
+	}
+	if c.Runtime == "" {
+		return false, nil
+	}
+	if err := stopRuntime(c.Runtime); err != nil {
+		return false, err
+	}
+	c.Runtime = ""
+	return true, saveModel(c)
+}
+
+// stopRuntime quita el conector del runtime indicado.
+func stopRuntime(runtime string) error {
+	r := utils.DefaultRunner()
+	switch runtime {
+	case RuntimeDocker:
+		if _, err := infra.RemoveCloudflaredContainer(); err != nil {
+			return err
+		}
+		if err := r.Remove(DockerConfigPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	case RuntimeSystemd:
+		_ = r.Run(utils.Command("sudo", "systemctl", "disable", "--now", serviceName))
+		if err := r.Run(utils.Command("sudo", "rm", "-f", systemdUnitPath)); err != nil {
+			return fmt.Errorf("no se pudo eliminar %s: %w", systemdUnitPath, err)
+		}
+		if err := r.Run(utils.Command("sudo", "systemctl", "daemon-reload")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderUnit genera la unidad systemd del conector.
+func renderUnit(bin, username string) string {
+	var b strings.Builder
+	b.WriteString("# Generado por autohost (autohost cloudflare service install); no editar a mano.\n")
+	b.WriteString("[Unit]\nDescription=Cloudflare Tunnel (autohost)\nAfter=network-online.target\nWants=network-online.target\n\n")
+	b.WriteString("[Service]\nType=notify\n")
+	if username != "" && username != "root" {
+		fmt.Fprintf(&b, "User=%s\n", username)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s --no-autoupdate --metrics %s --config %s tunnel run\n", bin, systemdMetricsAddr, ConfigPath())
+	b.WriteString("Restart=on-failure\nRestartSec=5s\nTimeoutStartSec=0\n\n")
+	b.WriteString("[Install]\nWantedBy=multi-user.target\n")
+	return b.String()
+}
+
+func installSystemdUnit() error {
+	bin, err := exec.LookPath("cloudflared")
+	if err != nil {
+		return errors.New("cloudflared no está instalado; ejecuta `autohost cloudflare install`")
+	}
+	if abs, err := filepath.Abs(bin); err == nil {
+		bin = abs
+	}
+	username := ""
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	// La unidad vive en /etc: se escribe en un temporal y se instala con sudo.
+	tmp, err := os.CreateTemp("", "autohost-cloudflared-*.service")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(renderUnit(bin, username)); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	r := utils.DefaultRunner()
+	steps := []utils.Cmd{
+		utils.Command("sudo", "install", "-m", "0644", tmp.Name(), systemdUnitPath),
+		utils.Command("sudo", "systemctl", "daemon-reload"),
+		utils.Command("sudo", "systemctl", "enable", serviceName),
+		utils.Command("sudo", "systemctl", "restart", serviceName),
+	}
+	for _, c := range steps {
+		if err := r.Run(c); err != nil {
+			return fmt.Errorf("no se pudo instalar el servicio %s: %w", serviceName, err)
+		}
+	}
+	return nil
+}
+
+// GetServiceStatus consulta el conector (systemd o contenedor) y su /ready.
+func GetServiceStatus() (*ServiceStatus, error) {
+	c, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	st := &ServiceStatus{Runtime: c.Runtime, Tunnel: c.Tunnel, TunnelID: c.TunnelID, State: "no instalado"}
+	var metrics string
+	switch c.Runtime {
+	case RuntimeDocker:
+		info, err := infra.InspectCloudflaredContainer()
+		if err != nil {
+			return nil, err
+		}
+		if info == nil {
+			return st, nil
+		}
+		st.Installed = true
+		st.Running = info.State.Running
+		st.State = info.State.Status
+		st.Restarts = info.RestartCount
+		st.Since = info.State.StartedAt
+		if ip := info.NetworkIP(infra.AutohostNetwork); ip != "" {
+			metrics = ip + ":" + infra.CloudflaredMetricsPort
+		}
+	case RuntimeSystemd:
+		props, err := systemdProps(serviceName, "LoadState", "ActiveState", "SubState", "NRestarts", "ActiveEnterTimestamp")
+		if err != nil {
+			return nil, err
+		}
+		st.Installed = props["LoadState"] == "loaded"
+		st.Running = props["ActiveState"] == "active"
+		st.State = props["ActiveState"]
+		if sub := props["SubState"]; sub != "" {
+			st.State += " (" + sub + ")"
+		}
+		st.Restarts, _ = strconv.Atoi(props["NRestarts"])
+		st.Since = props["ActiveEnterTimestamp"]
+		metrics = systemdMetricsAddr
+	default:
+		return st, nil
+	}
+	if st.Running && metrics != "" {
+		st.Connections, st.Detail = readyConnections(metrics)
+		st.Ready = st.Connections > 0
+	}
+	return st, nil
+}
+
+// systemdProps lee propiedades de una unidad con `systemctl show`.
+func systemdProps(unit string, props ...string) (map[string]string, error) {
+	c := utils.Command("systemctl", "show", unit, "--property="+strings.Join(props, ","))
+	c.ReadOnly = true
+	out, err := utils.DefaultRunner().Output(c)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo consultar systemd: %w", err)
+	}
+	m := map[string]string{}
+	for _, ln := range strings.Split(string(out), "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(ln), "="); ok {
+			m[k] = v
+		}
+	}
+	return m, nil
+}
+
+// readyConnections consulta /ready de las métricas de cloudflared; devuelve las
+// conexiones activas con el edge de Cloudflare o el motivo si no responde.
+func readyConnections(addr string) (int, string) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get("http://" + addr + "/ready")
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	var body struct {
+		ReadyConnections int `json:"readyConnections"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body)
+	if resp.StatusCode != http.StatusOK {
+		return body.ReadyConnections, fmt.Sprintf("/ready respondió %s", resp.Status)
+	}
+	return body.ReadyConnections, ""
+}
+
+// ServiceLogs copia los logs del conector a w (journalctl o docker logs).
+func ServiceLogs(w io.Writer, tail int, follow bool) error {
+	c, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	switch c.Runtime {
+	case RuntimeDocker:
+		return infra.CloudflaredContainerLogs(w, tail, follow)
+	case RuntimeSystemd:
+		args := []string{"-u", serviceName, "--no-pager"}
+		if tail > 0 {
+			args = append(args, "-n", strconv.Itoa(tail))
+		}
+		if follow {
+			args = append(args, "-f")
+		}
+		cmd := utils.Command("journalctl", args...)
+		cmd.Stdout, cmd.ReadOnly = w, true
+		return utils.DefaultRunner().Run(cmd)
+	}
+	return errors.New("el conector no está instalado; usa `autohost cloudflare service install`")
+}
@@ -1,8 +1,10 @@
 package docker
 
 import (
+	"autohost-cli/internal/infra"
 	"autohost-cli/utils"
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +12,20 @@ import (
 	"strings"
 )
 
+func shell(script string) error { return utils.DefaultRunner().Run(utils.ShellCmd(script)) }
+
+func run(name string, args ...string) error {
+	return utils.DefaultRunner().Run(utils.Command(name, args...))
+}
+
+// probe ejecuta una consulta sin efectos y solo reporta si terminó bien.
+func probe(name string, args ...string) bool {
+	c := utils.Command(name, args...)
+	c.ReadOnly = true
+	_, err := utils.DefaultRunner().Output(c)
+	return err == nil
+}
+
 func DockerInstalled() bool {
 	_, err := exec.LookPath("docker")
 	return err == nil
@@ -23,7 +39,18 @@ func runningInContainer() bool {
 	return os.Getenv("AUTOHOST_IN_CONTAINER") == "true"
 }
 
-func dockerAvailable() bool { return exec.Command("docker", "version").Run() == nil }
+// dockerAvailable indica si el daemon responde por la Engine API.
+func dockerAvailable() bool { return DaemonStatus() == nil }
+
+// DaemonStatus hace ping al daemon; el error distingue (con errors.Is)
+// infra.ErrDockerUnavailable de infra.ErrDockerPermission.
+func DaemonStatus() error {
+	dc, err := infra.NewDockerClient()
+	if err != nil {
+		return err
+	}
+	return dc.Ping()
+}
 
 type osRelease struct {
 	ID     string
@@ -57,24 +84,27 @@ func ensureCurl() error {
 
 	switch {
 	case strings.Contains(id, "debian") || strings.Contains(id, "ubuntu"):
-		return utils.ExecShell(`sudo apt-get update -y && sudo apt-get install -y curl ca-certificates && sudo update-ca-certificates`)
+		return shell(`sudo apt-get update -y && sudo apt-get install -y curl ca-certificates && sudo update-ca-certificates`)
 	case strings.Contains(id, "rhel") || strings.Contains(id, "centos") || strings.Contains(id, "rocky") || strings.Contains(id, "almalinux"):
-		return utils.ExecShell(`sudo yum install -y curl ca-certificates || sudo dnf install -y curl ca-certificates`)
+		return shell(`sudo yum install -y curl ca-certificates || sudo dnf install -y curl ca-certificates`)
 	case strings.Contains(id, "fedora"):
-		return utils.ExecShell(`sudo dnf install -y curl ca-certificates`)
+		return shell(`sudo dnf install -y curl ca-certificates`)
 	case strings.Contains(id, "amzn"): // Amazon Linux
-		return utils.ExecShell(`sudo yum install -y curl ca-certificates || sudo dnf install -y curl ca-certificates`)
+		return shell(`sudo yum install -y curl ca-certificates || sudo dnf install -y curl ca-certificates`)
 	case strings.Contains(id, "alpine"):
-		return utils.ExecShell(`sudo apk add --no-cache curl ca-certificates && sudo update-ca-certificates`)
+		return shell(`sudo apk add --no-cache curl ca-certificates && sudo update-ca-certificates`)
 	case strings.Contains(id, "suse") || strings.Contains(id, "sles") || strings.Contains(id, "opensuse"):
-		return utils.ExecShell(`sudo zypper --non-interactive install -y curl ca-certificates`)
+		return shell(`sudo zypper --non-interactive install -y curl ca-certificates`)
 	default:
 		// mejor intentar y que falle claro
-		return utils.Exec("which", "curl")
+		return run("which", "curl")
 	}
 }
 
-func systemctlAvailable() bool { return exec.Command("which", "systemctl").Run() == nil }
+func systemctlAvailable() bool {
+	_, err := exec.LookPath("systemctl")
+	return err == nil
+}
 
 func InstallDocker() {
 	if runningInContainer() {
@@ -93,7 +123,7 @@ func InstallDocker() {
 	}
 
 	// Script oficial SIN pipe ciego
-	if err := utils.ExecShell(`
+	if err := shell(`
 set -e
 tmp="$(mktemp)"
 curl -fsSL https://get.docker.com -o "$tmp"
@@ -105,17 +135,23 @@ rm -f "$tmp"
 
 	// Arrancar/enable del daemon (si hay systemd)
 	if systemctlAvailable() {
-		_ = utils.Exec("sudo", "systemctl", "enable", "--now", "docker")
+		_ = run("sudo", "systemctl", "enable", "--now", "docker")
 	} else {
 		// fallback best-effort
-		_ = utils.Exec("sudo", "service", "docker", "start")
+		_ = run("sudo", "service", "docker", "start")
+	}
+
+	if utils.DefaultRunner().DryRun() {
+		return
 	}
 
 	// Verificar CLI + daemon
-	if err := exec.Command("docker", "--version").Run(); err != nil {
+	if !probe("docker", "--version") {
 		panic("❌ Docker CLI no quedó instalado correctamente.")
 	}
-	if err := exec.Command("docker", "info").Run(); err != nil {
+	if err := DaemonStatus(); errors.Is(err, infra.ErrDockerPermission) {
+		fmt.Println("✅ Docker instalado. Tu usuario aún no tiene permisos sobre el socket; usa sudo o agrégalo al grupo docker.")
+	} else if err != nil {
 		fmt.Println("⚠️  Docker instalado, pero el daemon no responde aún. Revisa el servicio o reinicia el host.")
 	} else {
 		fmt.Println("✅ Docker instalado y en ejecución.")
@@ -143,10 +179,10 @@ func AddUserToDockerGroup() {
 	}
 
 	// Crea grupo si falta y agrega usuario
-	if err := utils.ExecShell(`getent group docker >/dev/null 2>&1 || sudo groupadd docker`); err != nil {
+	if err := shell(`getent group docker >/dev/null 2>&1 || sudo groupadd docker`); err != nil {
 		fmt.Println("⚠️  No pude crear/verificar grupo docker:", err)
 	}
-	if err := utils.Exec("sudo", "usermod", "-aG", "docker", u); err != nil {
+	if err := run("sudo", "usermod", "-aG", "docker", u); err != nil {
 		fmt.Printf("⚠️  No pude agregar el usuario '%s' al grupo docker: %v\n", u, err)
 		return
 	}
@@ -0,0 +1,200 @@
+package exposure
+
+import (
+	"autohost-cli/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Proveedores de exposición.
+const (
+	ProviderTailscale      = "tailscale"
+	ProviderTailscaleServe = "tailscale-serve"
+	ProviderCloudflare     = "cloudflare"
+)
+
+// Exposure es una app publicada con `autohost expose`. Se identifica por
+// host, puerto HTTPS y path (ver Key).
+type Exposure struct {
+	Host      string `json:"host"`
+	HTTPSPort int    `json:"https_port,omitempty"` // 0 = 443
+	Path      string `json:"path,omitempty"`       // "" = /
+	Provider  string `json:"provider"`
+	Port      int    `json:"port,omitempty"`
+	// Service es el destino final de la petición (ej: http://localhost:3000).
+	Service string `json:"service"`
+	Caddy   bool   `json:"caddy"`
+	// Tailscale: zona de CoreDNS e IP de la tailnet que la resuelve.
+	Zone    string `json:"zone,omitempty"`
+	Address string `json:"address,omitempty"`
+	// Cloudflare: túnel que recibe el tráfico.
+	Tunnel   string `json:"tunnel,omitempty"`
+	TunnelID string `json:"tunnel_id,omitempty"`
+	// Tailscale Serve: publicado también en internet con Funnel.
+	Funnel bool `json:"funnel,omitempty"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Key identifica la exposición: host[:puerto][/path].
+func (e Exposure) Key() string {
+	return makeKey(e.Host, e.HTTPSPort, e.Path)
+}
+
+// URL es la dirección pública de la exposición.
+func (e Exposure) URL() string {
+	return "https://" + e.Key()
+}
+
+// ParseKey acepta host, host/path o una URL (https://host:8443/path) y
+// devuelve la clave normalizada.
+func ParseKey(s string) string {
+	s = strings.TrimSpace(s)
+	for _, scheme := range []string{"https://", "http://"} {
+		s = strings.TrimPrefix(s, scheme)
+	}
+	host, path := s, ""
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		host, path = s[:i], s[i:]
+	}
+	port := 0
+	if h, p, ok := strings.Cut(host, ":"); ok {
+		host = h
+		port, _ = strconv.Atoi(p)
+	}
+	return makeKey(host, port, path)
+}
+
+func makeKey(host string, port int, path string) string {
+	k := normalizeHost(host)
+	if port != 0 && port != 443 {
+		k += ":" + strconv.Itoa(port)
+	}
+	if path = strings.Trim(path, "/"); path != "" {
+		k += "/" + path
+	}
+	return k
+}
+
+type stateFile struct {
+	Exposures []Exposure `json:"exposures"`
+}
+
+// StatePath es el archivo con las exposiciones (~/.autohost/state/exposures.json).
+func StatePath() string {
+	return filepath.Join(utils.GetSubdir("state"), "exposures.json")
+}
+
+// List devuelve las exposiciones registradas ordenadas por host.
+func List() ([]Exposure, error) {
+	b, err := os.ReadFile(StatePath())
+	if os.IsNotExist(err) {
+		return []Exposure{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st stateFile
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", StatePath(), err)
+	}
+	if st.Exposures == nil {
+		st.Exposures = []Exposure{}
+	}
+	sortExposures(st.Exposures)
+	return st.Exposures, nil
+}
+
+// Get busca una exposición por clave (host, host/path o URL); nil si no existe.
+func Get(key string) (*Exposure, error) {
+	all, err := List()
+	if err != nil {
+		return nil, err
+	}
+	key = ParseKey(key)
+	for i := range all {
+		if all[i].Key() == key {
+			return &all[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Save registra (o actualiza) la exposición con la clave de e, conservando su fecha de creación.
+func Save(e Exposure) error {
+	all, err := List()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	e.Host = normalizeHost(e.Host)
+	if e.HTTPSPort == 443 {
+		e.HTTPSPort = 0
+	}
+	if e.Path = strings.Trim(e.Path, "/"); e.Path != "" {
+		e.Path = "/" + e.Path
+	}
+	e.Created, e.Updated = now, now
+	replaced := false
+	for i, cur := range all {
+		if cur.Key() == e.Key() {
+			if !cur.Created.IsZero() {
+				e.Created = cur.Created
+			}
+			all[i] = e
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		all = append(all, e)
+	}
+	return write(all)
+}
+
+// Delete quita la exposición con esa clave; devuelve false si no estaba registrada.
+func Delete(key string) (bool, error) {
+	all, err := List()
+	if err != nil {
+		return false, err
+	}
+	key = ParseKey(key)
+	for i, cur := range all {
+		if cur.Key() == key {
+			return true, write(append(all[:i], all[i+1:]...))
+		}
+	}
+	return false, nil
+}
+
+func write(all []Exposure) error {
+	sortExposures(all)
+	data, err := json.MarshalIndent(stateFile{Exposures: all}, "", "  ")
+	if err != nil {
+		return err
+	}
+	r := utils.DefaultRunner()
+	if err := r.MkdirAll(filepath.Dir(StatePath()), 0o755); err != nil {
+		return err
+	}
+	tmp := StatePath() + ".tmp"
+	if err := r.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return r.Rename(tmp, StatePath())
+}
+
+func sortExposures(all []Exposure) {
+	sort.Slice(all, func(i, j int) bool { return all[i].Key() < all[j].Key() })
+}
+
+func normalizeHost(h string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(h), "."))
+}
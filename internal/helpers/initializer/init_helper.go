@@ -2,7 +2,6 @@ package initializer
 
 import (
 	"autohost-cli/utils"
-	"os"
 )
 
 func EnsureAutohostDirs() error {
@@ -16,7 +15,7 @@ func EnsureAutohostDirs() error {
 	}
 
 	for _, sub := range subdirs {
-		if err := os.MkdirAll(utils.GetSubdir(sub), 0755); err != nil {
+		if err := utils.DefaultRunner().MkdirAll(utils.GetSubdir(sub), 0755); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,202 @@
+package schedule
+
+import (
+	"autohost-cli/utils"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// systemdDir es donde se instalan los timers de las tareas.
+var systemdDir = "/etc/systemd/system"
+
+// unitName es la base de las unidades de una tarea (autohost-<tarea>).
+func unitName(job string) string { return "autohost-" + job }
+
+// cronMarker etiqueta la línea del crontab de cada tarea.
+func cronMarker(job string) string { return "# autohost:" + job }
+
+// selfBinary es el binario de autohost al que llaman los disparadores.
+func selfBinary() (string, error) {
+	bin, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("no se pudo localizar el binario de autohost: %w", err)
+	}
+	if abs, err := filepath.EvalSymlinks(bin); err == nil {
+		bin = abs
+	}
+	return bin, nil
+}
+
+// triggerCmd es lo que ejecutan systemd o cron al dispararse la tarea.
+func triggerCmd(bin, job string) utils.Cmd {
+	return utils.Command(bin, "schedule", "run-now", job, "--scheduled")
+}
+
+func install(j *Job, spec *CronSpec) error {
+	bin, err := selfBinary()
+	if err != nil {
+		return err
+	}
+	if j.Backend == BackendSystemd {
+		return installTimer(j, spec, bin)
+	}
+	return installCron(j, spec, bin)
+}
+
+func uninstall(j *Job) error {
+	if j.Backend == BackendSystemd {
+		return uninstallTimer(j)
+	}
+	return uninstallCron(j)
+}
+
+// -----------------------------------------------------------------------------
+// systemd
+// -----------------------------------------------------------------------------
+
+// renderService genera la unidad que ejecuta la tarea una vez.
+func renderService(j *Job, bin, username, home string) string {
+	var b strings.Builder
+	b.WriteString("# Generado por autohost (autohost schedule add); no editar a mano.\n")
+	fmt.Fprintf(&b, "[Unit]\nDescription=autohost: %s (%s)\nAfter=network-online.target docker.service\nWants=network-online.target\n\n", j.Name, j.CommandLine())
+	b.WriteString("[Service]\nType=oneshot\n")
+	if username != "" && username != "root" {
+		fmt.Fprintf(&b, "User=%s\n", username)
+	}
+	// Sin HOME autohost no encontraría ~/.autohost.
+	if home != "" {
+		fmt.Fprintf(&b, "Environment=HOME=%s\n", home)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", triggerCmd(bin, j.Name))
+	return b.String()
+}
+
+// renderTimer genera el timer con el horario de la tarea.
+func renderTimer(j *Job, spec *CronSpec) string {
+	var b strings.Builder
+	b.WriteString("# Generado por autohost (autohost schedule add); no editar a mano.\n")
+	fmt.Fprintf(&b, "[Unit]\nDescription=autohost: %s (%s)\n\n", j.Name, j.Schedule)
+	// Persistent recupera la ejecución perdida si el equipo estaba apagado.
+	fmt.Fprintf(&b, "[Timer]\nOnCalendar=%s\nPersistent=true\n\n", spec.OnCalendar())
+	b.WriteString("[Install]\nWantedBy=timers.target\n")
+	return b.String()
+}
+
+func installTimer(j *Job, spec *CronSpec, bin string) error {
+	username, home := "", ""
+	if u, err := user.Current(); err == nil {
+		username, home = u.Username, u.HomeDir
+	}
+	if h, err := os.UserHomeDir(); err == nil {
+		home = h
+	}
+
+	unit := unitName(j.Name)
+	files := map[string]string{
+		unit + ".service": renderService(j, bin, username, home),
+		unit + ".timer":   renderTimer(j, spec),
+	}
+
+	// Las unidades viven en /etc: se escriben en temporales y se instalan con sudo.
+	r := utils.DefaultRunner()
+	var steps []utils.Cmd
+	for _, name := range []string{unit + ".service", unit + ".timer"} {
+		tmp, err := os.CreateTemp("", name+"-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.WriteString(files[name]); err != nil {
+			tmp.Close()
+			return err
+		}
+		tmp.Close()
+		steps = append(steps, utils.Command("sudo", "install", "-m", "0644", tmp.Name(), filepath.Join(systemdDir, name)))
+	}
+	steps = append(steps,
+		utils.Command("sudo", "systemctl", "daemon-reload"),
+		utils.Command("sudo", "systemctl", "enable", "--now", unit+".timer"),
+	)
+	for _, c := range steps {
+		if err := r.Run(c); err != nil {
+			return fmt.Errorf("no se pudo instalar el timer %s: %w", unit, err)
+		}
+	}
+	return nil
+}
+
+func uninstallTimer(j *Job) error {
+	r := utils.DefaultRunner()
+	unit := unitName(j.Name)
+	_ = r.Run(utils.Command("sudo", "systemctl", "disable", "--now", unit+".timer"))
+	rm := utils.Command("sudo", "rm", "-f",
+		filepath.Join(systemdDir, unit+".timer"), filepath.Join(systemdDir, unit+".service"))
+	if err := r.Run(rm); err != nil {
+		return fmt.Errorf("no se pudieron eliminar las unidades de %s: %w", unit, err)
+	}
+	return r.Run(utils.Command("sudo", "systemctl", "daemon-reload"))
+}
+
+// -----------------------------------------------------------------------------
+// cron
+// -----------------------------------------------------------------------------
+
+// readCrontab devuelve el crontab del usuario ("" si todavía no tiene).
+func readCrontab() (string, error) {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return "", errors.New("crontab no está instalado (instala cron o usa --backend systemd)")
+	}
+	out, err := utils.DefaultRunner().Output(utils.Cmd{Name: "crontab", Args: []string{"-l"}, ReadOnly: true})
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// "no crontab for <usuario>"
+			return "", nil
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// writeCrontab reemplaza la línea de la tarea (o la quita si line es "").
+func writeCrontab(job, line string) error {
+	current, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	var lines []string
+	for _, l := range strings.Split(strings.TrimRight(current, "\n"), "\n") {
+		if l == "" && len(lines) == 0 {
+			continue
+		}
+		if strings.HasSuffix(l, cronMarker(job)) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	c := utils.Cmd{Name: "crontab", Args: []string{"-"}, Stdin: bytes.NewBufferString(content)}
+	if err := utils.DefaultRunner().Run(c); err != nil {
+		return fmt.Errorf("no se pudo actualizar el crontab: %w", err)
+	}
+	return nil
+}
+
+func installCron(j *Job, spec *CronSpec, bin string) error {
+	line := fmt.Sprintf("%s %s >/dev/null 2>&1 %s", spec, triggerCmd(bin, j.Name), cronMarker(j.Name))
+	return writeCrontab(j.Name, line)
+}
+
+func uninstallCron(j *Job) error { return writeCrontab(j.Name, "") }
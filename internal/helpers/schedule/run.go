@@ -0,0 +1,237 @@
+package schedule
+
+import (
+	"autohost-cli/utils"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Cómo se lanzó una ejecución.
+const (
+	TriggerSchedule = "schedule" // systemd o cron
+	TriggerManual   = "manual"   // autohost schedule run-now
+)
+
+// historyLimit es cuántas ejecuciones (y sus logs) se conservan por tarea.
+const historyLimit = 50
+
+// Run es una ejecución de una tarea.
+type Run struct {
+	Job      string    `json:"job"`
+	Trigger  string    `json:"trigger"`
+	Start    time.Time `json:"start"`
+	Seconds  float64   `json:"duration_seconds"`
+	ExitCode int       `json:"exit_code"`
+	// Error explica un fallo al lanzar el comando (sin código de salida propio).
+	Error string `json:"error,omitempty"`
+	Log   string `json:"log,omitempty"`
+}
+
+// OK indica si la ejecución terminó bien.
+func (r *Run) OK() bool { return r.ExitCode == 0 && r.Error == "" }
+
+// Duration es lo que tardó la ejecución.
+func (r *Run) Duration() time.Duration {
+	return time.Duration(r.Seconds * float64(time.Second)).Round(time.Second)
+}
+
+// ErrRunning indica que la tarea ya se está ejecutando.
+var ErrRunning = errors.New("la tarea ya se está ejecutando")
+
+// ExitError es el código de salida de una ejecución fallida.
+type ExitError struct {
+	Job  string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("la tarea %s terminó con código %d", e.Job, e.Code)
+}
+
+func historyPath(job string) string { return filepath.Join(Dir(), "history", job+".jsonl") }
+func logDir(job string) string      { return filepath.Join(Dir(), "logs", job) }
+func lockPath(job string) string    { return filepath.Join(Dir(), "locks", job+".lock") }
+
+// History devuelve las ejecuciones de una tarea, de la más antigua a la más reciente.
+func History(job string) ([]*Run, error) {
+	f, err := os.Open(historyPath(job))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var runs []*Run
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Run
+		// Una línea truncada (p. ej. disco lleno) no invalida el resto.
+		if json.Unmarshal(sc.Bytes(), &r) == nil {
+			runs = append(runs, &r)
+		}
+	}
+	return runs, sc.Err()
+}
+
+// LastRun devuelve la última ejecución de la tarea (nil si nunca se ejecutó).
+func LastRun(job string) (*Run, error) {
+	runs, err := History(job)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[len(runs)-1], nil
+}
+
+// RunNow ejecuta la tarea con el binario actual, guarda su salida en un log
+// (y la muestra en out) y registra el resultado en el historial. Devuelve
+// *ExitError si el comando falla, para propagar el código a systemd o cron.
+func RunNow(name, trigger string, out io.Writer) (*Run, error) {
+	j, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	bin, err := selfBinary()
+	if err != nil {
+		return nil, err
+	}
+	r := utils.DefaultRunner()
+	if r.DryRun() {
+		fmt.Printf("%s ejecutaría la tarea %s: %s\n", utils.DryRunPrefix, j.Name, utils.Command(bin, j.Args...))
+		return nil, nil
+	}
+
+	release, err := lock(j.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := os.MkdirAll(logDir(j.Name), 0o700); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	run := &Run{
+		Job:     j.Name,
+		Trigger: trigger,
+		Start:   start.UTC().Truncate(time.Second),
+		Log:     filepath.Join(logDir(j.Name), start.UTC().Format("20060102T150405.000Z")+".log"),
+	}
+	logFile, err := os.OpenFile(run.Log, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(logFile, "# %s %s (%s)\n", run.Start.Format(time.RFC3339), j.CommandLine(), trigger)
+
+	w := io.Writer(logFile)
+	if out != nil {
+		w = io.MultiWriter(logFile, out)
+	}
+	runErr := r.Run(utils.Cmd{Name: bin, Args: j.Args, Stdin: strings.NewReader(""), Stdout: w, Stderr: w})
+	run.Seconds = time.Since(start).Seconds()
+
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+	case errors.As(runErr, &exitErr) && exitErr.ExitCode() > 0:
+		run.ExitCode = exitErr.ExitCode()
+	default:
+		run.ExitCode = -1
+		run.Error = runErr.Error()
+		fmt.Fprintf(logFile, "# error: %v\n", runErr)
+	}
+	fmt.Fprintf(logFile, "# fin: código %d en %s\n", run.ExitCode, run.Duration())
+	logFile.Close()
+
+	if err := appendHistory(run); err != nil {
+		return run, err
+	}
+	if !run.OK() {
+		if run.Error != "" {
+			return run, fmt.Errorf("la tarea %s falló: %s", j.Name, run.Error)
+		}
+		return run, &ExitError{Job: j.Name, Code: run.ExitCode}
+	}
+	return run, nil
+}
+
+// appendHistory añade la ejecución y recorta el historial (y los logs) a historyLimit.
+func appendHistory(run *Run) error {
+	runs, err := History(run.Job)
+	if err != nil {
+		return err
+	}
+	runs = append(runs, run)
+	if extra := len(runs) - historyLimit; extra > 0 {
+		for _, old := range runs[:extra] {
+			if old.Log != "" {
+				os.Remove(old.Log)
+			}
+		}
+		runs = runs[extra:]
+	}
+	var b strings.Builder
+	for _, r := range runs {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	path := historyPath(run.Job)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lock evita que dos ejecuciones de la misma tarea se solapen (p. ej. un
+// backup que tarda más que su intervalo). Un lock de un proceso muerto se
+// descarta.
+func lock(job string) (func(), error) {
+	path := lockPath(job)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		data, _ := os.ReadFile(path)
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && processAlive(pid) {
+			return nil, fmt.Errorf("%w (pid %d)", ErrRunning, pid)
+		}
+		os.Remove(path)
+	}
+	return nil, fmt.Errorf("no se pudo bloquear la tarea %s", job)
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
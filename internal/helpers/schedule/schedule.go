@@ -0,0 +1,324 @@
+package schedule
+
+import (
+	"autohost-cli/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dónde se instala el disparador de una tarea.
+const (
+	BackendSystemd = "systemd" // autohost-<tarea>.service + .timer
+	BackendCron    = "cron"    // línea en el crontab del usuario
+)
+
+var jobNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Job es una tarea programada: una invocación de autohost con un horario.
+type Job struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"` // expresión cron de 5 campos
+	Args     []string  `json:"args"`     // argumentos de autohost (sin el binario)
+	Backend  string    `json:"backend"`
+	Created  time.Time `json:"created"`
+}
+
+// CommandLine es el comando que ejecuta la tarea, para mostrarlo.
+func (j *Job) CommandLine() string {
+	return utils.Command("autohost", j.Args...).String()
+}
+
+// Dir es el estado del planificador (~/.autohost/state/schedule).
+func Dir() string { return filepath.Join(utils.GetSubdir("state"), "schedule") }
+
+func jobsPath() string { return filepath.Join(Dir(), "jobs.json") }
+
+// DefaultBackend elige systemd si el host lo usa y, si no, cron.
+func DefaultBackend() string {
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		if _, err := os.Stat("/run/systemd/system"); err == nil {
+			return BackendSystemd
+		}
+	}
+	return BackendCron
+}
+
+// Jobs devuelve las tareas ordenadas por nombre.
+func Jobs() ([]*Job, error) {
+	b, err := os.ReadFile(jobsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, fmt.Errorf("%s: %w", jobsPath(), err)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+	return jobs, nil
+}
+
+// Get busca una tarea por nombre.
+func Get(name string) (*Job, error) {
+	jobs, err := Jobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jobs {
+		if j.Name == name {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("no existe la tarea %s", name)
+}
+
+func saveJobs(jobs []*Job) error {
+	if jobs == nil {
+		jobs = []*Job{}
+	}
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	r := utils.DefaultRunner()
+	if err := r.MkdirAll(Dir(), 0o700); err != nil {
+		return err
+	}
+	return r.WriteFile(jobsPath(), append(data, '\n'), 0o600)
+}
+
+// Add guarda la tarea e instala su disparador. Si la instalación falla la
+// tarea no queda registrada.
+func Add(j *Job) error {
+	if !jobNameRe.MatchString(j.Name) {
+		return fmt.Errorf("nombre de tarea inválido: %q (minúsculas, números, - y _)", j.Name)
+	}
+	if len(j.Args) == 0 {
+		return errors.New("falta el comando de autohost a ejecutar")
+	}
+	spec, err := ParseCron(j.Schedule)
+	if err != nil {
+		return err
+	}
+	j.Schedule = spec.String()
+	switch j.Backend {
+	case "":
+		j.Backend = DefaultBackend()
+	case BackendSystemd, BackendCron:
+	default:
+		return fmt.Errorf("backend inválido: %q (usa %s|%s)", j.Backend, BackendSystemd, BackendCron)
+	}
+	if j.Created.IsZero() {
+		j.Created = time.Now().UTC().Truncate(time.Second)
+	}
+
+	jobs, err := Jobs()
+	if err != nil {
+		return err
+	}
+	for _, other := range jobs {
+		if other.Name == j.Name {
+			return fmt.Errorf("ya existe la tarea %s; bórrala antes con `autohost schedule rm %s`", j.Name, j.Name)
+		}
+	}
+	if err := install(j, spec); err != nil {
+		return err
+	}
+	if err := saveJobs(append(jobs, j)); err != nil {
+		return errors.Join(err, uninstall(j))
+	}
+	return nil
+}
+
+// Remove desinstala el disparador y borra la tarea con su historial.
+func Remove(name string) error {
+	jobs, err := Jobs()
+	if err != nil {
+		return err
+	}
+	var job *Job
+	kept := jobs[:0]
+	for _, j := range jobs {
+		if j.Name == name {
+			job = j
+			continue
+		}
+		kept = append(kept, j)
+	}
+	if job == nil {
+		return fmt.Errorf("no existe la tarea %s", name)
+	}
+	if err := uninstall(job); err != nil {
+		return err
+	}
+	if err := saveJobs(kept); err != nil {
+		return err
+	}
+	r := utils.DefaultRunner()
+	if err := r.Remove(historyPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.RemoveAll(logDir(name))
+}
+
+// -----------------------------------------------------------------------------
+// Horarios
+// -----------------------------------------------------------------------------
+
+// CronSpec es una expresión cron de 5 campos (minuto hora día mes día-semana)
+// con cada campo expandido a sus valores (nil = "*").
+type CronSpec struct {
+	fields [5]string
+	values [5][]int
+}
+
+var cronAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+var cronRanges = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minuto", 0, 59},
+	{"hora", 0, 23},
+	{"día del mes", 1, 31},
+	{"mes", 1, 12},
+	{"día de la semana", 0, 7},
+}
+
+// ParseCron interpreta "m h dom mes dow" (con *, listas, rangos y /paso) o
+// @hourly, @daily, @weekly y @monthly (también sin @).
+func ParseCron(expr string) (*CronSpec, error) {
+	expr = strings.TrimSpace(expr)
+	if alias, ok := cronAliases["@"+strings.TrimPrefix(expr, "@")]; ok {
+		expr = alias
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("horario inválido %q: usa 5 campos cron (ej. \"0 3 * * *\") o @daily", expr)
+	}
+	spec := &CronSpec{}
+	for i, p := range parts {
+		vals, err := parseCronField(p, cronRanges[i].min, cronRanges[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("horario inválido %q: %s: %w", expr, cronRanges[i].name, err)
+		}
+		if i == 4 && vals != nil {
+			vals = normalizeWeekdays(vals)
+		}
+		spec.fields[i], spec.values[i] = p, vals
+	}
+	// cron combina día del mes y día de la semana con OR y systemd con AND.
+	if spec.values[2] != nil && spec.values[4] != nil {
+		return nil, fmt.Errorf("horario inválido %q: indica día del mes o día de la semana, no ambos", expr)
+	}
+	return spec, nil
+}
+
+func parseCronField(field string, lo, hi int) ([]int, error) {
+	if field == "*" {
+		return nil, nil
+	}
+	seen := map[int]bool{}
+	for _, item := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("paso inválido %q", item)
+			}
+			step = n
+		}
+		from, to := lo, hi
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err1, err2 error
+			from, err1 = strconv.Atoi(a)
+			to, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil || from > to {
+				return nil, fmt.Errorf("rango inválido %q", item)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return nil, fmt.Errorf("valor inválido %q", item)
+			}
+			from, to = n, n
+			if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi {
+			return nil, fmt.Errorf("%q fuera de rango (%d-%d)", item, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			seen[v] = true
+		}
+	}
+	vals := make([]int, 0, len(seen))
+	for v := range seen {
+		vals = append(vals, v)
+	}
+	sort.Ints(vals)
+	return vals, nil
+}
+
+// normalizeWeekdays trata el 7 como domingo (0).
+func normalizeWeekdays(vals []int) []int {
+	seen := map[int]bool{}
+	var out []int
+	for _, v := range vals {
+		v %= 7
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// String devuelve la expresión cron.
+func (s *CronSpec) String() string { return strings.Join(s.fields[:], " ") }
+
+// OnCalendar traduce la expresión al formato de los timers de systemd.
+func (s *CronSpec) OnCalendar() string {
+	field := func(i int, width int) string {
+		if s.values[i] == nil {
+			return "*"
+		}
+		parts := make([]string, len(s.values[i]))
+		for k, v := range s.values[i] {
+			parts[k] = fmt.Sprintf("%0*d", width, v)
+		}
+		return strings.Join(parts, ",")
+	}
+	cal := fmt.Sprintf("*-%s-%s %s:%s:00", field(3, 2), field(2, 2), field(1, 2), field(0, 2))
+	if s.values[4] != nil {
+		names := []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+		days := make([]string, len(s.values[4]))
+		for k, v := range s.values[4] {
+			days[k] = names[v]
+		}
+		cal = strings.Join(days, ",") + " " + cal
+	}
+	return cal
+}
@@ -0,0 +1,140 @@
+package schedule
+
+import (
+	"autohost-cli/utils"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useRecorder aísla HOME y reemplaza el Runner global por un RecordingRunner.
+func useRecorder(t *testing.T) *utils.RecordingRunner {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	rec := utils.NewRecordingRunner()
+	utils.SetDefaultRunner(rec)
+	t.Cleanup(func() { utils.SetDefaultRunner(nil) })
+	return rec
+}
+
+func cmdStrings(rec *utils.RecordingRunner) []string {
+	var out []string
+	for _, c := range rec.Commands {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestAddSystemdRecordsCommands(t *testing.T) {
+	rec := useRecorder(t)
+	j := &Job{Name: "nightly", Schedule: "@daily", Args: []string{"backup", "create", "--all"}, Backend: BackendSystemd}
+	if err := Add(j); err != nil {
+		t.Fatal(err)
+	}
+
+	got := cmdStrings(rec)
+	if len(got) != 4 {
+		t.Fatalf("comandos = %q", got)
+	}
+	for i, suffix := range []string{"/etc/systemd/system/autohost-nightly.service", "/etc/systemd/system/autohost-nightly.timer"} {
+		if !strings.HasPrefix(got[i], "sudo install -m 0644 ") || !strings.HasSuffix(got[i], suffix) {
+			t.Errorf("comando %d = %q", i, got[i])
+		}
+	}
+	if got[2] != "sudo systemctl daemon-reload" || got[3] != "sudo systemctl enable --now autohost-nightly.timer" {
+		t.Errorf("comandos = %q", got[2:])
+	}
+
+	data, ok := rec.Files[jobsPath()]
+	if !ok {
+		t.Fatalf("no se escribió %s; llamadas: %q", jobsPath(), rec.Calls)
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Schedule != "0 0 * * *" || jobs[0].Backend != BackendSystemd {
+		t.Fatalf("jobs.json = %s", data)
+	}
+}
+
+func TestAddCronReplacesTaggedLine(t *testing.T) {
+	rec := useRecorder(t)
+	// Solo hace falta que crontab exista en el PATH: la salida la da el recorder.
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "crontab"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	rec.On("crontab -l", "MAILTO=yo\n0 1 * * * /viejo schedule run-now nightly --scheduled # autohost:nightly\n30 2 * * * otro # autohost:nightly-db\n", nil)
+
+	j := &Job{Name: "nightly", Schedule: "0 3 * * 1-5", Args: []string{"backup", "create", "--all"}, Backend: BackendCron}
+	if err := Add(j); err != nil {
+		t.Fatal(err)
+	}
+
+	var crontab string
+	for _, c := range rec.Commands {
+		if c.Name == "crontab" && len(c.Args) == 1 && c.Args[0] == "-" {
+			b, _ := io.ReadAll(c.Stdin)
+			crontab = string(b)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(crontab), "\n")
+	if len(lines) != 3 || lines[0] != "MAILTO=yo" || !strings.HasSuffix(lines[1], "# autohost:nightly-db") {
+		t.Fatalf("crontab =\n%s", crontab)
+	}
+	if !strings.HasPrefix(lines[2], "0 3 * * 1-5 ") || !strings.HasSuffix(lines[2], " schedule run-now nightly --scheduled >/dev/null 2>&1 # autohost:nightly") {
+		t.Fatalf("línea de la tarea = %q", lines[2])
+	}
+}
+
+func TestAddFailedInstallDoesNotSaveJob(t *testing.T) {
+	rec := useRecorder(t)
+	rec.On("sudo systemctl enable", "", io.ErrUnexpectedEOF)
+	err := Add(&Job{Name: "x", Schedule: "@hourly", Args: []string{"backup", "list"}, Backend: BackendSystemd})
+	if err == nil {
+		t.Fatal("se esperaba error")
+	}
+	if _, ok := rec.Files[jobsPath()]; ok {
+		t.Fatal("la tarea no debería registrarse si falla la instalación")
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr, cron, calendar string
+		wantErr              bool
+	}{
+		{expr: "@daily", cron: "0 0 * * *", calendar: "*-*-* 00:00:00"},
+		{expr: "weekly", cron: "0 0 * * 0", calendar: "Sun *-*-* 00:00:00"},
+		{expr: "0 3 * * *", cron: "0 3 * * *", calendar: "*-*-* 03:00:00"},
+		{expr: "*/20 8-9 * * *", cron: "*/20 8-9 * * *", calendar: "*-*-* 08,09:00,20,40:00"},
+		{expr: "30 4 1,15 */6 *", cron: "30 4 1,15 */6 *", calendar: "*-01,07-01,15 04:30:00"},
+		{expr: "0 0 * * 5-7", cron: "0 0 * * 5-7", calendar: "Sun,Fri,Sat *-*-* 00:00:00"},
+		{expr: "0 0 * *", wantErr: true},
+		{expr: "60 0 * * *", wantErr: true},
+		{expr: "0 0 1 * 1", wantErr: true},
+		{expr: "0 0 * * 5-1", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		spec, err := ParseCron(tt.expr)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: se esperaba error", tt.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if spec.String() != tt.cron || spec.OnCalendar() != tt.calendar {
+			t.Errorf("%q: cron %q calendar %q, quiero %q %q", tt.expr, spec.String(), spec.OnCalendar(), tt.cron, tt.calendar)
+		}
+	}
+}
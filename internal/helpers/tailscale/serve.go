@@ -0,0 +1,109 @@
+package tailscale
+
+import (
+	"autohost-cli/internal/infra"
+	"autohost-cli/utils"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FunnelPorts son los únicos puertos HTTPS que Tailscale permite para Funnel.
+var FunnelPorts = []int{443, 8443, 10000}
+
+// ServeSpec describe un mapeo de `tailscale serve` / `tailscale funnel`.
+type ServeSpec struct {
+	Target    string // destino local, ej: http://localhost:3000
+	Path      string // path montado, ej: /app ("" o "/" = raíz)
+	HTTPSPort int    // puerto HTTPS del nodo (0 = 443)
+	Funnel    bool   // publicar también en internet
+}
+
+// normalize completa valores por defecto y valida el mapeo.
+func (s *ServeSpec) normalize() error {
+	if s.HTTPSPort == 0 {
+		s.HTTPSPort = 443
+	}
+	if s.HTTPSPort < 1 || s.HTTPSPort > 65535 {
+		return fmt.Errorf("puerto HTTPS inválido: %d", s.HTTPSPort)
+	}
+	if s.Funnel && !containsInt(FunnelPorts, s.HTTPSPort) {
+		return fmt.Errorf("Funnel solo admite los puertos 443, 8443 y 10000 (pediste %d)", s.HTTPSPort)
+	}
+	s.Path = strings.TrimRight(strings.TrimSpace(s.Path), "/")
+	if s.Path != "" && !strings.HasPrefix(s.Path, "/") {
+		s.Path = "/" + s.Path
+	}
+	if strings.ContainsAny(s.Path, " ?#") {
+		return fmt.Errorf("path inválido: %q", s.Path)
+	}
+	return nil
+}
+
+// args arma los argumentos comunes de serve/funnel para el mapeo.
+func (s ServeSpec) args(extra ...string) []string {
+	sub := "serve"
+	if s.Funnel {
+		sub = "funnel"
+	}
+	args := []string{"tailscale", sub, "--https=" + strconv.Itoa(s.HTTPSPort)}
+	if s.Path != "" {
+		args = append(args, "--set-path="+s.Path)
+	}
+	return append(args, extra...)
+}
+
+// Serve publica Target en https://<nodo>.ts.net[:puerto]/path con el
+// certificado del nodo. Con Funnel también queda accesible desde internet.
+// Devuelve el nombre MagicDNS del nodo.
+func Serve(spec ServeSpec) (host string, err error) {
+	if err := spec.normalize(); err != nil {
+		return "", err
+	}
+	host, err = SelfDNSName()
+	if err != nil {
+		return "", err
+	}
+	args := spec.args("--bg", spec.Target)
+	if err := utils.DefaultRunner().Run(utils.Command("sudo", args...)); err != nil {
+		what := "tailscale serve"
+		if spec.Funnel {
+			what = "tailscale funnel (¿HTTPS y Funnel habilitados en la tailnet?)"
+		}
+		return "", fmt.Errorf("%s falló: %w", what, err)
+	}
+	return host, nil
+}
+
+// Unserve quita el mapeo del puerto y path indicados.
+func Unserve(spec ServeSpec) error {
+	if err := spec.normalize(); err != nil {
+		return err
+	}
+	if err := utils.DefaultRunner().Run(utils.Command("sudo", spec.args("off")...)); err != nil {
+		return fmt.Errorf("no se pudo quitar el mapeo de tailscale: %w", err)
+	}
+	return nil
+}
+
+// SelfDNSName devuelve el nombre MagicDNS del nodo (ej: server.tailnet.ts.net).
+func SelfDNSName() (string, error) {
+	st, err := infra.NewTailscaleLocalClient().StatusWithoutPeers()
+	if err != nil {
+		return "", err
+	}
+	name := st.DNSName()
+	if name == "" {
+		return "", fmt.Errorf("el nodo no tiene nombre MagicDNS; habilita MagicDNS en la tailnet")
+	}
+	return name, nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,27 +1,35 @@
 package tailscale
 
 import (
+	"autohost-cli/internal/infra"
 	"autohost-cli/utils"
+	"errors"
 	"fmt"
-	"os/exec"
-	"strings"
 )
 
 func InstallTailscale() {
 	fmt.Println("🔐 Instalando Tailscale...")
-	utils.ExecShell("curl -fsSL https://tailscale.com/install.sh | sh")
+	utils.DefaultRunner().Run(utils.ShellCmd("curl -fsSL https://tailscale.com/install.sh | sh"))
 	fmt.Println("🔐 Autenticándote con Tailscale...")
-	utils.ExecShell("sudo tailscale up")
+	utils.DefaultRunner().Run(utils.Command("sudo", "tailscale", "up"))
 }
 
+// Status consulta a tailscaled (LocalAPI) el estado del nodo y sus peers.
+func Status() (*infra.TailscaleStatus, error) {
+	return infra.NewTailscaleLocalClient().Status()
+}
+
+// TailscaleIP devuelve la IPv4 de la tailnet de este nodo.
 func TailscaleIP() (string, error) {
-	out, err := exec.Command("tailscale", "ip", "-4").Output()
+	st, err := infra.NewTailscaleLocalClient().StatusWithoutPeers()
 	if err != nil {
 		return "", err
 	}
-	ip := strings.TrimSpace(string(out))
-	if i := strings.IndexByte(ip, '\n'); i > -1 {
-		ip = ip[:i]
+	if !st.Running() {
+		return "", fmt.Errorf("tailscale no está conectado (estado: %s); ejecuta `autohost tailscale login`", st.BackendState)
+	}
+	if ip := st.IPv4(); ip != "" {
+		return ip, nil
 	}
-	return ip, nil
+	return "", errors.New("el nodo no tiene IPv4 en la tailnet")
 }
@@ -0,0 +1,135 @@
+// internal/infra/cloudflared_docker.go
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// CloudflaredContainer es el contenedor del conector de Cloudflare Tunnel.
+	CloudflaredContainer = "autohost-cloudflared"
+
+	cloudflaredDefaultImage = "cloudflare/cloudflared:latest"
+	cloudflaredMountDir     = "/etc/cloudflared"
+	// CloudflaredMetricsPort es el puerto de métricas (/ready) dentro del contenedor.
+	CloudflaredMetricsPort = "2000"
+)
+
+// cloudflaredImage permite fijar la imagen con AUTOHOST_CLOUDFLARED_IMAGE.
+func cloudflaredImage() string {
+	if img := strings.TrimSpace(os.Getenv("AUTOHOST_CLOUDFLARED_IMAGE")); img != "" {
+		return img
+	}
+	return cloudflaredDefaultImage
+}
+
+// CloudflaredContainerOpts describe el contenedor del conector.
+type CloudflaredContainerOpts struct {
+	Dir        string // directorio con config y credenciales (se monta en /etc/cloudflared)
+	ConfigName string // archivo de config dentro de Dir (ej: config.docker.yml)
+	User       string // "uid:gid" dueño de las credenciales (0600)
+}
+
+// RunCloudflaredContainer (re)crea el conector en la red AutohostNetwork con
+// política de reinicio unless-stopped y lo inicia.
+func RunCloudflaredContainer(opts CloudflaredContainerOpts) error {
+	dc, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	if err := dc.Ping(); err != nil {
+		return fmt.Errorf("no se pudo contactar a Docker: %w", err)
+	}
+	if err := dc.RemoveContainer(CloudflaredContainer, true); err != nil && !errors.Is(err, ErrDockerNotFound) {
+		return fmt.Errorf("no se pudo eliminar %s: %w", CloudflaredContainer, err)
+	}
+	if err := dc.EnsureNetwork(AutohostNetwork); err != nil {
+		return fmt.Errorf("no se pudo crear la red %s: %w", AutohostNetwork, err)
+	}
+	_, err = dc.CreateContainer(CloudflaredContainer, ContainerSpec{
+		Image: cloudflaredImage(),
+		Cmd: []string{"tunnel", "--no-autoupdate",
+			"--metrics", "0.0.0.0:" + CloudflaredMetricsPort,
+			"--config", cloudflaredMountDir + "/" + opts.ConfigName, "run"},
+		User:          opts.User,
+		Binds:         []string{opts.Dir + ":" + cloudflaredMountDir + ":ro"},
+		NetworkMode:   AutohostNetwork,
+		RestartPolicy: "unless-stopped",
+		ExtraHosts:    []string{"host.docker.internal:host-gateway"},
+		Labels:        map[string]string{"dev.autohost.managed": "true"},
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo crear el contenedor cloudflared: %w", err)
+	}
+	if err := dc.StartContainer(CloudflaredContainer); err != nil {
+		return fmt.Errorf("no se pudo iniciar el contenedor cloudflared: %w", err)
+	}
+	return nil
+}
+
+// InspectCloudflaredContainer devuelve nil (sin error) si el contenedor no existe.
+func InspectCloudflaredContainer() (*ContainerInfo, error) {
+	dc, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	info, err := dc.InspectContainer(CloudflaredContainer)
+	if errors.Is(err, ErrDockerNotFound) {
+		return nil, nil
+	}
+	return info, err
+}
+
+// RestartCloudflaredContainer reinicia el conector; restarted es false si no existe.
+func RestartCloudflaredContainer() (restarted bool, err error) {
+	info, err := InspectCloudflaredContainer()
+	if err != nil || info == nil {
+		return false, err
+	}
+	dc, err := NewDockerClient()
+	if err != nil {
+		return false, err
+	}
+	if err := dc.RestartContainer(CloudflaredContainer); err != nil {
+		return false, fmt.Errorf("no se pudo reiniciar %s: %w", CloudflaredContainer, err)
+	}
+	return true, nil
+}
+
+// RemoveCloudflaredContainer detiene y elimina el conector; removed es false si no existía.
+func RemoveCloudflaredContainer() (removed bool, err error) {
+	dc, err := NewDockerClient()
+	if err != nil {
+		return false, err
+	}
+	if err := dc.StopContainer(CloudflaredContainer, 10*time.Second); err != nil {
+		if errors.Is(err, ErrDockerNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("no se pudo detener %s: %w", CloudflaredContainer, err)
+	}
+	if err := dc.RemoveContainer(CloudflaredContainer, true); err != nil && !errors.Is(err, ErrDockerNotFound) {
+		return false, fmt.Errorf("no se pudo eliminar %s: %w", CloudflaredContainer, err)
+	}
+	return true, nil
+}
+
+// CloudflaredContainerLogs copia los logs del conector a w.
+func CloudflaredContainerLogs(w io.Writer, tail int, follow bool) error {
+	dc, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	if err := dc.ContainerLogs(CloudflaredContainer, tail, follow, w); err != nil {
+		if errors.Is(err, ErrDockerNotFound) {
+			return fmt.Errorf("el contenedor %s no existe; instálalo con `autohost cloudflare service install --runtime docker`", CloudflaredContainer)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// internal/infra/cloudflared_download.go
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	cloudflaredReleasesAPI = "https://api.github.com/repos/cloudflare/cloudflared/releases"
+	cloudflaredDownload    = "https://github.com/cloudflare/cloudflared/releases/download"
+)
+
+// FetchCloudflared descarga cloudflared verificando su SHA256 y devuelve la
+// ruta en la caché. La versión sale de AUTOHOST_CLOUDFLARED_VERSION (o la
+// última release) y el hash de AUTOHOST_CLOUDFLARED_SHA256 (o del digest que
+// publica GitHub para el asset). Con ambas variables no hace falta la API.
+func FetchCloudflared() (path, version string, err error) {
+	if runtime.GOOS != "linux" {
+		return "", "", fmt.Errorf("instalación de cloudflared no soportada en %s", runtime.GOOS)
+	}
+	asset := "cloudflared-linux-" + runtime.GOARCH
+	version = strings.TrimSpace(os.Getenv("AUTOHOST_CLOUDFLARED_VERSION"))
+	sum := strings.TrimSpace(os.Getenv("AUTOHOST_CLOUDFLARED_SHA256"))
+
+	if version == "" || sum == "" {
+		rel, err := cloudflaredRelease(version)
+		if err != nil {
+			return "", "", err
+		}
+		version = rel.TagName
+		if sum == "" {
+			for _, a := range rel.Assets {
+				if a.Name == asset {
+					sum = strings.TrimPrefix(a.Digest, "sha256:")
+				}
+			}
+			if sum == "" {
+				return "", "", fmt.Errorf("la release %s no publica el SHA256 de %s; defínelo en AUTOHOST_CLOUDFLARED_SHA256", version, asset)
+			}
+		}
+	}
+
+	path, err = Download{
+		Name:    "cloudflared " + version,
+		BaseURL: cloudflaredDownload,
+		Path:    version + "/" + asset,
+		SHA256:  sum,
+	}.Fetch()
+	return path, version, err
+}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name   string `json:"name"`
+		Digest string `json:"digest"`
+	} `json:"assets"`
+}
+
+// cloudflaredRelease consulta la release indicada (o la última) en GitHub.
+func cloudflaredRelease(version string) (*githubRelease, error) {
+	url := cloudflaredReleasesAPI + "/latest"
+	if version != "" {
+		url = cloudflaredReleasesAPI + "/tags/" + version
+	}
+	b, err := httpGet(url, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("no pude consultar la release de cloudflared: %w", err)
+	}
+	var rel githubRelease
+	if err := json.Unmarshal(b, &rel); err != nil || rel.TagName == "" {
+		return nil, fmt.Errorf("respuesta inesperada de %s", url)
+	}
+	return &rel, nil
+}
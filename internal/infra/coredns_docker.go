@@ -2,18 +2,23 @@
 package infra
 
 import (
+	"autohost-cli/utils"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"text/template"
 )
 
 const (
 	coreDNSContainer = "coredns-autohost"
 	coreDNSImage     = "coredns/coredns:latest"
+
+	// coreDNSReloadSignal hace que CoreDNS recargue la configuración en caliente.
+	coreDNSReloadSignal = "SIGUSR1"
+	// zoneFileReload es cada cuánto el plugin file revisa el serial del archivo de zona.
+	zoneFileReload = "5s"
 )
 
 // InstallAndRunCoreDNSWithDocker asegura Docker, genera/actualiza el Corefile para la zona
@@ -27,14 +32,17 @@ func InstallAndRunCoreDNSWithDocker(zone, fqdn, tailIP string) (string, error) {
 	if strings.TrimSpace(zone) == "" || strings.TrimSpace(fqdn) == "" || strings.TrimSpace(tailIP) == "" {
 		return "", errors.New("zone, fqdn y tailIP son requeridos")
 	}
-	if _, err := exec.LookPath("docker"); err != nil {
-		return "", fmt.Errorf("docker no está instalado en PATH: %w", err)
+	dc, err := NewDockerClient()
+	if err != nil {
+		return "", err
+	}
+	if err := dc.Ping(); err != nil {
+		return "", fmt.Errorf("no se pudo contactar a Docker: %w", err)
 	}
 
 	// Preparar directorio y Corefile
-	home, _ := os.UserHomeDir()
-	dir := filepath.Join(home, ".autohost", "coredns")
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	dir := coreDNSDir()
+	if err := utils.DefaultRunner().MkdirAll(filepath.Join(dir, "zones"), 0o755); err != nil {
 		return "", err
 	}
 	corefilePath := filepath.Join(dir, "Corefile")
@@ -42,412 +50,347 @@ func InstallAndRunCoreDNSWithDocker(zone, fqdn, tailIP string) (string, error) {
 	// Si no existe, crear uno base con la zona + bloque global "."
 	created := false
 	if _, err := os.Stat(corefilePath); os.IsNotExist(err) {
-		base := `# CoreDNS (Docker) para AutoHost
-
-{{.Zone}}:53 {
-    # Limita el binding a la IP de Tailscale para evitar conflictos en :53
-    bind {{.TailIP}}
-
-    log
-    errors
-    hosts {
-        {{.TailIP}} {{.FQDN}}
-        fallthrough
-    }
-}
-
-. {
-    log
-    errors
-    forward . /etc/resolv.conf
-}
-`
-		if err := renderToFile(base, corefilePath, map[string]string{
-			"Zone": zone, "TailIP": tailIP, "FQDN": fqdn,
-		}); err != nil {
+		cf := &Corefile{Header: []string{"# CoreDNS (Docker) para AutoHost"}}
+		ensureZone(cf, zone, tailIP)
+		if err := utils.DefaultRunner().WriteFile(corefilePath, []byte(cf.Format()), 0o644); err != nil {
 			return "", fmt.Errorf("no pude escribir Corefile inicial: %w", err)
 		}
 		created = true
-	} else {
-		// Si ya existe, asegurar que tenga la zona y el mapping FQDN → tailIP
-		if _, err := AddDomainToCorefileDocker(zone, fqdn, tailIP); err != nil {
-			return "", fmt.Errorf("no pude actualizar Corefile existente: %w", err)
-		}
+	}
+	// Asegurar la zona y el registro FQDN → tailIP
+	changed, err := AddDomainToCorefileDocker(zone, fqdn, tailIP)
+	if err != nil {
+		return "", fmt.Errorf("no pude actualizar la zona %s: %w", zone, err)
 	}
 
 	// Levantar/reciclar contenedor
-	exists, running, _ := containerState(coreDNSContainer)
-
-	switch {
-	case !exists:
-		// run nuevo
-		if err := runCoreDNSContainer(corefilePath); err != nil {
-			return "", err
-		}
-	case exists && !running:
-		// start
-		if err := dockerStart(coreDNSContainer); err != nil {
-			return "", err
-		}
-	default: // running
-		// reinicia si acabamos de crear el corefile o si se actualizó (AddDomain... ya lo hace en EnsureDomainAndReload)
-		if created {
-			if err := dockerRestart(coreDNSContainer); err != nil {
-				return "", err
-			}
+	recreated, err := ensureCoreDNSContainer(dc)
+	if err != nil {
+		return "", err
+	}
+	if !recreated && (created || changed) {
+		if err := dc.KillContainer(coreDNSContainer, coreDNSReloadSignal); err != nil {
+			return "", fmt.Errorf("no se pudo recargar %s: %w", coreDNSContainer, err)
 		}
 	}
-
 	return corefilePath, nil
 }
 
-// AddDomainToCorefileDocker garantiza que el Corefile tenga:
-// - un bloque de zona para `zone` (con bind a tailIP)
-// - dentro del bloque "hosts", una línea "tailIP fqdn" (idempotente; si hay otra IP para ese fqdn, la reemplaza)
-// Devuelve `changed=true` si el archivo fue modificado.
+// AddDomainToCorefileDocker garantiza que:
+// - el Corefile tenga un bloque para `zone` (con bind a tailIP) servido desde su archivo de zona
+// - la zona tenga un registro A "fqdn → tailIP" (idempotente; si había otro valor, lo reemplaza)
+// Devuelve `changed=true` si el Corefile o la zona fueron modificados.
 func AddDomainToCorefileDocker(zone, fqdn, tailIP string) (changed bool, err error) {
 	if strings.TrimSpace(zone) == "" || strings.TrimSpace(fqdn) == "" || strings.TrimSpace(tailIP) == "" {
 		return false, errors.New("zone, fqdn y tailIP son requeridos")
 	}
+	chs, err := editZone(zone, tailIP, func(z *ZoneData) error {
+		return z.Upsert(DNSRecord{Name: fqdn, Type: "A", Value: tailIP})
+	})
+	return chs.Changed(), err
+}
 
-	corefilePath, err := corefilePath()
+// ReloadCoreDNSDocker aplica los cambios sin reiniciar el contenedor: envía
+// SIGUSR1 para que CoreDNS relea el Corefile y los archivos de zona en caliente
+// (sin cortar la resolución). Si el contenedor es de una versión anterior o
+// está detenido, lo recrea/inicia.
+func ReloadCoreDNSDocker() error {
+	dc, err := NewDockerClient()
 	if err != nil {
-		return false, err
+		return err
 	}
-	b, err := os.ReadFile(corefilePath)
+	exists, _, err := dc.ContainerState(coreDNSContainer)
 	if err != nil {
-		return false, fmt.Errorf("no pude leer Corefile en %s: %w", corefilePath, err)
-	}
-	content := string(b)
-
-	// Asegurar bloque de la zona (y línea bind tailIP)
-	var zoneChanged bool
-	content, zoneChanged = ensureZoneBlock(content, zone, tailIP)
-	changed = changed || zoneChanged
-
-	// Insertar/actualizar la entrada "tailIP fqdn" dentro de hosts{}
-	newContent, hostChanged := ensureHostMapping(content, zone, fqdn, tailIP)
-	if hostChanged {
-		content = newContent
-		changed = true
-	}
-
-	// Escribir si cambió
-	if changed {
-		tmp := corefilePath + ".tmp"
-		if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
-			return false, err
-		}
-		if err := os.Rename(tmp, corefilePath); err != nil {
-			return false, err
-		}
+		return err
 	}
-	return changed, nil
-}
-
-// RestartCoreDNSDocker reinicia el contenedor de CoreDNS para aplicar cambios del Corefile.
-func RestartCoreDNSDocker() error {
-	exists, _, _ := containerState(coreDNSContainer)
 	if !exists {
 		return fmt.Errorf("el contenedor %q no existe; inicia CoreDNS primero", coreDNSContainer)
 	}
-	return dockerRestart(coreDNSContainer)
+	recreated, err := ensureCoreDNSContainer(dc)
+	if err != nil || recreated {
+		return err
+	}
+	return dc.KillContainer(coreDNSContainer, coreDNSReloadSignal)
 }
 
-// EnsureDomainAndReload agrega/actualiza el FQDN y si hubo cambios, reinicia CoreDNS.
+// EnsureDomainAndReload agrega/actualiza el FQDN, recarga CoreDNS si hubo
+// cambios y comprueba que el nombre ya resuelve a tailIP.
 func EnsureDomainAndReload(zone, fqdn, tailIP string) error {
 	changed, err := AddDomainToCorefileDocker(zone, fqdn, tailIP)
 	if err != nil {
 		return err
 	}
 	if changed {
-		if err := RestartCoreDNSDocker(); err != nil {
-			return fmt.Errorf("reinicio CoreDNS falló: %w", err)
+		if err := ReloadCoreDNSDocker(); err != nil {
+			return fmt.Errorf("recarga de CoreDNS falló: %w", err)
 		}
 	}
-	return nil
+	return VerifyDNSRecord(DNSRecord{Name: fqdn, Type: "A", Value: tailIP})
 }
 
 // -----------------------------------------------------------------------------
 // Helpers de contenedor Docker
 // -----------------------------------------------------------------------------
 
-func runCoreDNSContainer(corefilePath string) error {
-	cmd := exec.Command(
-		"docker", "run", "-d",
-		"--name", coreDNSContainer,
-		"--restart", "unless-stopped",
-		"--network", "host",
-		"-v", corefilePath+":/Corefile:ro",
-		coreDNSImage, "-conf", "/Corefile",
-	)
-	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
-	if err := cmd.Run(); err != nil {
+// runCoreDNSContainer crea el contenedor montando todo ~/.autohost/coredns en
+// /etc/coredns (Corefile + archivos de zona).
+func runCoreDNSContainer(dc *DockerClient, dir string) error {
+	_, err := dc.CreateContainer(coreDNSContainer, ContainerSpec{
+		Image:         coreDNSImage,
+		Cmd:           []string{"-conf", coreDNSMountDir + "/Corefile"},
+		Binds:         []string{dir + ":" + coreDNSMountDir + ":ro"},
+		NetworkMode:   "host",
+		RestartPolicy: "unless-stopped",
+		Labels:        map[string]string{"dev.autohost.managed": "true"},
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo crear el contenedor CoreDNS: %w", err)
+	}
+	if err := dc.StartContainer(coreDNSContainer); err != nil {
 		return fmt.Errorf("no se pudo iniciar el contenedor CoreDNS: %w", err)
 	}
 	return nil
 }
 
-func dockerRestart(name string) error {
-	cmd := exec.Command("docker", "restart", name)
-	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
-	return cmd.Run()
-}
-
-func dockerStart(name string) error {
-	cmd := exec.Command("docker", "start", name)
-	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
-	return cmd.Run()
-}
+// ensureCoreDNSContainer deja el contenedor creado, con el montaje esperado y
+// en marcha. Devuelve recreated=true si lo (re)creó (ya carga la config nueva).
+func ensureCoreDNSContainer(dc *DockerClient) (recreated bool, err error) {
+	dir := coreDNSDir()
+	info, err := dc.InspectContainer(coreDNSContainer)
+	if err != nil {
+		if !errors.Is(err, ErrDockerNotFound) {
+			return false, fmt.Errorf("no pude consultar el contenedor %s: %w", coreDNSContainer, err)
+		}
+		return true, runCoreDNSContainer(dc, dir)
+	}
 
-func containerState(name string) (exists bool, running bool, err error) {
-	cmd := exec.Command("docker", "inspect", "-f", "{{.State.Running}}", name)
-	out, e := cmd.Output()
-	if e != nil {
-		// no existe o error de docker
-		return false, false, nil
-	}
-	s := strings.TrimSpace(string(out))
-	switch s {
-	case "true":
-		return true, true, nil
-	case "false":
-		return true, false, nil
-	default:
-		return true, false, nil
+	mounted := false
+	for _, m := range info.Mounts {
+		if m.Source == dir && m.Destination == coreDNSMountDir {
+			mounted = true
+		}
+	}
+	if !mounted {
+		// Contenedor de una versión anterior (solo montaba el Corefile): recrearlo.
+		if err := dc.RemoveContainer(coreDNSContainer, true); err != nil {
+			return false, fmt.Errorf("no se pudo eliminar %s: %w", coreDNSContainer, err)
+		}
+		return true, runCoreDNSContainer(dc, dir)
+	}
+	if !info.State.Running {
+		if err := dc.StartContainer(coreDNSContainer); err != nil {
+			return false, fmt.Errorf("no se pudo iniciar %s: %w", coreDNSContainer, err)
+		}
+		return true, nil
 	}
+	return false, nil
 }
 
 // -----------------------------------------------------------------------------
-// Helpers de Corefile (parsing/edición string-based simple)
+// Helpers de Corefile (sobre el AST de corefile.go)
 // -----------------------------------------------------------------------------
 
 func corefilePath() (string, error) {
-	home, _ := os.UserHomeDir()
-	dir := filepath.Join(home, ".autohost", "coredns")
+	dir := coreDNSDir()
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		return "", fmt.Errorf("no existe %s; inicia CoreDNS con InstallAndRunCoreDNSWithDocker primero", dir)
 	}
 	return filepath.Join(dir, "Corefile"), nil
 }
 
-// ensureZoneBlock garantiza un bloque para la zona con bind a tailIP y hosts{}.
-// Si la zona no existe, lo inserta antes del bloque '.' (o lo crea si tampoco existe '.').
-func ensureZoneBlock(content, zone, tailIP string) (string, bool) {
-	changed := false
-	zoneHeader := zone + ":53 {"
+// ConfigChange describe la edición de un archivo gestionado (Corefile o
+// archivo de zona): contenido antes y después.
+type ConfigChange struct {
+	Path   string
+	Before string
+	After  string
+}
 
-	if strings.Contains(content, zoneHeader) {
-		// Asegurar "bind tailIP" dentro del bloque de la zona
-		zStart := strings.Index(content, zoneHeader)
-		zEnd := findMatchingBrace(content, zStart)
-		if zEnd == -1 {
-			return content, false
-		}
-		zBlock := content[zStart:zEnd]
-		if !strings.Contains(zBlock, "bind "+tailIP) {
-			// Insertar/actualizar bind (si hay otro bind, lo reemplazamos a lo bruto)
-			if strings.Contains(zBlock, "bind ") {
-				zBlock = replaceBindLine(zBlock, tailIP)
-			} else {
-				// Insertar después de la apertura "{"
-				openIdx := strings.Index(zBlock, "{")
-				if openIdx != -1 {
-					openIdx++
-					zBlock = zBlock[:openIdx] + "\n    bind " + tailIP + zBlock[openIdx:]
-				}
-			}
-			content = content[:zStart] + zBlock + content[zEnd:]
-			changed = true
-		}
-		// Asegurar que exista hosts{}
-		if !strings.Contains(zBlock, "hosts {") {
-			openIdx := strings.Index(zBlock, "{")
-			if openIdx != -1 {
-				openIdx++
-				toInsert := `
-    hosts {
-        # entries managed by autohost
-        fallthrough
-    }`
-				newZ := zBlock[:openIdx] + toInsert + zBlock[openIdx:]
-				content = content[:zStart] + newZ + content[zEnd:]
-				changed = true
-			}
-		}
-		return content, changed
-	}
+// Changed indica si la edición modificó el archivo.
+func (c ConfigChange) Changed() bool { return c.Before != c.After }
 
-	// Si no existe la zona, construir bloque de zona con bind+hosts
-	zoneBlock := fmt.Sprintf(`
-%s
-    bind %s
-    log
-    errors
-    hosts {
-        # entries managed by autohost
-        fallthrough
-    }
+// Diff devuelve el diff unificado de la edición ("" si no hubo cambios).
+func (c ConfigChange) Diff() string {
+	return utils.UnifiedDiff(c.Before, c.After, c.Path, c.Path)
 }
-`, zoneHeader, tailIP)
 
-	// Intentar insertar antes del bloque global "."
-	dotHeader := "\n. {"
-	idx := strings.Index(content, dotHeader)
-	if idx == -1 && !strings.Contains(content, "\n. {\n") && !strings.Contains(content, "\n.\n{") && !strings.Contains(content, ". {\n") {
-		// No hay bloque ".", lo creamos completo abajo
-		dotBlock := `
-. {
-    log
-    errors
-    forward . /etc/resolv.conf
-}
-`
-		return strings.TrimSpace(content) + "\n\n" + strings.TrimSpace(zoneBlock) + "\n\n" + strings.TrimSpace(dotBlock) + "\n", true
-	}
+// DNSChanges agrupa las ediciones de una operación sobre una zona.
+type DNSChanges []ConfigChange
 
-	if idx == -1 {
-		// fallback: insertar al inicio
-		return strings.TrimSpace(zoneBlock) + "\n\n" + content, true
+// Changed indica si alguno de los archivos cambió.
+func (cs DNSChanges) Changed() bool {
+	for _, c := range cs {
+		if c.Changed() {
+			return true
+		}
 	}
+	return false
+}
 
-	// Insertar la zona justo antes del bloque "."
-	out := content[:idx] + "\n" + strings.TrimSpace(zoneBlock) + "\n" + content[idx:]
-	return out, true
+// Diff concatena los diffs de todos los archivos modificados.
+func (cs DNSChanges) Diff() string {
+	var b strings.Builder
+	for _, c := range cs {
+		b.WriteString(c.Diff())
+	}
+	return b.String()
 }
 
-// ensureHostMapping añade/actualiza "tailIP fqdn" en el bloque hosts{} de la zona.
-func ensureHostMapping(content, zone, fqdn, tailIP string) (string, bool) {
-	zStart := strings.Index(content, zone+":53 {")
-	if zStart == -1 {
-		return content, false
+// updateCorefile parsea el Corefile, aplica edit y lo reescribe (tmp + rename)
+// solo si el resultado serializado cambió.
+func updateCorefile(edit func(cf *Corefile) error) (ConfigChange, error) {
+	var ch ConfigChange
+	path, err := corefilePath()
+	if err != nil {
+		return ch, err
 	}
-	zEnd := findMatchingBrace(content, zStart)
-	if zEnd == -1 {
-		return content, false
+	ch.Path = path
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ch, fmt.Errorf("no pude leer Corefile en %s: %w", path, err)
 	}
-
-	zBlock := content[zStart:zEnd]
-	hStartRel := strings.Index(zBlock, "hosts {")
-	if hStartRel == -1 {
-		// insertar hosts{} después de "{"
-		openIdx := strings.Index(zBlock, "{")
-		if openIdx == -1 {
-			return content, false
-		}
-		openIdx++
-		toInsert := fmt.Sprintf(`
-    hosts {
-        %s %s
-        fallthrough
-    }`, tailIP, fqdn)
-		newZ := zBlock[:openIdx] + toInsert + zBlock[openIdx:]
-		return content[:zStart] + newZ + content[zEnd:], true
+	cf, err := ParseCorefile(string(b))
+	if err != nil {
+		return ch, fmt.Errorf("%s: %w", path, err)
+	}
+	ch.Before = cf.Format()
+	if err := edit(cf); err != nil {
+		return ch, err
+	}
+	ch.After = cf.Format()
+	if !ch.Changed() {
+		return ch, nil
 	}
 
-	// localizar cierre de hosts{}
-	hStart := zStart + hStartRel
-	hEnd := findMatchingBrace(content, hStart+len("hosts "))
-	if hEnd == -1 {
-		return content, false
+	tmp := path + ".tmp"
+	if err := utils.DefaultRunner().WriteFile(tmp, []byte(ch.After), 0o644); err != nil {
+		return ch, err
+	}
+	if err := utils.DefaultRunner().Rename(tmp, path); err != nil {
+		return ch, err
 	}
-	hBlock := content[hStart:hEnd]
+	return ch, nil
+}
 
-	lines := strings.Split(hBlock, "\n")
-	found := false
-	for i, ln := range lines {
-		trim := strings.TrimSpace(ln)
-		// match "<ip> <fqdn>"
-		if strings.HasSuffix(trim, " "+fqdn) {
-			found = true
-			if !strings.HasPrefix(trim, tailIP+" ") { // IP distinta -> reemplazar
-				lines[i] = replaceLineKeepingIndent(ln, tailIP+" "+fqdn)
+// editZone aplica edit sobre la zona gestionada y asegura su bloque en el
+// Corefile. Si el bloque todavía usa el plugin hosts, sus entradas se migran
+// a la zona. bind puede ser "" para conservar el bind actual.
+func editZone(zone, bind string, edit func(z *ZoneData) error) (DNSChanges, error) {
+	zone = normalizeZone(zone)
+	cf, err := loadCorefile()
+	if err != nil {
+		return nil, err
+	}
+	var migrated []DNSRecord
+	migratedTTL := 0
+	if zb := cf.FindZone(zone); zb != nil {
+		if zb.Directive("file") == nil {
+			migrated = hostsRecords(zone, zb)
+			if hosts := zb.Directive("hosts"); hosts != nil {
+				if t := hosts.Sub("ttl"); t != nil && len(t.Args) == 1 {
+					migratedTTL, _ = strconv.Atoi(t.Args[0])
+				}
 			}
-			break
+		}
+		if d := zb.Directive("bind"); bind == "" && d != nil && len(d.Args) > 0 {
+			bind = d.Args[0]
 		}
 	}
-	if !found {
-		// insertar antes de "fallthrough" si existe
-		inserted := false
-		for i, ln := range lines {
-			if strings.Contains(ln, "fallthrough") {
-				indent := leadingSpaces(ln)
-				lines = append(lines[:i], append([]string{indent + tailIP + " " + fqdn}, lines[i:]...)...)
-				inserted = true
-				break
+
+	zc, err := updateZone(zone, func(z *ZoneData) error {
+		if migratedTTL > 0 {
+			z.TTL = migratedTTL
+		}
+		for _, r := range migrated {
+			if err := z.Upsert(r); err != nil {
+				return fmt.Errorf("migrando %s desde hosts: %w", r.Name, err)
 			}
 		}
-		if !inserted {
-			lines = append(lines, "        "+tailIP+" "+fqdn)
+		if bind != "" {
+			z.NSAddr = bind
 		}
+		return edit(z)
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	newH := strings.Join(lines, "\n")
-	newContent := content[:hStart] + newH + content[hEnd:]
-	return newContent, true
+	cc, err := updateCorefile(func(cf *Corefile) error {
+		ensureZone(cf, zone, bind)
+		return nil
+	})
+	return DNSChanges{cc, zc}, err
 }
 
-// findMatchingBrace encuentra la posición después del '}' que cierra el bloque
-// que inicia en 'startIdxHeader'. Se busca el primer '{' después de ese header
-// y se balancean llaves hasta cerrarlo.
-func findMatchingBrace(s string, startIdxHeader int) int {
-	open := strings.Index(s[startIdxHeader:], "{")
-	if open == -1 {
-		return -1
-	}
-	i := startIdxHeader + open + 1
-	level := 1
-	for i < len(s) {
-		switch s[i] {
-		case '{':
-			level++
-		case '}':
-			level--
-			if level == 0 {
-				return i + 1 // índice después de la llave de cierre
-			}
+// hostsOptions son las opciones del plugin hosts (no son entradas IP → nombres).
+var hostsOptions = []string{"fallthrough", "ttl", "reload", "no_reverse"}
+
+// ensureZone garantiza un bloque `<zone>:53` con `bind tailIP` (si se indica),
+// el plugin reload y `file <archivo de zona>` (que revisa el serial cada pocos
+// segundos). Un bloque hosts existente se reemplaza
+// por el file (sus entradas las migra editZone). Si la zona no existe la
+// inserta antes del bloque "." (creándolo si falta).
+func ensureZone(cf *Corefile, zone, tailIP string) {
+	zb := cf.FindZone(zone)
+	if zb == nil {
+		zb = &ServerBlock{
+			Keys: []string{zone + ":53"},
+			Directives: []*Directive{
+				{Comments: []string{"# Limita el binding a la IP de Tailscale para evitar conflictos en :53"}, Name: "bind", Args: []string{tailIP}},
+				{BlankBefore: true, Name: "log"},
+				{Name: "errors"},
+			},
+		}
+		if cf.FindZone(".") == nil {
+			cf.Blocks = append(cf.Blocks, zb, &ServerBlock{
+				Keys: []string{"."},
+				Directives: []*Directive{
+					{Name: "log"},
+					{Name: "errors"},
+					{Name: "forward", Args: []string{".", "/etc/resolv.conf"}},
+				},
+			})
+		} else {
+			cf.InsertBlockBefore(zb, ".")
 		}
-		i++
 	}
-	return -1
-}
 
-func replaceBindLine(block, tailIP string) string {
-	lines := strings.Split(block, "\n")
-	for i, ln := range lines {
-		trim := strings.TrimSpace(ln)
-		if strings.HasPrefix(trim, "bind ") {
-			lines[i] = replaceLineKeepingIndent(ln, "bind "+tailIP)
+	if tailIP != "" {
+		if bind := zb.Directive("bind"); bind == nil {
+			zb.Directives = insertAt(zb.Directives, 0, &Directive{Name: "bind", Args: []string{tailIP}})
+		} else if len(bind.Args) != 1 || bind.Args[0] != tailIP {
+			bind.Args = []string{tailIP}
 		}
 	}
-	return strings.Join(lines, "\n")
-}
 
-func leadingSpaces(s string) string {
-	i := 0
-	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
-		i++
+	file := zb.Directive("file")
+	if file == nil {
+		file = &Directive{Name: "file"}
+		if hosts := zb.Directive("hosts"); hosts != nil {
+			file.Comments, file.BlankBefore = hosts.Comments, hosts.BlankBefore
+			zb.Directives = insertAt(zb.Directives, indexOf(zb.Directives, "hosts"), file)
+		} else {
+			zb.Directives = append(zb.Directives, file)
+		}
+	}
+	file.Args = []string{zoneDBContainerPath(zone)}
+	if file.Sub("reload") == nil {
+		file.HasBlock = true
+		file.Block = append(file.Block, &Directive{Name: "reload", Args: []string{zoneFileReload}})
+	}
+	// reload relee el Corefile cuando cambia, aunque nadie envíe la señal.
+	if zb.Directive("reload") == nil {
+		zb.Directives = insertAt(zb.Directives, indexOf(zb.Directives, "file"), &Directive{Name: "reload"})
+	}
+	if hosts := zb.Directive("hosts"); hosts != nil {
+		zb.Directives = removeDirective(zb.Directives, hosts)
 	}
-	return s[:i]
-}
-
-func replaceLineKeepingIndent(oldLine, newCore string) string {
-	return leadingSpaces(oldLine) + newCore
 }
 
-// -----------------------------------------------------------------------------
-// Utils
-// -----------------------------------------------------------------------------
-
-func renderToFile(tmpl, outPath string, data any) error {
-	t, err := template.New("tmpl").Parse(tmpl)
-	if err != nil {
-		return err
-	}
-	f, err := os.Create(outPath)
-	if err != nil {
-		return err
+func isHostsOption(name string) bool {
+	for _, o := range hostsOptions {
+		if name == o {
+			return true
+		}
 	}
-	defer f.Close()
-	return t.Execute(f, data)
+	return false
 }
@@ -0,0 +1,207 @@
+// internal/infra/coredns_records.go
+package infra
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DNSZone es una zona servida por el CoreDNS de autohost.
+type DNSZone struct {
+	Name    string   `json:"name"`
+	Bind    []string `json:"bind"`
+	Records int      `json:"records"`
+}
+
+// DNSRecord es un registro de una zona gestionada. TTL 0 usa el de la zona.
+type DNSRecord struct {
+	Zone  string `json:"zone"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   int    `json:"ttl,omitempty"`
+}
+
+// ReadCorefile devuelve la ruta y el contenido actual del Corefile.
+func ReadCorefile() (path, content string, err error) {
+	path, err = corefilePath()
+	if err != nil {
+		return "", "", err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return path, "", err
+	}
+	return path, string(b), nil
+}
+
+func loadCorefile() (*Corefile, error) {
+	path, content, err := ReadCorefile()
+	if err != nil {
+		return nil, err
+	}
+	cf, err := ParseCorefile(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cf, nil
+}
+
+// ListDNSZones lista las zonas del Corefile (sin el bloque raíz ".").
+func ListDNSZones() ([]DNSZone, error) {
+	cf, err := loadCorefile()
+	if err != nil {
+		return nil, err
+	}
+	var out []DNSZone
+	for _, z := range cf.Zones() {
+		if z == "." {
+			continue
+		}
+		zb := cf.FindZone(z)
+		records, err := zoneRecords(z, zb)
+		if err != nil {
+			return nil, err
+		}
+		dz := DNSZone{Name: z, Bind: []string{}, Records: len(records)}
+		if bind := zb.Directive("bind"); bind != nil {
+			dz.Bind = append(dz.Bind, bind.Args...)
+		}
+		out = append(out, dz)
+	}
+	return out, nil
+}
+
+// ListDNSRecords lista los registros de una zona (o de todas si zone es "").
+func ListDNSRecords(zone string) ([]DNSRecord, error) {
+	cf, err := loadCorefile()
+	if err != nil {
+		return nil, err
+	}
+	if zone != "" {
+		zb := cf.FindZone(zone)
+		if zb == nil {
+			return nil, fmt.Errorf("la zona %s no existe en el Corefile", zone)
+		}
+		return zoneRecords(normalizeZone(zone), zb)
+	}
+	out := []DNSRecord{}
+	for _, z := range cf.Zones() {
+		if z == "." {
+			continue
+		}
+		records, err := zoneRecords(z, cf.FindZone(z))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, records...)
+	}
+	return out, nil
+}
+
+// AddDNSRecord publica un registro en la zona gestionada que contiene fqdn. Si
+// no hay ninguna, crea la zona padre del fqdn enlazada a bind (la IP de tailnet).
+// fqdn puede ser un comodín ("*.apps.zona").
+func AddDNSRecord(fqdn, typ, value string, ttl int, bind string) (DNSChanges, error) {
+	fqdn = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(fqdn), "."))
+	cf, err := loadCorefile()
+	if err != nil {
+		return nil, err
+	}
+	zone := zoneFor(cf, fqdn)
+	if zone == "" {
+		i := strings.IndexByte(fqdn, '.')
+		if i <= 0 || i == len(fqdn)-1 {
+			return nil, fmt.Errorf("fqdn inválido: %s (esperado: host.zona)", fqdn)
+		}
+		if bind == "" {
+			return nil, fmt.Errorf("no hay zona para %s y no se indicó IP de bind para crearla", fqdn)
+		}
+		zone = fqdn[i+1:]
+	} else {
+		bind = "" // la zona ya existe: se conserva su bind
+	}
+	return editZone(zone, bind, func(z *ZoneData) error {
+		return z.Upsert(DNSRecord{Name: fqdn, Type: typ, Value: value, TTL: ttl})
+	})
+}
+
+// RemoveDNSRecord quita los registros de fqdn (de un tipo, o todos si typ es "").
+func RemoveDNSRecord(fqdn, typ string) (DNSChanges, error) {
+	fqdn = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(fqdn), "."))
+	cf, err := loadCorefile()
+	if err != nil {
+		return nil, err
+	}
+	zone := zoneFor(cf, fqdn)
+	if zone == "" {
+		return nil, fmt.Errorf("no hay ninguna zona que contenga %s", fqdn)
+	}
+	return editZone(zone, "", func(z *ZoneData) error {
+		if z.Remove(fqdn, typ) == 0 {
+			if typ != "" {
+				return fmt.Errorf("no existe un registro %s para %s", strings.ToUpper(typ), fqdn)
+			}
+			return fmt.Errorf("no existe un registro para %s", fqdn)
+		}
+		return nil
+	})
+}
+
+// zoneRecords devuelve los registros de la zona: del archivo de zona gestionado
+// o, si el bloque aún usa el plugin hosts, de sus entradas.
+func zoneRecords(zone string, zb *ServerBlock) ([]DNSRecord, error) {
+	if zb.Directive("file") == nil {
+		return hostsRecords(zone, zb), nil
+	}
+	z, _, err := loadZone(zone)
+	if err != nil {
+		return nil, err
+	}
+	z.sortRecords()
+	return z.Records, nil
+}
+
+// zoneFor devuelve la zona gestionada más específica que contiene fqdn
+// (coincidencia por etiquetas completas, nunca por sufijo de texto).
+func zoneFor(cf *Corefile, fqdn string) string {
+	best := ""
+	for _, z := range cf.Zones() {
+		if z == "." {
+			continue
+		}
+		if (fqdn == z || strings.HasSuffix(fqdn, "."+z)) && len(z) > len(best) {
+			best = z
+		}
+	}
+	return best
+}
+
+func hostsRecords(zone string, zb *ServerBlock) []DNSRecord {
+	out := []DNSRecord{}
+	hosts := zb.Directive("hosts")
+	if hosts == nil {
+		return out
+	}
+	for _, e := range hosts.Block {
+		if isHostsOption(e.Name) {
+			continue
+		}
+		ip := net.ParseIP(e.Name)
+		if ip == nil {
+			continue
+		}
+		typ := "A"
+		if ip.To4() == nil {
+			typ = "AAAA"
+		}
+		for _, name := range e.Args {
+			out = append(out, DNSRecord{Zone: zone, Name: strings.TrimSuffix(name, "."), Type: typ, Value: e.Name})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,121 @@
+// internal/infra/coredns_verify.go
+package infra
+
+import (
+	"autohost-cli/utils"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dnsVerifyTimeout  = 15 * time.Second
+	dnsVerifyInterval = 500 * time.Millisecond
+)
+
+// VerifyDNSRecord pregunta directamente a CoreDNS (en la IP de bind de la zona)
+// hasta que responda rec con el valor esperado o se agote el tiempo. Para un
+// comodín consulta un nombre cualquiera cubierto por él.
+func VerifyDNSRecord(rec DNSRecord) error {
+	cf, err := loadCorefile()
+	if err != nil {
+		return err
+	}
+	fqdn := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(rec.Name), "."))
+	zone := zoneFor(cf, fqdn)
+	if zone == "" {
+		return fmt.Errorf("no hay ninguna zona que contenga %s", fqdn)
+	}
+	rec, err = normalizeRecord(zone, rec)
+	if err != nil {
+		return err
+	}
+	bind := "127.0.0.1"
+	if d := cf.FindZone(zone).Directive("bind"); d != nil && len(d.Args) > 0 {
+		bind = d.Args[0]
+	}
+	name := rec.Name
+	if strings.HasPrefix(name, "*.") {
+		name = "autohost-check" + name[1:]
+	}
+	server := net.JoinHostPort(bind, "53")
+
+	if utils.DefaultRunner().DryRun() {
+		fmt.Printf("%s verificar %s %s → %s en %s\n", utils.DryRunPrefix, name, rec.Type, rec.Value, server)
+		return nil
+	}
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	deadline := time.Now().Add(dnsVerifyTimeout)
+	var last string
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		answers, err := lookupRecord(ctx, resolver, name, rec.Type)
+		cancel()
+		if err == nil {
+			for _, a := range answers {
+				if a == rec.Value {
+					return nil
+				}
+			}
+			last = strings.Join(answers, ", ")
+		} else {
+			last = err.Error()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("CoreDNS (%s) no respondió %s %s → %s tras %s (última respuesta: %s)",
+				server, name, rec.Type, rec.Value, dnsVerifyTimeout, last)
+		}
+		time.Sleep(dnsVerifyInterval)
+	}
+}
+
+// lookupRecord devuelve las respuestas en el mismo formato que DNSRecord.Value.
+func lookupRecord(ctx context.Context, r *net.Resolver, name, typ string) ([]string, error) {
+	var out []string
+	switch typ {
+	case "A", "AAAA":
+		network := "ip4"
+		if typ == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			out = append(out, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, strings.TrimSuffix(cname, "."))
+	case "TXT":
+		return r.LookupTXT(ctx, name)
+	case "SRV":
+		_, srvs, err := r.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range srvs {
+			out = append(out, strings.Join([]string{
+				strconv.Itoa(int(s.Priority)), strconv.Itoa(int(s.Weight)),
+				strconv.Itoa(int(s.Port)), strings.TrimSuffix(s.Target, "."),
+			}, " "))
+		}
+	default:
+		return nil, fmt.Errorf("tipo no verificable: %s", typ)
+	}
+	return out, nil
+}
@@ -0,0 +1,386 @@
+// internal/infra/coredns_zone.go
+package infra
+
+import (
+	"autohost-cli/utils"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Las zonas gestionadas se guardan como JSON (fuente de verdad) y se generan
+// como archivos de zona RFC 1035 que CoreDNS sirve con el plugin `file`:
+//
+//	~/.autohost/coredns/zones/<zona>.json
+//	~/.autohost/coredns/zones/db.<zona>   → /etc/coredns/zones/db.<zona> en el contenedor
+const (
+	coreDNSMountDir  = "/etc/coredns"
+	defaultZoneTTL   = 300
+	maxTXTChunkBytes = 255
+)
+
+// Tipos de registro soportados en las zonas gestionadas.
+var supportedRecordTypes = []string{"A", "AAAA", "CNAME", "TXT", "SRV"}
+
+// ZoneData es el contenido de una zona gestionada.
+type ZoneData struct {
+	Zone    string      `json:"zone"`
+	Serial  uint32      `json:"serial"`
+	TTL     int         `json:"ttl"`
+	NSAddr  string      `json:"ns_addr,omitempty"` // IP del nameserver (glue de ns.<zona>)
+	Records []DNSRecord `json:"records"`
+}
+
+var dnsLabelRe = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_-]*[a-z0-9])?)$`)
+
+func coreDNSDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".autohost", "coredns")
+}
+
+func zoneJSONPath(zone string) string {
+	return filepath.Join(coreDNSDir(), "zones", zone+".json")
+}
+
+func zoneDBPath(zone string) string {
+	return filepath.Join(coreDNSDir(), "zones", "db."+zone)
+}
+
+// zoneDBContainerPath es la ruta del archivo de zona dentro del contenedor.
+func zoneDBContainerPath(zone string) string {
+	return coreDNSMountDir + "/zones/db." + zone
+}
+
+// loadZone lee la zona; si no existe devuelve una vacía (exists=false).
+func loadZone(zone string) (z *ZoneData, exists bool, err error) {
+	b, err := os.ReadFile(zoneJSONPath(zone))
+	if os.IsNotExist(err) {
+		return &ZoneData{Zone: zone, TTL: defaultZoneTTL, Records: []DNSRecord{}}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	z = &ZoneData{}
+	if err := json.Unmarshal(b, z); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", zoneJSONPath(zone), err)
+	}
+	if z.TTL <= 0 {
+		z.TTL = defaultZoneTTL
+	}
+	for i := range z.Records {
+		z.Records[i].Zone = zone
+	}
+	return z, true, nil
+}
+
+// updateZone aplica edit sobre la zona y, si los registros cambiaron, sube
+// el serial del SOA y reescribe el JSON y el archivo de zona.
+func updateZone(zone string, edit func(z *ZoneData) error) (ConfigChange, error) {
+	ch := ConfigChange{Path: zoneDBPath(zone)}
+	z, exists, err := loadZone(zone)
+	if err != nil {
+		return ch, err
+	}
+	if exists {
+		if b, err := os.ReadFile(ch.Path); err == nil {
+			ch.Before = string(b)
+		}
+	}
+	prev := z.fingerprint()
+	if err := edit(z); err != nil {
+		return ch, err
+	}
+	if exists && z.fingerprint() == prev && ch.Before != "" {
+		ch.After = ch.Before
+		return ch, nil
+	}
+
+	z.Serial = nextSerial(z.Serial, time.Now())
+	z.sortRecords()
+	ch.After = z.Render()
+
+	data, err := json.MarshalIndent(z, "", "  ")
+	if err != nil {
+		return ch, err
+	}
+	r := utils.DefaultRunner()
+	if err := r.MkdirAll(filepath.Dir(ch.Path), 0o755); err != nil {
+		return ch, err
+	}
+	if err := r.WriteFile(zoneJSONPath(zone), append(data, '\n'), 0o644); err != nil {
+		return ch, err
+	}
+	if err := r.WriteFile(ch.Path+".tmp", []byte(ch.After), 0o644); err != nil {
+		return ch, err
+	}
+	return ch, r.Rename(ch.Path+".tmp", ch.Path)
+}
+
+// nextSerial sigue el formato YYYYMMDDnn; si ya se usaron los 100 del día, suma 1.
+func nextSerial(prev uint32, now time.Time) uint32 {
+	y, m, d := now.Date()
+	base := uint32(y*1000000 + int(m)*10000 + d*100)
+	if prev < base {
+		return base
+	}
+	return prev + 1
+}
+
+// fingerprint resume lo que se publica (sin el serial) para detectar cambios.
+func (z *ZoneData) fingerprint() string {
+	cp := *z
+	cp.Serial = 0
+	cp.Records = append([]DNSRecord(nil), z.Records...)
+	cp.sortRecords()
+	b, _ := json.Marshal(cp)
+	return string(b)
+}
+
+func (z *ZoneData) sortRecords() {
+	order := map[string]int{}
+	for i, t := range supportedRecordTypes {
+		order[t] = i
+	}
+	sort.SliceStable(z.Records, func(i, j int) bool {
+		a, b := z.Records[i], z.Records[j]
+		if a.Name != b.Name {
+			if a.Name == z.Zone || b.Name == z.Zone {
+				return a.Name == z.Zone // el apex primero
+			}
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return order[a.Type] < order[b.Type]
+		}
+		return a.Value < b.Value
+	})
+}
+
+// Upsert agrega un registro. A, AAAA y CNAME tienen un solo valor por nombre
+// (se reemplaza); TXT y SRV admiten varios. Un CNAME no puede convivir con
+// otros registros del mismo nombre.
+func (z *ZoneData) Upsert(rec DNSRecord) error {
+	rec, err := normalizeRecord(z.Zone, rec)
+	if err != nil {
+		return err
+	}
+	kept := z.Records[:0:0]
+	for _, r := range z.Records {
+		if r.Name != rec.Name {
+			kept = append(kept, r)
+			continue
+		}
+		if (rec.Type == "CNAME") != (r.Type == "CNAME") {
+			return fmt.Errorf("%s ya tiene un registro %s; un CNAME no puede convivir con otros tipos", rec.Name, r.Type)
+		}
+		switch {
+		case r.Type != rec.Type:
+			kept = append(kept, r)
+		case rec.Type == "TXT" || rec.Type == "SRV":
+			if r.Value == rec.Value {
+				r.TTL = rec.TTL
+				rec = DNSRecord{} // ya existe: solo se actualiza el TTL
+			}
+			kept = append(kept, r)
+		}
+		// A/AAAA/CNAME del mismo tipo: se descarta y se agrega el nuevo
+	}
+	if rec.Name != "" {
+		kept = append(kept, rec)
+	}
+	z.Records = kept
+	return nil
+}
+
+// Remove quita los registros de name (de un tipo, o todos si typ es "").
+func (z *ZoneData) Remove(name, typ string) int {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	typ = strings.ToUpper(typ)
+	kept := z.Records[:0:0]
+	for _, r := range z.Records {
+		if r.Name == name && (typ == "" || r.Type == typ) {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	n := len(z.Records) - len(kept)
+	z.Records = kept
+	return n
+}
+
+// normalizeRecord valida nombre y valor según el tipo.
+func normalizeRecord(zone string, rec DNSRecord) (DNSRecord, error) {
+	rec.Zone = zone
+	rec.Type = strings.ToUpper(strings.TrimSpace(rec.Type))
+	rec.Name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(rec.Name), "."))
+	if rec.Name == "@" || rec.Name == "" {
+		rec.Name = zone
+	}
+	if rec.Name != zone && !strings.HasSuffix(rec.Name, "."+zone) {
+		return rec, fmt.Errorf("%s no pertenece a la zona %s", rec.Name, zone)
+	}
+	if rel := strings.TrimSuffix(strings.TrimSuffix(rec.Name, zone), "."); rel != "" {
+		for i, l := range strings.Split(rel, ".") {
+			if l == "*" && i == 0 {
+				continue
+			}
+			if !dnsLabelRe.MatchString(l) {
+				return rec, fmt.Errorf("nombre inválido: %s", rec.Name)
+			}
+		}
+	}
+	if rec.TTL < 0 {
+		return rec, fmt.Errorf("TTL inválido: %d", rec.TTL)
+	}
+
+	v := strings.TrimSpace(rec.Value)
+	switch rec.Type {
+	case "A":
+		if ip := net.ParseIP(v); ip == nil || ip.To4() == nil {
+			return rec, fmt.Errorf("IPv4 inválida para registro A: %q", v)
+		}
+	case "AAAA":
+		if ip := net.ParseIP(v); ip == nil || ip.To4() != nil {
+			return rec, fmt.Errorf("IPv6 inválida para registro AAAA: %q", v)
+		}
+	case "CNAME":
+		if rec.Name == zone {
+			return rec, fmt.Errorf("%s es el apex de la zona (tiene SOA y NS); un CNAME no puede ir ahí, usa A/AAAA", zone)
+		}
+		v = strings.ToLower(strings.TrimSuffix(v, "."))
+		if !isHostname(v) {
+			return rec, fmt.Errorf("destino CNAME inválido: %q", rec.Value)
+		}
+		if v == rec.Name {
+			return rec, fmt.Errorf("un CNAME no puede apuntarse a sí mismo")
+		}
+	case "TXT":
+		if v == "" {
+			return rec, fmt.Errorf("el registro TXT no puede estar vacío")
+		}
+		v = rec.Value // se respeta tal cual (espacios incluidos)
+	case "SRV":
+		f := strings.Fields(v)
+		if len(f) != 4 {
+			return rec, fmt.Errorf("SRV espera \"prioridad peso puerto destino\", no %q", v)
+		}
+		for i, n := range f[:3] {
+			if x, err := strconv.Atoi(n); err != nil || x < 0 || x > 65535 || (i == 2 && x == 0) {
+				return rec, fmt.Errorf("SRV: valor numérico inválido %q", n)
+			}
+		}
+		target := strings.ToLower(strings.TrimSuffix(f[3], "."))
+		if !isHostname(target) {
+			return rec, fmt.Errorf("SRV: destino inválido %q", f[3])
+		}
+		v = strings.Join(append(f[:3], target), " ")
+	default:
+		return rec, fmt.Errorf("tipo de registro no soportado: %s (usa %s)", rec.Type, strings.Join(supportedRecordTypes, "|"))
+	}
+	rec.Value = v
+	return rec, nil
+}
+
+func isHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, l := range strings.Split(s, ".") {
+		if !dnsLabelRe.MatchString(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// -----------------------------------------------------------------------------
+// Archivo de zona RFC 1035
+// -----------------------------------------------------------------------------
+
+// Render genera el archivo de zona para el plugin `file` de CoreDNS.
+func (z *ZoneData) Render() string {
+	origin := z.Zone + "."
+	var b strings.Builder
+	fmt.Fprintf(&b, "; Zona %s generada por autohost a partir de zones/%s.json; no editar a mano.\n", z.Zone, z.Zone)
+	fmt.Fprintf(&b, "$ORIGIN %s\n$TTL %d\n\n", origin, z.TTL)
+	fmt.Fprintf(&b, "@\tIN\tSOA\tns.%s hostmaster.%s (\n", origin, origin)
+	fmt.Fprintf(&b, "\t\t%d\t; serial\n\t\t7200\t; refresh\n\t\t3600\t; retry\n\t\t1209600\t; expire\n\t\t%d )\t; minimum\n", z.Serial, z.TTL)
+	fmt.Fprintf(&b, "@\tIN\tNS\tns.%s\n", origin)
+
+	hasNS := false
+	for _, r := range z.Records {
+		if r.Name == "ns."+z.Zone {
+			hasNS = true
+		}
+	}
+	if !hasNS && z.NSAddr != "" {
+		typ := "A"
+		if ip := net.ParseIP(z.NSAddr); ip != nil && ip.To4() == nil {
+			typ = "AAAA"
+		}
+		fmt.Fprintf(&b, "ns\tIN\t%s\t%s\n", typ, z.NSAddr)
+	}
+	if len(z.Records) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, r := range z.Records {
+		name := z.relative(r.Name)
+		ttl := ""
+		if r.TTL > 0 {
+			ttl = strconv.Itoa(r.TTL)
+		}
+		var value string
+		switch r.Type {
+		case "CNAME":
+			value = z.target(r.Value)
+		case "SRV":
+			f := strings.Fields(r.Value)
+			value = strings.Join(f[:3], " ") + " " + z.target(f[3])
+		case "TXT":
+			value = quoteTXT(r.Value)
+		default:
+			value = r.Value
+		}
+		fmt.Fprintf(&b, "%s\t%s\tIN\t%s\t%s\n", name, ttl, r.Type, value)
+	}
+	return b.String()
+}
+
+// relative convierte un nombre de la zona a relativo ("@" para el apex).
+func (z *ZoneData) relative(name string) string {
+	if name == z.Zone {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+z.Zone)
+}
+
+// target deja relativo un destino dentro de la zona; los externos van con punto final.
+func (z *ZoneData) target(host string) string {
+	if host == z.Zone || strings.HasSuffix(host, "."+z.Zone) {
+		return z.relative(host)
+	}
+	return host + "."
+}
+
+// quoteTXT parte el texto en cadenas de 255 bytes y escapa comillas y barras.
+func quoteTXT(s string) string {
+	var parts []string
+	for len(s) > 0 {
+		n := len(s)
+		if n > maxTXTChunkBytes {
+			n = maxTXTChunkBytes
+		}
+		chunk := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s[:n])
+		parts = append(parts, `"`+chunk+`"`)
+		s = s[n:]
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,221 @@
+package infra
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testZone(t *testing.T, recs ...DNSRecord) *ZoneData {
+	t.Helper()
+	z := &ZoneData{Zone: "maza-server", Serial: 2024050101, TTL: 300, NSAddr: "100.64.0.1", Records: []DNSRecord{}}
+	for _, r := range recs {
+		if err := z.Upsert(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return z
+}
+
+func TestZoneRender(t *testing.T) {
+	z := testZone(t,
+		DNSRecord{Name: "wiki.maza-server", Type: "A", Value: "100.64.0.2", TTL: 60},
+		DNSRecord{Name: "docs.maza-server.", Type: "cname", Value: "wiki.maza-server."},
+		DNSRecord{Name: "ext.maza-server", Type: "CNAME", Value: "Example.com", TTL: 60},
+		DNSRecord{Name: "@", Type: "TXT", Value: "v=spf1 -all"},
+		DNSRecord{Name: "_http._tcp.maza-server", Type: "SRV", Value: "10 5 80 wiki.maza-server"},
+		DNSRecord{Name: "*.apps.maza-server", Type: "A", Value: "100.64.0.9"},
+		DNSRecord{Name: "maza-server", Type: "A", Value: "100.64.0.1"},
+	)
+	z.sortRecords()
+	want := `; Zona maza-server generada por autohost a partir de zones/maza-server.json; no editar a mano.
+$ORIGIN maza-server.
+$TTL 300
+
+@	IN	SOA	ns.maza-server. hostmaster.maza-server. (
+		2024050101	; serial
+		7200	; refresh
+		3600	; retry
+		1209600	; expire
+		300 )	; minimum
+@	IN	NS	ns.maza-server.
+ns	IN	A	100.64.0.1
+
+@		IN	A	100.64.0.1
+@		IN	TXT	"v=spf1 -all"
+*.apps		IN	A	100.64.0.9
+_http._tcp		IN	SRV	10 5 80 wiki
+docs		IN	CNAME	wiki
+ext	60	IN	CNAME	example.com.
+wiki	60	IN	A	100.64.0.2
+`
+	if got := z.Render(); got != want {
+		t.Fatalf("Render() =\n%s\nquiero\n%s", got, want)
+	}
+}
+
+// Con un registro propio para ns.<zona> no se agrega el glue de NSAddr;
+// sin registros la zona solo tiene SOA y NS.
+func TestZoneRenderNS(t *testing.T) {
+	z := testZone(t, DNSRecord{Name: "ns.maza-server", Type: "AAAA", Value: "fd7a:115c:a1e0::1"})
+	if got := z.Render(); strings.Contains(got, "100.64.0.1") || !strings.Contains(got, "ns\t\tIN\tAAAA\tfd7a:115c:a1e0::1\n") {
+		t.Fatalf("Render() =\n%s", got)
+	}
+	empty := &ZoneData{Zone: "lan", Serial: 1, TTL: 300}
+	if got := empty.Render(); !strings.HasSuffix(got, "@\tIN\tNS\tns.lan.\n") {
+		t.Fatalf("zona vacía =\n%s", got)
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := map[string]string{
+		`v=spf1 -all`:   `"v=spf1 -all"`,
+		`dice "hola" \`: `"dice \"hola\" \\"`,
+		long:            `"` + long[:255] + `" "` + long[255:] + `"`,
+	}
+	for in, want := range tests {
+		if got := quoteTXT(in); got != want {
+			t.Errorf("quoteTXT(%.20q…) = %.40q…", in, got)
+		}
+	}
+}
+
+func TestZoneUpsert(t *testing.T) {
+	base := []DNSRecord{
+		{Name: "wiki.maza-server", Type: "A", Value: "100.64.0.2"},
+		{Name: "wiki.maza-server", Type: "TXT", Value: "uno"},
+		{Name: "docs.maza-server", Type: "CNAME", Value: "wiki.maza-server"},
+	}
+	tests := []struct {
+		name    string
+		rec     DNSRecord
+		want    []string // "nombre tipo valor ttl" de la zona resultante
+		wantErr string
+	}{
+		{
+			name: "A reemplaza a A",
+			rec:  DNSRecord{Name: "wiki.maza-server", Type: "A", Value: "100.64.0.3"},
+			want: []string{"docs CNAME wiki.maza-server 0", "wiki A 100.64.0.3 0", "wiki TXT uno 0"},
+		},
+		{
+			name: "TXT se acumula",
+			rec:  DNSRecord{Name: "wiki.maza-server", Type: "TXT", Value: "dos"},
+			want: []string{"docs CNAME wiki.maza-server 0", "wiki A 100.64.0.2 0", "wiki TXT dos 0", "wiki TXT uno 0"},
+		},
+		{
+			name: "TXT repetido solo cambia el TTL",
+			rec:  DNSRecord{Name: "wiki.maza-server", Type: "TXT", Value: "uno", TTL: 30},
+			want: []string{"docs CNAME wiki.maza-server 0", "wiki A 100.64.0.2 0", "wiki TXT uno 30"},
+		},
+		{
+			name: "CNAME reemplaza a CNAME",
+			rec:  DNSRecord{Name: "docs.maza-server", Type: "CNAME", Value: "example.com"},
+			want: []string{"docs CNAME example.com 0", "wiki A 100.64.0.2 0", "wiki TXT uno 0"},
+		},
+		{name: "CNAME sobre otros tipos", rec: DNSRecord{Name: "wiki.maza-server", Type: "CNAME", Value: "example.com"}, wantErr: "no puede convivir"},
+		{name: "otro tipo sobre CNAME", rec: DNSRecord{Name: "docs.maza-server", Type: "TXT", Value: "x"}, wantErr: "no puede convivir"},
+		{name: "CNAME en el apex (@)", rec: DNSRecord{Name: "@", Type: "CNAME", Value: "example.com"}, wantErr: "apex"},
+		{name: "CNAME en el apex (nombre)", rec: DNSRecord{Name: "Maza-Server.", Type: "CNAME", Value: "example.com"}, wantErr: "apex"},
+		{name: "CNAME a sí mismo", rec: DNSRecord{Name: "x.maza-server", Type: "CNAME", Value: "x.maza-server."}, wantErr: "a sí mismo"},
+		{name: "fuera de la zona", rec: DNSRecord{Name: "wiki.otra", Type: "A", Value: "1.2.3.4"}, wantErr: "no pertenece"},
+		{name: "comodín en medio", rec: DNSRecord{Name: "a.*.maza-server", Type: "A", Value: "1.2.3.4"}, wantErr: "nombre inválido"},
+		{name: "IPv6 en A", rec: DNSRecord{Name: "v6.maza-server", Type: "A", Value: "::1"}, wantErr: "IPv4 inválida"},
+		{name: "IPv4 en AAAA", rec: DNSRecord{Name: "v6.maza-server", Type: "AAAA", Value: "1.2.3.4"}, wantErr: "IPv6 inválida"},
+		{name: "SRV incompleto", rec: DNSRecord{Name: "_x._tcp.maza-server", Type: "SRV", Value: "10 5 wiki"}, wantErr: "SRV espera"},
+		{name: "SRV puerto 0", rec: DNSRecord{Name: "_x._tcp.maza-server", Type: "SRV", Value: "10 5 0 wiki"}, wantErr: "valor numérico"},
+		{name: "TXT vacío", rec: DNSRecord{Name: "t.maza-server", Type: "TXT", Value: "  "}, wantErr: "vacío"},
+		{name: "tipo desconocido", rec: DNSRecord{Name: "m.maza-server", Type: "MX", Value: "10 mail"}, wantErr: "no soportado"},
+		{name: "TTL negativo", rec: DNSRecord{Name: "n.maza-server", Type: "A", Value: "1.2.3.4", TTL: -1}, wantErr: "TTL inválido"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z := testZone(t, base...)
+			err := z.Upsert(tt.rec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, quiero %q", err, tt.wantErr)
+				}
+				if len(z.Records) != len(base) {
+					t.Fatalf("un error modificó la zona: %+v", z.Records)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			z.sortRecords()
+			var got []string
+			for _, r := range z.Records {
+				got = append(got, z.relative(r.Name)+" "+r.Type+" "+r.Value+" "+strconv.Itoa(r.TTL))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("registros =\n%s\nquiero\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestNextSerial(t *testing.T) {
+	day := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		prev uint32
+		want uint32
+	}{
+		{"zona nueva", 0, 2024050100},
+		{"serial de un día anterior", 2024043007, 2024050100},
+		{"mismo día", 2024050100, 2024050101},
+		{"mismo día, varios cambios", 2024050141, 2024050142},
+		// Pasados los 100 del día se sigue sumando: el serial nunca baja.
+		{"día agotado", 2024050199, 2024050200},
+		{"serial adelantado", 2030010100, 2030010101},
+	}
+	for _, tt := range tests {
+		if got := nextSerial(tt.prev, day); got != tt.want {
+			t.Errorf("%s: nextSerial(%d) = %d, quiero %d", tt.name, tt.prev, got, tt.want)
+		}
+	}
+}
+
+// updateZone solo reescribe (y sube el serial) cuando cambian los registros.
+func TestUpdateZoneSerial(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	add := func(z *ZoneData) error {
+		return z.Upsert(DNSRecord{Name: "wiki.lab", Type: "A", Value: "100.64.0.2"})
+	}
+	ch, err := updateZone("lab", add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Before != "" || !strings.Contains(ch.After, "wiki\t\tIN\tA\t100.64.0.2") {
+		t.Fatalf("primer cambio = %+v", ch)
+	}
+	z1, exists, err := loadZone("lab")
+	if err != nil || !exists {
+		t.Fatalf("loadZone: %v (existe %v)", err, exists)
+	}
+	if b, _ := os.ReadFile(zoneDBPath("lab")); string(b) != ch.After {
+		t.Fatal("db.lab no coincide con el cambio")
+	}
+
+	ch, err = updateZone("lab", add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Before != ch.After {
+		t.Fatal("sin cambios no debería reescribirse la zona")
+	}
+	if z2, _, _ := loadZone("lab"); z2.Serial != z1.Serial {
+		t.Fatalf("serial %d → %d sin cambios", z1.Serial, z2.Serial)
+	}
+
+	if _, err := updateZone("lab", func(z *ZoneData) error { z.Remove("wiki.lab", ""); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if z3, _, _ := loadZone("lab"); z3.Serial <= z1.Serial || len(z3.Records) != 0 {
+		t.Fatalf("tras borrar: serial %d (antes %d), registros %+v", z3.Serial, z1.Serial, z3.Records)
+	}
+}
@@ -0,0 +1,471 @@
+// internal/infra/corefile.go
+package infra
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Corefile es el AST de un Corefile de CoreDNS: bloques de servidor con sus
+// claves, directivas (con bloques anidados) y comentarios. Format() lo vuelve
+// a serializar con sangría canónica conservando comentarios y líneas en blanco.
+type Corefile struct {
+	Header  []string // comentarios antes del primer bloque
+	Blocks  []*ServerBlock
+	Trailer []string // comentarios después del último bloque
+}
+
+// ServerBlock es `clave1 clave2 { ... }`.
+type ServerBlock struct {
+	Comments    []string
+	Keys        []string
+	LineComment string
+	Directives  []*Directive
+	Trailing    []string // comentarios antes de la llave de cierre
+}
+
+// Directive es una línea `nombre arg1 arg2` con bloque `{ ... }` opcional.
+// Los argumentos se guardan tal como aparecen (incluidas comillas).
+type Directive struct {
+	Comments    []string
+	BlankBefore bool
+	Name        string
+	Args        []string
+	LineComment string
+	HasBlock    bool
+	Block       []*Directive
+	Trailing    []string
+}
+
+// CorefileError indica un error de sintaxis con su línea.
+type CorefileError struct {
+	Line int
+	Msg  string
+}
+
+func (e *CorefileError) Error() string {
+	return fmt.Sprintf("Corefile línea %d: %s", e.Line, e.Msg)
+}
+
+// -----------------------------------------------------------------------------
+// Tokenizer
+// -----------------------------------------------------------------------------
+
+type cfTokenKind int
+
+const (
+	cfWord cfTokenKind = iota
+	cfOpen
+	cfClose
+	cfComment
+)
+
+type cfToken struct {
+	kind    cfTokenKind
+	text    string
+	line    int // línea donde empieza
+	endLine int // línea donde termina (strings con saltos de línea)
+	blank   bool
+}
+
+// tokenizeCorefile separa palabras, `{`, `}` y comentarios. Como en el lexer
+// de Caddy, las llaves solo cuentan si son un token aislado y los strings
+// entre comillas pueden contener espacios, `#` y llaves.
+func tokenizeCorefile(src string) ([]cfToken, error) {
+	var toks []cfToken
+	line := 1
+	// blank: hay al menos una línea vacía entre el token anterior y este.
+	blank := func(start int) bool {
+		return len(toks) > 0 && start > toks[len(toks)-1].endLine+1
+	}
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#':
+			j := strings.IndexByte(src[i:], '\n')
+			if j < 0 {
+				j = len(src) - i
+			}
+			toks = append(toks, cfToken{kind: cfComment, text: strings.TrimRight(src[i:i+j], " \t\r"), line: line, endLine: line, blank: blank(line)})
+			i += j
+			continue
+		}
+
+		start, startLine := i, line
+		for i < len(src) {
+			c = src[i]
+			if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+				break
+			}
+			if c == '"' || c == '`' {
+				quote := c
+				i++
+				for i < len(src) && src[i] != quote {
+					if src[i] == '\\' && quote == '"' && i+1 < len(src) {
+						i++
+					}
+					if src[i] == '\n' {
+						line++
+					}
+					i++
+				}
+				if i >= len(src) {
+					return nil, &CorefileError{Line: startLine, Msg: "comillas sin cerrar"}
+				}
+			}
+			i++
+		}
+		text := src[start:i]
+		kind := cfWord
+		switch text {
+		case "{":
+			kind = cfOpen
+		case "}":
+			kind = cfClose
+		}
+		toks = append(toks, cfToken{kind: kind, text: text, line: startLine, endLine: line, blank: blank(startLine)})
+	}
+	return toks, nil
+}
+
+// -----------------------------------------------------------------------------
+// Parser
+// -----------------------------------------------------------------------------
+
+type cfParser struct {
+	toks []cfToken
+	pos  int
+}
+
+func (p *cfParser) peek() *cfToken {
+	if p.pos < len(p.toks) {
+		return &p.toks[p.pos]
+	}
+	return nil
+}
+
+func (p *cfParser) next() cfToken {
+	t := p.toks[p.pos]
+	p.pos++
+	return t
+}
+
+// lineComment consume un comentario en la misma línea que el token anterior.
+func (p *cfParser) lineComment(line int) string {
+	if t := p.peek(); t != nil && t.kind == cfComment && t.line == line {
+		return p.next().text
+	}
+	return ""
+}
+
+// comments consume comentarios en líneas propias.
+func (p *cfParser) comments() (out []string, blank bool) {
+	first := true
+	for t := p.peek(); t != nil && t.kind == cfComment; t = p.peek() {
+		if first {
+			blank = t.blank
+			first = false
+		}
+		out = append(out, p.next().text)
+	}
+	return out, blank
+}
+
+// ParseCorefile construye el AST de un Corefile.
+func ParseCorefile(src string) (*Corefile, error) {
+	toks, err := tokenizeCorefile(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &cfParser{toks: toks}
+	cf := &Corefile{}
+
+	for {
+		start := p.pos
+		comments, _ := p.comments()
+		t := p.peek()
+		if t == nil {
+			if len(cf.Blocks) == 0 {
+				cf.Header = comments
+			} else {
+				cf.Trailer = comments
+			}
+			return cf, nil
+		}
+		if len(cf.Blocks) == 0 && len(comments) > 0 {
+			// Lo que esté separado del primer bloque por una línea en blanco es encabezado.
+			split := 0
+			if t.blank {
+				split = len(comments)
+			} else {
+				for i := len(comments) - 1; i > 0; i-- {
+					if p.toks[start+i].blank {
+						split = i
+						break
+					}
+				}
+			}
+			cf.Header, comments = comments[:split], comments[split:]
+		}
+
+		sb := &ServerBlock{Comments: comments}
+		keyLine := t.line
+		for {
+			t = p.peek()
+			if t == nil {
+				return nil, &CorefileError{Line: keyLine, Msg: "bloque de servidor sin `{`"}
+			}
+			if t.kind == cfOpen {
+				break
+			}
+			if t.kind != cfWord {
+				return nil, &CorefileError{Line: t.line, Msg: fmt.Sprintf("token inesperado %q en las claves del servidor", t.text)}
+			}
+			for _, k := range strings.Split(p.next().text, ",") {
+				if k = strings.TrimSpace(k); k != "" {
+					sb.Keys = append(sb.Keys, k)
+				}
+			}
+		}
+		open := p.next()
+		sb.LineComment = p.lineComment(open.line)
+		body, trailing, err := p.parseBody(open.line)
+		if err != nil {
+			return nil, err
+		}
+		sb.Directives, sb.Trailing = body, trailing
+		cf.Blocks = append(cf.Blocks, sb)
+	}
+}
+
+// parseBody lee directivas hasta la `}` que cierra el bloque abierto en openLine.
+func (p *cfParser) parseBody(openLine int) ([]*Directive, []string, error) {
+	var out []*Directive
+	for {
+		comments, blank := p.comments()
+		t := p.peek()
+		if t == nil {
+			return nil, nil, &CorefileError{Line: openLine, Msg: "falta `}` de cierre"}
+		}
+		switch t.kind {
+		case cfClose:
+			p.next()
+			return out, comments, nil
+		case cfOpen:
+			return nil, nil, &CorefileError{Line: t.line, Msg: "`{` sin directiva"}
+		}
+
+		name := p.next()
+		if len(comments) == 0 {
+			blank = name.blank
+		}
+		d := &Directive{Comments: comments, BlankBefore: blank, Name: name.text}
+		last := name.endLine
+		for t = p.peek(); t != nil && t.line == last && t.kind == cfWord; t = p.peek() {
+			a := p.next()
+			d.Args = append(d.Args, a.text)
+			last = a.endLine
+		}
+		if t != nil && t.line == last && t.kind == cfOpen {
+			open := p.next()
+			d.HasBlock = true
+			d.LineComment = p.lineComment(open.line)
+			block, trailing, err := p.parseBody(open.line)
+			if err != nil {
+				return nil, nil, err
+			}
+			d.Block, d.Trailing = block, trailing
+		} else {
+			d.LineComment = p.lineComment(last)
+		}
+		out = append(out, d)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Serializer
+// -----------------------------------------------------------------------------
+
+const corefileIndent = "    "
+
+// Format serializa el Corefile con sangría de 4 espacios y una línea en
+// blanco entre bloques de servidor.
+func (cf *Corefile) Format() string {
+	var b strings.Builder
+	for _, c := range cf.Header {
+		b.WriteString(c + "\n")
+	}
+	for i, sb := range cf.Blocks {
+		if i > 0 || len(cf.Header) > 0 {
+			b.WriteString("\n")
+		}
+		for _, c := range sb.Comments {
+			b.WriteString(c + "\n")
+		}
+		b.WriteString(strings.Join(sb.Keys, " ") + " {")
+		if sb.LineComment != "" {
+			b.WriteString(" " + sb.LineComment)
+		}
+		b.WriteString("\n")
+		writeDirectives(&b, sb.Directives, 1)
+		for _, c := range sb.Trailing {
+			b.WriteString(corefileIndent + c + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	if len(cf.Trailer) > 0 {
+		b.WriteString("\n")
+		for _, c := range cf.Trailer {
+			b.WriteString(c + "\n")
+		}
+	}
+	return b.String()
+}
+
+func writeDirectives(b *strings.Builder, ds []*Directive, depth int) {
+	pad := strings.Repeat(corefileIndent, depth)
+	for i, d := range ds {
+		if d.BlankBefore && i > 0 {
+			b.WriteString("\n")
+		}
+		for _, c := range d.Comments {
+			b.WriteString(pad + c + "\n")
+		}
+		b.WriteString(pad + d.Name)
+		for _, a := range d.Args {
+			b.WriteString(" " + a)
+		}
+		if d.HasBlock {
+			b.WriteString(" {")
+		}
+		if d.LineComment != "" {
+			b.WriteString(" " + d.LineComment)
+		}
+		b.WriteString("\n")
+		if d.HasBlock {
+			writeDirectives(b, d.Block, depth+1)
+			for _, c := range d.Trailing {
+				b.WriteString(pad + corefileIndent + c + "\n")
+			}
+			b.WriteString(pad + "}\n")
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Consultas y edición
+// -----------------------------------------------------------------------------
+
+// normalizeZone quita esquema, puerto y punto final: "dns://Maza-Server.:53" → "maza-server".
+func normalizeZone(key string) string {
+	k := strings.ToLower(strings.TrimSpace(key))
+	if i := strings.Index(k, "://"); i >= 0 {
+		k = k[i+3:]
+	}
+	if i := strings.LastIndexByte(k, ':'); i >= 0 {
+		k = k[:i]
+	}
+	if k != "." {
+		k = strings.TrimSuffix(k, ".")
+	}
+	return k
+}
+
+// Zones devuelve las zonas servidas (claves normalizadas, sin repetir).
+func (cf *Corefile) Zones() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, sb := range cf.Blocks {
+		for _, k := range sb.Keys {
+			z := normalizeZone(k)
+			if !seen[z] {
+				seen[z] = true
+				out = append(out, z)
+			}
+		}
+	}
+	return out
+}
+
+// FindZone devuelve el bloque cuya clave es exactamente la zona (no sufijos).
+func (cf *Corefile) FindZone(zone string) *ServerBlock {
+	z := normalizeZone(zone)
+	for _, sb := range cf.Blocks {
+		for _, k := range sb.Keys {
+			if normalizeZone(k) == z {
+				return sb
+			}
+		}
+	}
+	return nil
+}
+
+// InsertBlockBefore inserta nb antes del bloque de la zona `before`
+// (al final si no existe).
+func (cf *Corefile) InsertBlockBefore(nb *ServerBlock, before string) {
+	if ref := cf.FindZone(before); ref != nil {
+		for i, sb := range cf.Blocks {
+			if sb == ref {
+				cf.Blocks = append(cf.Blocks[:i], append([]*ServerBlock{nb}, cf.Blocks[i:]...)...)
+				return
+			}
+		}
+	}
+	cf.Blocks = append(cf.Blocks, nb)
+}
+
+// Directive devuelve la primera directiva del bloque con ese nombre.
+func (sb *ServerBlock) Directive(name string) *Directive {
+	return findDirective(sb.Directives, name)
+}
+
+// Sub devuelve la primera subdirectiva con ese nombre.
+func (d *Directive) Sub(name string) *Directive {
+	return findDirective(d.Block, name)
+}
+
+func findDirective(ds []*Directive, name string) *Directive {
+	for _, d := range ds {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// indexOf devuelve la posición de la primera directiva cuyo nombre esté en names (-1 si ninguna).
+func indexOf(ds []*Directive, names ...string) int {
+	for i, d := range ds {
+		for _, n := range names {
+			if d.Name == n {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// insertAt inserta d en la posición i (al final si i < 0).
+func insertAt(ds []*Directive, i int, d *Directive) []*Directive {
+	if i < 0 || i >= len(ds) {
+		return append(ds, d)
+	}
+	return append(ds[:i], append([]*Directive{d}, ds[i:]...)...)
+}
+
+func removeDirective(ds []*Directive, d *Directive) []*Directive {
+	for i, x := range ds {
+		if x == d {
+			return append(ds[:i], ds[i+1:]...)
+		}
+	}
+	return ds
+}
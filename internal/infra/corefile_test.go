@@ -0,0 +1,338 @@
+package infra
+
+import (
+	"strings"
+	"testing"
+)
+
+// Entradas que ya están en formato canónico: Format debe devolverlas intactas.
+func TestCorefileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "comentarios con llaves",
+			src: `# CoreDNS { de prueba }
+# } suelta
+
+maza.lan:53 { # bloque {
+    # { no abre nada
+    log
+    errors # } tampoco cierra
+    # }
+}
+`,
+		},
+		{
+			name: "varias claves",
+			src: `maza.lan:53 otra.lan:53 {
+    errors
+}
+
+. {
+    forward . /etc/resolv.conf
+}
+`,
+		},
+		{
+			name: "claves dns://",
+			src: `dns://maza.lan:53 {
+    bind 100.64.0.1
+}
+
+dns://.:53 {
+    log
+}
+`,
+		},
+		{
+			name: "argumentos entre comillas",
+			src: `maza.lan {
+    rewrite name regex "(.*)\.viejo\.lan" "{1}.maza.lan"
+    template IN TXT maza.lan {
+        answer "{{ .Name }} 60 IN TXT \"a # b { c }\""
+    }
+    hosts {
+        100.64.0.2 "app.maza.lan"
+    }
+}
+`,
+		},
+		{
+			name: "bloques anidados y líneas en blanco",
+			src: `. {
+    forward . 1.1.1.1 9.9.9.9 {
+        policy sequential
+        health_check 5s {
+            domain example.org
+        }
+        # fin de forward
+    }
+
+    cache 30
+    # fin del bloque
+}
+
+# trailer
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf, err := ParseCorefile(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cf.Format(); got != tt.src {
+				t.Fatalf("Format() =\n%s\nquiero\n%s", got, tt.src)
+			}
+		})
+	}
+}
+
+// Entradas con sangría y separadores irregulares: se normalizan y el
+// resultado es estable.
+func TestCorefileFormatNormalizes(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "sangría y comas en las claves",
+			src:  "maza.lan,  otra.lan {\n\t\tlog\n  errors\n}\n. {\n}\n",
+			want: "maza.lan otra.lan {\n    log\n    errors\n}\n\n. {\n}\n",
+		},
+		{
+			name: "bloques pegados",
+			src:  "a.lan {\nlog\n}\nb.lan {\n  forward . 1.1.1.1 {\n max_fails 2\n   }\n}",
+			want: "a.lan {\n    log\n}\n\nb.lan {\n    forward . 1.1.1.1 {\n        max_fails 2\n    }\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf, err := ParseCorefile(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := cf.Format()
+			if got != tt.want {
+				t.Fatalf("Format() =\n%q\nquiero\n%q", got, tt.want)
+			}
+			again, err := ParseCorefile(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Format() != got {
+				t.Fatalf("Format no es idempotente:\n%s", again.Format())
+			}
+		})
+	}
+}
+
+func TestCorefileParseErrors(t *testing.T) {
+	tests := []struct {
+		name, src string
+		line      int
+	}{
+		{"comillas sin cerrar", "maza.lan {\n    hosts \"abc\n}\n", 2},
+		{"falta cierre", "maza.lan {\n    log\n", 1},
+		{"llave sin directiva", "maza.lan {\n    {\n    }\n}\n", 2},
+		{"claves sin bloque", "# x\nmaza.lan otra.lan\n", 2},
+		{"llave de cierre suelta", "}\n", 1},
+		// Como en Caddy, una llave solo cuenta si es un token aislado.
+		{"llave pegada a la clave", "maza.lan {\n}\n.{\n}\n", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCorefile(tt.src)
+			cfErr, ok := err.(*CorefileError)
+			if !ok {
+				t.Fatalf("err = %v, quiero *CorefileError", err)
+			}
+			if cfErr.Line != tt.line {
+				t.Fatalf("línea = %d, quiero %d (%v)", cfErr.Line, tt.line, err)
+			}
+		})
+	}
+}
+
+func TestCorefileZones(t *testing.T) {
+	cf, err := ParseCorefile(`dns://Maza.Lan.:53 otra.lan {
+}
+
+amaza.lan:53 {
+}
+
+lan {
+}
+
+. {
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cf.Zones(), ","); got != "maza.lan,otra.lan,amaza.lan,lan,." {
+		t.Fatalf("Zones() = %s", got)
+	}
+	for _, tt := range []struct{ zone, key string }{
+		{"maza.lan", "dns://Maza.Lan.:53"},
+		{"MAZA.LAN.", "dns://Maza.Lan.:53"},
+		{"otra.lan:53", "dns://Maza.Lan.:53"},
+		{"amaza.lan", "amaza.lan:53"},
+		{"lan", "lan"},
+		{"aza.lan", ""},
+	} {
+		sb := cf.FindZone(tt.zone)
+		got := ""
+		if sb != nil {
+			got = sb.Keys[0]
+		}
+		if got != tt.key {
+			t.Errorf("FindZone(%q) = %q, quiero %q", tt.zone, got, tt.key)
+		}
+	}
+	for _, tt := range []struct{ fqdn, zone string }{
+		{"app.maza.lan", "maza.lan"},
+		{"maza.lan", "maza.lan"},
+		{"app.amaza.lan", "amaza.lan"},
+		{"x.lan", "lan"},
+		{"app.example.com", ""},
+	} {
+		if got := zoneFor(cf, tt.fqdn); got != tt.zone {
+			t.Errorf("zoneFor(%q) = %q, quiero %q", tt.fqdn, got, tt.zone)
+		}
+	}
+}
+
+// ensureZone sobre Corefiles editados a mano: no debe tocar más que el bloque
+// de la zona ni confundirla con otra que sea sufijo o prefijo.
+func TestEnsureZoneEdits(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "Corefile vacío",
+			src:  "# CoreDNS (Docker) para AutoHost\n",
+			want: `# CoreDNS (Docker) para AutoHost
+
+maza.lan:53 {
+    # Limita el binding a la IP de Tailscale para evitar conflictos en :53
+    bind 100.64.0.1
+
+    log
+    errors
+    reload
+    file /etc/coredns/zones/db.maza.lan {
+        reload 5s
+    }
+}
+
+. {
+    log
+    errors
+    forward . /etc/resolv.conf
+}
+`,
+		},
+		{
+			name: "zona sufijo y prefijo ya presentes",
+			src: `# { comentario con llaves }
+lan:53 {
+    log
+}
+
+amaza.lan:53 {
+    errors
+}
+
+. {
+    forward . 1.1.1.1 {
+        max_fails 2
+    }
+}
+`,
+			want: `# { comentario con llaves }
+lan:53 {
+    log
+}
+
+amaza.lan:53 {
+    errors
+}
+
+maza.lan:53 {
+    # Limita el binding a la IP de Tailscale para evitar conflictos en :53
+    bind 100.64.0.1
+
+    log
+    errors
+    reload
+    file /etc/coredns/zones/db.maza.lan {
+        reload 5s
+    }
+}
+
+. {
+    forward . 1.1.1.1 {
+        max_fails 2
+    }
+}
+`,
+		},
+		{
+			name: "bloque dns:// con varias claves y hosts",
+			src: `dns://maza.lan:53 otra.lan {
+    bind 100.64.0.9 # IP vieja
+    # Entradas gestionadas { a mano }
+    hosts {
+        100.64.0.2 app.maza.lan
+        fallthrough
+    }
+    cache 30
+}
+`,
+			want: `dns://maza.lan:53 otra.lan {
+    bind 100.64.0.1 # IP vieja
+    reload
+    # Entradas gestionadas { a mano }
+    file /etc/coredns/zones/db.maza.lan {
+        reload 5s
+    }
+    cache 30
+}
+`,
+		},
+		{
+			name: "idempotente",
+			src: `maza.lan:53 {
+    bind 100.64.0.1
+    reload
+    file /etc/coredns/zones/db.maza.lan {
+        reload 5s
+    }
+}
+`,
+			want: `maza.lan:53 {
+    bind 100.64.0.1
+    reload
+    file /etc/coredns/zones/db.maza.lan {
+        reload 5s
+    }
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf, err := ParseCorefile(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureZone(cf, "maza.lan", "100.64.0.1")
+			if got := cf.Format(); got != tt.want {
+				t.Fatalf("ensureZone =\n%s\nquiero\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,623 @@
+// internal/infra/docker_client.go
+package infra
+
+import (
+	"autohost-cli/utils"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// AutohostNetwork es la red Docker compartida por los contenedores de autohost
+// y las apps del catálogo, que la declaran como externa en su compose.
+const AutohostNetwork = "autohost_net"
+
+// Errores tipados del cliente de Docker. Se comparan con errors.Is.
+var (
+	ErrDockerNotFound    = errors.New("recurso de Docker no encontrado")
+	ErrDockerConflict    = errors.New("conflicto en Docker (el recurso ya existe o está en uso)")
+	ErrDockerUnavailable = errors.New("el daemon de Docker no responde")
+	ErrDockerPermission  = errors.New("sin permisos para usar Docker (¿tu usuario está en el grupo docker?)")
+)
+
+// DockerAPIError es una respuesta de error de la Engine API.
+type DockerAPIError struct {
+	Op         string
+	StatusCode int
+	Message    string
+}
+
+func (e *DockerAPIError) Error() string {
+	return fmt.Sprintf("docker %s: %s (HTTP %d)", e.Op, e.Message, e.StatusCode)
+}
+
+func (e *DockerAPIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrDockerNotFound
+	case http.StatusConflict:
+		return ErrDockerConflict
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrDockerPermission
+	}
+	return nil
+}
+
+// DockerClient habla con la Engine API por el socket unix (o TCP) sin usar el CLI.
+type DockerClient struct {
+	http    *http.Client
+	baseURL string
+	Host    string
+
+	// DryRun imprime las operaciones que modifican el daemon en vez de
+	// ejecutarlas; las consultas (GET) se hacen igual.
+	DryRun bool
+}
+
+// NewDockerClient crea un cliente usando DOCKER_HOST o /var/run/docker.sock.
+func NewDockerClient() (*DockerClient, error) {
+	return NewDockerClientForHost(os.Getenv("DOCKER_HOST"))
+}
+
+// NewDockerClientForHost acepta unix://ruta, tcp://host:puerto o http(s)://host:puerto.
+// Como el CLI de Docker, DOCKER_TLS_VERIFY activa TLS mutuo con ca.pem,
+// cert.pem y key.pem de DOCKER_CERT_PATH (por defecto ~/.docker).
+func NewDockerClientForHost(host string) (*DockerClient, error) {
+	if strings.TrimSpace(host) == "" {
+		host = defaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("DOCKER_HOST inválido %q: %w", host, err)
+	}
+
+	c := &DockerClient{Host: host, DryRun: utils.DefaultRunner().DryRun()}
+	switch u.Scheme {
+	case "unix":
+		sock := u.Path
+		tr := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sock)
+			},
+		}
+		c.http = &http.Client{Transport: tr}
+		c.baseURL = "http://docker"
+	case "tcp", "http", "https":
+		verify := os.Getenv("DOCKER_TLS_VERIFY") != ""
+		if u.Scheme == "http" && verify {
+			return nil, errors.New("DOCKER_TLS_VERIFY está activo pero DOCKER_HOST usa http://; usa tcp:// o https://")
+		}
+		if u.Scheme == "http" || (u.Scheme == "tcp" && !verify) {
+			c.http = &http.Client{}
+			c.baseURL = "http://" + u.Host
+			break
+		}
+		tlsConf, err := dockerTLSConfig(verify, os.Getenv("DOCKER_CERT_PATH"))
+		if err != nil {
+			return nil, err
+		}
+		c.http = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConf}}
+		c.baseURL = "https://" + u.Host
+	default:
+		return nil, fmt.Errorf("esquema de DOCKER_HOST no soportado: %s", u.Scheme)
+	}
+	return c, nil
+}
+
+// dockerTLSConfig carga los certificados de certPath. Con verify los tres
+// archivos son obligatorios; sin él (https:// a secas) se usan si existen.
+func dockerTLSConfig(verify bool, certPath string) (*tls.Config, error) {
+	if certPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("DOCKER_CERT_PATH no definido y sin HOME: %w", err)
+		}
+		certPath = filepath.Join(home, ".docker")
+	}
+	ca, cert, key := filepath.Join(certPath, "ca.pem"), filepath.Join(certPath, "cert.pem"), filepath.Join(certPath, "key.pem")
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if b, err := os.ReadFile(ca); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s no contiene certificados PEM válidos", ca)
+		}
+		conf.RootCAs = pool
+	} else if verify {
+		return nil, fmt.Errorf("DOCKER_TLS_VERIFY requiere %s: %w", ca, err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	switch {
+	case err == nil:
+		conf.Certificates = []tls.Certificate{pair}
+	case verify:
+		return nil, fmt.Errorf("DOCKER_TLS_VERIFY requiere %s y %s: %w", cert, key, err)
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("certificado de cliente de Docker inválido (%s): %w", certPath, err)
+	}
+	return conf, nil
+}
+
+// -----------------------------------------------------------------------------
+// Tipos
+// -----------------------------------------------------------------------------
+
+// ContainerInfo es un subconjunto de GET /containers/{id}/json.
+type ContainerInfo struct {
+	ID           string `json:"Id"`
+	Name         string `json:"Name"`
+	Image        string `json:"Image"`
+	RestartCount int    `json:"RestartCount"`
+	State        struct {
+		Status     string `json:"Status"`
+		Running    bool   `json:"Running"`
+		Restarting bool   `json:"Restarting"`
+		ExitCode   int    `json:"ExitCode"`
+		StartedAt  string `json:"StartedAt"`
+		Health     *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Env    []string          `json:"Env"`
+		Cmd    []string          `json:"Cmd"`
+		Tty    bool              `json:"Tty"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		Binds       []string `json:"Binds"`
+		NetworkMode string   `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+	Mounts []struct {
+		Type        string `json:"Type"`
+		Name        string `json:"Name"`
+		Source      string `json:"Source"`
+		Destination string `json:"Destination"`
+		RW          bool   `json:"RW"`
+	} `json:"Mounts"`
+}
+
+// HealthStatus devuelve el estado del healthcheck o "" si el contenedor no tiene.
+func (ci *ContainerInfo) HealthStatus() string {
+	if ci.State.Health == nil {
+		return ""
+	}
+	return ci.State.Health.Status
+}
+
+// NetworkIP devuelve la IP del contenedor en la red indicada ("" si no está conectado).
+func (ci *ContainerInfo) NetworkIP(network string) string {
+	return ci.NetworkSettings.Networks[network].IPAddress
+}
+
+// ContainerSummary es un elemento de GET /containers/json.
+type ContainerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// ContainerSpec describe un contenedor a crear.
+type ContainerSpec struct {
+	Image         string
+	Cmd           []string
+	Env           []string
+	User          string // "uid:gid"; vacío usa el de la imagen
+	Labels        map[string]string
+	Binds         []string // "origen:destino[:ro]"
+	NetworkMode   string   // host | bridge | <red>
+	RestartPolicy string   // no | always | unless-stopped | on-failure
+	ExtraHosts    []string // "host.docker.internal:host-gateway"
+}
+
+// NetworkInfo es un subconjunto de GET /networks/{id}.
+type NetworkInfo struct {
+	ID     string            `json:"Id"`
+	Name   string            `json:"Name"`
+	Driver string            `json:"Driver"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// VolumeInfo es un subconjunto de GET /volumes/{name}.
+type VolumeInfo struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	Labels     map[string]string `json:"Labels"`
+}
+
+// -----------------------------------------------------------------------------
+// Sistema
+// -----------------------------------------------------------------------------
+
+// Ping verifica que el daemon responda.
+func (c *DockerClient) Ping() error {
+	return c.do("ping", http.MethodGet, "/_ping", nil, nil)
+}
+
+// -----------------------------------------------------------------------------
+// Contenedores
+// -----------------------------------------------------------------------------
+
+// InspectContainer devuelve ErrDockerNotFound si el contenedor no existe.
+func (c *DockerClient) InspectContainer(name string) (*ContainerInfo, error) {
+	var info ContainerInfo
+	if err := c.do("inspect", http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ContainerState indica si el contenedor existe y si está corriendo.
+// A diferencia de `docker inspect`, distingue "no existe" de "Docker no responde".
+func (c *DockerClient) ContainerState(name string) (exists, running bool, err error) {
+	info, err := c.InspectContainer(name)
+	if errors.Is(err, ErrDockerNotFound) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, info.State.Running, nil
+}
+
+// ListContainers lista contenedores; `labels` filtra por etiquetas "k=v" o "k".
+func (c *DockerClient) ListContainers(all bool, labels ...string) ([]ContainerSummary, error) {
+	q := url.Values{}
+	if all {
+		q.Set("all", "1")
+	}
+	if len(labels) > 0 {
+		f, _ := json.Marshal(map[string][]string{"label": labels})
+		q.Set("filters", string(f))
+	}
+	var out []ContainerSummary
+	err := c.do("ps", http.MethodGet, "/containers/json?"+q.Encode(), nil, &out)
+	return out, err
+}
+
+// CreateContainer crea el contenedor; si la imagen no existe localmente la descarga y reintenta.
+// Otros 404 (por ejemplo una red inexistente) se devuelven sin descargar nada.
+func (c *DockerClient) CreateContainer(name string, spec ContainerSpec) (string, error) {
+	body := map[string]any{
+		"Image":  spec.Image,
+		"Cmd":    spec.Cmd,
+		"Env":    spec.Env,
+		"User":   spec.User,
+		"Labels": spec.Labels,
+		"HostConfig": map[string]any{
+			"Binds":         spec.Binds,
+			"NetworkMode":   spec.NetworkMode,
+			"RestartPolicy": map[string]string{"Name": spec.RestartPolicy},
+			"ExtraHosts":    spec.ExtraHosts,
+		},
+	}
+	path := "/containers/create?name=" + url.QueryEscape(name)
+
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	err := c.do("create", http.MethodPost, path, body, &resp)
+	if isNoSuchImage(err) {
+		if pullErr := c.PullImage(spec.Image); pullErr != nil {
+			return "", pullErr
+		}
+		err = c.do("create", http.MethodPost, path, body, &resp)
+	}
+	return resp.ID, err
+}
+
+// isNoSuchImage indica si el 404 de create se debe a que falta la imagen
+// ("No such image: alpine:3.20") y no a otro recurso.
+func isNoSuchImage(err error) bool {
+	var apiErr *DockerAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound &&
+		strings.Contains(strings.ToLower(apiErr.Message), "no such image")
+}
+
+// StartContainer inicia el contenedor (no falla si ya estaba corriendo).
+func (c *DockerClient) StartContainer(name string) error {
+	return c.do("start", http.MethodPost, "/containers/"+url.PathEscape(name)+"/start", nil, nil)
+}
+
+// StopContainer detiene el contenedor esperando hasta `timeout`.
+func (c *DockerClient) StopContainer(name string, timeout time.Duration) error {
+	q := "?t=" + strconv.Itoa(int(timeout.Seconds()))
+	return c.do("stop", http.MethodPost, "/containers/"+url.PathEscape(name)+"/stop"+q, nil, nil)
+}
+
+// RestartContainer reinicia el contenedor.
+func (c *DockerClient) RestartContainer(name string) error {
+	return c.do("restart", http.MethodPost, "/containers/"+url.PathEscape(name)+"/restart", nil, nil)
+}
+
+// KillContainer envía una señal (p.ej. "SIGUSR1") al proceso principal del contenedor.
+func (c *DockerClient) KillContainer(name, signal string) error {
+	q := "?signal=" + url.QueryEscape(signal)
+	return c.do("kill", http.MethodPost, "/containers/"+url.PathEscape(name)+"/kill"+q, nil, nil)
+}
+
+// RemoveContainer elimina el contenedor (force lo detiene antes).
+func (c *DockerClient) RemoveContainer(name string, force bool) error {
+	q := "?force=" + strconv.FormatBool(force)
+	return c.do("rm", http.MethodDelete, "/containers/"+url.PathEscape(name)+q, nil, nil)
+}
+
+// ContainerLogs copia stdout/stderr del contenedor a w (demultiplexando el stream).
+func (c *DockerClient) ContainerLogs(name string, tail int, follow bool, w io.Writer) error {
+	q := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if tail > 0 {
+		q.Set("tail", strconv.Itoa(tail))
+	}
+	if follow {
+		q.Set("follow", "1")
+	}
+	info, err := c.InspectContainer(name)
+	if err != nil {
+		return err
+	}
+	resp, err := c.request(http.MethodGet, "/containers/"+url.PathEscape(name)+"/logs?"+q.Encode(), nil)
+	if err != nil {
+		return c.wrapTransportErr("logs", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("logs", resp); err != nil {
+		return err
+	}
+	if info.Config.Tty {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	return demuxDockerStream(w, resp.Body)
+}
+
+// -----------------------------------------------------------------------------
+// Imágenes
+// -----------------------------------------------------------------------------
+
+// PullImage descarga una imagen ("repo:tag"); el error del stream se devuelve como error.
+func (c *DockerClient) PullImage(ref string) error {
+	image, tag := ref, "latest"
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		image, tag = ref[:i], ref[i+1:]
+	}
+	if c.skip("pull " + ref) {
+		return nil
+	}
+	q := url.Values{"fromImage": {image}, "tag": {tag}}
+	resp, err := c.request(http.MethodPost, "/images/create?"+q.Encode(), nil)
+	if err != nil {
+		return c.wrapTransportErr("pull", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("pull", resp); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("docker pull %s: %w", ref, err)
+		}
+		if msg.Error != "" {
+			return &DockerAPIError{Op: "pull " + ref, StatusCode: http.StatusOK, Message: msg.Error}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Redes
+// -----------------------------------------------------------------------------
+
+// InspectNetwork devuelve ErrDockerNotFound si la red no existe.
+func (c *DockerClient) InspectNetwork(name string) (*NetworkInfo, error) {
+	var n NetworkInfo
+	if err := c.do("network inspect", http.MethodGet, "/networks/"+url.PathEscape(name), nil, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ListNetworks lista las redes del daemon.
+func (c *DockerClient) ListNetworks() ([]NetworkInfo, error) {
+	var out []NetworkInfo
+	err := c.do("network ls", http.MethodGet, "/networks", nil, &out)
+	return out, err
+}
+
+// CreateNetwork crea una red bridge (o del driver indicado).
+func (c *DockerClient) CreateNetwork(name, driver string, labels map[string]string) error {
+	if driver == "" {
+		driver = "bridge"
+	}
+	body := map[string]any{"Name": name, "Driver": driver, "Labels": labels, "CheckDuplicate": true}
+	return c.do("network create", http.MethodPost, "/networks/create", body, nil)
+}
+
+// EnsureNetwork crea la red si no existe.
+func (c *DockerClient) EnsureNetwork(name string) error {
+	_, err := c.InspectNetwork(name)
+	if errors.Is(err, ErrDockerNotFound) {
+		return c.CreateNetwork(name, "bridge", map[string]string{"dev.autohost.managed": "true"})
+	}
+	return err
+}
+
+// RemoveNetwork elimina una red.
+func (c *DockerClient) RemoveNetwork(name string) error {
+	return c.do("network rm", http.MethodDelete, "/networks/"+url.PathEscape(name), nil, nil)
+}
+
+// -----------------------------------------------------------------------------
+// Volúmenes
+// -----------------------------------------------------------------------------
+
+// InspectVolume devuelve ErrDockerNotFound si el volumen no existe.
+func (c *DockerClient) InspectVolume(name string) (*VolumeInfo, error) {
+	var v VolumeInfo
+	if err := c.do("volume inspect", http.MethodGet, "/volumes/"+url.PathEscape(name), nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListVolumes lista los volúmenes del daemon.
+func (c *DockerClient) ListVolumes() ([]VolumeInfo, error) {
+	var out struct {
+		Volumes []VolumeInfo `json:"Volumes"`
+	}
+	err := c.do("volume ls", http.MethodGet, "/volumes", nil, &out)
+	return out.Volumes, err
+}
+
+// CreateVolume crea un volumen local (idempotente en el daemon).
+func (c *DockerClient) CreateVolume(name string, labels map[string]string) (*VolumeInfo, error) {
+	var v VolumeInfo
+	body := map[string]any{"Name": name, "Driver": "local", "Labels": labels}
+	if err := c.do("volume create", http.MethodPost, "/volumes/create", body, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// RemoveVolume elimina un volumen.
+func (c *DockerClient) RemoveVolume(name string, force bool) error {
+	q := "?force=" + strconv.FormatBool(force)
+	return c.do("volume rm", http.MethodDelete, "/volumes/"+url.PathEscape(name)+q, nil, nil)
+}
+
+// -----------------------------------------------------------------------------
+// Transporte
+// -----------------------------------------------------------------------------
+
+func (c *DockerClient) request(method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.http.Do(req)
+}
+
+// do ejecuta la petición y decodifica la respuesta JSON en out (si no es nil).
+func (c *DockerClient) do(op, method, path string, body, out any) error {
+	if method != http.MethodGet && c.skip(op+" "+path) {
+		return nil
+	}
+	resp, err := c.request(method, path, body)
+	if err != nil {
+		return c.wrapTransportErr(op, err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(op, resp); err != nil {
+		return err
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("docker %s: respuesta inválida: %w", op, err)
+	}
+	return nil
+}
+
+// skip informa la operación y devuelve true si estamos en modo DryRun.
+func (c *DockerClient) skip(desc string) bool {
+	if !c.DryRun {
+		return false
+	}
+	fmt.Printf("%s docker api: %s\n", utils.DryRunPrefix, desc)
+	return true
+}
+
+func checkResponse(op string, resp *http.Response) error {
+	// 304: el contenedor ya estaba en el estado pedido (start/stop)
+	if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(b, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(b))
+	}
+	return &DockerAPIError{Op: op, StatusCode: resp.StatusCode, Message: msg.Message}
+}
+
+// wrapTransportErr traduce errores de conexión a ErrDockerPermission / ErrDockerUnavailable.
+func (c *DockerClient) wrapTransportErr(op string, err error) error {
+	switch {
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return fmt.Errorf("docker %s (%s): %w: %v", op, c.Host, ErrDockerPermission, err)
+	case errors.Is(err, syscall.ENOENT), errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("docker %s (%s): %w: %v", op, c.Host, ErrDockerUnavailable, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("docker %s (%s): %w: %v", op, c.Host, ErrDockerUnavailable, err)
+	}
+	return fmt.Errorf("docker %s (%s): %w", op, c.Host, err)
+}
+
+// demuxDockerStream separa el stream multiplexado de logs/attach (cabecera de 8 bytes por trama).
+func demuxDockerStream(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		n := binary.BigEndian.Uint32(hdr[4:])
+		if _, err := io.CopyN(w, br, int64(n)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package infra
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newUnixDocker levanta h en un socket unix temporal y devuelve un cliente conectado.
+func newUnixDocker(t *testing.T, h http.Handler) *DockerClient {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	c, err := NewDockerClientForHost("unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDockerClientStatusErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrDockerNotFound},
+		{http.StatusUnauthorized, ErrDockerPermission},
+		{http.StatusForbidden, ErrDockerPermission},
+		{http.StatusConflict, ErrDockerConflict},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			c := newUnixDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"message":"boom"}`))
+			}))
+			_, err := c.InspectContainer("web")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, quiero %v", err, tt.want)
+			}
+			var apiErr *DockerAPIError
+			if !errors.As(err, &apiErr) || apiErr.Message != "boom" || apiErr.StatusCode != tt.status {
+				t.Fatalf("DockerAPIError = %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestDockerClientConflictOnCreateNetwork(t *testing.T) {
+	c := newUnixDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/networks/create" {
+			t.Errorf("petición inesperada %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message":"network with name autohost already exists"}`))
+	}))
+	err := c.CreateNetwork("autohost", "", nil)
+	if !errors.Is(err, ErrDockerConflict) || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestDockerClientTransportErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	// ENOENT: el socket no existe.
+	c, err := NewDockerClientForHost("unix://" + filepath.Join(dir, "missing.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(); !errors.Is(err, ErrDockerUnavailable) {
+		t.Fatalf("ENOENT: err = %v", err)
+	}
+
+	// ECONNREFUSED: el archivo del socket existe pero nadie escucha.
+	sock := filepath.Join(dir, "dead.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.SetUnlinkOnClose(false)
+	ln.Close()
+	c, err = NewDockerClientForHost("unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(); !errors.Is(err, ErrDockerUnavailable) {
+		t.Fatalf("ECONNREFUSED: err = %v", err)
+	}
+}
+
+func TestDockerClientCreatePullsMissingImage(t *testing.T) {
+	var calls []string
+	creates := 0
+	c := newUnixDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/containers/create":
+			creates++
+			if creates == 1 {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"No such image: alpine:3.20"}`))
+				return
+			}
+			w.Write([]byte(`{"Id":"abc123"}`))
+		case "/images/create":
+			if got := r.URL.Query(); got.Get("fromImage") != "alpine" || got.Get("tag") != "3.20" {
+				t.Errorf("pull con %v", got)
+			}
+			w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Done"}` + "\n"))
+		default:
+			t.Errorf("petición inesperada %s", r.URL.Path)
+		}
+	}))
+
+	id, err := c.CreateContainer("helper", ContainerSpec{Image: "alpine:3.20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "abc123" {
+		t.Fatalf("id = %q", id)
+	}
+	want := []string{"POST /containers/create", "POST /images/create", "POST /containers/create"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("llamadas = %v, quiero %v", calls, want)
+	}
+}
+
+func TestDockerClientPullStreamError(t *testing.T) {
+	c := newUnixDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/create" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"No such image"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"error":"manifest unknown"}` + "\n"))
+	}))
+	_, err := c.CreateContainer("helper", ContainerSpec{Image: "nope:1"})
+	if err == nil || !strings.Contains(err.Error(), "manifest unknown") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// Un 404 por otra causa (red inexistente) no dispara un pull.
+func TestDockerClientCreateMissingNetworkDoesNotPull(t *testing.T) {
+	var calls []string
+	c := newUnixDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"network autohost_net not found"}`))
+	}))
+	_, err := c.CreateContainer("helper", ContainerSpec{Image: "alpine:3.20", NetworkMode: "autohost_net"})
+	if !errors.Is(err, ErrDockerNotFound) || !strings.Contains(err.Error(), "network autohost_net not found") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("llamadas = %v, quiero solo el create", calls)
+	}
+}
+
+// frame arma una trama del stream multiplexado (1 = stdout, 2 = stderr).
+func frame(stream byte, s string) []byte {
+	hdr := make([]byte, 8)
+	hdr[0] = stream
+	binary.BigEndian.PutUint32(hdr[4:], uint32(len(s)))
+	return append(hdr, s...)
+}
+
+func TestDockerClientLogsDemux(t *testing.T) {
+	for _, tty := range []bool{false, true} {
+		c := newUnixDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/json"):
+				if tty {
+					w.Write([]byte(`{"Id":"x","Config":{"Tty":true}}`))
+				} else {
+					w.Write([]byte(`{"Id":"x","Config":{"Tty":false}}`))
+				}
+			case strings.HasSuffix(r.URL.Path, "/logs"):
+				if r.URL.Query().Get("tail") != "10" {
+					t.Errorf("tail = %q", r.URL.Query().Get("tail"))
+				}
+				if tty {
+					w.Write([]byte("hola\nerror\n"))
+					return
+				}
+				var b bytes.Buffer
+				b.Write(frame(1, "hola\n"))
+				b.Write(frame(2, "error\n"))
+				b.Write(frame(1, ""))
+				w.Write(b.Bytes())
+			}
+		}))
+		var out bytes.Buffer
+		if err := c.ContainerLogs("web", 10, false, &out); err != nil {
+			t.Fatalf("tty=%v: %v", tty, err)
+		}
+		if out.String() != "hola\nerror\n" {
+			t.Fatalf("tty=%v: logs = %q", tty, out.String())
+		}
+	}
+}
+
+func TestDemuxDockerStreamTruncated(t *testing.T) {
+	// Una trama que anuncia más bytes de los que llegan es un error.
+	data := frame(1, "hola")
+	data = append(frame(1, "ok\n"), data[:len(data)-2]...)
+	var out bytes.Buffer
+	if err := demuxDockerStream(&out, bytes.NewReader(data)); err == nil {
+		t.Fatal("se esperaba error por trama incompleta")
+	}
+	if !strings.HasPrefix(out.String(), "ok\n") {
+		t.Fatalf("out = %q", out.String())
+	}
+}
+
+// -----------------------------------------------------------------------------
+// TLS mutuo (DOCKER_TLS_VERIFY / DOCKER_CERT_PATH)
+// -----------------------------------------------------------------------------
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	signer, signerCert := key, tmpl
+	if parent != nil {
+		signer, signerCert = parent.key, parent.cert
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDockerClientMutualTLS(t *testing.T) {
+	ca := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "ca"},
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil)
+	server := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "docker"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	srv.TLS = &tls.Config{
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.der}, PrivateKey: server.key}},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	host := "tcp://" + srv.Listener.Addr().String()
+
+	certDir := t.TempDir()
+	writePEM(t, filepath.Join(certDir, "ca.pem"), "CERTIFICATE", ca.der)
+	t.Setenv("DOCKER_CERT_PATH", certDir)
+
+	// Sin DOCKER_TLS_VERIFY, tcp:// es HTTP plano y el daemon lo rechaza.
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	c, err := NewDockerClientForHost(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(); err == nil {
+		t.Fatal("HTTP plano contra un daemon TLS no debería funcionar")
+	}
+
+	// Con DOCKER_TLS_VERIFY pero sin certificado de cliente: error claro al crear el cliente.
+	t.Setenv("DOCKER_TLS_VERIFY", "1")
+	if _, err := NewDockerClientForHost(host); err == nil || !strings.Contains(err.Error(), "cert.pem") {
+		t.Fatalf("err = %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(client.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(certDir, "cert.pem"), "CERTIFICATE", client.der)
+	writePEM(t, filepath.Join(certDir, "key.pem"), "EC PRIVATE KEY", keyDER)
+	c, err = NewDockerClientForHost(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Ping con TLS mutuo: %v", err)
+	}
+
+	if _, err := NewDockerClientForHost("http://" + srv.Listener.Addr().String()); err == nil {
+		t.Fatal("http:// con DOCKER_TLS_VERIFY debería rechazarse")
+	}
+}
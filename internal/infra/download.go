@@ -0,0 +1,312 @@
+// internal/infra/download.go
+package infra
+
+import (
+	"archive/zip"
+	"autohost-cli/assets"
+	"autohost-cli/utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Descargas verificadas de binarios (terraform, cloudflared, ...).
+//
+//   - AUTOHOST_DOWNLOAD_MIRROR: URL base o directorio local con la misma
+//     estructura que el origen (para hosts sin salida a internet).
+//   - AUTOHOST_DOWNLOAD_CACHE: caché de descargas (por defecto ~/.autohost/cache/downloads).
+//   - AUTOHOST_INSECURE_SKIP_SIGNATURE=1: omite la firma GPG (el SHA256 se verifica igual).
+
+// PGPSigner es la clave que firma los archivos de sumas de un proveedor.
+type PGPSigner struct {
+	Name        string // ej: "HashiCorp"
+	Fingerprint string // huella de la clave primaria (fijada en el código)
+	Key         []byte // clave pública armada, embebida en el binario
+	KeyFileEnv  string // variable con la ruta a otra clave pública (rotación)
+}
+
+// HashiCorpSigner es la clave de firma de releases.hashicorp.com
+// (https://www.hashicorp.com/security).
+var HashiCorpSigner = PGPSigner{
+	Name:        "HashiCorp",
+	Fingerprint: "C874011F0AB405110D02105534365D9472D7468F",
+	Key:         assets.HashiCorpKey,
+	KeyFileEnv:  "AUTOHOST_HASHICORP_KEY",
+}
+
+// Download describe un artefacto y cómo verificarlo: con un archivo de sumas
+// SHA256 (opcionalmente firmado) o con un SHA256 conocido.
+type Download struct {
+	Name    string // para mensajes, ej: "terraform"
+	BaseURL string // origen, ej: https://releases.hashicorp.com
+	Path    string // ruta relativa del artefacto bajo BaseURL
+
+	SumsPath string     // ruta relativa del archivo SHA256SUMS
+	SigPath  string     // ruta relativa de la firma (detached) de SumsPath
+	Signer   *PGPSigner // clave que debe haber firmado SumsPath
+	SHA256   string     // alternativa a SumsPath
+}
+
+// Fetch devuelve la ruta (en la caché) del artefacto verificado. Si ya está
+// en caché y su hash coincide, no descarga nada.
+func (d Download) Fetch() (string, error) {
+	want, err := d.expectedSHA256()
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(downloadCacheDir(), filepath.FromSlash(d.Path))
+	if b, err := os.ReadFile(dst); err == nil && sha256Hex(b) == want {
+		return dst, nil
+	}
+
+	fmt.Printf("⬇️  Descargando %s: %s\n", d.Name, d.source(d.Path))
+	body, err := d.get(d.Path)
+	if err != nil {
+		return "", err
+	}
+	if got := sha256Hex(body); got != want {
+		return "", fmt.Errorf("%s: SHA256 no coincide (esperado %s, obtenido %s)", d.Path, want, got)
+	}
+	if err := writeFileAtomic(dst, body, 0o644); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// expectedSHA256 obtiene el hash esperado, verificando la firma de las sumas.
+func (d Download) expectedSHA256() (string, error) {
+	if d.SumsPath == "" {
+		if d.SHA256 == "" {
+			return "", fmt.Errorf("%s: no hay SHA256 ni archivo de sumas para verificar la descarga", d.Name)
+		}
+		return strings.ToLower(d.SHA256), nil
+	}
+	sums, err := d.cached(d.SumsPath)
+	if err != nil {
+		return "", err
+	}
+	if d.SigPath != "" && d.Signer != nil {
+		sig, err := d.cached(d.SigPath)
+		if err != nil {
+			return "", err
+		}
+		if err := d.Signer.Verify(sums, sig); err != nil {
+			return "", fmt.Errorf("%s: %w", d.SumsPath, err)
+		}
+	}
+	name := pathBase(d.Path)
+	for _, line := range strings.Split(string(sums), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 && strings.TrimPrefix(f[1], "*") == name {
+			return strings.ToLower(f[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s no aparece en %s", name, d.SumsPath)
+}
+
+// cached lee rel de la caché o lo descarga y lo guarda (sumas y firmas: se
+// verifican cada vez, así que cachearlas antes no es un riesgo).
+func (d Download) cached(rel string) ([]byte, error) {
+	p := filepath.Join(downloadCacheDir(), filepath.FromSlash(rel))
+	if b, err := os.ReadFile(p); err == nil {
+		return b, nil
+	}
+	b, err := d.get(rel)
+	if err != nil {
+		return nil, err
+	}
+	return b, writeFileAtomic(p, b, 0o644)
+}
+
+// get lee rel del mirror (directorio o URL) o del origen.
+func (d Download) get(rel string) ([]byte, error) {
+	src := d.source(rel)
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("mirror local: %w", err)
+		}
+		return b, nil
+	}
+	return httpGet(src, 5*time.Minute)
+}
+
+func (d Download) source(rel string) string {
+	base := d.BaseURL
+	if m := strings.TrimSpace(os.Getenv("AUTOHOST_DOWNLOAD_MIRROR")); m != "" {
+		base = strings.TrimPrefix(m, "file://")
+	}
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		return filepath.Join(base, filepath.FromSlash(rel))
+	}
+	return strings.TrimRight(base, "/") + "/" + rel
+}
+
+// -----------------------------------------------------------------------------
+// Firma GPG
+// -----------------------------------------------------------------------------
+
+// Verify comprueba con gpg que sig es una firma válida de data hecha por la
+// clave primaria fijada (o una de sus subclaves). Usa un keyring temporal, así
+// que no depende ni modifica el del usuario.
+func (s *PGPSigner) Verify(data, sig []byte) error {
+	if os.Getenv("AUTOHOST_INSECURE_SKIP_SIGNATURE") == "1" {
+		fmt.Printf("⚠️  Omitiendo la verificación de firma de %s (AUTOHOST_INSECURE_SKIP_SIGNATURE=1); solo se comprueba el SHA256.\n", s.Name)
+		return nil
+	}
+	gpg, err := exec.LookPath("gpg")
+	if err != nil {
+		return errors.New("gpg no está instalado y hace falta para verificar la firma (instala gnupg o define AUTOHOST_INSECURE_SKIP_SIGNATURE=1)")
+	}
+	key, err := s.publicKey()
+	if err != nil {
+		return err
+	}
+
+	home, err := os.MkdirTemp("", "autohost-gpg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+	files := map[string][]byte{"key.asc": key, "data": data, "data.sig": sig}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(home, name), b, 0o600); err != nil {
+			return err
+		}
+	}
+
+	imp := utils.Command(gpg, "--homedir", home, "--batch", "--quiet", "--import", filepath.Join(home, "key.asc"))
+	imp.ReadOnly = true
+	if _, err := utils.DefaultRunner().Output(imp); err != nil {
+		return fmt.Errorf("no pude importar la clave de %s: %w", s.Name, err)
+	}
+	ver := utils.Command(gpg, "--homedir", home, "--batch", "--status-fd", "1",
+		"--verify", filepath.Join(home, "data.sig"), filepath.Join(home, "data"))
+	ver.ReadOnly = true
+	out, err := utils.DefaultRunner().Output(ver)
+	if err != nil {
+		return fmt.Errorf("firma inválida de %s: gpg --verify falló: %w", s.Name, err)
+	}
+
+	// Además del código de salida, la firma tiene que ser de la clave fijada.
+	want := normalizeFingerprint(s.Fingerprint)
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		// [GNUPG:] VALIDSIG <huella-subclave> ... <huella-primaria>
+		if len(f) >= 3 && f[0] == "[GNUPG:]" && f[1] == "VALIDSIG" {
+			if normalizeFingerprint(f[len(f)-1]) == want || normalizeFingerprint(f[2]) == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("firma inválida o no hecha por la clave de %s (%s)", s.Name, s.Fingerprint)
+}
+
+// publicKey devuelve la clave de KeyFileEnv o la embebida. La huella se
+// comprueba al verificar, no al obtener la clave.
+func (s *PGPSigner) publicKey() ([]byte, error) {
+	if p := os.Getenv(s.KeyFileEnv); s.KeyFileEnv != "" && p != "" {
+		return os.ReadFile(p)
+	}
+	if !bytes.Contains(s.Key, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		return nil, fmt.Errorf("este binario no incluye la clave pública de %s: define %s con su ruta", s.Name, s.KeyFileEnv)
+	}
+	return s.Key, nil
+}
+
+func normalizeFingerprint(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+func downloadCacheDir() string {
+	if d := os.Getenv("AUTOHOST_DOWNLOAD_CACHE"); d != "" {
+		return d
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".autohost", "cache", "downloads")
+}
+
+// extractZipMember extrae el archivo member del zip a dest (tmp + rename).
+// Rechaza el zip entero si alguna entrada intenta salir del directorio.
+func extractZipMember(zipPath, member, dest string, perm os.FileMode) error {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var found *zip.File
+	for _, f := range r.File {
+		if !filepath.IsLocal(f.Name) || strings.Contains(f.Name, `\`) {
+			return fmt.Errorf("%s: entrada sospechosa %q (path traversal)", zipPath, f.Name)
+		}
+		if f.Name == member {
+			found = f
+		}
+	}
+	if found == nil || found.FileInfo().IsDir() {
+		return fmt.Errorf("%s no contiene %s", zipPath, member)
+	}
+	rc, err := found.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, rc); err != nil {
+		return err
+	}
+	return writeFileAtomic(dest, buf.Bytes(), perm)
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func pathBase(p string) string {
+	if i := strings.LastIndexByte(p, '/'); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
+
+func httpGet(url string, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("descarga de %s falló: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,120 @@
+package infra
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSigner genera con gpg una clave de firma desechable y devuelve el
+// PGPSigner que la fija y la firma (detached) de data.
+func testSigner(t *testing.T, data []byte) (PGPSigner, []byte) {
+	t.Helper()
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg no está instalado")
+	}
+	home := t.TempDir()
+	gpg := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command("gpg", append([]string{"--homedir", home, "--batch", "--quiet", "--pinentry-mode", "loopback", "--passphrase", ""}, args...)...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("gpg %s: %v", strings.Join(args, " "), err)
+		}
+		return out
+	}
+	gpg("--quick-gen-key", "Pruebas autohost <pruebas@example.com>", "ed25519", "sign", "never")
+
+	fpr := ""
+	for _, line := range strings.Split(string(gpg("--with-colons", "--list-keys")), "\n") {
+		if f := strings.Split(line, ":"); len(f) > 9 && f[0] == "fpr" && fpr == "" {
+			fpr = f[9]
+		}
+	}
+	dataPath := filepath.Join(home, "data")
+	if err := os.WriteFile(dataPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	gpg("--detach-sign", "-o", dataPath+".sig", dataPath)
+	sig, err := os.ReadFile(dataPath + ".sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return PGPSigner{Name: "Pruebas", Fingerprint: fpr, Key: gpg("--armor", "--export"), KeyFileEnv: "AUTOHOST_TEST_KEY"}, sig
+}
+
+func TestPGPSignerVerify(t *testing.T) {
+	data := []byte("0123abcd  terraform_1.9.0_linux_amd64.zip\n")
+	signer, sig := testSigner(t, data)
+	other, _ := testSigner(t, data)
+
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	if err := os.WriteFile(keyFile, signer.Key, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		signer  PGPSigner
+		data    []byte
+		keyFile string
+		wantErr string
+	}{
+		{name: "firma válida", signer: signer, data: data},
+		{name: "datos alterados", signer: signer, data: []byte("ffff  terraform_1.9.0_linux_amd64.zip\n"), wantErr: "gpg --verify falló"},
+		// Firma correcta pero de otra clave: gpg la da por buena, la huella no.
+		{name: "otra clave", signer: PGPSigner{Name: "Pruebas", Fingerprint: other.Fingerprint, Key: signer.Key}, data: data, wantErr: "no hecha por la clave"},
+		{name: "sin clave embebida", signer: PGPSigner{Name: "Pruebas", Fingerprint: signer.Fingerprint, Key: []byte("PENDIENTE"), KeyFileEnv: "AUTOHOST_TEST_KEY"}, data: data, wantErr: "define AUTOHOST_TEST_KEY"},
+		{name: "clave desde KeyFileEnv", signer: PGPSigner{Name: "Pruebas", Fingerprint: signer.Fingerprint, KeyFileEnv: "AUTOHOST_TEST_KEY"}, data: data, keyFile: keyFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTOHOST_TEST_KEY", tt.keyFile)
+			err := tt.signer.Verify(tt.data, sig)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatal(err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("err = %v, quiero %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Un VALIDSIG en la salida no basta si gpg termina con error.
+func TestPGPSignerVerifyExitStatus(t *testing.T) {
+	const fpr = "C874011F0AB405110D02105534365D9472D7468F"
+	bin := t.TempDir()
+	script := "#!/bin/sh\ncase \"$*\" in *--verify*) echo '[GNUPG:] VALIDSIG 0000 2024-01-01 0 4 0 1 10 00 " + fpr + "'; exit 2;; esac\n"
+	if err := os.WriteFile(filepath.Join(bin, "gpg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	s := PGPSigner{Name: "Pruebas", Fingerprint: fpr, Key: []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----\n")}
+	err := s.Verify([]byte("data"), []byte("sig"))
+	if err == nil || !strings.Contains(err.Error(), "gpg --verify falló") {
+		t.Fatalf("err = %v, quiero el error de gpg --verify", err)
+	}
+}
+
+// La clave embebida tiene que ser la de la huella fijada.
+func TestHashiCorpKeyFingerprint(t *testing.T) {
+	if !strings.Contains(string(HashiCorpSigner.Key), "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+		t.Fatal("assets/keys/hashicorp.asc no contiene la clave armada (go generate ./assets)")
+	}
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg no está instalado")
+	}
+	cmd := exec.Command("gpg", "--batch", "--with-colons", "--show-keys")
+	cmd.Stdin = strings.NewReader(string(HashiCorpSigner.Key))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), ":"+HashiCorpSigner.Fingerprint+":") {
+		t.Fatalf("la clave embebida no tiene la huella %s:\n%s", HashiCorpSigner.Fingerprint, out)
+	}
+}
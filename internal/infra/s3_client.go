@@ -0,0 +1,301 @@
+// internal/infra/s3_client.go
+package infra
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultS3Region = "us-east-1"
+
+// Errores tipados del cliente S3. Se comparan con errors.Is.
+var (
+	ErrS3NotFound = errors.New("objeto S3 no encontrado")
+	ErrS3Auth     = errors.New("credenciales S3 inválidas o sin permisos")
+	ErrS3NoCreds  = errors.New("faltan credenciales S3: define AWS_ACCESS_KEY_ID y AWS_SECRET_ACCESS_KEY")
+)
+
+// S3Error es una respuesta de error de un servicio compatible con S3.
+type S3Error struct {
+	Op         string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *S3Error) Error() string {
+	msg := e.Message
+	if e.Code != "" {
+		msg = e.Code + ": " + msg
+	}
+	return fmt.Sprintf("s3 %s: %s (HTTP %d)", e.Op, msg, e.StatusCode)
+}
+
+func (e *S3Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrS3NotFound
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrS3Auth
+	}
+	return nil
+}
+
+// S3Credentials son las claves de acceso (AWS, MinIO, Garage, R2...).
+type S3Credentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// S3CredentialsFromEnv lee AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY.
+func S3CredentialsFromEnv() S3Credentials {
+	return S3Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+}
+
+// S3RegionFromEnv devuelve AWS_REGION, AWS_DEFAULT_REGION o us-east-1.
+func S3RegionFromEnv() string {
+	for _, k := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+			return v
+		}
+	}
+	return defaultS3Region
+}
+
+// S3Client habla con un bucket S3 con URLs path-style (endpoint/bucket/clave),
+// que aceptan AWS y los servidores compatibles (MinIO, Garage, SeaweedFS...).
+// Firma las peticiones con AWS Signature V4.
+type S3Client struct {
+	http     *http.Client
+	Endpoint string // https://s3.amazonaws.com, http://127.0.0.1:9000...
+	Bucket   string
+	Region   string
+	creds    S3Credentials
+}
+
+// NewS3Client crea un cliente para el bucket. region vacío usa S3RegionFromEnv.
+func NewS3Client(endpoint, bucket, region string, creds S3Credentials) (*S3Client, error) {
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, ErrS3NoCreds
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("endpoint S3 inválido %q", endpoint)
+	}
+	if bucket == "" {
+		return nil, errors.New("falta el bucket S3")
+	}
+	if region == "" {
+		region = S3RegionFromEnv()
+	}
+	return &S3Client{
+		http:     &http.Client{Timeout: 5 * time.Minute},
+		Endpoint: strings.TrimRight(endpoint, "/"),
+		Bucket:   bucket,
+		Region:   region,
+		creds:    creds,
+	}, nil
+}
+
+// PutObject sube data a la clave.
+func (c *S3Client) PutObject(key string, data []byte) error {
+	resp, err := c.do("put "+key, http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject descarga la clave (ErrS3NotFound si no existe).
+func (c *S3Client) GetObject(key string) ([]byte, error) {
+	resp, err := c.do("get "+key, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// DeleteObject borra la clave; S3 no falla si ya no existe.
+func (c *S3Client) DeleteObject(key string) error {
+	resp, err := c.do("delete "+key, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListObjects devuelve las claves que empiezan por prefix (ListObjectsV2,
+// paginando hasta el final).
+func (c *S3Client) ListObjects(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := c.do("list "+prefix, http.MethodGet, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var out struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list %s: respuesta inválida: %w", prefix, err)
+		}
+		for _, o := range out.Contents {
+			keys = append(keys, o.Key)
+		}
+		if !out.IsTruncated || out.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = out.NextContinuationToken
+	}
+}
+
+// do firma y ejecuta la petición; el llamador cierra el cuerpo de la respuesta.
+func (c *S3Client) do(op, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := "/" + c.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	path, rawQuery := s3Escape(path), s3Query(query)
+	u := c.Endpoint + path
+	if rawQuery != "" {
+		u += "?" + rawQuery
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	c.sign(req, path, rawQuery, body, time.Now().UTC())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s: %w", op, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var msg struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(b, &msg) != nil || msg.Message == "" {
+		msg.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, &S3Error{Op: op, StatusCode: resp.StatusCode, Code: msg.Code, Message: msg.Message}
+}
+
+// sign agrega las cabeceras de AWS Signature V4 (host, x-amz-date y
+// x-amz-content-sha256 firmadas).
+func (c *S3Client) sign(req *http.Request, path, rawQuery string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		rawQuery,
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.Region + "/s3/aws4_request"
+	crHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.creds.SecretAccessKey), date)
+	for _, part := range []string{c.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.creds.AccessKeyID, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape codifica una ruta como pide SigV4: todo salvo A-Z a-z 0-9 - . _ ~ y /.
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		ch := path[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '.', ch == '_', ch == '~', ch == '/':
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// s3Query es la query canónica: claves ordenadas y valores codificados con s3Escape.
+func s3Query(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, s3Escape(k)+"="+strings.ReplaceAll(s3Escape(v), "/", "%2F"))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,192 @@
+// internal/infra/sftp_client.go
+package infra
+
+import (
+	"autohost-cli/utils"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrSFTPNotFound indica que el archivo remoto no existe.
+var ErrSFTPNotFound = errors.New("archivo SFTP no encontrado")
+
+// SFTPClient opera sobre un directorio remoto con el cliente `sftp` de
+// OpenSSH en modo batch: usa las claves y ~/.ssh/config del usuario y cada
+// operación es una sola sesión, por grande que sea el lote.
+type SFTPClient struct {
+	Host string // [usuario@]host
+	Port string // vacío = 22 o lo que diga ~/.ssh/config
+	Base string // directorio remoto
+}
+
+// NewSFTPClient crea un cliente para [usuario@]host[:puerto] y el directorio base.
+func NewSFTPClient(host, port, base string) (*SFTPClient, error) {
+	if host == "" || base == "" {
+		return nil, errors.New("destino SFTP incompleto: hace falta host y ruta")
+	}
+	return &SFTPClient{Host: host, Port: port, Base: strings.TrimRight(base, "/")}, nil
+}
+
+// Upload sube los archivos (ruta relativa → contenido) en una sesión. Cada
+// uno se escribe con otro nombre y se renombra al final, así nunca queda un
+// archivo a medias con el nombre definitivo.
+func (c *SFTPClient) Upload(files map[string][]byte) error {
+	if len(files) == 0 {
+		return nil
+	}
+	stage, err := os.MkdirTemp("", "autohost-sftp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stage)
+
+	names := make([]string, 0, len(files))
+	dirs := map[string]bool{}
+	for name := range files {
+		names = append(names, name)
+		for d := path.Dir(name); d != "." && d != "/"; d = path.Dir(d) {
+			dirs[d] = true
+		}
+	}
+	sort.Strings(names)
+	dirList := make([]string, 0, len(dirs)+1)
+	for d := range dirs {
+		dirList = append(dirList, d)
+	}
+	sort.Strings(dirList) // los padres antes que los hijos
+
+	var batch strings.Builder
+	fmt.Fprintf(&batch, "-mkdir %s\n", sftpQuote(c.Base))
+	for _, d := range dirList {
+		fmt.Fprintf(&batch, "-mkdir %s\n", sftpQuote(c.remote(d)))
+	}
+	for i, name := range names {
+		local := filepath.Join(stage, fmt.Sprintf("%06d", i))
+		if err := os.WriteFile(local, files[name], 0o600); err != nil {
+			return err
+		}
+		remote := c.remote(name)
+		fmt.Fprintf(&batch, "put %s %s\n", sftpQuote(local), sftpQuote(remote+".partial"))
+		fmt.Fprintf(&batch, "-rm %s\n", sftpQuote(remote))
+		fmt.Fprintf(&batch, "rename %s %s\n", sftpQuote(remote+".partial"), sftpQuote(remote))
+	}
+	return utils.DefaultRunner().Run(c.command(batch.String()))
+}
+
+// Download descarga un archivo. Solo devuelve ErrSFTPNotFound si sftp dice
+// que el archivo no existe; cualquier otro fallo (conexión, permisos) se
+// devuelve tal cual, para no confundirlo con un archivo ausente.
+func (c *SFTPClient) Download(name string) ([]byte, error) {
+	stage, err := os.MkdirTemp("", "autohost-sftp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stage)
+	local := filepath.Join(stage, "object")
+	// Sin "-": si get falla, sftp aborta el lote y sale con error.
+	batch := fmt.Sprintf("get %s %s\n", sftpQuote(c.remote(name)), sftpQuote(local))
+	var stderr bytes.Buffer
+	cmd := c.command(batch)
+	cmd.ReadOnly = true
+	cmd.Stderr = &stderr
+	if _, err := utils.DefaultRunner().Output(cmd); err != nil {
+		if sftpNotFound(stderr.String()) {
+			return nil, fmt.Errorf("%s: %w", name, ErrSFTPNotFound)
+		}
+		return nil, err
+	}
+	return os.ReadFile(local)
+}
+
+// List devuelve los nombres dentro de uno o varios directorios remotos, en
+// una sola sesión (los que no existen se omiten).
+func (c *SFTPClient) List(dirs ...string) ([]string, error) {
+	var batch strings.Builder
+	for _, dir := range dirs {
+		fmt.Fprintf(&batch, "-ls -1 %s\n", sftpQuote(c.remote(dir)))
+	}
+	cmd := c.command(batch.String())
+	cmd.ReadOnly = true
+	out, err := utils.DefaultRunner().Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "sftp>") {
+			continue
+		}
+		if base := path.Base(line); base != "." && base != ".." && !strings.HasSuffix(base, ".partial") {
+			names = append(names, base)
+		}
+	}
+	sort.Strings(names)
+	return names, sc.Err()
+}
+
+// Remove borra los archivos en una sesión; los que ya no existen se ignoran.
+func (c *SFTPClient) Remove(names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var batch strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&batch, "-rm %s\n", sftpQuote(c.remote(name)))
+	}
+	return utils.DefaultRunner().Run(c.command(batch.String()))
+}
+
+func (c *SFTPClient) remote(name string) string {
+	return c.Base + "/" + strings.TrimLeft(name, "/")
+}
+
+// command arma `sftp -b -` con el lote por stdin. BatchMode evita que ssh
+// se quede esperando una contraseña cuando corre desde cron o systemd.
+func (c *SFTPClient) command(batch string) utils.Cmd {
+	args := []string{"-q", "-b", "-", "-o", "BatchMode=yes"}
+	if c.Port != "" {
+		args = append(args, "-P", c.Port)
+	}
+	args = append(args, c.Host)
+	cmd := utils.Command(sftpBinary(), args...)
+	cmd.Stdin = strings.NewReader(batch)
+	// sftp repite cada comando del lote y avisa de los errores ignorados
+	// (-rm de un archivo que no existe); los fallos reales van en el error.
+	cmd.Stdout, cmd.Stderr = io.Discard, io.Discard
+	return cmd
+}
+
+// sftpBinary permite usar otro cliente (AUTOHOST_SFTP_COMMAND).
+func sftpBinary() string {
+	if b := strings.TrimSpace(os.Getenv("AUTOHOST_SFTP_COMMAND")); b != "" {
+		return b
+	}
+	return "sftp"
+}
+
+// sftpNotFound reconoce los mensajes de OpenSSH para un archivo remoto
+// inexistente: `File "<ruta>" not found.` o "No such file or directory".
+func sftpNotFound(stderr string) bool {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.Contains(line, "No such file") || (strings.HasPrefix(line, `File "`) && strings.HasSuffix(line, `" not found.`)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sftpQuote entrecomilla una ruta para los comandos batch de sftp.
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,83 @@
+package infra
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSFTPGet es un `sftp -b -` que atiende `get "origen" "destino"` desde el
+// disco local. Con SFTP_STDERR definido falla con ese mensaje y SFTP_EXIT.
+const fakeSFTPGet = `#!/bin/sh
+while IFS= read -r line; do
+	case "$line" in
+	"get "*)
+		if [ -n "$SFTP_STDERR" ]; then
+			printf '%s\n' "$SFTP_STDERR" >&2
+			exit "${SFTP_EXIT:-1}"
+		fi
+		eval "set -- $line"
+		if [ ! -f "$2" ]; then
+			echo "File \"$2\" not found." >&2
+			exit 1
+		fi
+		cp "$2" "$3" || exit 1
+		;;
+	esac
+done
+`
+
+func TestSFTPDownload(t *testing.T) {
+	bin, base := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "sftp"), []byte(fakeSFTPGet), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "config"), []byte("repo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AUTOHOST_SFTP_COMMAND", filepath.Join(bin, "sftp"))
+	c, err := NewSFTPClient("nas", "", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		stderr   string
+		exit     string
+		want     string
+		notFound bool
+		wantErr  string
+	}{
+		{name: "existe", file: "config", want: "repo"},
+		{name: "no existe", file: "keys/otra", notFound: true},
+		{name: "el servidor no lo encuentra", file: "config", stderr: `remote open("` + base + `/config"): No such file or directory`, notFound: true},
+		{name: "conexión rechazada", file: "config", stderr: "ssh: connect to host nas port 22: Connection refused\nConnection closed", exit: "255", wantErr: "Connection refused"},
+		{name: "sin permisos", file: "config", stderr: `remote open("` + base + `/config"): Permission denied`, wantErr: "Permission denied"},
+		{name: "clave del host", file: "config", stderr: "Host key verification failed.\nConnection closed", exit: "255", wantErr: "Host key verification failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SFTP_STDERR", tt.stderr)
+			t.Setenv("SFTP_EXIT", tt.exit)
+			data, err := c.Download(tt.file)
+			switch {
+			case tt.notFound:
+				if !errors.Is(err, ErrSFTPNotFound) {
+					t.Fatalf("err = %v, quiero ErrSFTPNotFound", err)
+				}
+			case tt.wantErr != "":
+				if err == nil || errors.Is(err, ErrSFTPNotFound) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, quiero un error con %q que no sea ErrSFTPNotFound", err, tt.wantErr)
+				}
+			default:
+				if err != nil || string(data) != tt.want {
+					t.Fatalf("Download = %q, %v", data, err)
+				}
+			}
+		})
+	}
+}
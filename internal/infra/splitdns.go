@@ -0,0 +1,439 @@
+// internal/infra/splitdns.go
+package infra
+
+import (
+	"autohost-cli/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backends para aplicar split DNS en la tailnet.
+const (
+	SplitDNSBackendAPI       = "api"       // API v2 de Tailscale (por defecto)
+	SplitDNSBackendTerraform = "terraform" // provider tailscale/tailscale
+)
+
+// splitDNSStateFile guarda en cada workspace lo último que se aplicó.
+const splitDNSStateFile = "applied.json"
+
+// errSplitDNSNotApplied indica un workspace sin applied.json ni
+// terraform.tfstate: quedó de un apply fallido y no hay nada aplicado.
+var errSplitDNSNotApplied = errors.New("el workspace nunca se aplicó")
+
+type SplitDNSOpts struct {
+	Tailnet      string   // ej: "tu-org.ts.net"  (si vacío, usa env TAILSCALE_TAILNET o "-")
+	Domain       string   // ej: "maza-server"
+	Nameservers  []string // ej: ["100.112.92.90"]
+	SearchPaths  []string // opcional: ej ["maza-server"]
+	APIKeyEnvVar string   // por defecto "TAILSCALE_API_KEY"
+	Backend      string   // api|terraform (si vacío, usa AUTOHOST_SPLITDNS_BACKEND o "api")
+}
+
+// SplitDNSState es lo último que autohost aplicó para un dominio.
+type SplitDNSState struct {
+	Tailnet     string   `json:"tailnet"`
+	Domain      string   `json:"domain"`
+	Backend     string   `json:"backend"`
+	Nameservers []string `json:"nameservers"`
+	SearchPaths []string `json:"search_paths,omitempty"`
+	// AddedSearchPaths son los search paths que agregó autohost (backend api);
+	// solo esos se quitan al eliminar el dominio.
+	AddedSearchPaths []string   `json:"added_search_paths,omitempty"`
+	AppliedAt        *time.Time `json:"applied_at,omitempty"`
+	Workspace        string     `json:"workspace"`
+}
+
+// ConfigureSplitDNS aplica split DNS con el backend elegido y registra lo
+// aplicado en el workspace del dominio.
+func ConfigureSplitDNS(opts SplitDNSOpts) error {
+	if opts.Domain == "" || len(opts.Nameservers) == 0 {
+		return fmt.Errorf("domain y al menos un nameserver son obligatorios")
+	}
+	tailnet := splitDNSTailnet(opts.Tailnet)
+	prev, _ := loadSplitDNSState(splitDNSWorkspace(tailnet, opts.Domain))
+
+	st := SplitDNSState{
+		Tailnet:     tailnet,
+		Domain:      opts.Domain,
+		Backend:     SplitDNSBackend(opts.Backend),
+		Nameservers: opts.Nameservers,
+		SearchPaths: opts.SearchPaths,
+	}
+	switch st.Backend {
+	case SplitDNSBackendAPI:
+		added, err := ConfigureSplitDNSWithAPI(opts)
+		if err != nil {
+			return err
+		}
+		if prev != nil {
+			added = mergeStrings(prev.AddedSearchPaths, added)
+		}
+		st.AddedSearchPaths = added
+	case SplitDNSBackendTerraform:
+		if err := ConfigureSplitDNSWithTerraform(opts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("backend de split DNS inválido: %s (usa %s|%s)", st.Backend, SplitDNSBackendAPI, SplitDNSBackendTerraform)
+	}
+
+	ws, err := prepareWorkspace(tailnet, opts.Domain)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	st.Workspace, st.AppliedAt = ws, &now
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.DefaultRunner().WriteFile(filepath.Join(ws, splitDNSStateFile), append(data, '\n'), 0o644)
+}
+
+// PlanSplitDNS muestra lo que haría ConfigureSplitDNS sin aplicar nada: el
+// diff contra la API o la salida de `terraform plan`.
+func PlanSplitDNS(opts SplitDNSOpts) (string, error) {
+	if opts.Domain == "" || len(opts.Nameservers) == 0 {
+		return "", fmt.Errorf("domain y al menos un nameserver son obligatorios")
+	}
+	switch backend := SplitDNSBackend(opts.Backend); backend {
+	case SplitDNSBackendAPI:
+		c, err := splitDNSClient(opts.Tailnet, opts.APIKeyEnvVar)
+		if err != nil {
+			return "", err
+		}
+		p, err := planSplitDNSAPI(c, opts)
+		if err != nil {
+			return "", err
+		}
+		return p.String(), nil
+	case SplitDNSBackendTerraform:
+		return planSplitDNSWithTerraform(opts)
+	default:
+		return "", fmt.Errorf("backend de split DNS inválido: %s (usa %s|%s)", backend, SplitDNSBackendAPI, SplitDNSBackendTerraform)
+	}
+}
+
+// RemoveSplitDNS quita el dominio de la tailnet (con el backend con que se
+// aplicó) y elimina su workspace.
+func RemoveSplitDNS(tailnet, domain, apiKeyEnv string) error {
+	tailnet = splitDNSTailnet(tailnet)
+	ws := splitDNSWorkspace(tailnet, domain)
+	if _, err := os.Stat(ws); os.IsNotExist(err) {
+		return fmt.Errorf("autohost no gestiona split DNS para %s en la tailnet %s (no existe %s)", domain, tailnet, ws)
+	}
+	st, err := loadSplitDNSState(ws)
+	if errors.Is(err, errSplitDNSNotApplied) {
+		// No hay nada que destruir en la tailnet: solo se limpian los restos.
+		if err := utils.DefaultRunner().RemoveAll(ws); err != nil {
+			return err
+		}
+		return fmt.Errorf("autohost no gestiona split DNS para %s en la tailnet %s (%s nunca se aplicó; se eliminó)", domain, tailnet, ws)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch st.Backend {
+	case SplitDNSBackendTerraform:
+		if err := destroySplitDNSWithTerraform(ws); err != nil {
+			return err
+		}
+	default:
+		c, err := splitDNSClient(tailnet, apiKeyEnv)
+		if err != nil {
+			return err
+		}
+		current, err := c.SplitDNS()
+		if err != nil {
+			return err
+		}
+		if _, ok := current[domain]; ok {
+			if _, err := c.UpdateSplitDNS(map[string][]string{domain: nil}); err != nil {
+				return err
+			}
+		}
+		if err := removeSearchPaths(c, st); err != nil {
+			return err
+		}
+	}
+	return utils.DefaultRunner().RemoveAll(ws)
+}
+
+// ListSplitDNS enumera los workspaces de split DNS que gestiona autohost.
+func ListSplitDNS() ([]SplitDNSState, error) {
+	home, _ := os.UserHomeDir()
+	dirs, err := filepath.Glob(filepath.Join(home, tfStateDirRel, "*", "split-dns-*"))
+	if err != nil {
+		return nil, err
+	}
+	out := []SplitDNSState{}
+	for _, ws := range dirs {
+		if fi, err := os.Stat(ws); err != nil || !fi.IsDir() {
+			continue
+		}
+		st, err := loadSplitDNSState(ws)
+		if errors.Is(err, errSplitDNSNotApplied) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tailnet != out[j].Tailnet {
+			return out[i].Tailnet < out[j].Tailnet
+		}
+		return out[i].Domain < out[j].Domain
+	})
+	return out, nil
+}
+
+// SplitDNSBackend resuelve el backend: el indicado, AUTOHOST_SPLITDNS_BACKEND o "api".
+func SplitDNSBackend(backend string) string {
+	if backend == "" {
+		backend = os.Getenv("AUTOHOST_SPLITDNS_BACKEND")
+	}
+	if backend == "" {
+		return SplitDNSBackendAPI
+	}
+	return strings.ToLower(backend)
+}
+
+// ConfigureSplitDNSWithAPI fusiona el dominio y los search paths con la
+// configuración actual de la tailnet: no toca otros dominios ni search paths,
+// y no escribe nada si ya estaba aplicado. Devuelve los search paths agregados.
+func ConfigureSplitDNSWithAPI(opts SplitDNSOpts) (addedSearchPaths []string, err error) {
+	c, err := splitDNSClient(opts.Tailnet, opts.APIKeyEnvVar)
+	if err != nil {
+		return nil, err
+	}
+	p, err := planSplitDNSAPI(c, opts)
+	if err != nil {
+		return nil, err
+	}
+	if p.nameserversChanged() {
+		if _, err := c.UpdateSplitDNS(map[string][]string{opts.Domain: opts.Nameservers}); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.AddSearchPaths) > 0 {
+		if err := c.SetSearchPaths(append(p.SearchPaths, p.AddSearchPaths...)); err != nil {
+			return nil, err
+		}
+	}
+	return p.AddSearchPaths, nil
+}
+
+// -----------------------------------------------------------------------------
+// Plan (backend api)
+// -----------------------------------------------------------------------------
+
+type splitDNSAPIPlan struct {
+	Tailnet        string
+	Domain         string
+	Current        []string // nameservers actuales del dominio (nil si no existe)
+	Nameservers    []string // nameservers deseados
+	SearchPaths    []string // search paths actuales
+	AddSearchPaths []string // search paths que faltan
+}
+
+func planSplitDNSAPI(c *TailscaleClient, opts SplitDNSOpts) (splitDNSAPIPlan, error) {
+	p := splitDNSAPIPlan{Tailnet: c.Tailnet, Domain: opts.Domain, Nameservers: opts.Nameservers}
+	current, err := c.SplitDNS()
+	if err != nil {
+		return p, err
+	}
+	p.Current = current[opts.Domain]
+	if len(opts.SearchPaths) > 0 {
+		if p.SearchPaths, err = c.SearchPaths(); err != nil {
+			return p, err
+		}
+		for _, sp := range opts.SearchPaths {
+			if !containsFold(p.SearchPaths, sp) && !containsFold(p.AddSearchPaths, sp) {
+				p.AddSearchPaths = append(p.AddSearchPaths, sp)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p splitDNSAPIPlan) nameserversChanged() bool {
+	return !sameStrings(p.Current, p.Nameservers)
+}
+
+// String describe los cambios al estilo de un plan (+ agregar, ~ modificar).
+func (p splitDNSAPIPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tailnet %s:\n", p.Tailnet)
+	changes := 0
+	switch {
+	case p.Current == nil:
+		fmt.Fprintf(&b, "  + split-dns %s → %s\n", p.Domain, strings.Join(p.Nameservers, ", "))
+		changes++
+	case p.nameserversChanged():
+		fmt.Fprintf(&b, "  ~ split-dns %s: %s → %s\n", p.Domain, strings.Join(p.Current, ", "), strings.Join(p.Nameservers, ", "))
+		changes++
+	}
+	for _, sp := range p.AddSearchPaths {
+		fmt.Fprintf(&b, "  + search path %s\n", sp)
+		changes++
+	}
+	if changes == 0 {
+		b.WriteString("  (sin cambios)\n")
+	} else {
+		fmt.Fprintf(&b, "%d cambio(s) por aplicar.\n", changes)
+	}
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// splitDNSTailnet resuelve la tailnet: la indicada, TAILSCALE_TAILNET o "-".
+func splitDNSTailnet(tailnet string) string {
+	if tailnet == "" {
+		tailnet = os.Getenv("TAILSCALE_TAILNET")
+	}
+	if tailnet == "" {
+		tailnet = "-" // tailnet por defecto de la credencial
+	}
+	return tailnet
+}
+
+func splitDNSClient(tailnet, apiKeyEnv string) (*TailscaleClient, error) {
+	return NewTailscaleClientWithAuth(os.Getenv("TAILSCALE_API_BASE_URL"), splitDNSTailnet(tailnet), TailscaleAuthFromEnv(apiKeyEnv))
+}
+
+var (
+	tfDomainRe      = regexp.MustCompile(`(?m)^\s*domain\s*=\s*"([^"]*)"`)
+	tfNameserversRe = regexp.MustCompile(`(?m)^\s*nameservers\s*=\s*\[([^\]]*)\]`)
+	tfSearchPathsRe = regexp.MustCompile(`(?m)^\s*search_paths\s*=\s*\[([^\]]*)\]`)
+	tfQuotedRe      = regexp.MustCompile(`"([^"]*)"`)
+)
+
+// loadSplitDNSState lee applied.json del workspace. Los workspaces creados
+// antes de existir ese archivo se reconstruyen desde su main.tf, siempre que
+// tengan terraform.tfstate; si no, devuelve errSplitDNSNotApplied.
+func loadSplitDNSState(ws string) (*SplitDNSState, error) {
+	st := &SplitDNSState{Tailnet: filepath.Base(filepath.Dir(ws)), Workspace: ws}
+	b, err := os.ReadFile(filepath.Join(ws, splitDNSStateFile))
+	if err == nil {
+		if err := json.Unmarshal(b, st); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(ws, splitDNSStateFile), err)
+		}
+		st.Workspace = ws
+		return st, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fi, err := os.Stat(filepath.Join(ws, "terraform.tfstate"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", ws, errSplitDNSNotApplied)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tf, err := os.ReadFile(filepath.Join(ws, "main.tf"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s no tiene %s ni main.tf", ws, splitDNSStateFile)
+		}
+		return nil, err
+	}
+	st.Backend = SplitDNSBackendTerraform
+	if m := tfDomainRe.FindSubmatch(tf); m != nil {
+		st.Domain = string(m[1])
+	}
+	if m := tfNameserversRe.FindSubmatch(tf); m != nil {
+		st.Nameservers = quotedStrings(m[1])
+	}
+	if m := tfSearchPathsRe.FindSubmatch(tf); m != nil {
+		st.SearchPaths = quotedStrings(m[1])
+	}
+	t := fi.ModTime().UTC().Truncate(time.Second)
+	st.AppliedAt = &t
+	return st, nil
+}
+
+// removeSearchPaths quita los search paths que agregó autohost para st,
+// salvo los que otro dominio gestionado de la misma tailnet siga usando.
+func removeSearchPaths(c *TailscaleClient, st *SplitDNSState) error {
+	if len(st.AddedSearchPaths) == 0 {
+		return nil
+	}
+	others, err := ListSplitDNS()
+	if err != nil {
+		return err
+	}
+	var drop []string
+	for _, sp := range st.AddedSearchPaths {
+		used := false
+		for _, o := range others {
+			if o.Tailnet == st.Tailnet && o.Domain != st.Domain && containsFold(o.SearchPaths, sp) {
+				used = true
+			}
+		}
+		if !used {
+			drop = append(drop, sp)
+		}
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+	current, err := c.SearchPaths()
+	if err != nil {
+		return err
+	}
+	kept := []string{}
+	for _, sp := range current {
+		if !containsFold(drop, sp) {
+			kept = append(kept, sp)
+		}
+	}
+	if len(kept) == len(current) {
+		return nil
+	}
+	return c.SetSearchPaths(kept)
+}
+
+func quotedStrings(b []byte) []string {
+	var out []string
+	for _, m := range tfQuotedRe.FindAllSubmatch(b, -1) {
+		out = append(out, string(m[1]))
+	}
+	return out
+}
+
+func mergeStrings(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, s := range b {
+		if !containsFold(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
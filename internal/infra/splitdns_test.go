@@ -0,0 +1,146 @@
+package infra
+
+import (
+	"autohost-cli/utils"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTerraform pone en el PATH un `terraform` que anota cada llamada
+// ("<dir> <args>") en el log devuelto; `plan` muestra el state que recibió.
+func fakeTerraform(t *testing.T) (log string) {
+	t.Helper()
+	bin := t.TempDir()
+	log = filepath.Join(t.TempDir(), "terraform.log")
+	script := `#!/bin/sh
+echo "$PWD $*" >> "` + log + `"
+if [ "$1" = "plan" ]; then
+  echo "state: $(cat terraform.tfstate 2>/dev/null || echo ninguno)"
+  echo "Plan: 1 to add, 0 to change, 0 to destroy."
+fi
+`
+	if err := os.WriteFile(filepath.Join(bin, "terraform"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("TAILSCALE_API_KEY", "k")
+	utils.SetDefaultRunner(nil)
+	return log
+}
+
+func terraformCalls(t *testing.T, log string) []string {
+	t.Helper()
+	b, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+// Un --plan con terraform no crea el workspace del dominio: si no, list y
+// remove lo tratarían como aplicado.
+func TestPlanSplitDNSTerraformWithoutWorkspace(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	log := fakeTerraform(t)
+	opts := SplitDNSOpts{Tailnet: "ejemplo.ts.net", Domain: "maza.lan", Nameservers: []string{"100.64.0.1"}, Backend: SplitDNSBackendTerraform}
+
+	out, err := PlanSplitDNS(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "state: ninguno") || !strings.Contains(out, "1 to add") {
+		t.Fatalf("plan = %q", out)
+	}
+	ws := splitDNSWorkspace("ejemplo.ts.net", "maza.lan")
+	if _, err := os.Stat(ws); !os.IsNotExist(err) {
+		t.Fatalf("el plan creó %s", ws)
+	}
+	for _, call := range terraformCalls(t, log) {
+		if strings.HasPrefix(call, ws) {
+			t.Fatalf("terraform corrió en el workspace: %q", call)
+		}
+	}
+	if states, err := ListSplitDNS(); err != nil || len(states) != 0 {
+		t.Fatalf("ListSplitDNS = %+v, %v", states, err)
+	}
+}
+
+// Con un workspace aplicado, el plan parte de su state pero no lo modifica.
+func TestPlanSplitDNSTerraformKeepsWorkspace(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	log := fakeTerraform(t)
+	ws := splitDNSWorkspace("ejemplo.ts.net", "maza.lan")
+	mainTF := "resource \"tailscale_dns_split_nameservers\" \"split\" {\n  domain      = \"maza.lan\"\n  nameservers = [\"100.64.0.1\"]\n}\n"
+	if err := os.MkdirAll(ws, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"main.tf": mainTF, "terraform.tfstate": "ESTADO"} {
+		if err := os.WriteFile(filepath.Join(ws, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := PlanSplitDNS(SplitDNSOpts{Tailnet: "ejemplo.ts.net", Domain: "maza.lan", Nameservers: []string{"100.64.0.2"}, Backend: SplitDNSBackendTerraform})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "state: ESTADO") {
+		t.Fatalf("el plan no partió del state del workspace: %q", out)
+	}
+	if b, _ := os.ReadFile(filepath.Join(ws, "main.tf")); string(b) != mainTF {
+		t.Fatalf("el plan reescribió main.tf:\n%s", b)
+	}
+	if calls := terraformCalls(t, log); len(calls) != 2 || strings.HasPrefix(calls[0], ws) {
+		t.Fatalf("llamadas = %q", calls)
+	}
+}
+
+// Un workspace con main.tf pero sin applied.json ni terraform.tfstate (un
+// apply que falló) no se lista ni se destruye.
+func TestSplitDNSWorkspaceNotApplied(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	log := fakeTerraform(t)
+	applied := splitDNSWorkspace("ejemplo.ts.net", "ok.lan")
+	pending := splitDNSWorkspace("ejemplo.ts.net", "maza.lan")
+	files := map[string]string{
+		filepath.Join(applied, "main.tf"):           "domain = \"ok.lan\"\nnameservers = [\"100.64.0.1\"]\n",
+		filepath.Join(applied, "terraform.tfstate"): "{}",
+		filepath.Join(pending, "main.tf"):           "domain = \"maza.lan\"\nnameservers = [\"100.64.0.1\"]\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := loadSplitDNSState(pending); !errors.Is(err, errSplitDNSNotApplied) {
+		t.Fatalf("loadSplitDNSState = %v, quiero errSplitDNSNotApplied", err)
+	}
+	states, err := ListSplitDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Domain != "ok.lan" || states[0].Backend != SplitDNSBackendTerraform || states[0].AppliedAt == nil {
+		t.Fatalf("ListSplitDNS = %+v", states)
+	}
+
+	err = RemoveSplitDNS("ejemplo.ts.net", "maza.lan", "")
+	if err == nil || !strings.Contains(err.Error(), "nunca se aplicó") {
+		t.Fatalf("RemoveSplitDNS = %v", err)
+	}
+	if calls := terraformCalls(t, log); len(calls) != 0 {
+		t.Fatalf("no debería correr terraform: %q", calls)
+	}
+	if _, err := os.Stat(pending); !os.IsNotExist(err) {
+		t.Fatalf("%s debería eliminarse", pending)
+	}
+}
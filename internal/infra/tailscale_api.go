@@ -0,0 +1,359 @@
+// internal/infra/tailscale_api.go
+package infra
+
+import (
+	"autohost-cli/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTailscaleAPI = "https://api.tailscale.com"
+
+// Errores tipados del cliente de la API de Tailscale. Se comparan con errors.Is.
+var (
+	ErrTailscaleAuth     = errors.New("credenciales de Tailscale inválidas o sin permisos")
+	ErrTailscaleNotFound = errors.New("recurso de Tailscale no encontrado")
+	ErrTailscaleNoCreds  = errors.New("faltan credenciales: define TAILSCALE_API_KEY o TAILSCALE_OAUTH_CLIENT_ID/TAILSCALE_OAUTH_CLIENT_SECRET")
+)
+
+// TailscaleAPIError es una respuesta de error de la API v2.
+type TailscaleAPIError struct {
+	Op         string
+	StatusCode int
+	Message    string
+}
+
+func (e *TailscaleAPIError) Error() string {
+	return fmt.Sprintf("tailscale api %s: %s (HTTP %d)", e.Op, e.Message, e.StatusCode)
+}
+
+func (e *TailscaleAPIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrTailscaleNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrTailscaleAuth
+	}
+	return nil
+}
+
+// TailscaleAuth son las credenciales: una API key o un cliente OAuth
+// (client credentials, con scope dns y devices:core:read).
+type TailscaleAuth struct {
+	APIKey       string
+	ClientID     string
+	ClientSecret string
+}
+
+// TailscaleAuthFromEnv lee TAILSCALE_API_KEY (o la variable indicada) y
+// TAILSCALE_OAUTH_CLIENT_ID / TAILSCALE_OAUTH_CLIENT_SECRET.
+func TailscaleAuthFromEnv(apiKeyEnv string) TailscaleAuth {
+	if apiKeyEnv == "" {
+		apiKeyEnv = "TAILSCALE_API_KEY"
+	}
+	return TailscaleAuth{
+		APIKey:       os.Getenv(apiKeyEnv),
+		ClientID:     os.Getenv("TAILSCALE_OAUTH_CLIENT_ID"),
+		ClientSecret: os.Getenv("TAILSCALE_OAUTH_CLIENT_SECRET"),
+	}
+}
+
+// TailscaleClient habla con la API v2 de Tailscale (https://api.tailscale.com).
+type TailscaleClient struct {
+	http    *http.Client
+	BaseURL string
+	Tailnet string
+	auth    TailscaleAuth
+
+	mu       sync.Mutex
+	token    string
+	tokenExp time.Time
+
+	// DryRun imprime las operaciones que modifican la tailnet en vez de
+	// ejecutarlas; las consultas (GET) se hacen igual.
+	DryRun bool
+}
+
+// NewTailscaleClient crea un cliente con las credenciales del entorno.
+// tailnet vacío usa TAILSCALE_TAILNET o "-" (la tailnet de la credencial) y
+// TAILSCALE_API_BASE_URL permite apuntar a otro servidor.
+func NewTailscaleClient(tailnet string) (*TailscaleClient, error) {
+	return NewTailscaleClientWithAuth(os.Getenv("TAILSCALE_API_BASE_URL"), tailnet, TailscaleAuthFromEnv(""))
+}
+
+// NewTailscaleClientWithAuth crea un cliente con credenciales y URL explícitas.
+func NewTailscaleClientWithAuth(baseURL, tailnet string, auth TailscaleAuth) (*TailscaleClient, error) {
+	if auth.APIKey == "" && (auth.ClientID == "" || auth.ClientSecret == "") {
+		return nil, ErrTailscaleNoCreds
+	}
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = defaultTailscaleAPI
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("URL de la API de Tailscale inválida %q: %w", baseURL, err)
+	}
+	if tailnet == "" {
+		tailnet = os.Getenv("TAILSCALE_TAILNET")
+	}
+	if tailnet == "" {
+		tailnet = "-"
+	}
+	return &TailscaleClient{
+		http:    &http.Client{Timeout: 30 * time.Second},
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Tailnet: tailnet,
+		auth:    auth,
+		DryRun:  utils.DefaultRunner().DryRun(),
+	}, nil
+}
+
+// -----------------------------------------------------------------------------
+// Tipos
+// -----------------------------------------------------------------------------
+
+// DNSPreferences son las preferencias DNS de la tailnet.
+type DNSPreferences struct {
+	MagicDNS bool `json:"magicDNS"`
+}
+
+// TailscaleDevice es un subconjunto de GET /tailnet/{tailnet}/devices.
+type TailscaleDevice struct {
+	ID         string   `json:"id"`
+	NodeID     string   `json:"nodeId"`
+	Name       string   `json:"name"`
+	Hostname   string   `json:"hostname"`
+	Addresses  []string `json:"addresses"`
+	OS         string   `json:"os"`
+	User       string   `json:"user"`
+	Authorized bool     `json:"authorized"`
+	LastSeen   string   `json:"lastSeen"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// DNS
+// -----------------------------------------------------------------------------
+
+// SplitDNS devuelve la configuración de split DNS (dominio → nameservers).
+func (c *TailscaleClient) SplitDNS() (map[string][]string, error) {
+	out := map[string][]string{}
+	err := c.do("split-dns", http.MethodGet, c.tailnetPath("/dns/split-dns"), nil, &out)
+	return out, err
+}
+
+// UpdateSplitDNS fusiona changes con la configuración actual (PATCH): solo se
+// tocan los dominios indicados y un valor nil elimina el dominio. Devuelve la
+// configuración resultante.
+func (c *TailscaleClient) UpdateSplitDNS(changes map[string][]string) (map[string][]string, error) {
+	out := map[string][]string{}
+	err := c.do("split-dns", http.MethodPatch, c.tailnetPath("/dns/split-dns"), changes, &out)
+	return out, err
+}
+
+// SearchPaths devuelve los dominios de búsqueda de la tailnet.
+func (c *TailscaleClient) SearchPaths() ([]string, error) {
+	var out struct {
+		SearchPaths []string `json:"searchPaths"`
+	}
+	err := c.do("searchpaths", http.MethodGet, c.tailnetPath("/dns/searchpaths"), nil, &out)
+	return out.SearchPaths, err
+}
+
+// SetSearchPaths reemplaza los dominios de búsqueda (ver EnsureSearchPaths para fusionar).
+func (c *TailscaleClient) SetSearchPaths(paths []string) error {
+	body := map[string][]string{"searchPaths": nonNil(paths)}
+	return c.do("searchpaths", http.MethodPost, c.tailnetPath("/dns/searchpaths"), body, nil)
+}
+
+// EnsureSearchPaths agrega los dominios que falten conservando los existentes.
+func (c *TailscaleClient) EnsureSearchPaths(paths ...string) (changed bool, err error) {
+	current, err := c.SearchPaths()
+	if err != nil {
+		return false, err
+	}
+	merged := append([]string(nil), current...)
+	for _, p := range paths {
+		if !containsFold(merged, p) {
+			merged = append(merged, p)
+		}
+	}
+	if len(merged) == len(current) {
+		return false, nil
+	}
+	return true, c.SetSearchPaths(merged)
+}
+
+// Nameservers devuelve los nameservers globales de la tailnet.
+func (c *TailscaleClient) Nameservers() ([]string, error) {
+	var out struct {
+		DNS []string `json:"dns"`
+	}
+	err := c.do("nameservers", http.MethodGet, c.tailnetPath("/dns/nameservers"), nil, &out)
+	return out.DNS, err
+}
+
+// SetNameservers reemplaza los nameservers globales de la tailnet.
+func (c *TailscaleClient) SetNameservers(ns []string) error {
+	body := map[string][]string{"dns": nonNil(ns)}
+	return c.do("nameservers", http.MethodPost, c.tailnetPath("/dns/nameservers"), body, nil)
+}
+
+// DNSPreferences devuelve las preferencias DNS (MagicDNS).
+func (c *TailscaleClient) DNSPreferences() (DNSPreferences, error) {
+	var out DNSPreferences
+	err := c.do("preferences", http.MethodGet, c.tailnetPath("/dns/preferences"), nil, &out)
+	return out, err
+}
+
+// SetDNSPreferences actualiza las preferencias DNS (MagicDNS).
+func (c *TailscaleClient) SetDNSPreferences(p DNSPreferences) error {
+	return c.do("preferences", http.MethodPost, c.tailnetPath("/dns/preferences"), p, nil)
+}
+
+// -----------------------------------------------------------------------------
+// Dispositivos
+// -----------------------------------------------------------------------------
+
+// Devices lista los dispositivos de la tailnet.
+func (c *TailscaleClient) Devices() ([]TailscaleDevice, error) {
+	var out struct {
+		Devices []TailscaleDevice `json:"devices"`
+	}
+	err := c.do("devices", http.MethodGet, c.tailnetPath("/devices"), nil, &out)
+	return out.Devices, err
+}
+
+// -----------------------------------------------------------------------------
+// Transporte
+// -----------------------------------------------------------------------------
+
+func (c *TailscaleClient) tailnetPath(p string) string {
+	return "/api/v2/tailnet/" + url.PathEscape(c.Tailnet) + p
+}
+
+// do ejecuta la petición autenticada y decodifica la respuesta JSON en out (si no es nil).
+func (c *TailscaleClient) do(op, method, path string, body, out any) error {
+	if method != http.MethodGet && c.DryRun {
+		b, _ := json.Marshal(body)
+		fmt.Printf("%s tailscale api: %s %s %s\n", utils.DryRunPrefix, method, path, b)
+		return nil
+	}
+	token, err := c.accessToken()
+	if err != nil {
+		return err
+	}
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("tailscale api %s: %w", op, err)
+	}
+	defer resp.Body.Close()
+	if err := checkTailscaleResponse(op, resp); err != nil {
+		return err
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("tailscale api %s: respuesta inválida: %w", op, err)
+	}
+	return nil
+}
+
+// accessToken devuelve la API key o un token OAuth (cacheado hasta poco antes de expirar).
+func (c *TailscaleClient) accessToken() (string, error) {
+	if c.auth.APIKey != "" {
+		return c.auth.APIKey, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.tokenExp) {
+		return c.token, nil
+	}
+
+	form := url.Values{
+		"client_id":     {c.auth.ClientID},
+		"client_secret": {c.auth.ClientSecret},
+		"grant_type":    {"client_credentials"},
+	}
+	resp, err := c.http.PostForm(c.BaseURL+"/api/v2/oauth/token", form)
+	if err != nil {
+		return "", fmt.Errorf("tailscale oauth: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkTailscaleResponse("oauth", resp); err != nil {
+		return "", err
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("tailscale oauth: respuesta sin access_token")
+	}
+	ttl := time.Duration(tok.ExpiresIn) * time.Second
+	if ttl <= time.Minute {
+		ttl = 2 * time.Minute
+	}
+	c.token, c.tokenExp = tok.AccessToken, time.Now().Add(ttl-time.Minute)
+	return c.token, nil
+}
+
+func checkTailscaleResponse(op string, resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(b, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(b))
+	}
+	if msg.Message == "" {
+		msg.Message = http.StatusText(resp.StatusCode)
+	}
+	return &TailscaleAPIError{Op: op, StatusCode: resp.StatusCode, Message: msg.Message}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package infra
+
+import (
+	"autohost-cli/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTailnet imita la API v2 de Tailscale para la tailnet "ejemplo.ts.net":
+// guarda split DNS, search paths y nameservers globales y registra las
+// peticiones que modifican algo.
+type fakeTailnet struct {
+	t     *testing.T
+	token string // Bearer esperado
+
+	// OAuth (client credentials); vacío desactiva /api/v2/oauth/token.
+	clientID, clientSecret string
+	tokenRequests          int
+
+	mu          sync.Mutex
+	splitDNS    map[string][]string
+	searchPaths []string
+	nameservers []string
+	writes      []string // "MÉTODO ruta cuerpo"
+}
+
+func newFakeTailnet(t *testing.T, token string) (*fakeTailnet, *httptest.Server) {
+	t.Helper()
+	f := &fakeTailnet{
+		t:           t,
+		token:       token,
+		splitDNS:    map[string][]string{"corp.example": {"10.0.0.53"}},
+		searchPaths: []string{"corp.example"},
+		nameservers: []string{"1.1.1.1", "9.9.9.9"},
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	// Las escrituras deben llegar al servidor aunque otro test deje un DryRunner.
+	utils.SetDefaultRunner(utils.NewRecordingRunner())
+	t.Cleanup(func() { utils.SetDefaultRunner(nil) })
+	return f, srv
+}
+
+func (f *fakeTailnet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/api/v2/oauth/token" {
+		f.tokenRequests++
+		if err := r.ParseForm(); err != nil || f.clientID == "" {
+			http.Error(w, `{"message":"oauth no habilitado"}`, http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" ||
+			r.PostForm.Get("client_id") != f.clientID || r.PostForm.Get("client_secret") != f.clientSecret {
+			http.Error(w, `{"message":"invalid client"}`, http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": f.token, "expires_in": 3600, "token_type": "Bearer"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		http.Error(w, `{"message":"API token invalid"}`, http.StatusUnauthorized)
+		return
+	}
+
+	const prefix = "/api/v2/tailnet/ejemplo.ts.net"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, `{"message":"tailnet not found"}`, http.StatusNotFound)
+		return
+	}
+	var body json.RawMessage
+	if r.Method != http.MethodGet {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("%s %s: cuerpo inválido: %v", r.Method, r.URL.Path, err)
+		}
+		f.writes = append(f.writes, r.Method+" "+strings.TrimPrefix(r.URL.Path, prefix)+" "+string(body))
+	}
+
+	switch path := strings.TrimPrefix(r.URL.Path, prefix); {
+	case path == "/dns/split-dns" && r.Method == http.MethodGet:
+	case path == "/dns/split-dns" && r.Method == http.MethodPatch:
+		var changes map[string][]string
+		_ = json.Unmarshal(body, &changes)
+		for d, ns := range changes {
+			if ns == nil {
+				delete(f.splitDNS, d)
+			} else {
+				f.splitDNS[d] = ns
+			}
+		}
+	case path == "/dns/searchpaths" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(map[string][]string{"searchPaths": f.searchPaths})
+		return
+	case path == "/dns/searchpaths" && r.Method == http.MethodPost:
+		var in map[string][]string
+		_ = json.Unmarshal(body, &in)
+		f.searchPaths = in["searchPaths"]
+		_ = json.NewEncoder(w).Encode(in)
+		return
+	case path == "/dns/nameservers" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(map[string][]string{"dns": f.nameservers})
+		return
+	case path == "/dns/nameservers" && r.Method == http.MethodPost:
+		var in map[string][]string
+		_ = json.Unmarshal(body, &in)
+		f.nameservers = in["dns"]
+		_ = json.NewEncoder(w).Encode(in)
+		return
+	default:
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(f.splitDNS)
+}
+
+func TestTailscaleClientAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       TailscaleAuth
+		oauth      bool
+		wantErr    error
+		wantTokens int
+	}{
+		{name: "api key", auth: TailscaleAuth{APIKey: "tskey-api-123"}},
+		{name: "api key inválida", auth: TailscaleAuth{APIKey: "tskey-otra"}, wantErr: ErrTailscaleAuth},
+		{name: "oauth", auth: TailscaleAuth{ClientID: "cid", ClientSecret: "tskey-client-s3cr3t"}, oauth: true, wantTokens: 1},
+		// Un token rechazado no se cachea: cada llamada lo vuelve a pedir.
+		{name: "oauth secreto inválido", auth: TailscaleAuth{ClientID: "cid", ClientSecret: "mal"}, oauth: true, wantErr: ErrTailscaleAuth, wantTokens: 3},
+		// La API key tiene prioridad: no se pide token aunque haya cliente OAuth.
+		{name: "api key y oauth", auth: TailscaleAuth{APIKey: "tskey-api-123", ClientID: "cid", ClientSecret: "x"}, oauth: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, srv := newFakeTailnet(t, "tskey-api-123")
+			if tt.oauth {
+				f.clientID, f.clientSecret = "cid", "tskey-client-s3cr3t"
+			}
+			c, err := NewTailscaleClientWithAuth(srv.URL, "ejemplo.ts.net", tt.auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Varias llamadas: el token OAuth se pide una sola vez.
+			for i := 0; i < 3; i++ {
+				_, err = c.SplitDNS()
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("llamada %d: err = %v, quiero %v", i, err, tt.wantErr)
+				}
+			}
+			if f.tokenRequests != tt.wantTokens {
+				t.Fatalf("peticiones de token = %d, quiero %d", f.tokenRequests, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestTailscaleClientErrors(t *testing.T) {
+	if _, err := NewTailscaleClientWithAuth("", "x", TailscaleAuth{ClientID: "solo-id"}); !errors.Is(err, ErrTailscaleNoCreds) {
+		t.Fatalf("sin secreto: err = %v, quiero ErrTailscaleNoCreds", err)
+	}
+
+	_, srv := newFakeTailnet(t, "k")
+	c, err := NewTailscaleClientWithAuth(srv.URL, "otra.ts.net", TailscaleAuth{APIKey: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.SearchPaths()
+	var apiErr *TailscaleAPIError
+	if !errors.Is(err, ErrTailscaleNotFound) || !errors.As(err, &apiErr) || apiErr.Message != "tailnet not found" {
+		t.Fatalf("err = %v, quiero ErrTailscaleNotFound con el mensaje de la API", err)
+	}
+}
+
+func TestEnsureSearchPathsKeepsExisting(t *testing.T) {
+	tests := []struct {
+		name        string
+		add         []string
+		wantChanged bool
+		want        []string
+	}{
+		{name: "agrega al final", add: []string{"maza.lan"}, wantChanged: true, want: []string{"corp.example", "maza.lan"}},
+		{name: "ya presente sin distinguir mayúsculas", add: []string{"CORP.example"}, want: []string{"corp.example"}},
+		{name: "duplicados en la entrada", add: []string{"a.lan", "A.LAN", "corp.example"}, wantChanged: true, want: []string{"corp.example", "a.lan"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, srv := newFakeTailnet(t, "k")
+			c, err := NewTailscaleClientWithAuth(srv.URL, "ejemplo.ts.net", TailscaleAuth{APIKey: "k"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			changed, err := c.EnsureSearchPaths(tt.add...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.wantChanged || !reflect.DeepEqual(f.searchPaths, tt.want) {
+				t.Fatalf("changed = %v, searchPaths = %q; quiero %v %q", changed, f.searchPaths, tt.wantChanged, tt.want)
+			}
+			if !tt.wantChanged && len(f.writes) != 0 {
+				t.Fatalf("no debería escribir: %q", f.writes)
+			}
+		})
+	}
+}
+
+// ConfigureSplitDNSWithAPI solo toca el dominio pedido y agrega los search
+// paths que falten: los demás dominios, search paths y los nameservers
+// globales quedan como estaban.
+func TestConfigureSplitDNSWithAPIMerges(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       SplitDNSOpts
+		existing   map[string][]string
+		wantWrites []string
+		wantAdded  []string
+		wantSearch []string
+	}{
+		{
+			name:       "dominio nuevo",
+			opts:       SplitDNSOpts{Domain: "maza.lan", Nameservers: []string{"100.64.0.1"}, SearchPaths: []string{"maza.lan"}},
+			wantWrites: []string{`PATCH /dns/split-dns {"maza.lan":["100.64.0.1"]}`, `POST /dns/searchpaths {"searchPaths":["corp.example","maza.lan"]}`},
+			wantAdded:  []string{"maza.lan"},
+			wantSearch: []string{"corp.example", "maza.lan"},
+		},
+		{
+			name:       "cambia los nameservers",
+			opts:       SplitDNSOpts{Domain: "maza.lan", Nameservers: []string{"100.64.0.2"}},
+			existing:   map[string][]string{"maza.lan": {"100.64.0.1"}},
+			wantWrites: []string{`PATCH /dns/split-dns {"maza.lan":["100.64.0.2"]}`},
+			wantSearch: []string{"corp.example"},
+		},
+		{
+			name:       "ya aplicado",
+			opts:       SplitDNSOpts{Domain: "maza.lan", Nameservers: []string{"100.64.0.1"}, SearchPaths: []string{"Corp.Example"}},
+			existing:   map[string][]string{"maza.lan": {"100.64.0.1"}},
+			wantSearch: []string{"corp.example"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, srv := newFakeTailnet(t, "k")
+			for d, ns := range tt.existing {
+				f.splitDNS[d] = ns
+			}
+			t.Setenv("TAILSCALE_API_BASE_URL", srv.URL)
+			t.Setenv("TAILSCALE_API_KEY", "k")
+			tt.opts.Tailnet = "ejemplo.ts.net"
+
+			added, err := ConfigureSplitDNSWithAPI(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.writes, tt.wantWrites) {
+				t.Fatalf("escrituras = %q\nquiero %q", f.writes, tt.wantWrites)
+			}
+			if !reflect.DeepEqual(added, tt.wantAdded) || !reflect.DeepEqual(f.searchPaths, tt.wantSearch) {
+				t.Fatalf("added = %q, searchPaths = %q", added, f.searchPaths)
+			}
+			if ns := f.splitDNS["corp.example"]; !reflect.DeepEqual(ns, []string{"10.0.0.53"}) {
+				t.Fatalf("corp.example = %q: se pisó otro dominio", ns)
+			}
+			if want := []string{"1.1.1.1", "9.9.9.9"}; !reflect.DeepEqual(f.nameservers, want) {
+				t.Fatalf("nameservers globales = %q, quiero %q", f.nameservers, want)
+			}
+			if want := tt.opts.Nameservers; !reflect.DeepEqual(f.splitDNS["maza.lan"], want) {
+				t.Fatalf("maza.lan = %q, quiero %q", f.splitDNS["maza.lan"], want)
+			}
+		})
+	}
+}
+
+// Aplicar y quitar un dominio deja la tailnet como estaba: solo se borran el
+// dominio y los search paths que agregó autohost.
+func TestSplitDNSApplyRemoveRoundTrip(t *testing.T) {
+	f, srv := newFakeTailnet(t, "k")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TAILSCALE_API_BASE_URL", srv.URL)
+	t.Setenv("TAILSCALE_API_KEY", "k")
+	t.Setenv("AUTOHOST_SPLITDNS_BACKEND", "")
+	// Los archivos de estado se escriben de verdad.
+	utils.SetDefaultRunner(nil)
+
+	opts := SplitDNSOpts{Tailnet: "ejemplo.ts.net", Domain: "maza.lan", Nameservers: []string{"100.64.0.1"}, SearchPaths: []string{"maza.lan", "corp.example"}}
+	if err := ConfigureSplitDNS(opts); err != nil {
+		t.Fatal(err)
+	}
+	st, err := loadSplitDNSState(splitDNSWorkspace("ejemplo.ts.net", "maza.lan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Backend != SplitDNSBackendAPI || !reflect.DeepEqual(st.AddedSearchPaths, []string{"maza.lan"}) {
+		t.Fatalf("estado = %+v", st)
+	}
+
+	if err := RemoveSplitDNS("ejemplo.ts.net", "maza.lan", ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"corp.example": {"10.0.0.53"}}; !reflect.DeepEqual(f.splitDNS, want) {
+		t.Fatalf("split DNS = %v, quiero %v", f.splitDNS, want)
+	}
+	if want := []string{"corp.example"}; !reflect.DeepEqual(f.searchPaths, want) {
+		t.Fatalf("searchPaths = %q, quiero %q", f.searchPaths, want)
+	}
+	if _, err := os.Stat(st.Workspace); !os.IsNotExist(err) {
+		t.Fatalf("el workspace %s debería eliminarse", st.Workspace)
+	}
+}
@@ -0,0 +1,195 @@
+// internal/infra/tailscale_local.go
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTailscaledSocket = "/var/run/tailscale/tailscaled.sock"
+	// tailscaledLocalAPIHost es el Host que tailscaled exige en la LocalAPI.
+	tailscaledLocalAPIHost = "local-tailscaled.sock"
+)
+
+var (
+	ErrTailscaledUnavailable = errors.New("tailscaled no responde (¿está instalado y en ejecución?)")
+	ErrTailscaledPermission  = errors.New("sin permisos sobre el socket de tailscaled")
+)
+
+// TailscaleLocalClient habla con la LocalAPI de tailscaled por su socket unix,
+// sin ejecutar ni parsear el CLI `tailscale`.
+type TailscaleLocalClient struct {
+	http   *http.Client
+	Socket string
+}
+
+// NewTailscaleLocalClient usa AUTOHOST_TAILSCALED_SOCKET o el socket por defecto.
+func NewTailscaleLocalClient() *TailscaleLocalClient {
+	sock := strings.TrimSpace(os.Getenv("AUTOHOST_TAILSCALED_SOCKET"))
+	if sock == "" {
+		sock = defaultTailscaledSocket
+	}
+	return NewTailscaleLocalClientForSocket(sock)
+}
+
+// NewTailscaleLocalClientForSocket crea un cliente para un socket concreto.
+func NewTailscaleLocalClientForSocket(sock string) *TailscaleLocalClient {
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}
+	return &TailscaleLocalClient{
+		http:   &http.Client{Transport: tr, Timeout: 10 * time.Second},
+		Socket: sock,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Tipos
+// -----------------------------------------------------------------------------
+
+// TailscaleStatus es un subconjunto de GET /localapi/v0/status (ipnstate.Status).
+type TailscaleStatus struct {
+	Version        string                    `json:"Version"`
+	BackendState   string                    `json:"BackendState"` // Running, NeedsLogin, Stopped...
+	TailscaleIPs   []string                  `json:"TailscaleIPs"`
+	Self           *TailscalePeer            `json:"Self"`
+	MagicDNSSuffix string                    `json:"MagicDNSSuffix"`
+	CurrentTailnet *TailscaleTailnet         `json:"CurrentTailnet"`
+	CertDomains    []string                  `json:"CertDomains"`
+	Health         []string                  `json:"Health"`
+	Peer           map[string]*TailscalePeer `json:"Peer"`
+}
+
+// TailscaleTailnet describe la tailnet a la que pertenece el nodo.
+type TailscaleTailnet struct {
+	Name            string `json:"Name"`
+	MagicDNSSuffix  string `json:"MagicDNSSuffix"`
+	MagicDNSEnabled bool   `json:"MagicDNSEnabled"`
+}
+
+// TailscalePeer es un nodo de la tailnet (el propio o un peer).
+type TailscalePeer struct {
+	ID           string   `json:"ID"`
+	HostName     string   `json:"HostName"`
+	DNSName      string   `json:"DNSName"`
+	OS           string   `json:"OS"`
+	TailscaleIPs []string `json:"TailscaleIPs"`
+	Tags         []string `json:"Tags,omitempty"`
+	Online       bool     `json:"Online"`
+	ExitNode     bool     `json:"ExitNode"`
+	LastSeen     string   `json:"LastSeen"`
+}
+
+// Running indica si el nodo está conectado a la tailnet.
+func (s *TailscaleStatus) Running() bool { return s.BackendState == "Running" }
+
+// IPv4 devuelve la IP 100.x del nodo ("" si no tiene).
+func (s *TailscaleStatus) IPv4() string {
+	for _, ip := range s.TailscaleIPs {
+		if p := net.ParseIP(ip); p != nil && p.To4() != nil {
+			return ip
+		}
+	}
+	return ""
+}
+
+// DNSName devuelve el nombre MagicDNS del nodo sin el punto final.
+func (s *TailscaleStatus) DNSName() string {
+	if s.Self == nil {
+		return ""
+	}
+	return strings.TrimSuffix(s.Self.DNSName, ".")
+}
+
+// TailnetName devuelve el nombre de la tailnet ("" si no se conoce).
+func (s *TailscaleStatus) TailnetName() string {
+	if s.CurrentTailnet == nil {
+		return ""
+	}
+	return s.CurrentTailnet.Name
+}
+
+// Peers devuelve los peers ordenados por nombre.
+func (s *TailscaleStatus) Peers() []TailscalePeer {
+	out := make([]TailscalePeer, 0, len(s.Peer))
+	for _, p := range s.Peer {
+		if p != nil {
+			out = append(out, *p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DNSName < out[j].DNSName })
+	return out
+}
+
+// ShortName devuelve el primer label del nombre MagicDNS (o el hostname).
+func (p TailscalePeer) ShortName() string {
+	if name, _, _ := strings.Cut(p.DNSName, "."); name != "" {
+		return name
+	}
+	return p.HostName
+}
+
+// -----------------------------------------------------------------------------
+// Endpoints
+// -----------------------------------------------------------------------------
+
+// Status devuelve el estado del nodo y de sus peers.
+func (c *TailscaleLocalClient) Status() (*TailscaleStatus, error) {
+	var st TailscaleStatus
+	if err := c.get("/localapi/v0/status", &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// StatusWithoutPeers es Status sin la lista de peers (más barato).
+func (c *TailscaleLocalClient) StatusWithoutPeers() (*TailscaleStatus, error) {
+	var st TailscaleStatus
+	if err := c.get("/localapi/v0/status?peers=false", &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (c *TailscaleLocalClient) get(path string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, "http://"+tailscaledLocalAPIHost+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+			return fmt.Errorf("tailscaled (%s): %w", c.Socket, ErrTailscaledPermission)
+		default:
+			return fmt.Errorf("tailscaled (%s): %w: %v", c.Socket, ErrTailscaledUnavailable, err)
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusForbidden {
+			// tailscaled rechaza a quien no es root ni su operador (tailscale set --operator).
+			return fmt.Errorf("tailscaled %s: %w: %s", path, ErrTailscaledPermission, strings.TrimSpace(string(b)))
+		}
+		return fmt.Errorf("tailscaled %s: %s: %s", path, resp.Status, strings.TrimSpace(string(b)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("tailscaled %s: respuesta inválida: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// Respuesta recortada de un `GET /localapi/v0/status` real.
+const localAPIStatus = `{
+  "Version": "1.76.6-t1234",
+  "BackendState": "Running",
+  "TailscaleIPs": ["fd7a:115c:a1e0::1", "100.101.102.103"],
+  "Self": {
+    "ID": "nSelf",
+    "HostName": "maza-server",
+    "DNSName": "maza-server.tail1234.ts.net.",
+    "OS": "linux",
+    "TailscaleIPs": ["100.101.102.103", "fd7a:115c:a1e0::1"],
+    "Online": true
+  },
+  "MagicDNSSuffix": "tail1234.ts.net",
+  "CurrentTailnet": {"Name": "yo@example.com", "MagicDNSSuffix": "tail1234.ts.net", "MagicDNSEnabled": true},
+  "Peer": {
+    "nodekey:bb": {"ID": "n2", "HostName": "portatil", "DNSName": "portatil.tail1234.ts.net.", "OS": "macOS", "TailscaleIPs": ["100.64.0.2"], "Online": true},
+    "nodekey:aa": {"ID": "n1", "HostName": "NAS", "DNSName": "nas.tail1234.ts.net.", "OS": "linux", "TailscaleIPs": ["100.64.0.1"], "Tags": ["tag:server"], "ExitNode": true},
+    "nodekey:cc": null
+  }
+}`
+
+// newFakeTailscaled levanta h en un socket unix temporal y devuelve un cliente para él.
+func newFakeTailscaled(t *testing.T, h http.HandlerFunc) *TailscaleLocalClient {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "tailscaled.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return NewTailscaleLocalClientForSocket(sock)
+}
+
+func TestTailscaleLocalStatus(t *testing.T) {
+	var gotPath, gotHost string
+	c := newFakeTailscaled(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotHost = r.URL.RequestURI(), r.Host
+		w.Write([]byte(localAPIStatus))
+	})
+
+	st, err := c.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/localapi/v0/status" || gotHost != tailscaledLocalAPIHost {
+		t.Fatalf("petición a %s (Host %s)", gotPath, gotHost)
+	}
+	if !st.Running() || st.IPv4() != "100.101.102.103" {
+		t.Errorf("Running() = %v, IPv4() = %q", st.Running(), st.IPv4())
+	}
+	if st.DNSName() != "maza-server.tail1234.ts.net" || st.TailnetName() != "yo@example.com" {
+		t.Errorf("DNSName() = %q, TailnetName() = %q", st.DNSName(), st.TailnetName())
+	}
+
+	var names []string
+	for _, p := range st.Peers() {
+		names = append(names, p.ShortName())
+	}
+	if want := []string{"nas", "portatil"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Peers() = %q, quiero %q", names, want)
+	}
+	if nas := st.Peers()[0]; !nas.ExitNode || !reflect.DeepEqual(nas.Tags, []string{"tag:server"}) {
+		t.Errorf("peer nas = %+v", nas)
+	}
+
+	if _, err := c.StatusWithoutPeers(); err != nil || gotPath != "/localapi/v0/status?peers=false" {
+		t.Fatalf("StatusWithoutPeers: %v (petición a %s)", err, gotPath)
+	}
+}
+
+func TestTailscaleLocalStatusEmpty(t *testing.T) {
+	c := newFakeTailscaled(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"BackendState":"NeedsLogin","TailscaleIPs":null,"Self":null,"CurrentTailnet":null}`))
+	})
+	st, err := c.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Running() || st.IPv4() != "" || st.DNSName() != "" || st.TailnetName() != "" || len(st.Peers()) != 0 {
+		t.Fatalf("estado sin login = %+v", st)
+	}
+}
+
+func TestTailscaleLocalErrors(t *testing.T) {
+	dir := t.TempDir()
+	refused := filepath.Join(dir, "dead.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: refused, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.SetUnlinkOnClose(false)
+	ln.Close()
+
+	tests := []struct {
+		name   string
+		client func(t *testing.T) *TailscaleLocalClient
+		want   error
+	}{
+		{
+			name: "socket inexistente",
+			client: func(*testing.T) *TailscaleLocalClient {
+				return NewTailscaleLocalClientForSocket(filepath.Join(dir, "no.sock"))
+			},
+			want: ErrTailscaledUnavailable,
+		},
+		{
+			name:   "nadie escucha",
+			client: func(*testing.T) *TailscaleLocalClient { return NewTailscaleLocalClientForSocket(refused) },
+			want:   ErrTailscaledUnavailable,
+		},
+		{
+			// Como root no se puede provocar EACCES con chmod: se simula en el dial.
+			name: "socket sin permisos",
+			client: func(*testing.T) *TailscaleLocalClient {
+				c := NewTailscaleLocalClientForSocket("/var/run/tailscale/tailscaled.sock")
+				c.http.Transport = &http.Transport{DialContext: func(context.Context, string, string) (net.Conn, error) {
+					return nil, &net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.EACCES)}
+				}}
+				return c
+			},
+			want: ErrTailscaledPermission,
+		},
+		{
+			name: "LocalAPI responde 403",
+			client: func(t *testing.T) *TailscaleLocalClient {
+				return newFakeTailscaled(t, func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "access denied", http.StatusForbidden)
+				})
+			},
+			want: ErrTailscaledPermission,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.client(t).Status()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, quiero %v", err, tt.want)
+			}
+		})
+	}
+
+	c := newFakeTailscaled(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>")) })
+	if _, err := c.Status(); err == nil || errors.Is(err, ErrTailscaledUnavailable) {
+		t.Fatalf("respuesta inválida: err = %v", err)
+	}
+}
@@ -2,194 +2,194 @@
 package infra
 
 import (
-	"archive/zip"
-	"bytes"
-	"compress/gzip"
+	"autohost-cli/utils"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
 )
 
 const (
-	tfVersion      = "1.9.8" // fija una versión estable
+	tfVersion      = "1.9.8" // versión por defecto (AUTOHOST_TERRAFORM_VERSION la cambia)
 	tfBinDirRel    = ".autohost/bin"
 	tfStateDirRel  = ".autohost/state/tailscale"
 	tfProviderVer  = "~> 0.21"
-	tfDownloadBase = "https://releases.hashicorp.com/terraform"
+	tfDownloadBase = "https://releases.hashicorp.com"
 )
 
-type SplitDNSOpts struct {
-	Tailnet      string   // ej: "tu-org.ts.net"  (si vacío, usa env TAILSCALE_TAILNET o "-")
-	Domain       string   // ej: "maza-server"
-	Nameservers  []string // ej: ["100.112.92.90"]
-	SearchPaths  []string // opcional: ej ["maza-server"]
-	APIKeyEnvVar string   // por defecto "TAILSCALE_API_KEY"
-}
-
 // ConfigureSplitDNSWithTerraform genera el .tf y aplica con terraform.
 func ConfigureSplitDNSWithTerraform(opts SplitDNSOpts) error {
-	if opts.Domain == "" || len(opts.Nameservers) == 0 {
-		return fmt.Errorf("domain y al menos un nameserver son obligatorios")
+	tfPath, err := terraformBinary(opts)
+	if err != nil {
+		return err
+	}
+	// Workspace: ~/.autohost/state/tailscale/<tailnet>/split-dns-<domain>/
+	ws, err := prepareWorkspace(splitDNSTailnet(opts.Tailnet), opts.Domain)
+	if err != nil {
+		return err
+	}
+	if err := initTerraformDir(ws, tfPath, opts); err != nil {
+		return err
+	}
+	if err := runCmd(ws, tfPath, "apply", "-auto-approve", "-input=false"); err != nil {
+		return fmt.Errorf("terraform apply falló: %w", err)
 	}
+	return nil
+}
 
-	// 1) Validar API key (Terraform provider la usa)
-	apiEnv := opts.APIKeyEnvVar
-	if apiEnv == "" {
-		apiEnv = "TAILSCALE_API_KEY"
+// planSplitDNSWithTerraform ejecuta `terraform plan` y devuelve su salida.
+// El plan corre en una copia temporal del workspace: no crea ni modifica el
+// workspace del dominio, así un plan nunca cuenta como aplicado.
+func planSplitDNSWithTerraform(opts SplitDNSOpts) (string, error) {
+	tfPath, err := terraformBinary(opts)
+	if err != nil {
+		return "", err
 	}
-	if os.Getenv(apiEnv) == "" {
-		return fmt.Errorf("%s no está definido en el entorno", apiEnv)
+	dir, err := os.MkdirTemp("", "autohost-split-dns-plan-")
+	if err != nil {
+		return "", err
 	}
+	defer os.RemoveAll(dir)
 
-	// 2) Resolver tailnet
-	tailnet := opts.Tailnet
-	if tailnet == "" {
-		tailnet = os.Getenv("TAILSCALE_TAILNET")
-		if tailnet == "" {
-			tailnet = "-" // tailnet por defecto del token
+	ws := splitDNSWorkspace(splitDNSTailnet(opts.Tailnet), opts.Domain)
+	for _, name := range []string{"terraform.tfstate", ".terraform.lock.hcl"} {
+		b, err := os.ReadFile(filepath.Join(ws, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			return "", err
 		}
 	}
+	if err := initTerraformDir(dir, tfPath, opts); err != nil {
+		return "", err
+	}
+	c := utils.Command(tfPath, "plan", "-input=false", "-no-color", "-lock=false")
+	c.Dir = dir
+	out, err := utils.DefaultRunner().Output(c)
+	if err != nil {
+		return "", fmt.Errorf("terraform plan falló: %w", err)
+	}
+	return string(out), nil
+}
 
-	// 3) Asegurar terraform binario
+// destroySplitDNSWithTerraform destruye los recursos del workspace.
+func destroySplitDNSWithTerraform(ws string) error {
 	tfPath, err := ensureTerraform()
 	if err != nil {
 		return fmt.Errorf("no se pudo asegurar terraform: %w", err)
 	}
+	if err := runCmd(ws, tfPath, "init", "-input=false"); err != nil {
+		return fmt.Errorf("terraform init falló: %w", err)
+	}
+	if err := runCmd(ws, tfPath, "destroy", "-auto-approve", "-input=false"); err != nil {
+		return fmt.Errorf("terraform destroy falló: %w", err)
+	}
+	return nil
+}
 
-	// 4) Preparar workspace: ~/.autohost/state/tailscale/<tailnet>/split-dns-<domain>/
-	ws, err := prepareWorkspace(tailnet, opts.Domain)
+// terraformBinary valida las opciones y credenciales y asegura el binario.
+func terraformBinary(opts SplitDNSOpts) (string, error) {
+	if opts.Domain == "" || len(opts.Nameservers) == 0 {
+		return "", fmt.Errorf("domain y al menos un nameserver son obligatorios")
+	}
+	// El provider lee las mismas variables de entorno
+	auth := TailscaleAuthFromEnv(opts.APIKeyEnvVar)
+	if auth.APIKey == "" && (auth.ClientID == "" || auth.ClientSecret == "") {
+		return "", ErrTailscaleNoCreds
+	}
+	tfPath, err := ensureTerraform()
 	if err != nil {
-		return err
+		return "", fmt.Errorf("no se pudo asegurar terraform: %w", err)
 	}
+	return tfPath, nil
+}
 
-	// 5) Escribir main.tf
-	if err := writeMainTF(ws, opts.Domain, opts.Nameservers, opts.SearchPaths); err != nil {
+// initTerraformDir escribe main.tf en dir y ejecuta `terraform init`.
+func initTerraformDir(dir, tfPath string, opts SplitDNSOpts) error {
+	if err := writeMainTF(dir, opts.Domain, opts.Nameservers, opts.SearchPaths); err != nil {
 		return err
 	}
-
-	// 6) terraform init
-	if err := runCmd(ws, tfPath, "init", "-upgrade"); err != nil {
+	if err := runCmd(dir, tfPath, "init", "-upgrade", "-input=false"); err != nil {
 		return fmt.Errorf("terraform init falló: %w", err)
 	}
-
-	// 7) terraform apply
-	if err := runCmd(ws, tfPath, "apply", "-auto-approve"); err != nil {
-		return fmt.Errorf("terraform apply falló: %w", err)
-	}
-
 	return nil
 }
 
+// ensureTerraform devuelve terraform del PATH o descarga la versión fijada
+// (AUTOHOST_TERRAFORM_VERSION o tfVersion) verificando SHA256SUMS y su firma.
 func ensureTerraform() (string, error) {
 	// Si está en PATH, úsalo
 	if p, err := exec.LookPath("terraform"); err == nil {
 		return p, nil
 	}
-	home, _ := os.UserHomeDir()
-	binDir := filepath.Join(home, tfBinDirRel)
-	if err := os.MkdirAll(binDir, 0o755); err != nil {
-		return "", err
+	version := terraformVersion()
+	exe := "terraform"
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
 	}
-	tfPath := filepath.Join(binDir, "terraform")
+	home, _ := os.UserHomeDir()
+	tfPath := filepath.Join(home, tfBinDirRel, "terraform_"+version, exe)
 	if _, err := os.Stat(tfPath); err == nil {
 		return tfPath, nil
 	}
-
-	// Descargar según OS/ARCH
-	osName := runtime.GOOS
-	arch := runtime.GOARCH
-	var url string
-	var isZip bool
-
-	switch osName {
-	case "linux":
-		switch arch {
-		case "amd64":
-			url = fmt.Sprintf("%s/%s/terraform_%s_linux_amd64.zip", tfDownloadBase, tfVersion, tfVersion)
-			isZip = true
-		case "arm64":
-			url = fmt.Sprintf("%s/%s/terraform_%s_linux_arm64.zip", tfDownloadBase, tfVersion, tfVersion)
-			isZip = true
-		default:
-			return "", fmt.Errorf("arquitectura no soportada: %s/%s", osName, arch)
-		}
-	case "darwin":
-		switch arch {
-		case "arm64":
-			url = fmt.Sprintf("%s/%s/terraform_%s_darwin_arm64.zip", tfDownloadBase, tfVersion, tfVersion)
-			isZip = true
-		case "amd64":
-			url = fmt.Sprintf("%s/%s/terraform_%s_darwin_amd64.zip", tfDownloadBase, tfVersion, tfVersion)
-			isZip = true
-		default:
-			return "", fmt.Errorf("arquitectura no soportada: %s/%s", osName, arch)
-		}
-	case "windows":
-		switch arch {
-		case "amd64":
-			url = fmt.Sprintf("%s/%s/terraform_%s_windows_amd64.zip", tfDownloadBase, tfVersion, tfVersion)
-			isZip = true
-		case "arm64":
-			url = fmt.Sprintf("%s/%s/terraform_%s_windows_arm64.zip", tfDownloadBase, tfVersion, tfVersion)
-			isZip = true
-		default:
-			return "", fmt.Errorf("arquitectura no soportada: %s/%s", osName, arch)
-		}
-	default:
-		return "", fmt.Errorf("SO no soportado: %s", osName)
+	if utils.DefaultRunner().DryRun() {
+		fmt.Printf("%s descargar Terraform %s en %s\n", utils.DryRunPrefix, version, tfPath)
+		return tfPath, nil
 	}
 
-	fmt.Println("⬇️  Descargando Terraform:", url)
-	body, err := httpGet(url, 60*time.Second)
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64", "windows/amd64", "windows/arm64":
+	default:
+		return "", fmt.Errorf("plataforma no soportada: %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	dir := "terraform/" + version + "/"
+	zipPath, err := Download{
+		Name:     "Terraform " + version,
+		BaseURL:  tfDownloadBase,
+		Path:     dir + fmt.Sprintf("terraform_%s_%s_%s.zip", version, runtime.GOOS, runtime.GOARCH),
+		SumsPath: dir + fmt.Sprintf("terraform_%s_SHA256SUMS", version),
+		SigPath:  dir + fmt.Sprintf("terraform_%s_SHA256SUMS.sig", version),
+		Signer:   &HashiCorpSigner,
+	}.Fetch()
 	if err != nil {
 		return "", err
 	}
-
-	if isZip {
-		if err := unzipTerraform(body, binDir); err != nil {
-			return "", err
-		}
-	} else {
-		// (Nunca usamos .tgz aquí, pero dejamos el hook por si cambias arriba)
-		r, err := gzip.NewReader(bytes.NewReader(body))
-		if err != nil {
-			return "", err
-		}
-		defer r.Close()
-		out, err := os.Create(tfPath)
-		if err != nil {
-			return "", err
-		}
-		if _, err := io.Copy(out, r); err != nil {
-			_ = out.Close()
-			return "", err
-		}
-		_ = out.Close()
+	if err := extractZipMember(zipPath, exe, tfPath, 0o755); err != nil {
+		return "", err
 	}
+	return tfPath, nil
+}
 
-	if runtime.GOOS != "windows" {
-		_ = os.Chmod(filepath.Join(binDir, "terraform"), 0o755)
+func terraformVersion() string {
+	if v := strings.TrimSpace(os.Getenv("AUTOHOST_TERRAFORM_VERSION")); v != "" {
+		return strings.TrimPrefix(v, "v")
 	}
-	return filepath.Join(binDir, "terraform"), nil
+	return tfVersion
 }
 
-func prepareWorkspace(tailnet, domain string) (string, error) {
+// splitDNSWorkspace es el directorio de estado de un dominio:
+// ~/.autohost/state/tailscale/<tailnet>/split-dns-<domain>/
+func splitDNSWorkspace(tailnet, domain string) string {
 	home, _ := os.UserHomeDir()
 	safeDomain := strings.ReplaceAll(domain, ".", "-")
-	stateDir := filepath.Join(home, tfStateDirRel, tailnet, "split-dns-"+safeDomain)
+	return filepath.Join(home, tfStateDirRel, tailnet, "split-dns-"+safeDomain)
+}
+
+func prepareWorkspace(tailnet, domain string) (string, error) {
+	stateDir := splitDNSWorkspace(tailnet, domain)
 
-	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+	if err := utils.DefaultRunner().MkdirAll(stateDir, 0o755); err != nil {
 		return "", err
 	}
 	// .gitignore para evitar subir el state
-	_ = os.WriteFile(filepath.Join(stateDir, ".gitignore"),
+	_ = utils.DefaultRunner().WriteFile(filepath.Join(stateDir, ".gitignore"),
 		[]byte("*.tfstate\n*.tfstate.backup\n.terraform/\n.terraform.lock.hcl\n"), 0o644)
 	return stateDir, nil
 }
@@ -225,15 +225,13 @@ resource "tailscale_dns_search_paths" "paths" {
 `, quoteJoin(searchPaths))
 	}
 
-	return os.WriteFile(filepath.Join(dir, "main.tf"), []byte(strings.TrimSpace(tf)+"\n"), 0o644)
+	return utils.DefaultRunner().WriteFile(filepath.Join(dir, "main.tf"), []byte(strings.TrimSpace(tf)+"\n"), 0o644)
 }
 
 func runCmd(workdir, bin string, args ...string) error {
-	cmd := exec.Command(bin, args...)
-	cmd.Dir = workdir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	c := utils.Command(bin, args...)
+	c.Dir = workdir
+	return utils.DefaultRunner().Run(c)
 }
 
 func quoteJoin(items []string) string {
@@ -243,44 +241,3 @@ func quoteJoin(items []string) string {
 	}
 	return strings.Join(qs, ", ")
 }
-
-func httpGet(url string, timeout time.Duration) ([]byte, error) {
-	client := &http.Client{Timeout: timeout}
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("descarga falló: %s", resp.Status)
-	}
-	return io.ReadAll(resp.Body)
-}
-
-func unzipTerraform(zipBytes []byte, dest string) error {
-	r, err := zip.NewReader(bytes.NewReader(zipBytes), int64(len(zipBytes)))
-	if err != nil {
-		return err
-	}
-	for _, f := range r.File {
-		if f.FileInfo().IsDir() {
-			continue
-		}
-		rc, err := f.Open()
-		if err != nil {
-			return err
-		}
-		defer rc.Close()
-		out := filepath.Join(dest, f.Name)
-		w, err := os.Create(out)
-		if err != nil {
-			return err
-		}
-		if _, err := io.Copy(w, rc); err != nil {
-			w.Close()
-			return err
-		}
-		w.Close()
-	}
-	return nil
-}
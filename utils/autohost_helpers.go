@@ -4,19 +4,18 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 )
 
+// Exec ejecuta un comando con el Runner global (respeta --dry-run).
 func Exec(cmd string, args ...string) error {
-	c := exec.Command(cmd, args...)
-	c.Stdout, c.Stderr = os.Stdout, os.Stderr
-	return c.Run()
+	return DefaultRunner().Run(Command(cmd, args...))
 }
+
+// ExecShell ejecuta un script con bash -e (stop on error) y -o pipefail.
 func ExecShell(script string) error {
-	// bash con -e (stop on error) y -o pipefail
-	return Exec("bash", "-eo", "pipefail", "-c", script)
+	return DefaultRunner().Run(ShellCmd(script))
 }
 
 // func Exec(cmdName string, args ...string) error {
@@ -27,11 +26,9 @@ func ExecShell(script string) error {
 // }
 
 func ExecWithDir(dir string, cmdName string, args ...string) error {
-	cmd := exec.Command(cmdName, args...)
-	cmd.Dir = dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	c := Command(cmdName, args...)
+	c.Dir = dir
+	return DefaultRunner().Run(c)
 }
 
 // func ExecShell(command string) {
@@ -1,23 +1,18 @@
 package utils
 
 import (
-	"io"
 	"os"
 )
 
+// CopyFile copia src a dst usando el Runner global (respeta --dry-run).
 func CopyFile(src, dst string) error {
-	in, err := os.Open(src)
+	data, err := os.ReadFile(src)
 	if err != nil {
 		return err
 	}
-	defer in.Close()
-
-	out, err := os.Create(dst)
+	info, err := os.Stat(src)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, in)
-	return err
+	return DefaultRunner().WriteFile(dst, data, info.Mode().Perm())
 }
@@ -1,12 +1,168 @@
 package utils
 
-import "strings"
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
 
-// ReplacePlaceholders reemplaza claves tipo {{KEY}} por valores del map.
-func ReplacePlaceholders(content string, values map[string]string) string {
-	out := content
-	for k, v := range values {
-		out = strings.ReplaceAll(out, "{{"+k+"}}", v)
+// placeholderRe reconoce {{nombre}} y {{nombre:argumento}}.
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)(?::([^{}]*?))?\s*\}\}`)
+
+// envLineRe reconoce líneas KEY=VALUE (con "export " opcional).
+var envLineRe = regexp.MustCompile(`^(\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=)(.*)$`)
+
+// ReplacePlaceholders resuelve los placeholders {{...}} de una plantilla .env:
+//   - {{KEY}} (mayúsculas) toma el valor de `values` o de otra clave del mismo archivo.
+//   - {{generador}} / {{generador:arg}} usa el registro de generadores (password, hex, uuid…).
+//
+// Cualquier placeholder desconocido, referencia sin definir o ciclo devuelve error
+// para no filtrar "{{...}}" al .env final. Una clave definida dos veces también
+// es error: no está claro a cuál de los dos valores apuntaría {{KEY}}.
+func ReplacePlaceholders(content string, values map[string]string) (string, error) {
+	lines := strings.Split(content, "\n")
+
+	keyLine := map[string]int{}
+	for i, ln := range lines {
+		if m := envLineRe.FindStringSubmatch(ln); m != nil && !isCommentLine(ln) {
+			if prev, dup := keyLine[m[2]]; dup {
+				return "", fmt.Errorf("línea %d: %s ya está definida en la línea %d", i+1, m[2], prev+1)
+			}
+			keyLine[m[2]] = i
+		}
+	}
+
+	resolved := map[string]string{}
+	visiting := map[string]bool{}
+
+	var expand func(s string) (string, error)
+	var resolveKey func(key string) (string, error)
+
+	resolveKey = func(key string) (string, error) {
+		if v, ok := resolved[key]; ok {
+			return v, nil
+		}
+		if visiting[key] {
+			return "", fmt.Errorf("referencia circular en %s", key)
+		}
+		visiting[key] = true
+		defer delete(visiting, key)
+
+		m := envLineRe.FindStringSubmatch(lines[keyLine[key]])
+		v, err := expand(m[3])
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		resolved[key] = v
+		return v, nil
+	}
+
+	expand = func(s string) (string, error) {
+		var firstErr error
+		out := placeholderRe.ReplaceAllStringFunc(s, func(tok string) string {
+			if firstErr != nil {
+				return tok
+			}
+			m := placeholderRe.FindStringSubmatch(tok)
+			name, arg := m[1], strings.TrimSpace(m[2])
+			hasArg := strings.Contains(tok, ":")
+
+			if !hasArg && isEnvKey(name) {
+				if v, ok := values[name]; ok {
+					return v
+				}
+				if _, ok := keyLine[name]; ok {
+					v, err := resolveKey(name)
+					if err != nil {
+						firstErr = err
+						return tok
+					}
+					return v
+				}
+				firstErr = fmt.Errorf("placeholder %s hace referencia a una clave sin definir", tok)
+				return tok
+			}
+
+			v, err := GeneratePlaceholder(name, arg)
+			if err != nil {
+				firstErr = fmt.Errorf("placeholder %s: %w", tok, err)
+				return tok
+			}
+			return v
+		})
+		if firstErr != nil {
+			return "", firstErr
+		}
+		return out, nil
+	}
+
+	for i, ln := range lines {
+		if isCommentLine(ln) {
+			continue
+		}
+		if m := envLineRe.FindStringSubmatch(ln); m != nil {
+			v, err := resolveKey(m[2])
+			if err != nil {
+				return "", err
+			}
+			lines[i] = m[1] + v
+			continue
+		}
+		v, err := expand(ln)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = v
+	}
+
+	for i, ln := range lines {
+		if isCommentLine(ln) {
+			continue
+		}
+		if start := strings.Index(ln, "{{"); start >= 0 && strings.Contains(ln[start:], "}}") {
+			end := start + strings.Index(ln[start:], "}}") + 2
+			return "", fmt.Errorf("línea %d: placeholder sin resolver %s", i+1, ln[start:end])
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// ParseEnv lee pares KEY=VALUE ignorando comentarios y líneas vacías.
+// Las comillas simples o dobles alrededor del valor se eliminan.
+func ParseEnv(content string) map[string]string {
+	out := map[string]string{}
+	for _, ln := range strings.Split(content, "\n") {
+		if isCommentLine(ln) {
+			continue
+		}
+		m := envLineRe.FindStringSubmatch(strings.TrimRight(ln, "\r"))
+		if m == nil {
+			continue
+		}
+		v := strings.TrimSpace(m[3])
+		if len(v) >= 2 && (v[0] == '"' && v[len(v)-1] == '"' || v[0] == '\'' && v[len(v)-1] == '\'') {
+			v = v[1 : len(v)-1]
+		}
+		out[m[2]] = v
 	}
 	return out
 }
+
+// ParseEnvFile lee y parsea un archivo .env.
+func ParseEnvFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnv(string(b)), nil
+}
+
+func isCommentLine(ln string) bool {
+	return strings.HasPrefix(strings.TrimSpace(ln), "#")
+}
+
+// isEnvKey indica si el nombre parece una clave de entorno (MAYÚSCULAS) y no un generador.
+func isEnvKey(name string) bool {
+	return name == strings.ToUpper(name)
+}
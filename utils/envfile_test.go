@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReplacePlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		values  map[string]string
+		want    string         // salida exacta (si no hay match)
+		match   *regexp.Regexp // salida con valores generados
+		wantErr string
+	}{
+		{
+			name: "sin placeholders",
+			in:   "# comentario {{no_se_toca}}\nAPP_URL=http://localhost\n",
+			want: "# comentario {{no_se_toca}}\nAPP_URL=http://localhost\n",
+		},
+		{
+			name: "referencia a otra clave, definida después",
+			in:   "APP_URL=http://{{HOST}}:{{PORT}}\nHOST=wiki.lan\nPORT=6875",
+			want: "APP_URL=http://wiki.lan:6875\nHOST=wiki.lan\nPORT=6875",
+		},
+		{
+			name:   "values tiene prioridad sobre el archivo",
+			in:     "HOST=local\nURL=https://{{HOST}}",
+			values: map[string]string{"HOST": "externo.example.com"},
+			want:   "HOST=local\nURL=https://externo.example.com",
+		},
+		{
+			name: "referencias encadenadas",
+			in:   "A={{B}}/a\nB={{C}}/b\nC=c",
+			want: "A=c/b/a\nB=c/b\nC=c",
+		},
+		{
+			name:  "export y espacios",
+			in:    "export DB_PASS={{ password:12 }}\nDB_URL=mysql://u:{{DB_PASS}}@db",
+			match: regexp.MustCompile(`^export DB_PASS=([A-Za-z0-9]{12})\nDB_URL=mysql://u:([A-Za-z0-9]{12})@db$`),
+		},
+		{
+			name:  "generadores con y sin argumento",
+			in:    "K={{hex:10}}\nU={{uuid}}\nB={{base64:3}}",
+			match: regexp.MustCompile(`^K=[0-9a-f]{10}\nU=[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\nB=[A-Za-z0-9+/]{4}$`),
+		},
+		{name: "ciclo directo", in: "A={{A}}", wantErr: "referencia circular en A"},
+		{name: "ciclo indirecto", in: "A={{B}}\nB={{C}}\nC={{A}}", wantErr: "referencia circular"},
+		{name: "clave sin definir", in: "URL=http://{{HOST}}", wantErr: "clave sin definir"},
+		{name: "generador desconocido", in: "X={{magia}}", wantErr: `generador desconocido "magia"`},
+		{name: "argumento inválido", in: "X={{password:3}}", wantErr: "longitud inválida"},
+		{name: "clave duplicada", in: "A=1\nB={{A}}\nA=2", wantErr: "línea 3: A ya está definida en la línea 1"},
+		{name: "duplicada con export", in: "A=1\nexport A=2", wantErr: "A ya está definida"},
+		{name: "placeholder mal formado", in: "X={{ hex:1:{2} }}", wantErr: "placeholder sin resolver"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReplacePlaceholders(tt.in, tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, quiero %q (salida %q)", err, tt.wantErr, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.match != nil {
+				if !tt.match.MatchString(got) {
+					t.Fatalf("salida =\n%s", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("salida =\n%q\nquiero\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Una clave se genera una sola vez aunque varias líneas la referencien.
+func TestReplacePlaceholdersGeneratesOnce(t *testing.T) {
+	got, err := ReplacePlaceholders("SECRET={{password}}\nA={{SECRET}}\nB={{SECRET}}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := ParseEnv(got)
+	if len(env["SECRET"]) != 32 || env["A"] != env["SECRET"] || env["B"] != env["SECRET"] {
+		t.Fatalf("salida =\n%s", got)
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	got := ParseEnv("# c\nA=1\nexport B = \"dos\"\r\nC='tres'\nD=\"sin cerrar\n\nno es par")
+	want := map[string]string{"A": "1", "B": "dos", "C": "tres", "D": `"sin cerrar`}
+	if len(got) != len(want) {
+		t.Fatalf("ParseEnv = %q", got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, quiero %q", k, got[k], v)
+		}
+	}
+}
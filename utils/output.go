@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formatos de salida soportados por los comandos con --output.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// ValidateOutputFormat revisa el valor de --output.
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case OutputTable, OutputJSON, OutputYAML:
+		return nil
+	}
+	return fmt.Errorf("formato de salida inválido %q (usa table|json|yaml)", format)
+}
+
+// WriteStructured escribe v como JSON o YAML según el formato.
+func WriteStructured(w io.Writer, format string, v any) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case OutputYAML:
+		b, err := ToYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	return fmt.Errorf("formato no estructurado: %s", format)
+}
+
+// ToYAML serializa v a YAML usando sus tags JSON (pasa por encoding/json, así
+// que solo cubre lo que JSON puede representar: mapas, listas y escalares).
+func ToYAML(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	writeYAML(&b, generic, 0)
+	return []byte(b.String()), nil
+}
+
+func writeYAML(b *strings.Builder, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) == 0 {
+			b.WriteString(pad + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeYAMLEntry(b, pad+yamlScalar(k)+":", t[k], indent)
+		}
+	case []any:
+		if len(t) == 0 {
+			b.WriteString(pad + "[]\n")
+			return
+		}
+		for _, item := range t {
+			writeYAMLEntry(b, pad+"-", item, indent)
+		}
+	default:
+		b.WriteString(pad + yamlScalar(t) + "\n")
+	}
+}
+
+// writeYAMLEntry escribe "prefijo valor" en línea si es escalar o colección
+// vacía, o anidado con un nivel más de sangría si no.
+func writeYAMLEntry(b *strings.Builder, prefix string, v any, indent int) {
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) == 0 {
+			b.WriteString(prefix + " {}\n")
+			return
+		}
+		b.WriteString(prefix + "\n")
+		writeYAML(b, t, indent+1)
+	case []any:
+		if len(t) == 0 {
+			b.WriteString(prefix + " []\n")
+			return
+		}
+		b.WriteString(prefix + "\n")
+		writeYAML(b, t, indent+1)
+	default:
+		b.WriteString(prefix + " " + yamlScalar(t) + "\n")
+	}
+}
+
+func yamlScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case string:
+		if yamlNeedsQuotes(t) {
+			return strconv.Quote(t)
+		}
+		return t
+	}
+	return fmt.Sprint(v)
+}
+
+func yamlNeedsQuotes(s string) bool {
+	if s == "" {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` ") || strings.HasSuffix(s, " ") {
+		return true
+	}
+	return strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s, "\n\t")
+}
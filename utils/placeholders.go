@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PlaceholderGenerator produce el valor de un placeholder {{nombre:arg}}.
+// `arg` llega vacío cuando el placeholder no trae argumento.
+type PlaceholderGenerator func(arg string) (string, error)
+
+var placeholderGenerators = map[string]PlaceholderGenerator{}
+
+// RegisterPlaceholder agrega (o reemplaza) un generador de placeholders.
+func RegisterPlaceholder(name string, gen PlaceholderGenerator) {
+	placeholderGenerators[strings.ToLower(name)] = gen
+}
+
+// PlaceholderNames devuelve los generadores registrados, ordenados.
+func PlaceholderNames() []string {
+	names := make([]string, 0, len(placeholderGenerators))
+	for n := range placeholderGenerators {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GeneratePlaceholder ejecuta el generador `name` con su argumento.
+func GeneratePlaceholder(name, arg string) (string, error) {
+	gen, ok := placeholderGenerators[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("generador desconocido %q (disponibles: %s)", name, strings.Join(PlaceholderNames(), ", "))
+	}
+	return gen(arg)
+}
+
+// ValidatePlaceholderSpec revisa una especificación "nombre" o "nombre:arg"
+// sin generar el valor (útil para validar manifiestos).
+func ValidatePlaceholderSpec(spec string) error {
+	name, _, _ := strings.Cut(spec, ":")
+	if _, ok := placeholderGenerators[strings.ToLower(strings.TrimSpace(name))]; !ok {
+		return fmt.Errorf("generador desconocido %q", name)
+	}
+	return nil
+}
+
+// GeneratePlaceholderSpec ejecuta una especificación "nombre" o "nombre:arg".
+func GeneratePlaceholderSpec(spec string) (string, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	return GeneratePlaceholder(strings.TrimSpace(name), strings.TrimSpace(arg))
+}
+
+func init() {
+	RegisterPlaceholder("password", genPassword)
+	RegisterPlaceholder("hex", genHex)
+	RegisterPlaceholder("base64", genBase64)
+	RegisterPlaceholder("uuid", genUUID)
+	RegisterPlaceholder("laravel_key", func(string) (string, error) { return GenerateLaravelAppKey() })
+	RegisterPlaceholder("free_port", genFreePort)
+	RegisterPlaceholder("host_tz", func(string) (string, error) { return HostTimezone(), nil })
+	RegisterPlaceholder("puid", func(string) (string, error) { return hostID("SUDO_UID", os.Getuid()), nil })
+	RegisterPlaceholder("pgid", func(string) (string, error) { return hostID("SUDO_GID", os.Getgid()), nil })
+}
+
+// -----------------------------------------------------------------------------
+// Generadores
+// -----------------------------------------------------------------------------
+
+const passwordAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// GeneratePassword devuelve una contraseña alfanumérica aleatoria (sin símbolos
+// para no romper .env, URLs ni YAML).
+func GeneratePassword(n int) (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	out := make([]byte, n)
+	for i := range out {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("no se pudo generar contraseña: %w", err)
+		}
+		out[i] = passwordAlphabet[idx.Int64()]
+	}
+	return string(out), nil
+}
+
+func genPassword(arg string) (string, error) {
+	n, err := lengthArg(arg, 32, 8, 1024)
+	if err != nil {
+		return "", err
+	}
+	return GeneratePassword(n)
+}
+
+// genHex devuelve `arg` caracteres hexadecimales (64 por defecto).
+func genHex(arg string) (string, error) {
+	n, err := lengthArg(arg, 64, 2, 4096)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, (n+1)/2)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf)[:n], nil
+}
+
+// genBase64 codifica `arg` bytes aleatorios en base64 (32 por defecto), como `openssl rand -base64 N`.
+func genBase64(arg string) (string, error) {
+	n, err := lengthArg(arg, 32, 1, 4096)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+func genUUID(string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // versión 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variante RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// genFreePort usa el puerto sugerido en `arg` si está libre; si no, pide uno al kernel.
+func genFreePort(arg string) (string, error) {
+	if arg != "" {
+		p, err := strconv.Atoi(arg)
+		if err != nil || p < 1 || p > 65535 {
+			return "", fmt.Errorf("puerto sugerido inválido %q", arg)
+		}
+		if l, err := net.Listen("tcp", ":"+arg); err == nil {
+			l.Close()
+			return arg, nil
+		}
+	}
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return "", fmt.Errorf("no se encontró un puerto libre: %w", err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// HostTimezone devuelve la zona horaria IANA del host (TZ, /etc/timezone o /etc/localtime).
+func HostTimezone() string {
+	if tz := strings.TrimSpace(os.Getenv("TZ")); tz != "" {
+		return strings.TrimPrefix(tz, ":")
+	}
+	if b, err := os.ReadFile("/etc/timezone"); err == nil {
+		if tz := strings.TrimSpace(string(b)); tz != "" {
+			return tz
+		}
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, tz, ok := strings.Cut(target, "zoneinfo/"); ok && tz != "" {
+			return tz
+		}
+	}
+	return "UTC"
+}
+
+// hostID devuelve el uid/gid del usuario real (el que invocó sudo si aplica).
+func hostID(sudoEnv string, fallback int) string {
+	if v := os.Getenv(sudoEnv); v != "" {
+		return v
+	}
+	return strconv.Itoa(fallback)
+}
+
+func lengthArg(arg string, def, min, max int) (int, error) {
+	if arg == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("longitud inválida %q (entre %d y %d)", arg, min, max)
+	}
+	return n, nil
+}
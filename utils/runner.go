@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DryRunPrefix antecede cada acción que se omite en modo --dry-run.
+const DryRunPrefix = "🧪 [dry-run]"
+
+// Cmd describe un comando externo a ejecutar por un Runner.
+type Cmd struct {
+	Name string
+	Args []string
+	Dir  string
+	Env  []string // KEY=VALUE adicionales al entorno actual
+
+	Stdin  io.Reader // nil → os.Stdin en Run
+	Stdout io.Writer // nil → os.Stdout en Run
+	Stderr io.Writer // nil → os.Stderr en Run
+
+	// ReadOnly marca consultas sin efectos (inspect, ps, ip…): en dry-run se ejecutan igual.
+	ReadOnly bool
+}
+
+// Command arma un Cmd simple.
+func Command(name string, args ...string) Cmd {
+	return Cmd{Name: name, Args: args}
+}
+
+// ShellCmd arma `bash -eo pipefail -c script`.
+func ShellCmd(script string) Cmd {
+	return Command("bash", "-eo", "pipefail", "-c", script)
+}
+
+// String devuelve el comando con comillas de shell donde hagan falta.
+func (c Cmd) String() string {
+	parts := make([]string, 0, len(c.Args)+1)
+	parts = append(parts, shellQuote(c.Name))
+	for _, a := range c.Args {
+		parts = append(parts, shellQuote(a))
+	}
+	s := strings.Join(parts, " ")
+	if c.Dir != "" {
+		s = "(cd " + shellQuote(c.Dir) + " && " + s + ")"
+	}
+	return s
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n'\"$`\\|&;<>()*?[]{}!#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Runner ejecuta comandos y escrituras de archivos. Los helpers usan siempre
+// DefaultRunner(); SetDefaultRunner lo reemplaza para previsualizar (dry-run)
+// o grabar (tests con RecordingRunner) sus efectos.
+type Runner interface {
+	// Run ejecuta el comando mostrando su salida; stderr también se incluye en el error.
+	Run(c Cmd) error
+	// Output ejecuta el comando y devuelve stdout; stderr va en el error.
+	Output(c Cmd) ([]byte, error)
+
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	MkdirAll(path string, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(path string) error
+	RemoveAll(path string) error
+
+	// DryRun indica si los efectos se están omitiendo.
+	DryRun() bool
+}
+
+var (
+	defaultRunnerMu sync.RWMutex
+	defaultRunner   Runner = ExecRunner{}
+)
+
+// DefaultRunner devuelve el Runner global (ExecRunner salvo que se use --dry-run).
+func DefaultRunner() Runner {
+	defaultRunnerMu.RLock()
+	defer defaultRunnerMu.RUnlock()
+	return defaultRunner
+}
+
+// SetDefaultRunner reemplaza el Runner global (nil vuelve a ExecRunner).
+func SetDefaultRunner(r Runner) {
+	defaultRunnerMu.Lock()
+	defer defaultRunnerMu.Unlock()
+	if r == nil {
+		r = ExecRunner{}
+	}
+	defaultRunner = r
+}
+
+// -----------------------------------------------------------------------------
+// ExecRunner: ejecución real
+// -----------------------------------------------------------------------------
+
+// ExecRunner ejecuta de verdad con os/exec y el sistema de archivos.
+type ExecRunner struct{}
+
+func (ExecRunner) Run(c Cmd) error {
+	cmd := buildExec(c)
+	var stderr bytes.Buffer
+	cmd.Stdin = orReader(c.Stdin, os.Stdin)
+	cmd.Stdout = orWriter(c.Stdout, os.Stdout)
+	cmd.Stderr = io.MultiWriter(orWriter(c.Stderr, os.Stderr), &stderr)
+	return wrapCmdErr(c, cmd.Run(), stderr.String())
+}
+
+func (ExecRunner) Output(c Cmd) ([]byte, error) {
+	cmd := buildExec(c)
+	var stderr bytes.Buffer
+	cmd.Stdin = c.Stdin
+	if c.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(c.Stderr, &stderr)
+	} else {
+		cmd.Stderr = &stderr
+	}
+	out, err := cmd.Output()
+	return out, wrapCmdErr(c, err, stderr.String())
+}
+
+func (ExecRunner) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+
+func (ExecRunner) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (ExecRunner) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (ExecRunner) Remove(path string) error                     { return os.Remove(path) }
+func (ExecRunner) RemoveAll(path string) error                  { return os.RemoveAll(path) }
+func (ExecRunner) DryRun() bool                                 { return false }
+
+func buildExec(c Cmd) *exec.Cmd {
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	return cmd
+}
+
+// wrapCmdErr agrega el comando y las últimas líneas de stderr al error.
+func wrapCmdErr(c Cmd, err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if len(lines) > 15 {
+		lines = lines[len(lines)-15:]
+	}
+	if msg := strings.TrimSpace(strings.Join(lines, "\n")); msg != "" {
+		return fmt.Errorf("%s: %w\n%s", c.Name, err, msg)
+	}
+	return fmt.Errorf("%s: %w", c.Name, err)
+}
+
+func orWriter(w, def io.Writer) io.Writer {
+	if w != nil {
+		return w
+	}
+	return def
+}
+
+func orReader(r, def io.Reader) io.Reader {
+	if r != nil {
+		return r
+	}
+	return def
+}
+
+// -----------------------------------------------------------------------------
+// DryRunner: solo imprime
+// -----------------------------------------------------------------------------
+
+// DryRunner imprime cada comando y escritura sin ejecutarlos. Las consultas
+// marcadas como ReadOnly sí se ejecutan para que el plan sea realista.
+type DryRunner struct {
+	Out io.Writer
+}
+
+// NewDryRunner crea un DryRunner que escribe en out (os.Stdout si es nil).
+func NewDryRunner(out io.Writer) *DryRunner {
+	if out == nil {
+		out = os.Stdout
+	}
+	return &DryRunner{Out: out}
+}
+
+func (d *DryRunner) printf(format string, args ...any) {
+	fmt.Fprintf(d.Out, DryRunPrefix+" "+format+"\n", args...)
+}
+
+func (d *DryRunner) Run(c Cmd) error {
+	if c.ReadOnly {
+		return ExecRunner{}.Run(c)
+	}
+	d.printf("$ %s", c)
+	return nil
+}
+
+func (d *DryRunner) Output(c Cmd) ([]byte, error) {
+	if c.ReadOnly {
+		return ExecRunner{}.Output(c)
+	}
+	d.printf("$ %s", c)
+	return nil, nil
+}
+
+func (d *DryRunner) WriteFile(path string, data []byte, perm os.FileMode) error {
+	d.printf("escribir %s (%d bytes, %v)", path, len(data), perm)
+	return nil
+}
+
+func (d *DryRunner) MkdirAll(path string, perm os.FileMode) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	d.printf("crear directorio %s", path)
+	return nil
+}
+
+func (d *DryRunner) Rename(oldpath, newpath string) error {
+	d.printf("mover %s → %s", oldpath, newpath)
+	return nil
+}
+
+func (d *DryRunner) Remove(path string) error {
+	d.printf("eliminar %s", path)
+	return nil
+}
+
+func (d *DryRunner) RemoveAll(path string) error {
+	d.printf("eliminar %s (recursivo)", path)
+	return nil
+}
+
+func (d *DryRunner) DryRun() bool { return true }
+
+// -----------------------------------------------------------------------------
+// RecordingRunner: falso para pruebas
+// -----------------------------------------------------------------------------
+
+// FakeResponse es la respuesta programada para un comando en RecordingRunner.
+type FakeResponse struct {
+	Output []byte
+	Err    error
+}
+
+// RecordingRunner no ejecuta nada: registra cada acción y devuelve las
+// respuestas programadas con On. Los archivos escritos quedan en Files.
+type RecordingRunner struct {
+	mu        sync.Mutex
+	Calls     []string
+	Commands  []Cmd
+	Files     map[string][]byte
+	Responses map[string]FakeResponse
+}
+
+// NewRecordingRunner crea un RecordingRunner vacío.
+func NewRecordingRunner() *RecordingRunner {
+	return &RecordingRunner{Files: map[string][]byte{}, Responses: map[string]FakeResponse{}}
+}
+
+// On programa la respuesta para los comandos cuyo texto empiece con prefix
+// (gana el prefijo más largo).
+func (r *RecordingRunner) On(prefix, output string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Responses[prefix] = FakeResponse{Output: []byte(output), Err: err}
+}
+
+func (r *RecordingRunner) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Calls = append(r.Calls, call)
+}
+
+func (r *RecordingRunner) respond(c Cmd) FakeResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Commands = append(r.Commands, c)
+	s := c.String()
+	r.Calls = append(r.Calls, "$ "+s)
+
+	keys := make([]string, 0, len(r.Responses))
+	for k := range r.Responses {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, k := range keys {
+		if strings.HasPrefix(s, k) {
+			return r.Responses[k]
+		}
+	}
+	return FakeResponse{}
+}
+
+func (r *RecordingRunner) Run(c Cmd) error {
+	resp := r.respond(c)
+	if c.Stdout != nil && len(resp.Output) > 0 {
+		_, _ = c.Stdout.Write(resp.Output)
+	}
+	return resp.Err
+}
+
+func (r *RecordingRunner) Output(c Cmd) ([]byte, error) {
+	resp := r.respond(c)
+	return resp.Output, resp.Err
+}
+
+func (r *RecordingRunner) WriteFile(path string, data []byte, perm os.FileMode) error {
+	r.record(fmt.Sprintf("write %s", path))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files[path] = append([]byte(nil), data...)
+	return nil
+}
+
+func (r *RecordingRunner) MkdirAll(path string, perm os.FileMode) error {
+	r.record("mkdir " + path)
+	return nil
+}
+
+func (r *RecordingRunner) Rename(oldpath, newpath string) error {
+	r.record("rename " + oldpath + " " + newpath)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.Files[oldpath]; ok {
+		r.Files[newpath] = b
+		delete(r.Files, oldpath)
+	}
+	return nil
+}
+
+func (r *RecordingRunner) Remove(path string) error {
+	r.record("remove " + path)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.Files, path)
+	return nil
+}
+
+func (r *RecordingRunner) RemoveAll(path string) error {
+	r.record("remove-all " + path)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range r.Files {
+		if p == path || strings.HasPrefix(p, path+string(os.PathSeparator)) {
+			delete(r.Files, p)
+		}
+	}
+	return nil
+}
+
+func (r *RecordingRunner) DryRun() bool { return false }
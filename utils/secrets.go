@@ -3,7 +3,11 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 )
 
 func GenerateLaravelAppKey() (string, error) {
@@ -13,3 +17,47 @@ func GenerateLaravelAppKey() (string, error) {
 	}
 	return "base64:" + base64.StdEncoding.EncodeToString(buf), nil
 }
+
+// ErrSecretNotFound indica que el secreto no está en el almacén.
+var ErrSecretNotFound = errors.New("secreto no encontrado")
+
+var secretNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SecretsDir es el almacén de secretos de autohost (~/.autohost/secrets, 0700).
+func SecretsDir() string { return GetSubdir("secrets") }
+
+// SecretPath es el archivo de un secreto.
+func SecretPath(name string) string { return filepath.Join(SecretsDir(), name) }
+
+// WriteSecret guarda un secreto (0600) en el almacén.
+func WriteSecret(name string, value []byte) error {
+	if !secretNameRe.MatchString(name) {
+		return fmt.Errorf("nombre de secreto inválido: %q", name)
+	}
+	r := DefaultRunner()
+	if err := r.MkdirAll(SecretsDir(), 0o700); err != nil {
+		return err
+	}
+	return r.WriteFile(SecretPath(name), value, 0o600)
+}
+
+// ReadSecret lee un secreto del almacén (ErrSecretNotFound si no existe).
+func ReadSecret(name string) ([]byte, error) {
+	if !secretNameRe.MatchString(name) {
+		return nil, fmt.Errorf("nombre de secreto inválido: %q", name)
+	}
+	b, err := os.ReadFile(SecretPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", name, ErrSecretNotFound)
+	}
+	return b, err
+}
+
+// RandomToken devuelve n bytes aleatorios codificados en base64 URL.
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}